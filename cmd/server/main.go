@@ -9,6 +9,8 @@ import (
 	"os/signal"
 	"syscall"
 	"time"
+	// Embedded zone data so the billing scheduler timezone resolves on minimal images
+	_ "time/tzdata"
 
 	"github.com/gin-gonic/gin"
 
@@ -79,16 +81,88 @@ func main() {
 	masterMenuRepo := repository.NewMasterMenuRepository(db.DB)
 	roleMenuRepo := repository.NewRoleMenuRepository(db.DB)
 	dashboardRepo := repository.NewDashboardRepository(db.DB)
+	paymentWebhookRepo := repository.NewPaymentWebhookRepository(db.DB)
+	paymentReviewRepo := repository.NewPaymentReviewRepository(db.DB)
+	paymentRepo := repository.NewPaymentRepository(db.DB)
+	reconciliationRepo := repository.NewPaymentReconciliationRepository(db.DB)
+	jobRunRepo := repository.NewJobRunRepository(db.DB)
+	bulkBillingJobRepo := repository.NewBulkBillingJobRepository(db.DB)
+	penaltyRepo := repository.NewBillingPenaltyRepository(db.DB)
+	statusRepo := repository.NewStatusRepository(db.DB)
+	billingStatusRepo := repository.NewBillingStatusRepository(db.DB)
+	adjustmentRepo := repository.NewBillingAdjustmentRepository(db.DB)
+	installmentRepo := repository.NewBillingInstallmentRepository(db.DB)
+	creditRepo := repository.NewCreditLedgerRepository(db.DB)
 
 	// Initialize services
+	authService := service.NewAuthService(userRepo, cfg.JWT.Secret, appLogger)
 	menuService := service.NewMenuService(menuRepo)
-	mayarService := service.NewMayarService(appLogger)
-	paymentService := service.NewPaymentService(billingRepo, mayarService, appLogger)
+	gateways := []service.PaymentGateway{
+		service.NewMayarGateway(cfg.Mayar, appLogger),
+		service.NewDokuGateway(cfg.Doku, appLogger),
+	}
+	// The fake gateway accepts self-signed payments, so it only exists when selected
+	var fakeGateway *service.FakePaymentGateway
+	if cfg.Payment.Gateway == service.PaymentGatewayFake {
+		fakeGateway = service.NewFakePaymentGateway(cfg.Fake, appLogger)
+		gateways = append(gateways, fakeGateway)
+		appLogger.Warn("Using the offline fake payment gateway, do not use in production")
+	}
+	gatewayRegistry, err := service.NewPaymentGatewayRegistry(cfg.Payment.Gateway, gateways...)
+	if err != nil {
+		appLogger.WithField("error", err).Fatal("Failed to configure payment gateway")
+	}
+
+	adminFeePolicy, err := service.NewAdminFeePolicy(cfg.AdminFee)
+	if err != nil {
+		appLogger.WithField("error", err).Fatal("Invalid admin fee configuration")
+	}
+
+	duePolicy, err := service.NewBillingDuePolicy(cfg.Due)
+	if err != nil {
+		appLogger.WithField("error", err).Fatal("Invalid billing due date configuration")
+	}
+
+	statusRegistry, err := service.NewStatusRegistry(statusRepo, cfg.Status, appLogger)
+	if err != nil {
+		appLogger.WithField("error", err).Fatal("Failed to load billing statuses")
+	}
+
+	billingStateMachine := service.NewBillingStateMachine(billingStatusRepo, billingRepo, statusRegistry, appLogger)
 	userService := service.NewUserService(userRepo, appLogger)
-	billingService := service.NewBillingService(billingRepo, db.DB)
+	creditService := service.NewCreditService(creditRepo, appLogger)
+	billingService := service.NewBillingService(billingRepo, bulkBillingJobRepo, creditRepo, duePolicy, statusRegistry, billingStateMachine, db.DB, appLogger)
+	paymentService := service.NewPaymentService(billingRepo, paymentRepo, penaltyRepo, installmentRepo, billingService, creditService, statusRegistry, billingStateMachine, gatewayRegistry, adminFeePolicy, appLogger)
 	masterMenuService := service.NewMasterMenuService(masterMenuRepo, appLogger)
 	roleMenuService := service.NewRoleMenuService(roleMenuRepo, masterMenuRepo, appLogger)
-	dashboardService := service.NewDashboardService(dashboardRepo, appLogger)
+	dashboardService := service.NewDashboardService(dashboardRepo, statusRegistry, appLogger)
+	paymentWebhookService := service.NewPaymentWebhookService(paymentWebhookRepo, paymentReviewRepo, paymentRepo, billingRepo, billingService, creditService, billingStateMachine, statusRegistry, gatewayRegistry, appLogger)
+	paymentReviewService := service.NewPaymentReviewService(paymentReviewRepo, paymentRepo, billingRepo, billingService, billingStateMachine, statusRegistry, appLogger)
+	penaltyService := service.NewBillingPenaltyService(penaltyRepo, billingRepo, duePolicy, statusRegistry, appLogger)
+	adjustmentService := service.NewBillingAdjustmentService(adjustmentRepo, billingRepo, penaltyRepo, billingStateMachine, statusRegistry, appLogger)
+	installmentService := service.NewBillingInstallmentService(installmentRepo, billingRepo, statusRegistry, appLogger)
+	reconciliationService := service.NewPaymentReconciliationService(reconciliationRepo, paymentRepo, paymentWebhookService, gatewayRegistry, cfg.Reconciler, appLogger)
+
+	// Start background jobs, stopped on shutdown
+	jobsCtx, stopJobs := context.WithCancel(context.Background())
+	defer stopJobs()
+	if cfg.Reconciler.Enabled {
+		reconciliationService.Start(jobsCtx)
+	}
+	if cfg.Scheduler.Enabled {
+		billingScheduler, err := service.NewBillingScheduler(billingService, jobRunRepo, cfg.Scheduler, appLogger)
+		if err != nil {
+			appLogger.WithField("error", err).Fatal("Invalid billing scheduler configuration")
+		}
+		billingScheduler.Start(jobsCtx)
+	}
+	if cfg.Due.OverdueJobEnabled {
+		overdueJob, err := service.NewBillingOverdueJob(billingRepo, jobRunRepo, penaltyService, billingStateMachine, statusRegistry, duePolicy, cfg.Due, appLogger)
+		if err != nil {
+			appLogger.WithField("error", err).Fatal("Invalid overdue billing job configuration")
+		}
+		overdueJob.Start(jobsCtx)
+	}
 
 	// Initialize Gin router
 	router := gin.New()
@@ -97,11 +171,12 @@ func main() {
 	router.Use(middleware.CORS())
 	router.Use(middleware.LoggerMiddleware(appLogger))
 	router.Use(middleware.ErrorHandler())
+	router.Use(middleware.Auth(authService, appLogger, handler.PublicPaths...))
 	router.NoRoute(middleware.NoRouteHandler())
 	router.NoMethod(middleware.NoMethodHandler())
 
 	// Setup routes
-	handler.SetupRoutes(router, menuService, paymentService, userService, billingService, masterMenuService, roleMenuService, dashboardService, appLogger)
+	handler.SetupRoutes(router, menuService, paymentService, userService, billingService, masterMenuService, roleMenuService, dashboardService, paymentWebhookService, paymentReviewService, reconciliationService, penaltyService, statusRegistry, billingStateMachine, adjustmentService, installmentService, creditService, fakeGateway, cfg.RBAC, appLogger)
 
 	// Create HTTP server
 	server := &http.Server{
@@ -126,6 +201,7 @@ func main() {
 	<-quit
 
 	appLogger.Info("Shutting down server...")
+	stopJobs()
 
 	// Give outstanding requests a deadline for completion
 	ctx, cancel := context.WithTimeout(context.Background(), 30*time.Second)
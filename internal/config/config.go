@@ -10,13 +10,21 @@ import (
 
 // Config holds all configuration for our application
 type Config struct {
-	Server   ServerConfig
-	Database DatabaseConfig
-	Logger   LoggerConfig
-	Doku     DokuConfig
-	Mayar    MayarConfig
-	JWT      JWTConfig
-	CORS     CORSConfig
+	Server     ServerConfig
+	Database   DatabaseConfig
+	Logger     LoggerConfig
+	Doku       DokuConfig
+	Mayar      MayarConfig
+	Payment    PaymentConfig
+	AdminFee   AdminFeeConfig
+	Fake       FakeGatewayConfig
+	Reconciler ReconcilerConfig
+	Scheduler  BillingSchedulerConfig
+	Due        BillingDueConfig
+	Status     BillingStatusConfig
+	JWT        JWTConfig
+	CORS       CORSConfig
+	RBAC       RBACConfig
 }
 
 // ServerConfig holds server configuration
@@ -41,17 +49,92 @@ type LoggerConfig struct {
 	Format string
 }
 
-// DokuConfig holds DOKU payment configuration (deprecated, use Mayar instead)
+// DokuConfig holds DOKU Checkout payment configuration
 type DokuConfig struct {
 	ClientID  string
 	SecretKey string
 	BaseURL   string
+	// NotificationPath is the Request-Target DOKU signs notifications with
+	NotificationPath string
 }
 
 // MayarConfig holds Mayar payment configuration
 type MayarConfig struct {
 	AuthKey string
 	BaseURL string
+	// WebhookToken is the callback token from the Mayar dashboard used to verify webhooks
+	WebhookToken string
+	// WebhookToleranceSeconds bounds how old a webhook's delivery timestamp header may be (0 disables the check)
+	WebhookToleranceSeconds int
+}
+
+// PaymentConfig selects the gateway new invoices are created with (mayar, doku or fake)
+type PaymentConfig struct {
+	Gateway string
+}
+
+// AdminFeeConfig holds the admin fee policy added to payment links.
+// Mode is flat, percentage or tiered. Tiers is a comma-separated list of
+// "<minimum amount>:<fee>" pairs, e.g. "0:5000,500000:7500".
+// Waived lists are comma-separated master_kategori_transaksi IDs and up_roles types.
+type AdminFeeConfig struct {
+	Mode              string
+	Flat              int
+	Percentage        float64
+	Tiers             string
+	WaivedKategoriIDs string
+	WaivedRoleTypes   string
+}
+
+// FakeGatewayConfig holds configuration of the offline fake payment gateway
+type FakeGatewayConfig struct {
+	// BaseURL is where this service is reachable; pay pages link to it and webhooks are sent to it
+	BaseURL string
+	Secret  string
+}
+
+// ReconcilerConfig holds configuration of the background job that polls gateways for pending invoices
+type ReconcilerConfig struct {
+	Enabled bool
+	// IntervalMinutes is the time between runs
+	IntervalMinutes int
+	// MinAgeMinutes leaves fresh invoices to their webhooks
+	MinAgeMinutes int
+	// BatchSize bounds how many payments a run checks
+	BatchSize int
+}
+
+// BillingSchedulerConfig holds configuration of the in-process monthly billing scheduler
+type BillingSchedulerConfig struct {
+	Enabled bool
+	// Schedule is a "minute hour day-of-month * *" cron expression, e.g. "0 6 1 * *"
+	Schedule string
+	Timezone string
+	// CatchUpMonths bounds how many months missed during downtime are generated afterwards
+	CatchUpMonths int
+}
+
+// BillingDueConfig holds the due dates of billings and the daily job that marks unpaid billings past
+// their due date as overdue
+type BillingDueConfig struct {
+	// DefaultDueDay is the day of the billing month billings are due when their setting has no due_day
+	DefaultDueDay int
+	Timezone      string
+	// OverdueJobEnabled runs the overdue job in-process
+	OverdueJobEnabled bool
+	// OverdueJobHour is the hour of the day the overdue job runs
+	OverdueJobHour int
+}
+
+// BillingStatusConfig holds the master_general_statuses names of the billing states, which are
+// resolved to their IDs at startup
+type BillingStatusConfig struct {
+	Unpaid              string
+	Paid                string
+	Overdue             string
+	Cancelled           string
+	PendingVerification string
+	Refunded            string
 }
 
 // JWTConfig holds JWT configuration
@@ -59,6 +142,15 @@ type JWTConfig struct {
 	Secret string
 }
 
+// RBACConfig maps each protected route group to the master_menus.kode_menu
+// a caller's roles must be linked to (via role_menus) to access it
+type RBACConfig struct {
+	BillingMenuCode    string
+	DashboardMenuCode  string
+	MasterMenuMenuCode string
+	RoleMenuMenuCode   string
+}
+
 // CORSConfig holds CORS configuration
 type CORSConfig struct {
 	AllowedOrigins string
@@ -90,13 +182,57 @@ func Load() (*Config, error) {
 			Format: getEnv("LOG_FORMAT", "json"),
 		},
 		Doku: DokuConfig{
-			ClientID:  getEnv("DOKU_CLIENT_ID", "BRN-0241-1762176502792"),
-			SecretKey: getEnv("DOKU_SECRET_KEY", "SK-PaILsZudZTytTSTNCmUV"),
-			BaseURL:   getEnv("DOKU_BASE_URL", "https://api-sandbox.doku.com"),
+			ClientID:         getEnv("DOKU_CLIENT_ID", "BRN-0241-1762176502792"),
+			SecretKey:        getEnv("DOKU_SECRET_KEY", "SK-PaILsZudZTytTSTNCmUV"),
+			BaseURL:          getEnv("DOKU_BASE_URL", "https://api-sandbox.doku.com"),
+			NotificationPath: getEnv("DOKU_NOTIFICATION_PATH", "/api/v1/payments/webhook/doku"),
 		},
 		Mayar: MayarConfig{
-			AuthKey: getEnv("MAYAR_AUTH_KEY", "your-mayar-auth-key"),
-			BaseURL: getEnv("MAYAR_BASE_URL", "https://api.mayar.id/hl/v1"),
+			AuthKey:                 getEnv("MAYAR_AUTH_KEY", "your-mayar-auth-key"),
+			BaseURL:                 getEnv("MAYAR_BASE_URL", "https://api.mayar.id/hl/v1"),
+			WebhookToken:            getEnv("MAYAR_WEBHOOK_TOKEN", ""),
+			WebhookToleranceSeconds: getEnvAsInt("MAYAR_WEBHOOK_TOLERANCE_SECONDS", 600),
+		},
+		Payment: PaymentConfig{
+			Gateway: getEnv("PAYMENT_GATEWAY", "mayar"),
+		},
+		AdminFee: AdminFeeConfig{
+			Mode:              getEnv("ADMIN_FEE_MODE", "flat"),
+			Flat:              getEnvAsInt("ADMIN_FEE_FLAT", 5000),
+			Percentage:        getEnvAsFloat("ADMIN_FEE_PERCENTAGE", 0),
+			Tiers:             getEnv("ADMIN_FEE_TIERS", ""),
+			WaivedKategoriIDs: getEnv("ADMIN_FEE_WAIVED_KATEGORI_IDS", ""),
+			WaivedRoleTypes:   getEnv("ADMIN_FEE_WAIVED_ROLE_TYPES", ""),
+		},
+		Fake: FakeGatewayConfig{
+			BaseURL: getEnv("FAKE_GATEWAY_BASE_URL", "http://localhost:"+getEnv("PORT", "8080")),
+			Secret:  getEnv("FAKE_GATEWAY_SECRET", "fake-gateway-secret"),
+		},
+		Reconciler: ReconcilerConfig{
+			Enabled:         getEnvAsBool("PAYMENT_RECONCILER_ENABLED", true),
+			IntervalMinutes: getEnvAsInt("PAYMENT_RECONCILER_INTERVAL_MINUTES", 15),
+			MinAgeMinutes:   getEnvAsInt("PAYMENT_RECONCILER_MIN_AGE_MINUTES", 10),
+			BatchSize:       getEnvAsInt("PAYMENT_RECONCILER_BATCH_SIZE", 100),
+		},
+		Scheduler: BillingSchedulerConfig{
+			Enabled:       getEnvAsBool("BILLING_SCHEDULER_ENABLED", false),
+			Schedule:      getEnv("BILLING_SCHEDULE", "0 6 1 * *"),
+			Timezone:      getEnv("BILLING_SCHEDULER_TIMEZONE", "Asia/Jakarta"),
+			CatchUpMonths: getEnvAsInt("BILLING_SCHEDULER_CATCH_UP_MONTHS", 3),
+		},
+		Due: BillingDueConfig{
+			DefaultDueDay:     getEnvAsInt("BILLING_DEFAULT_DUE_DAY", 10),
+			Timezone:          getEnv("BILLING_DUE_TIMEZONE", getEnv("BILLING_SCHEDULER_TIMEZONE", "Asia/Jakarta")),
+			OverdueJobEnabled: getEnvAsBool("BILLING_OVERDUE_JOB_ENABLED", true),
+			OverdueJobHour:    getEnvAsInt("BILLING_OVERDUE_JOB_HOUR", 1),
+		},
+		Status: BillingStatusConfig{
+			Unpaid:              getEnv("BILLING_STATUS_UNPAID", "Belum Dibayar"),
+			Paid:                getEnv("BILLING_STATUS_PAID", "Sudah Dibayar"),
+			Overdue:             getEnv("BILLING_STATUS_OVERDUE", "Terlambat"),
+			Cancelled:           getEnv("BILLING_STATUS_CANCELLED", "Dibatalkan"),
+			PendingVerification: getEnv("BILLING_STATUS_PENDING_VERIFICATION", "Menunggu Verifikasi"),
+			Refunded:            getEnv("BILLING_STATUS_REFUNDED", "Dikembalikan"),
 		},
 		JWT: JWTConfig{
 			Secret: getEnv("JWT_SECRET", "your-secret-key"),
@@ -104,6 +240,12 @@ func Load() (*Config, error) {
 		CORS: CORSConfig{
 			AllowedOrigins: getEnv("CORS_ALLOWED_ORIGINS", "http://localhost:3000,http://localhost:3001,http://127.0.0.1:3000,http://127.0.0.1:3001"),
 		},
+		RBAC: RBACConfig{
+			BillingMenuCode:    getEnv("RBAC_BILLING_MENU_CODE", "billing"),
+			DashboardMenuCode:  getEnv("RBAC_DASHBOARD_MENU_CODE", "dashboard"),
+			MasterMenuMenuCode: getEnv("RBAC_MASTER_MENU_MENU_CODE", "master-menu"),
+			RoleMenuMenuCode:   getEnv("RBAC_ROLE_MENU_MENU_CODE", "role-menu"),
+		},
 	}
 
 	return config, nil
@@ -134,3 +276,23 @@ func getEnvAsInt(key string, fallback int) int {
 	}
 	return fallback
 }
+
+// getEnvAsFloat gets an environment variable as float with a fallback value
+func getEnvAsFloat(key string, fallback float64) float64 {
+	if value := os.Getenv(key); value != "" {
+		if floatVal, err := strconv.ParseFloat(value, 64); err == nil {
+			return floatVal
+		}
+	}
+	return fallback
+}
+
+// getEnvAsBool gets an environment variable as boolean with a fallback value
+func getEnvAsBool(key string, fallback bool) bool {
+	if value := os.Getenv(key); value != "" {
+		if boolVal, err := strconv.ParseBool(value); err == nil {
+			return boolVal
+		}
+	}
+	return fallback
+}
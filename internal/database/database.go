@@ -42,10 +42,72 @@ func NewDatabase(cfg *config.DatabaseConfig) (*Database, error) {
 
 // AutoMigrate runs database migrations
 func (d *Database) AutoMigrate() error {
-	return d.DB.AutoMigrate(
+	err := d.DB.AutoMigrate(
 		&models.MasterMenu{},
+		&models.PaymentWebhookEvent{},
+		&models.PaymentReview{},
+		&models.Payment{},
+		&models.PaymentBillingLink{},
+		&models.PaymentApplication{},
+		&models.PaymentReconciliationRun{},
+		&models.PaymentReconciliationChange{},
+		&models.JobRun{},
+		&models.BulkBillingJob{},
+		&models.BulkBillingJobFailure{},
+		&models.BillingPenaltyRule{},
+		&models.BillingPenalty{},
+		&models.BillingStatusHistory{},
+		&models.BillingAdjustment{},
+		&models.BillingInstallment{},
+		&models.CreditLedgerEntry{},
 		// Add more models here as needed
 	)
+	if err != nil {
+		return err
+	}
+
+	return d.migrateStrapiTables()
+}
+
+// migrateStrapiTables adds the columns this service needs to tables owned by Strapi,
+// which AutoMigrate must not take over
+func (d *Database) migrateStrapiTables() error {
+	migrator := d.DB.Migrator()
+
+	if !migrator.HasColumn(&models.Billing{}, "SettingBillingID") {
+		if err := migrator.AddColumn(&models.Billing{}, "SettingBillingID"); err != nil {
+			return fmt.Errorf("failed to add billings.setting_billing_id: %w", err)
+		}
+	}
+	// Duplicate detection looks billings up by setting and period
+	if err := d.DB.Exec("CREATE INDEX IF NOT EXISTS idx_billings_setting_period ON billings (setting_billing_id, tahun, bulan)").Error; err != nil {
+		return fmt.Errorf("failed to create billings setting period index: %w", err)
+	}
+
+	if !migrator.HasColumn(&models.Billing{}, "DueDate") {
+		if err := migrator.AddColumn(&models.Billing{}, "DueDate"); err != nil {
+			return fmt.Errorf("failed to add billings.due_date: %w", err)
+		}
+	}
+	// The overdue job looks unpaid billings up by due date
+	if err := d.DB.Exec("CREATE INDEX IF NOT EXISTS idx_billings_due_date ON billings (due_date)").Error; err != nil {
+		return fmt.Errorf("failed to create billings due date index: %w", err)
+	}
+
+	// Billings without an outstanding amount owe their whole nominal until paid, so existing rows need no backfill
+	if !migrator.HasColumn(&models.Billing{}, "OutstandingAmount") {
+		if err := migrator.AddColumn(&models.Billing{}, "OutstandingAmount"); err != nil {
+			return fmt.Errorf("failed to add billings.outstanding_amount: %w", err)
+		}
+	}
+
+	if !migrator.HasColumn(&models.SettingBilling{}, "DueDay") {
+		if err := migrator.AddColumn(&models.SettingBilling{}, "DueDay"); err != nil {
+			return fmt.Errorf("failed to add setting_billings.due_day: %w", err)
+		}
+	}
+
+	return nil
 }
 
 // Close closes the database connection
@@ -0,0 +1,160 @@
+package handler
+
+import (
+	"errors"
+
+	"ipl-be-svc/internal/middleware"
+	"ipl-be-svc/internal/service"
+	"ipl-be-svc/pkg/logger"
+	"ipl-be-svc/pkg/utils"
+
+	"github.com/gin-gonic/gin"
+	"gorm.io/gorm"
+)
+
+// BillingAdjustmentHandler handles billing cancellation and adjustment HTTP requests
+type BillingAdjustmentHandler struct {
+	adjustmentService service.BillingAdjustmentService
+	logger            *logger.Logger
+}
+
+// NewBillingAdjustmentHandler creates a new BillingAdjustmentHandler instance
+func NewBillingAdjustmentHandler(adjustmentService service.BillingAdjustmentService, logger *logger.Logger) *BillingAdjustmentHandler {
+	return &BillingAdjustmentHandler{
+		adjustmentService: adjustmentService,
+		logger:            logger,
+	}
+}
+
+// CancelBilling handles POST /api/v1/billings/:id/cancel
+// @Summary Cancel billing
+// @Description Cancel a billing that has not been paid, recording the admin and reason in its timeline. The open late fee charged on it is cancelled too. Cancelled billings are left out of the statistics and cannot be paid.
+// @Tags billings
+// @Accept json
+// @Produce json
+// @Param id path int true "Billing ID"
+// @Param request body service.CancelBillingRequest true "Cancellation"
+// @Success 200 {object} utils.APIResponse{data=service.BillingTimeline} "Billing cancelled"
+// @Failure 400 {object} utils.APIResponse "Invalid request"
+// @Failure 404 {object} utils.APIResponse "Billing not found"
+// @Failure 409 {object} utils.APIResponse "Billing cannot be cancelled"
+// @Failure 500 {object} utils.APIResponse "Internal server error"
+// @Router /api/v1/billings/{id}/cancel [post]
+func (h *BillingAdjustmentHandler) CancelBilling(c *gin.Context) {
+	billingID, err := utils.GetIDParam(c)
+	if err != nil {
+		utils.BadRequestResponse(c, "Invalid billing ID", err)
+		return
+	}
+
+	var req service.CancelBillingRequest
+	if err := c.ShouldBindJSON(&req); err != nil {
+		utils.BadRequestResponse(c, "Invalid request data", err)
+		return
+	}
+
+	var actorID uint
+	if user, ok := middleware.GetAuthUser(c); ok {
+		actorID = user.ID
+	}
+
+	timeline, err := h.adjustmentService.CancelBilling(billingID, &req, actorID)
+	if err != nil {
+		h.logger.WithError(err).WithField("billing_id", billingID).Error("Failed to cancel billing")
+		switch {
+		case errors.Is(err, gorm.ErrRecordNotFound):
+			utils.NotFoundResponse(c, "Billing not found")
+		case errors.Is(err, service.ErrInvalidTransition):
+			utils.ConflictResponse(c, "Billing cannot be cancelled", err)
+		default:
+			utils.InternalServerErrorResponse(c, "Failed to cancel billing", err)
+		}
+		return
+	}
+
+	utils.SuccessResponse(c, "Billing cancelled", timeline)
+}
+
+// AdjustBilling handles POST /api/v1/billings/:id/adjustments
+// @Summary Adjust billing
+// @Description Correct the nominal of an unpaid or overdue billing. The adjustment keeps the original amount with the admin and reason. What was already paid is kept, so the nominal must stay above it, and an installment plan is removed and must be defined again.
+// @Tags billings
+// @Accept json
+// @Produce json
+// @Param id path int true "Billing ID"
+// @Param request body service.AdjustBillingRequest true "Adjustment"
+// @Success 201 {object} utils.APIResponse{data=models.BillingAdjustment} "Billing adjusted"
+// @Failure 400 {object} utils.APIResponse "Invalid request or nominal unchanged"
+// @Failure 404 {object} utils.APIResponse "Billing not found"
+// @Failure 409 {object} utils.APIResponse "Billing cannot be adjusted or nominal below the amount paid"
+// @Failure 500 {object} utils.APIResponse "Internal server error"
+// @Router /api/v1/billings/{id}/adjustments [post]
+func (h *BillingAdjustmentHandler) AdjustBilling(c *gin.Context) {
+	billingID, err := utils.GetIDParam(c)
+	if err != nil {
+		utils.BadRequestResponse(c, "Invalid billing ID", err)
+		return
+	}
+
+	var req service.AdjustBillingRequest
+	if err := c.ShouldBindJSON(&req); err != nil {
+		utils.BadRequestResponse(c, "Invalid request data", err)
+		return
+	}
+
+	var actorID uint
+	if user, ok := middleware.GetAuthUser(c); ok {
+		actorID = user.ID
+	}
+
+	adjustment, err := h.adjustmentService.AdjustBilling(billingID, &req, actorID)
+	if err != nil {
+		h.logger.WithError(err).WithField("billing_id", billingID).Error("Failed to adjust billing")
+		switch {
+		case errors.Is(err, gorm.ErrRecordNotFound):
+			utils.NotFoundResponse(c, "Billing not found")
+		case errors.Is(err, service.ErrNominalUnchanged):
+			utils.BadRequestResponse(c, "Nominal is unchanged", err)
+		case errors.Is(err, service.ErrBillingNotAdjustable), errors.Is(err, service.ErrNominalBelowPaid):
+			utils.ConflictResponse(c, "Billing cannot be adjusted", err)
+		default:
+			utils.InternalServerErrorResponse(c, "Failed to adjust billing", err)
+		}
+		return
+	}
+
+	utils.CreatedResponse(c, "Billing adjusted", adjustment)
+}
+
+// ListBillingAdjustments handles GET /api/v1/billings/:id/adjustments
+// @Summary List billing adjustments
+// @Description List the nominal corrections of a billing, oldest first
+// @Tags billings
+// @Accept json
+// @Produce json
+// @Param id path int true "Billing ID"
+// @Success 200 {object} utils.APIResponse{data=[]models.BillingAdjustment} "Billing adjustments retrieved successfully"
+// @Failure 400 {object} utils.APIResponse "Invalid billing ID"
+// @Failure 404 {object} utils.APIResponse "Billing not found"
+// @Failure 500 {object} utils.APIResponse "Internal server error"
+// @Router /api/v1/billings/{id}/adjustments [get]
+func (h *BillingAdjustmentHandler) ListBillingAdjustments(c *gin.Context) {
+	billingID, err := utils.GetIDParam(c)
+	if err != nil {
+		utils.BadRequestResponse(c, "Invalid billing ID", err)
+		return
+	}
+
+	adjustments, err := h.adjustmentService.GetAdjustments(billingID)
+	if err != nil {
+		h.logger.WithError(err).WithField("billing_id", billingID).Error("Failed to get billing adjustments")
+		if errors.Is(err, gorm.ErrRecordNotFound) {
+			utils.NotFoundResponse(c, "Billing not found")
+			return
+		}
+		utils.InternalServerErrorResponse(c, "Failed to get billing adjustments", err)
+		return
+	}
+
+	utils.SuccessResponse(c, "Billing adjustments retrieved successfully", adjustments)
+}
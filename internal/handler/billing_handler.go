@@ -1,16 +1,23 @@
 package handler
 
 import (
+	"bytes"
+	"encoding/csv"
+	"errors"
 	"fmt"
 	"io"
+	"ipl-be-svc/internal/middleware"
+	"ipl-be-svc/internal/models"
 	"ipl-be-svc/internal/service"
 	"ipl-be-svc/pkg/logger"
 	"ipl-be-svc/pkg/utils"
+	"net/http"
 	"os"
 	"strconv"
 	"strings"
 
 	"github.com/gin-gonic/gin"
+	"gorm.io/gorm"
 )
 
 // BulkBillingRequest represents the request for bulk billing creation
@@ -18,6 +25,8 @@ type BulkBillingRequest struct {
 	UserIDs []uint `json:"user_ids,omitempty"`                        // Empty means all penghuni users
 	Month   int    `json:"month" binding:"required,min=1,max=12"`     // Month 1-12
 	Year    int    `json:"year" binding:"required,min=2020,max=2100"` // Reasonable year range
+	DryRun  bool   `json:"dry_run,omitempty"`                         // Return the billing plan without creating billings
+	Async   bool   `json:"async,omitempty"`                           // Generate in a background job and return its ID
 }
 
 // BulkBillingCustomRequest represents the request for bulk billing creation
@@ -26,30 +35,36 @@ type BulkBillingCustomRequest struct {
 	BillingSettingsId int    `json:"billing_settings_id" binding:"required"`    // Billing settings ID
 	Month             int    `json:"month" binding:"required,min=1,max=12"`     // Month 1-12
 	Year              int    `json:"year" binding:"required,min=2020,max=2100"` // Reasonable year range
+	DryRun            bool   `json:"dry_run,omitempty"`                         // Return the billing plan without creating billings
+	Async             bool   `json:"async,omitempty"`                           // Generate in a background job and return its ID
 }
 
 // BulkBillingHandler handles bulk billing-related HTTP requests
 type BulkBillingHandler struct {
 	billingService service.BillingService
+	webhookService service.PaymentWebhookService
 	logger         *logger.Logger
 }
 
 // NewBulkBillingHandler creates a new BulkBillingHandler instance
-func NewBulkBillingHandler(billingService service.BillingService, logger *logger.Logger) *BulkBillingHandler {
+func NewBulkBillingHandler(billingService service.BillingService, webhookService service.PaymentWebhookService, logger *logger.Logger) *BulkBillingHandler {
 	return &BulkBillingHandler{
 		billingService: billingService,
+		webhookService: webhookService,
 		logger:         logger,
 	}
 }
 
 // CreateBulkMonthlyBillings creates monthly billings for specified users or all penghuni users
 // @Summary Create bulk monthly billings
-// @Description Create monthly billings for specified user IDs or all penghuni users if user_ids is empty. Requires auth-token cookie.
+// @Description Create monthly billings for specified user IDs or all penghuni users if user_ids is empty. Users that are not found, blocked, have no profile or already have a billing from the same setting for the month and year are skipped and listed in `skipped`. Residents' credit balances are applied to their new billings in order, which are created as paid when fully covered; `credit_applied` gives the amounts taken from them. `results` gives each resident's outcome: created with the billing IDs, skipped with a reason, or failed with an error. With `dry_run` the billing plan is returned instead, as JSON or as CSV with `format=csv`. With `async` the billings are generated in a background job and 202 is returned with the job, see GET /billings/jobs/{id}. Requires auth-token cookie.
 // @Tags billings
 // @Accept json
-// @Produce json
+// @Produce json,text/csv
 // @Param request body BulkBillingRequest true "Bulk billing request with month and year"
-// @Success 200 {object} utils.APIResponse{data=service.BulkBillingResponse} "Bulk billing creation result"
+// @Param format query string false "Dry run output format (json or csv)" default(json)
+// @Success 200 {object} utils.APIResponse{data=service.BulkBillingResponse} "Bulk billing creation result, or service.BillingPlan for dry runs"
+// @Success 202 {object} utils.APIResponse{data=models.BulkBillingJob} "Bulk billing job submitted"
 // @Failure 400 {object} utils.APIResponse "Invalid request"
 // @Failure 401 {object} utils.APIResponse "Unauthorized"
 // @Failure 500 {object} utils.APIResponse "Internal server error"
@@ -62,6 +77,27 @@ func (h *BulkBillingHandler) CreateBulkMonthlyBillings(c *gin.Context) {
 		return
 	}
 
+	if req.DryRun {
+		plan, err := h.billingService.PreviewBulkMonthlyBillings(req.UserIDs, req.Month, req.Year)
+		if err != nil {
+			h.logger.WithError(err).Error("Failed to preview bulk billings")
+			utils.InternalServerErrorResponse(c, "Failed to preview billings", err)
+			return
+		}
+		h.respondBillingPlan(c, plan)
+		return
+	}
+
+	if req.Async {
+		h.submitBulkBillingJob(c, &service.BulkBillingJobRequest{
+			Kind:    models.BulkBillingJobKindMonthly,
+			UserIDs: req.UserIDs,
+			Month:   req.Month,
+			Year:    req.Year,
+		})
+		return
+	}
+
 	var response *service.BulkBillingResponse
 	var serviceErr error
 
@@ -84,6 +120,7 @@ func (h *BulkBillingHandler) CreateBulkMonthlyBillings(c *gin.Context) {
 		"total_billings": response.TotalBillings,
 		"success_count":  response.SuccessCount,
 		"failed_count":   response.FailedCount,
+		"skipped_count":  response.SkippedCount,
 	}).Info("Bulk billings created successfully")
 
 	utils.SuccessResponse(c, "Bulk billings created successfully", response)
@@ -91,12 +128,14 @@ func (h *BulkBillingHandler) CreateBulkMonthlyBillings(c *gin.Context) {
 
 // CreateBulkCustomBillings creates custom billings for specified users or all penghuni users
 // @Summary Create bulk custom billings
-// @Description Create custom billings for specified user IDs or all penghuni users if user_ids is empty. Requires auth-token cookie.
+// @Description Create custom billings for specified user IDs or all penghuni users if user_ids is empty. Users that are not found, blocked, have no profile or already have a billing from the same setting for the month and year are skipped and listed in `skipped`. `results` gives each resident's outcome: created with the billing IDs, skipped with a reason, or failed with an error. With `dry_run` the billing plan is returned instead, as JSON or as CSV with `format=csv`. With `async` the billings are generated in a background job and 202 is returned with the job, see GET /billings/jobs/{id}. Requires auth-token cookie.
 // @Tags billings
 // @Accept json
-// @Produce json
+// @Produce json,text/csv
 // @Param request body BulkBillingCustomRequest true "Bulk billing request with month and year"
-// @Success 200 {object} utils.APIResponse{data=service.BulkBillingResponse} "Bulk billing creation result"
+// @Param format query string false "Dry run output format (json or csv)" default(json)
+// @Success 200 {object} utils.APIResponse{data=service.BulkBillingResponse} "Bulk billing creation result, or service.BillingPlan for dry runs"
+// @Success 202 {object} utils.APIResponse{data=models.BulkBillingJob} "Bulk billing job submitted"
 // @Failure 400 {object} utils.APIResponse "Invalid request"
 // @Failure 401 {object} utils.APIResponse "Unauthorized"
 // @Failure 500 {object} utils.APIResponse "Internal server error"
@@ -109,6 +148,28 @@ func (h *BulkBillingHandler) CreateBulkCustomBillings(c *gin.Context) {
 		return
 	}
 
+	if req.DryRun {
+		plan, err := h.billingService.PreviewBulkCustomBillings(req.UserIDs, req.BillingSettingsId, req.Month, req.Year)
+		if err != nil {
+			h.logger.WithError(err).Error("Failed to preview bulk custom billings")
+			utils.InternalServerErrorResponse(c, "Failed to preview billings", err)
+			return
+		}
+		h.respondBillingPlan(c, plan)
+		return
+	}
+
+	if req.Async {
+		h.submitBulkBillingJob(c, &service.BulkBillingJobRequest{
+			Kind:              models.BulkBillingJobKindCustom,
+			UserIDs:           req.UserIDs,
+			BillingSettingsId: req.BillingSettingsId,
+			Month:             req.Month,
+			Year:              req.Year,
+		})
+		return
+	}
+
 	var response *service.BulkBillingResponse
 	var serviceErr error
 
@@ -131,11 +192,120 @@ func (h *BulkBillingHandler) CreateBulkCustomBillings(c *gin.Context) {
 		"total_billings": response.TotalBillings,
 		"success_count":  response.SuccessCount,
 		"failed_count":   response.FailedCount,
+		"skipped_count":  response.SkippedCount,
 	}).Info("Bulk custom billings created successfully")
 
 	utils.SuccessResponse(c, "Bulk custom billings created successfully", response)
 }
 
+// submitBulkBillingJob starts a background bulk billing job and responds with it
+func (h *BulkBillingHandler) submitBulkBillingJob(c *gin.Context, req *service.BulkBillingJobRequest) {
+	var actorID uint
+	if user, ok := middleware.GetAuthUser(c); ok {
+		actorID = user.ID
+	}
+
+	job, err := h.billingService.SubmitBulkBillingJob(req, actorID)
+	if err != nil {
+		h.logger.WithError(err).Error("Failed to submit bulk billing job")
+		if errors.Is(err, gorm.ErrRecordNotFound) {
+			utils.NotFoundResponse(c, "Billing setting not found")
+			return
+		}
+		utils.InternalServerErrorResponse(c, "Failed to submit bulk billing job", err)
+		return
+	}
+
+	utils.AcceptedResponse(c, "Bulk billing job submitted", job)
+}
+
+// GetBulkBillingJob reports the progress of a background bulk billing job
+// @Summary Get bulk billing job
+// @Description Get the status, progress (processed_users of total_users), final counts and per-resident failures of a bulk billing job submitted with `async`. Jobs that stop saving progress, e.g. because of a restart, are reported as failed.
+// @Tags billings
+// @Accept json
+// @Produce json
+// @Param id path int true "Job ID"
+// @Success 200 {object} utils.APIResponse{data=models.BulkBillingJob} "Bulk billing job retrieved successfully"
+// @Failure 400 {object} utils.APIResponse "Invalid job ID"
+// @Failure 404 {object} utils.APIResponse "Bulk billing job not found"
+// @Failure 500 {object} utils.APIResponse "Internal server error"
+// @Router /api/v1/billings/jobs/{id} [get]
+func (h *BulkBillingHandler) GetBulkBillingJob(c *gin.Context) {
+	id, err := utils.GetIDParam(c)
+	if err != nil {
+		utils.BadRequestResponse(c, "Invalid job ID", err)
+		return
+	}
+
+	job, err := h.billingService.GetBulkBillingJob(id)
+	if err != nil {
+		h.logger.WithError(err).WithField("job_id", id).Error("Failed to get bulk billing job")
+		if errors.Is(err, gorm.ErrRecordNotFound) {
+			utils.NotFoundResponse(c, "Bulk billing job not found")
+			return
+		}
+		utils.InternalServerErrorResponse(c, "Failed to get bulk billing job", err)
+		return
+	}
+
+	utils.SuccessResponse(c, "Bulk billing job retrieved successfully", job)
+}
+
+// respondBillingPlan writes a dry run billing plan as JSON, or as CSV when format=csv
+func (h *BulkBillingHandler) respondBillingPlan(c *gin.Context, plan *service.BillingPlan) {
+	h.logger.WithFields(map[string]interface{}{
+		"total_users":    plan.TotalUsers,
+		"total_billings": plan.TotalBillings,
+		"total_nominal":  plan.TotalNominal,
+		"skipped_count":  len(plan.Skipped),
+	}).Info("Bulk billing plan previewed")
+
+	if !strings.EqualFold(c.Query("format"), "csv") {
+		utils.SuccessResponse(c, "Bulk billing plan previewed successfully", plan)
+		return
+	}
+
+	var buf bytes.Buffer
+	writer := csv.NewWriter(&buf)
+	writer.Write([]string{"action", "user_id", "username", "nama_penghuni", "setting_billing_id", "nama_billing", "nominal", "credit_applied", "due_date", "resident_total", "reason"})
+	for _, resident := range plan.Residents {
+		for _, item := range resident.Items {
+			writer.Write([]string{
+				"create",
+				strconv.FormatUint(uint64(resident.UserID), 10),
+				resident.Username,
+				resident.NamaPenghuni,
+				strconv.FormatUint(uint64(item.SettingBillingID), 10),
+				item.NamaBilling,
+				strconv.FormatInt(item.Nominal, 10),
+				strconv.FormatInt(item.CreditApplied, 10),
+				item.DueDate.Format("2006-01-02"),
+				strconv.FormatInt(resident.TotalNominal, 10),
+				"",
+			})
+		}
+	}
+	for _, skip := range plan.Skipped {
+		settingID := ""
+		if skip.SettingBillingID != 0 {
+			settingID = strconv.FormatUint(uint64(skip.SettingBillingID), 10)
+		}
+		writer.Write([]string{"skip", strconv.FormatUint(uint64(skip.UserID), 10), skip.Username, "", settingID, "", "", "", "", "", skip.Reason})
+	}
+	writer.Write([]string{"total", "", "", "", "", "", strconv.FormatInt(plan.TotalNominal, 10), strconv.FormatInt(plan.TotalCredit, 10), "", "", ""})
+	writer.Flush()
+	if err := writer.Error(); err != nil {
+		h.logger.WithError(err).Error("Failed to write billing plan CSV")
+		utils.InternalServerErrorResponse(c, "Failed to write billing plan", err)
+		return
+	}
+
+	filename := fmt.Sprintf("billing-plan-%04d-%02d.csv", plan.Year, plan.Month)
+	c.Header("Content-Disposition", fmt.Sprintf("attachment; filename=%q", filename))
+	c.Data(http.StatusOK, "text/csv; charset=utf-8", buf.Bytes())
+}
+
 // GetBillingPenghuniSearch retrieves billing data for penghuni users with pagination and search
 // @Summary Get billing penghuni list with summed nominals
 // @Description Get billing data for penghuni users. Supports pagination and search by `q` (nama_penghuni or user ID).
@@ -199,40 +369,6 @@ func (h *BulkBillingHandler) GetBillingPenghuni(c *gin.Context) {
 	utils.SuccessResponse(c, "Billing penghuni retrieved successfully", results)
 }
 
-// MayarWebhookRequest represents the payload sent by Mayar payment gateway webhooks
-type MayarWebhookRequest struct {
-	Event string `json:"event" example:"payment.received"`
-	Data  struct {
-		ID                          string      `json:"id"`
-		TransactionID               string      `json:"transactionId"`
-		Status                      string      `json:"status"`
-		TransactionStatus           string      `json:"transactionStatus"`
-		CreatedAt                   string      `json:"createdAt"`
-		UpdatedAt                   string      `json:"updatedAt"`
-		MerchantID                  string      `json:"merchantId"`
-		MerchantName                string      `json:"merchantName"`
-		MerchantEmail               string      `json:"merchantEmail"`
-		CustomerID                  string      `json:"customerId"`
-		CustomerName                string      `json:"customerName"`
-		CustomerEmail               string      `json:"customerEmail"`
-		CustomerMobile              string      `json:"customerMobile"`
-		Amount                      int64       `json:"amount"`
-		PaymentLinkAmount           int64       `json:"paymentLinkAmount"`
-		IsAdminFeeBorneByCustomer   interface{} `json:"isAdminFeeBorneByCustomer"`
-		IsChannelFeeBorneByCustomer interface{} `json:"isChannelFeeBorneByCustomer"`
-		ProductID                   string      `json:"productId"`
-		ProductName                 string      `json:"productName"`
-		ProductDescription          string      `json:"productDescription" example:"1372,67 (DocumentID: monthly-xxx)"`
-		ProductType                 string      `json:"productType"`
-		PixelFbp                    interface{} `json:"pixelFbp"`
-		PixelFbc                    interface{} `json:"pixelFbc"`
-		Qty                         int         `json:"qty"`
-		CouponUsed                  interface{} `json:"couponUsed"`
-		PaymentMethod               string      `json:"paymentMethod"`
-		NettAmount                  int64       `json:"nettAmount"`
-	} `json:"data"`
-}
-
 // ConfirmPaymentWebhookRequest represents the payload sent by payment gateway webhooks (deprecated, use MayarWebhookRequest)
 type ConfirmPaymentWebhookRequest struct {
 	Service  map[string]interface{} `json:"service"`
@@ -248,95 +384,19 @@ type ConfirmPaymentWebhookRequest struct {
 
 // ConfirmPaymentWebhook handles incoming payment gateway webhooks for confirming payments
 // @Summary Confirm payment webhook (Mayar)
-// @Description Receive Mayar payment gateway webhook and process payment confirmation
+// @Description Receive Mayar payment gateway webhook and process payment confirmation. The request must carry the Mayar callback token in X-Callback-Token or an HMAC-SHA256 body signature in X-Mayar-Signature. Replayed deliveries are acknowledged without being applied again.
 // @Tags billings
 // @Accept json
 // @Produce json
-// @Param request body MayarWebhookRequest true "Mayar webhook payload"
+// @Param X-Callback-Token header string false "Mayar webhook callback token"
+// @Param X-Mayar-Signature header string false "Hex HMAC-SHA256 of the raw body keyed with the webhook token"
+// @Param request body service.MayarWebhookRequest true "Mayar webhook payload"
 // @Success 200 {object} utils.APIResponse "Webhook received"
 // @Failure 400 {object} utils.APIResponse "Invalid payload"
+// @Failure 401 {object} utils.APIResponse "Webhook verification failed"
 // @Router /api/v1/billings/confirm-payment [post]
 func (h *BulkBillingHandler) ConfirmPaymentWebhook(c *gin.Context) {
-	var req MayarWebhookRequest
-	if err := c.ShouldBindJSON(&req); err != nil {
-		h.logger.WithError(err).Error("Invalid webhook payload")
-		utils.BadRequestResponse(c, "Invalid webhook payload", err)
-		return
-	}
-
-	// Log the received webhook
-	h.logger.WithFields(map[string]interface{}{
-		"event":               req.Event,
-		"transaction_id":      req.Data.TransactionID,
-		"status":              req.Data.Status,
-		"amount":              req.Data.Amount,
-		"product_description": req.Data.ProductDescription,
-	}).Info("Received Mayar payment webhook")
-
-	// Parse billing IDs from productDescription
-	// Format: "1372,67 (DocumentID: ...)" or "1372 (DocumentID: ...)"
-	// We need to split by space and take the first part, then split by comma if present
-
-	productDesc := strings.TrimSpace(req.Data.ProductDescription)
-	if productDesc == "" {
-		h.logger.Error("Empty product description in webhook")
-		utils.BadRequestResponse(c, "Empty product description", nil)
-		return
-	}
-
-	// Split by space and take first part (before the parenthesis)
-	parts := strings.Split(productDesc, " ")
-	if len(parts) == 0 {
-		h.logger.Error("Invalid product description format")
-		utils.BadRequestResponse(c, "Invalid product description format", nil)
-		return
-	}
-
-	billingIDsStr := parts[0]
-	fmt.Println("Billing IDs string:", billingIDsStr)
-
-	// Split by comma to get individual IDs
-	idStrings := strings.Split(billingIDsStr, ",")
-	fmt.Println("ID strings:", idStrings)
-
-	var uintListId []uint
-	for _, idStr := range idStrings {
-		idStr = strings.TrimSpace(idStr)
-		if idStr == "" {
-			continue
-		}
-
-		var id uint
-		_, err := fmt.Sscanf(idStr, "%d", &id)
-		if err != nil {
-			h.logger.WithError(err).WithField("id_string", idStr).Error("Invalid billing ID format")
-			utils.BadRequestResponse(c, fmt.Sprintf("Invalid billing ID format: %s", idStr), err)
-			return
-		}
-		uintListId = append(uintListId, id)
-	}
-
-	if len(uintListId) == 0 {
-		h.logger.Error("No valid billing IDs found in product description")
-		utils.BadRequestResponse(c, "No valid billing IDs found", nil)
-		return
-	}
-
-	h.logger.WithField("billing_ids", uintListId).Info("Parsed billing IDs from webhook")
-	fmt.Println("uintListId : ", uintListId)
-	// Confirm payment for all billing IDs
-	// err := h.billingService.ConfirmPayment(uintListId)
-	// if err != nil {
-	// 	h.logger.WithError(err).Error("Failed to confirm payment for billing IDs")
-	// 	utils.InternalServerErrorResponse(c, "Failed to confirm payment", err)
-	// 	return
-	// }
-
-	utils.SuccessResponse(c, "Webhook received and payment confirmed", map[string]interface{}{
-		"billing_ids": uintListId,
-		"event":       req.Event,
-		"status":      req.Data.Status,
-	})
+	handleGatewayWebhook(c, h.webhookService, h.logger, service.PaymentGatewayMayar)
 }
 
 // ConfirmPaymentRequest is request body for confirming a single billing
@@ -346,13 +406,14 @@ type ConfirmPaymentRequest struct {
 
 // ConfirmPaymentSingle confirms payment for a single billing ID
 // @Summary Confirm single billing payment
-// @Description Confirm payment by sending a single billing_id in JSON body
+// @Description Confirm payment by sending a single billing_id in JSON body. Only unpaid, overdue and pending verification billings can be marked as paid.
 // @Tags billings
 // @Accept json
 // @Produce json
 // @Param request body ConfirmPaymentRequest true "Billing ID"
 // @Success 200 {object} utils.APIResponse "Payment confirmed"
 // @Failure 400 {object} utils.APIResponse "Invalid payload"
+// @Failure 409 {object} utils.APIResponse "Billing cannot be marked as paid"
 // @Failure 500 {object} utils.APIResponse "Internal server error"
 // @Router /api/v1/billings/confirm-single [post]
 func (h *BulkBillingHandler) ConfirmPaymentSingle(c *gin.Context) {
@@ -363,8 +424,17 @@ func (h *BulkBillingHandler) ConfirmPaymentSingle(c *gin.Context) {
 		return
 	}
 
-	if err := h.billingService.ConfirmPayment([]uint{req.BillingID}); err != nil {
+	var actorID uint
+	if user, ok := middleware.GetAuthUser(c); ok {
+		actorID = user.ID
+	}
+
+	if err := h.billingService.ConfirmPayment([]uint{req.BillingID}, actorID, "Confirmed manually"); err != nil {
 		h.logger.WithError(err).Error("Failed to confirm payment")
+		if errors.Is(err, service.ErrInvalidTransition) {
+			utils.ConflictResponse(c, "Billing cannot be marked as paid", err)
+			return
+		}
 		utils.InternalServerErrorResponse(c, "Failed to confirm payment", err)
 		return
 	}
@@ -589,7 +659,7 @@ func (h *BulkBillingHandler) GetProfileBillingWithFilters(c *gin.Context) {
 
 // GetBillingByProfileID retrieves billing data by profile ID with optional filters
 // @Summary Get billing by profile ID with optional filters
-// @Description Get billing data (id, profile_id, nama_billing, bulan, tahun, status_id, status_name, keterangan) by profile ID with optional filters for bulan, tahun, status_id, and rt. Profile ID is required. Supports pagination. Requires auth-token cookie.
+// @Description Get billing data (id, profile_id, nama_billing, bulan, tahun, due_date, status_id, status_name, keterangan) by profile ID with optional filters for bulan, tahun, status_id, and rt. Late fees are listed as penalty billings with penalty_for_billing_id set to the overdue billing. Profile ID is required. Supports pagination. Requires auth-token cookie.
 // @Tags billings
 // @Accept json
 // @Produce json
@@ -684,7 +754,7 @@ func (h *BulkBillingHandler) GetBillingByProfileID(c *gin.Context) {
 
 // GetBillingStatistics retrieves billing statistics with optional filters
 // @Summary Get billing statistics with optional filters
-// @Description Get billing statistics (total_billing, total_sudah_dibayar, total_belum_dibayar, total_nominal) with optional filters for search, bulan, tahun, rt, and status_ids. Search parameter will filter by nama_penghuni or nama_pemilik using LIKE. Status_ids parameter accepts comma-separated values, if not provided defaults to status IDs 2 and 6. Requires auth-token cookie.
+// @Description Get billing statistics (total_billing, total_sudah_dibayar, total_belum_dibayar, total_terlambat, total_sebagian_dibayar, total_nominal, total_collected, total_outstanding) with optional filters for search, bulan, tahun, rt, status_ids and overdue. Search parameter will filter by nama_penghuni or nama_pemilik using LIKE. Status_ids parameter accepts comma-separated values, if not provided defaults to unpaid, paid and overdue (Terlambat) billings. With overdue=true only overdue billings are counted and status_ids is ignored. Requires auth-token cookie.
 // @Tags billings
 // @Accept json
 // @Produce json
@@ -693,6 +763,7 @@ func (h *BulkBillingHandler) GetBillingByProfileID(c *gin.Context) {
 // @Param tahun query int false "Filter by year"
 // @Param rt query int false "Filter by RT"
 // @Param status_ids query string false "Filter by status IDs (comma-separated, e.g. '2,6,7')"
+// @Param overdue query bool false "Only count overdue (Terlambat) billings"
 // @Success 200 {object} utils.APIResponse
 // @Failure 400 {object} utils.APIResponse
 // @Failure 500 {object} utils.APIResponse
@@ -748,8 +819,18 @@ func (h *BulkBillingHandler) GetBillingStatistics(c *gin.Context) {
 		}
 	}
 
+	var overdue bool
+	if overdueStr := c.Query("overdue"); overdueStr != "" {
+		val, err := strconv.ParseBool(overdueStr)
+		if err != nil {
+			utils.BadRequestResponse(c, "Invalid overdue parameter", nil)
+			return
+		}
+		overdue = val
+	}
+
 	// Call service to get data
-	result, err := h.billingService.GetBillingStatistics(search, bulan, tahun, rt, statusIDs)
+	result, err := h.billingService.GetBillingStatistics(search, bulan, tahun, rt, statusIDs, overdue)
 	if err != nil {
 		h.logger.WithError(err).Error("Failed to get billing statistics")
 		utils.InternalServerErrorResponse(c, "Failed to retrieve billing statistics", err)
@@ -758,3 +839,165 @@ func (h *BulkBillingHandler) GetBillingStatistics(c *gin.Context) {
 
 	utils.SuccessResponse(c, "Billing statistics retrieved successfully", result)
 }
+
+// GetBilling handles GET /api/v1/billings/:id
+// @Summary Get billing
+// @Description Get a billing with its status, kategori transaksi, owner profile and attachments
+// @Tags billings
+// @Accept json
+// @Produce json
+// @Param id path int true "Billing ID"
+// @Success 200 {object} utils.APIResponse{data=service.BillingDetail} "Billing retrieved successfully"
+// @Failure 400 {object} utils.APIResponse "Invalid billing ID"
+// @Failure 404 {object} utils.APIResponse "Billing not found"
+// @Failure 500 {object} utils.APIResponse "Internal server error"
+// @Router /api/v1/billings/{id} [get]
+func (h *BulkBillingHandler) GetBilling(c *gin.Context) {
+	billingID, err := utils.GetIDParam(c)
+	if err != nil {
+		utils.BadRequestResponse(c, "Invalid billing ID", err)
+		return
+	}
+
+	billing, err := h.billingService.GetBilling(billingID)
+	if err != nil {
+		if errors.Is(err, gorm.ErrRecordNotFound) {
+			utils.NotFoundResponse(c, "Billing not found")
+			return
+		}
+		h.logger.WithError(err).WithField("billing_id", billingID).Error("Failed to get billing")
+		utils.InternalServerErrorResponse(c, "Failed to get billing", err)
+		return
+	}
+
+	utils.SuccessResponse(c, "Billing retrieved successfully", billing)
+}
+
+// CreateBilling handles POST /api/v1/billings
+// @Summary Create billing
+// @Description Create an unpaid one-off billing with a free-form nominal for a resident. The due date defaults to the configured due day of the month and the kategori transaksi to 1.
+// @Tags billings
+// @Accept json
+// @Produce json
+// @Param request body service.CreateBillingRequest true "Billing"
+// @Success 201 {object} utils.APIResponse{data=service.BillingDetail} "Billing created"
+// @Failure 400 {object} utils.APIResponse "Invalid request"
+// @Failure 500 {object} utils.APIResponse "Internal server error"
+// @Router /api/v1/billings [post]
+func (h *BulkBillingHandler) CreateBilling(c *gin.Context) {
+	var req service.CreateBillingRequest
+	if err := c.ShouldBindJSON(&req); err != nil {
+		utils.BadRequestResponse(c, "Invalid request data", err)
+		return
+	}
+
+	var actorID uint
+	if user, ok := middleware.GetAuthUser(c); ok {
+		actorID = user.ID
+	}
+
+	billing, err := h.billingService.CreateBilling(&req, actorID)
+	if err != nil {
+		h.logger.WithError(err).WithField("user_id", req.UserID).Error("Failed to create billing")
+		if errors.Is(err, service.ErrInvalidBilling) {
+			utils.BadRequestResponse(c, "Invalid billing", err)
+			return
+		}
+		utils.InternalServerErrorResponse(c, "Failed to create billing", err)
+		return
+	}
+
+	utils.CreatedResponse(c, "Billing created", billing)
+}
+
+// UpdateBilling handles PUT /api/v1/billings/:id
+// @Summary Update billing
+// @Description Edit the name, description, due date or kategori transaksi of an unpaid or overdue billing. Omitted fields are kept. The nominal is corrected through POST /api/v1/billings/{id}/adjustments.
+// @Tags billings
+// @Accept json
+// @Produce json
+// @Param id path int true "Billing ID"
+// @Param request body service.UpdateBillingRequest true "Billing fields"
+// @Success 200 {object} utils.APIResponse{data=service.BillingDetail} "Billing updated"
+// @Failure 400 {object} utils.APIResponse "Invalid request"
+// @Failure 404 {object} utils.APIResponse "Billing not found"
+// @Failure 409 {object} utils.APIResponse "Billing cannot be edited"
+// @Failure 500 {object} utils.APIResponse "Internal server error"
+// @Router /api/v1/billings/{id} [put]
+func (h *BulkBillingHandler) UpdateBilling(c *gin.Context) {
+	billingID, err := utils.GetIDParam(c)
+	if err != nil {
+		utils.BadRequestResponse(c, "Invalid billing ID", err)
+		return
+	}
+
+	var req service.UpdateBillingRequest
+	if err := c.ShouldBindJSON(&req); err != nil {
+		utils.BadRequestResponse(c, "Invalid request data", err)
+		return
+	}
+
+	var actorID uint
+	if user, ok := middleware.GetAuthUser(c); ok {
+		actorID = user.ID
+	}
+
+	billing, err := h.billingService.UpdateBilling(billingID, &req, actorID)
+	if err != nil {
+		h.logger.WithError(err).WithField("billing_id", billingID).Error("Failed to update billing")
+		switch {
+		case errors.Is(err, gorm.ErrRecordNotFound):
+			utils.NotFoundResponse(c, "Billing not found")
+		case errors.Is(err, service.ErrInvalidBilling):
+			utils.BadRequestResponse(c, "Invalid billing", err)
+		case errors.Is(err, service.ErrBillingNotEditable):
+			utils.ConflictResponse(c, "Billing cannot be edited", err)
+		default:
+			utils.InternalServerErrorResponse(c, "Failed to update billing", err)
+		}
+		return
+	}
+
+	utils.SuccessResponse(c, "Billing updated", billing)
+}
+
+// DeleteBilling handles DELETE /api/v1/billings/:id
+// @Summary Delete billing
+// @Description Delete an unpaid or cancelled billing by unpublishing it; its timeline is kept. Overdue billings must be cancelled first so their late fees are cancelled too. Billings partly paid or covered by an open payment link cannot be deleted.
+// @Tags billings
+// @Accept json
+// @Produce json
+// @Param id path int true "Billing ID"
+// @Success 200 {object} utils.APIResponse "Billing deleted"
+// @Failure 400 {object} utils.APIResponse "Invalid billing ID"
+// @Failure 404 {object} utils.APIResponse "Billing not found"
+// @Failure 409 {object} utils.APIResponse "Billing cannot be deleted"
+// @Failure 500 {object} utils.APIResponse "Internal server error"
+// @Router /api/v1/billings/{id} [delete]
+func (h *BulkBillingHandler) DeleteBilling(c *gin.Context) {
+	billingID, err := utils.GetIDParam(c)
+	if err != nil {
+		utils.BadRequestResponse(c, "Invalid billing ID", err)
+		return
+	}
+
+	var actorID uint
+	if user, ok := middleware.GetAuthUser(c); ok {
+		actorID = user.ID
+	}
+
+	if err := h.billingService.DeleteBilling(billingID, actorID); err != nil {
+		h.logger.WithError(err).WithField("billing_id", billingID).Error("Failed to delete billing")
+		switch {
+		case errors.Is(err, gorm.ErrRecordNotFound):
+			utils.NotFoundResponse(c, "Billing not found")
+		case errors.Is(err, service.ErrBillingNotDeletable):
+			utils.ConflictResponse(c, "Billing cannot be deleted", err)
+		default:
+			utils.InternalServerErrorResponse(c, "Failed to delete billing", err)
+		}
+		return
+	}
+
+	utils.SuccessResponse(c, "Billing deleted", nil)
+}
@@ -0,0 +1,111 @@
+package handler
+
+import (
+	"errors"
+
+	"ipl-be-svc/internal/middleware"
+	"ipl-be-svc/internal/service"
+	"ipl-be-svc/pkg/logger"
+	"ipl-be-svc/pkg/utils"
+
+	"github.com/gin-gonic/gin"
+	"gorm.io/gorm"
+)
+
+// BillingInstallmentHandler handles billing installment plan HTTP requests
+type BillingInstallmentHandler struct {
+	installmentService service.BillingInstallmentService
+	logger             *logger.Logger
+}
+
+// NewBillingInstallmentHandler creates a new BillingInstallmentHandler instance
+func NewBillingInstallmentHandler(installmentService service.BillingInstallmentService, logger *logger.Logger) *BillingInstallmentHandler {
+	return &BillingInstallmentHandler{
+		installmentService: installmentService,
+		logger:             logger,
+	}
+}
+
+// SetInstallmentPlan handles PUT /api/v1/billings/:id/installments
+// @Summary Set billing installment plan
+// @Description Replace the installment plan of an unpaid or overdue billing. The installments must add up to its outstanding balance and be due in order. Each installment is paid through its own payment link.
+// @Tags billings
+// @Accept json
+// @Produce json
+// @Param id path int true "Billing ID"
+// @Param request body service.InstallmentPlanRequest true "Installments"
+// @Success 200 {object} utils.APIResponse{data=service.InstallmentPlan} "Installment plan saved"
+// @Failure 400 {object} utils.APIResponse "Invalid installment plan"
+// @Failure 404 {object} utils.APIResponse "Billing not found"
+// @Failure 409 {object} utils.APIResponse "Billing cannot be paid in installments"
+// @Failure 500 {object} utils.APIResponse "Internal server error"
+// @Router /api/v1/billings/{id}/installments [put]
+func (h *BillingInstallmentHandler) SetInstallmentPlan(c *gin.Context) {
+	billingID, err := utils.GetIDParam(c)
+	if err != nil {
+		utils.BadRequestResponse(c, "Invalid billing ID", err)
+		return
+	}
+
+	var req service.InstallmentPlanRequest
+	if err := c.ShouldBindJSON(&req); err != nil {
+		utils.BadRequestResponse(c, "Invalid request data", err)
+		return
+	}
+
+	var actorID uint
+	if user, ok := middleware.GetAuthUser(c); ok {
+		actorID = user.ID
+	}
+
+	plan, err := h.installmentService.SetPlan(billingID, &req, actorID)
+	if err != nil {
+		h.logger.WithError(err).WithField("billing_id", billingID).Error("Failed to set installment plan")
+		switch {
+		case errors.Is(err, gorm.ErrRecordNotFound):
+			utils.NotFoundResponse(c, "Billing not found")
+		case errors.Is(err, service.ErrInvalidInstallmentPlan):
+			utils.BadRequestResponse(c, "Invalid installment plan", err)
+		case errors.Is(err, service.ErrBillingNotInstallable):
+			utils.ConflictResponse(c, "Billing cannot be paid in installments", err)
+		default:
+			utils.InternalServerErrorResponse(c, "Failed to set installment plan", err)
+		}
+		return
+	}
+
+	utils.SuccessResponse(c, "Installment plan saved", plan)
+}
+
+// GetInstallmentPlan handles GET /api/v1/billings/:id/installments
+// @Summary Get billing installment plan
+// @Description Get the installment plan of a billing with how much of each installment is paid. Payments cover the installments in sequence.
+// @Tags billings
+// @Accept json
+// @Produce json
+// @Param id path int true "Billing ID"
+// @Success 200 {object} utils.APIResponse{data=service.InstallmentPlan} "Installment plan retrieved successfully"
+// @Failure 400 {object} utils.APIResponse "Invalid billing ID"
+// @Failure 404 {object} utils.APIResponse "Billing not found"
+// @Failure 500 {object} utils.APIResponse "Internal server error"
+// @Router /api/v1/billings/{id}/installments [get]
+func (h *BillingInstallmentHandler) GetInstallmentPlan(c *gin.Context) {
+	billingID, err := utils.GetIDParam(c)
+	if err != nil {
+		utils.BadRequestResponse(c, "Invalid billing ID", err)
+		return
+	}
+
+	plan, err := h.installmentService.GetPlan(billingID)
+	if err != nil {
+		h.logger.WithError(err).WithField("billing_id", billingID).Error("Failed to get installment plan")
+		if errors.Is(err, gorm.ErrRecordNotFound) {
+			utils.NotFoundResponse(c, "Billing not found")
+			return
+		}
+		utils.InternalServerErrorResponse(c, "Failed to get installment plan", err)
+		return
+	}
+
+	utils.SuccessResponse(c, "Installment plan retrieved successfully", plan)
+}
@@ -0,0 +1,204 @@
+package handler
+
+import (
+	"errors"
+
+	"ipl-be-svc/internal/middleware"
+	"ipl-be-svc/internal/service"
+	"ipl-be-svc/pkg/logger"
+	"ipl-be-svc/pkg/utils"
+
+	"github.com/gin-gonic/gin"
+	"gorm.io/gorm"
+)
+
+// BillingPenaltyHandler handles late fee HTTP requests
+type BillingPenaltyHandler struct {
+	penaltyService service.BillingPenaltyService
+	logger         *logger.Logger
+}
+
+// NewBillingPenaltyHandler creates a new BillingPenaltyHandler instance
+func NewBillingPenaltyHandler(penaltyService service.BillingPenaltyService, logger *logger.Logger) *BillingPenaltyHandler {
+	return &BillingPenaltyHandler{
+		penaltyService: penaltyService,
+		logger:         logger,
+	}
+}
+
+// ListPenaltyRules handles GET /api/v1/billings/penalty-rules
+// @Summary List penalty rules
+// @Description List the late fee rules of the setting billings that have one
+// @Tags billings
+// @Accept json
+// @Produce json
+// @Success 200 {object} utils.APIResponse{data=[]models.BillingPenaltyRule} "Penalty rules retrieved successfully"
+// @Failure 500 {object} utils.APIResponse "Internal server error"
+// @Router /api/v1/billings/penalty-rules [get]
+func (h *BillingPenaltyHandler) ListPenaltyRules(c *gin.Context) {
+	rules, err := h.penaltyService.ListRules()
+	if err != nil {
+		h.logger.WithError(err).Error("Failed to list penalty rules")
+		utils.InternalServerErrorResponse(c, "Failed to list penalty rules", err)
+		return
+	}
+
+	utils.SuccessResponse(c, "Penalty rules retrieved successfully", rules)
+}
+
+// SavePenaltyRule handles PUT /api/v1/billings/penalty-rules/:id
+// @Summary Save penalty rule
+// @Description Create or replace the late fee of a setting billing. Flat rules charge amount and percentage rules charge percentage of the billing nominal for every month a billing is overdue, capped at cap when it is above 0. The daily overdue job charges them as penalty billings.
+// @Tags billings
+// @Accept json
+// @Produce json
+// @Param id path int true "Setting billing ID"
+// @Param request body service.PenaltyRuleRequest true "Penalty rule"
+// @Success 200 {object} utils.APIResponse{data=models.BillingPenaltyRule} "Penalty rule saved"
+// @Failure 400 {object} utils.APIResponse "Invalid request"
+// @Failure 404 {object} utils.APIResponse "Setting billing not found"
+// @Failure 500 {object} utils.APIResponse "Internal server error"
+// @Router /api/v1/billings/penalty-rules/{id} [put]
+func (h *BillingPenaltyHandler) SavePenaltyRule(c *gin.Context) {
+	settingID, err := utils.GetIDParam(c)
+	if err != nil {
+		utils.BadRequestResponse(c, "Invalid setting billing ID", err)
+		return
+	}
+
+	var req service.PenaltyRuleRequest
+	if err := c.ShouldBindJSON(&req); err != nil {
+		utils.BadRequestResponse(c, "Invalid request data", err)
+		return
+	}
+
+	rule, err := h.penaltyService.SaveRule(settingID, &req)
+	if err != nil {
+		h.logger.WithError(err).WithField("setting_billing_id", settingID).Error("Failed to save penalty rule")
+		switch {
+		case errors.Is(err, service.ErrInvalidPenaltyRule):
+			utils.BadRequestResponse(c, "Invalid penalty rule", err)
+		case errors.Is(err, gorm.ErrRecordNotFound):
+			utils.NotFoundResponse(c, "Setting billing not found")
+		default:
+			utils.InternalServerErrorResponse(c, "Failed to save penalty rule", err)
+		}
+		return
+	}
+
+	utils.SuccessResponse(c, "Penalty rule saved", rule)
+}
+
+// DeletePenaltyRule handles DELETE /api/v1/billings/penalty-rules/:id
+// @Summary Delete penalty rule
+// @Description Stop charging late fees on billings of a setting billing. Penalties already charged are kept.
+// @Tags billings
+// @Accept json
+// @Produce json
+// @Param id path int true "Setting billing ID"
+// @Success 200 {object} utils.APIResponse "Penalty rule deleted"
+// @Failure 400 {object} utils.APIResponse "Invalid setting billing ID"
+// @Failure 404 {object} utils.APIResponse "Penalty rule not found"
+// @Failure 500 {object} utils.APIResponse "Internal server error"
+// @Router /api/v1/billings/penalty-rules/{id} [delete]
+func (h *BillingPenaltyHandler) DeletePenaltyRule(c *gin.Context) {
+	settingID, err := utils.GetIDParam(c)
+	if err != nil {
+		utils.BadRequestResponse(c, "Invalid setting billing ID", err)
+		return
+	}
+
+	if err := h.penaltyService.DeleteRule(settingID); err != nil {
+		h.logger.WithError(err).WithField("setting_billing_id", settingID).Error("Failed to delete penalty rule")
+		if errors.Is(err, gorm.ErrRecordNotFound) {
+			utils.NotFoundResponse(c, "Penalty rule not found")
+			return
+		}
+		utils.InternalServerErrorResponse(c, "Failed to delete penalty rule", err)
+		return
+	}
+
+	utils.SuccessResponse(c, "Penalty rule deleted", nil)
+}
+
+// GetBillingPenalty handles GET /api/v1/billings/:id/penalty
+// @Summary Get billing penalty
+// @Description Get the late fee charged on an overdue billing, with its penalty billing and waiver
+// @Tags billings
+// @Accept json
+// @Produce json
+// @Param id path int true "Billing ID"
+// @Success 200 {object} utils.APIResponse{data=models.BillingPenalty} "Penalty retrieved successfully"
+// @Failure 400 {object} utils.APIResponse "Invalid billing ID"
+// @Failure 404 {object} utils.APIResponse "Billing has no penalty"
+// @Failure 500 {object} utils.APIResponse "Internal server error"
+// @Router /api/v1/billings/{id}/penalty [get]
+func (h *BillingPenaltyHandler) GetBillingPenalty(c *gin.Context) {
+	billingID, err := utils.GetIDParam(c)
+	if err != nil {
+		utils.BadRequestResponse(c, "Invalid billing ID", err)
+		return
+	}
+
+	penalty, err := h.penaltyService.GetPenaltyByBillingID(billingID)
+	if err != nil {
+		h.logger.WithError(err).WithField("billing_id", billingID).Error("Failed to get billing penalty")
+		if errors.Is(err, gorm.ErrRecordNotFound) {
+			utils.NotFoundResponse(c, "Billing has no penalty")
+			return
+		}
+		utils.InternalServerErrorResponse(c, "Failed to get billing penalty", err)
+		return
+	}
+
+	utils.SuccessResponse(c, "Penalty retrieved successfully", penalty)
+}
+
+// WaivePenalty handles POST /api/v1/billings/penalties/:id/waive
+// @Summary Waive penalty
+// @Description Waive an unpaid late fee, recording the reason and the admin. Its penalty billing is withdrawn so it is no longer shown or added to payment links.
+// @Tags billings
+// @Accept json
+// @Produce json
+// @Param id path int true "Penalty ID"
+// @Param request body service.WaivePenaltyRequest true "Waiver"
+// @Success 200 {object} utils.APIResponse{data=models.BillingPenalty} "Penalty waived"
+// @Failure 400 {object} utils.APIResponse "Invalid request"
+// @Failure 404 {object} utils.APIResponse "Penalty not found"
+// @Failure 409 {object} utils.APIResponse "Penalty already waived or paid, or a payment of it is under way"
+// @Failure 500 {object} utils.APIResponse "Internal server error"
+// @Router /api/v1/billings/penalties/{id}/waive [post]
+func (h *BillingPenaltyHandler) WaivePenalty(c *gin.Context) {
+	id, err := utils.GetIDParam(c)
+	if err != nil {
+		utils.BadRequestResponse(c, "Invalid penalty ID", err)
+		return
+	}
+
+	var req service.WaivePenaltyRequest
+	if err := c.ShouldBindJSON(&req); err != nil {
+		utils.BadRequestResponse(c, "Invalid request data", err)
+		return
+	}
+
+	var actorID uint
+	if user, ok := middleware.GetAuthUser(c); ok {
+		actorID = user.ID
+	}
+
+	penalty, err := h.penaltyService.WaivePenalty(id, &req, actorID)
+	if err != nil {
+		h.logger.WithError(err).WithField("penalty_id", id).Error("Failed to waive penalty")
+		switch {
+		case errors.Is(err, gorm.ErrRecordNotFound):
+			utils.NotFoundResponse(c, "Penalty not found")
+		case errors.Is(err, service.ErrPenaltyWaived), errors.Is(err, service.ErrPenaltyPaid), errors.Is(err, service.ErrPenaltyBeingPaid):
+			utils.ConflictResponse(c, "Penalty cannot be waived", err)
+		default:
+			utils.InternalServerErrorResponse(c, "Failed to waive penalty", err)
+		}
+		return
+	}
+
+	utils.SuccessResponse(c, "Penalty waived", penalty)
+}
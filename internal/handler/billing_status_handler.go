@@ -0,0 +1,97 @@
+package handler
+
+import (
+	"errors"
+
+	"ipl-be-svc/internal/service"
+	"ipl-be-svc/pkg/logger"
+	"ipl-be-svc/pkg/utils"
+
+	"github.com/gin-gonic/gin"
+	"gorm.io/gorm"
+)
+
+// BillingStatusHandler handles billing status HTTP requests
+type BillingStatusHandler struct {
+	statusRegistry service.StatusRegistry
+	stateMachine   service.BillingStateMachine
+	logger         *logger.Logger
+}
+
+// NewBillingStatusHandler creates a new BillingStatusHandler instance
+func NewBillingStatusHandler(statusRegistry service.StatusRegistry, stateMachine service.BillingStateMachine, logger *logger.Logger) *BillingStatusHandler {
+	return &BillingStatusHandler{
+		statusRegistry: statusRegistry,
+		stateMachine:   stateMachine,
+		logger:         logger,
+	}
+}
+
+// ListBillingStatuses handles GET /api/v1/billings/statuses
+// @Summary List billing statuses
+// @Description List the billing states (unpaid, paid, overdue, cancelled, pending_verification, refunded) with the master_general_statuses row each resolved to by name
+// @Tags billings
+// @Accept json
+// @Produce json
+// @Success 200 {object} utils.APIResponse{data=[]service.BillingStatus} "Billing statuses retrieved successfully"
+// @Router /api/v1/billings/statuses [get]
+func (h *BillingStatusHandler) ListBillingStatuses(c *gin.Context) {
+	utils.SuccessResponse(c, "Billing statuses retrieved successfully", h.statusRegistry.Statuses())
+}
+
+// RefreshBillingStatuses handles POST /api/v1/billings/statuses/refresh
+// @Summary Refresh billing statuses
+// @Description Reload the billing statuses from master_general_statuses after they were changed in Strapi. Missing overdue, cancelled, pending verification and refunded statuses are created; when the unpaid or paid status is missing the previous statuses are kept.
+// @Tags billings
+// @Accept json
+// @Produce json
+// @Success 200 {object} utils.APIResponse{data=[]service.BillingStatus} "Billing statuses refreshed"
+// @Failure 409 {object} utils.APIResponse "A required status is missing"
+// @Failure 500 {object} utils.APIResponse "Internal server error"
+// @Router /api/v1/billings/statuses/refresh [post]
+func (h *BillingStatusHandler) RefreshBillingStatuses(c *gin.Context) {
+	if err := h.statusRegistry.Refresh(); err != nil {
+		h.logger.WithError(err).Error("Failed to refresh billing statuses")
+		if errors.Is(err, service.ErrStatusNotFound) {
+			utils.ConflictResponse(c, "A required billing status is missing", err)
+			return
+		}
+		utils.InternalServerErrorResponse(c, "Failed to refresh billing statuses", err)
+		return
+	}
+
+	utils.SuccessResponse(c, "Billing statuses refreshed", h.statusRegistry.Statuses())
+}
+
+// GetBillingTimeline handles GET /api/v1/billings/:id/timeline
+// @Summary Get billing timeline
+// @Description Get the current status of a billing and every status change it went through, oldest first, with the admin and reason of each. Billings created before the history was recorded start at their first change.
+// @Tags billings
+// @Accept json
+// @Produce json
+// @Param id path int true "Billing ID"
+// @Success 200 {object} utils.APIResponse{data=service.BillingTimeline} "Billing timeline retrieved successfully"
+// @Failure 400 {object} utils.APIResponse "Invalid billing ID"
+// @Failure 404 {object} utils.APIResponse "Billing not found"
+// @Failure 500 {object} utils.APIResponse "Internal server error"
+// @Router /api/v1/billings/{id}/timeline [get]
+func (h *BillingStatusHandler) GetBillingTimeline(c *gin.Context) {
+	billingID, err := utils.GetIDParam(c)
+	if err != nil {
+		utils.BadRequestResponse(c, "Invalid billing ID", err)
+		return
+	}
+
+	timeline, err := h.stateMachine.GetTimeline(billingID)
+	if err != nil {
+		h.logger.WithError(err).WithField("billing_id", billingID).Error("Failed to get billing timeline")
+		if errors.Is(err, gorm.ErrRecordNotFound) {
+			utils.NotFoundResponse(c, "Billing not found")
+			return
+		}
+		utils.InternalServerErrorResponse(c, "Failed to get billing timeline", err)
+		return
+	}
+
+	utils.SuccessResponse(c, "Billing timeline retrieved successfully", timeline)
+}
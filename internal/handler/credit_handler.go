@@ -0,0 +1,142 @@
+package handler
+
+import (
+	"errors"
+
+	"ipl-be-svc/internal/middleware"
+	"ipl-be-svc/internal/service"
+	"ipl-be-svc/pkg/logger"
+	"ipl-be-svc/pkg/utils"
+
+	"github.com/gin-gonic/gin"
+	"gorm.io/gorm"
+)
+
+// CreditHandler handles resident credit balance HTTP requests
+type CreditHandler struct {
+	creditService service.CreditService
+	logger        *logger.Logger
+}
+
+// NewCreditHandler creates a new CreditHandler instance
+func NewCreditHandler(creditService service.CreditService, logger *logger.Logger) *CreditHandler {
+	return &CreditHandler{
+		creditService: creditService,
+		logger:        logger,
+	}
+}
+
+// GetCreditBalance handles GET /api/v1/billings/credits/:id
+// @Summary Get resident credit balance
+// @Description Get the credit a resident has left. Credit comes from advance deposits and overpayments and is applied to the billings generated by bulk monthly generation.
+// @Tags billings
+// @Accept json
+// @Produce json
+// @Param id path int true "Profile ID"
+// @Success 200 {object} utils.APIResponse{data=service.CreditBalance} "Credit balance retrieved successfully"
+// @Failure 400 {object} utils.APIResponse "Invalid profile ID"
+// @Failure 404 {object} utils.APIResponse "Profile not found"
+// @Failure 500 {object} utils.APIResponse "Internal server error"
+// @Router /api/v1/billings/credits/{id} [get]
+func (h *CreditHandler) GetCreditBalance(c *gin.Context) {
+	profileID, err := utils.GetIDParam(c)
+	if err != nil {
+		utils.BadRequestResponse(c, "Invalid profile ID", err)
+		return
+	}
+
+	balance, err := h.creditService.GetBalance(profileID)
+	if err != nil {
+		h.logger.WithError(err).WithField("profile_id", profileID).Error("Failed to get credit balance")
+		if errors.Is(err, gorm.ErrRecordNotFound) {
+			utils.NotFoundResponse(c, "Profile not found")
+			return
+		}
+		utils.InternalServerErrorResponse(c, "Failed to get credit balance", err)
+		return
+	}
+
+	utils.SuccessResponse(c, "Credit balance retrieved successfully", balance)
+}
+
+// ListCreditEntries handles GET /api/v1/billings/credits/:id/entries
+// @Summary List resident credit ledger
+// @Description List the movements of a resident's credit balance, newest first. Credit applied to a billing references it.
+// @Tags billings
+// @Accept json
+// @Produce json
+// @Param id path int true "Profile ID"
+// @Param page query int false "Page number" default(1)
+// @Param limit query int false "Items per page" default(10)
+// @Success 200 {object} utils.PaginatedResponse{data=[]models.CreditLedgerEntry} "Credit ledger retrieved successfully"
+// @Failure 400 {object} utils.APIResponse "Invalid profile ID"
+// @Failure 404 {object} utils.APIResponse "Profile not found"
+// @Failure 500 {object} utils.APIResponse "Internal server error"
+// @Router /api/v1/billings/credits/{id}/entries [get]
+func (h *CreditHandler) ListCreditEntries(c *gin.Context) {
+	profileID, err := utils.GetIDParam(c)
+	if err != nil {
+		utils.BadRequestResponse(c, "Invalid profile ID", err)
+		return
+	}
+
+	page, limit := utils.GetPaginationParams(c)
+
+	entries, total, err := h.creditService.GetEntries(profileID, page, limit)
+	if err != nil {
+		h.logger.WithError(err).WithField("profile_id", profileID).Error("Failed to get credit ledger")
+		if errors.Is(err, gorm.ErrRecordNotFound) {
+			utils.NotFoundResponse(c, "Profile not found")
+			return
+		}
+		utils.InternalServerErrorResponse(c, "Failed to get credit ledger", err)
+		return
+	}
+
+	utils.PaginatedSuccessResponse(c, "Credit ledger retrieved successfully", entries, page, limit, total)
+}
+
+// DepositCredit handles POST /api/v1/billings/credits/:id/deposits
+// @Summary Deposit resident credit
+// @Description Add money a resident paid in advance to their credit balance. It is applied to the billings generated by the next bulk monthly generations.
+// @Tags billings
+// @Accept json
+// @Produce json
+// @Param id path int true "Profile ID"
+// @Param request body service.CreditDepositRequest true "Deposit"
+// @Success 201 {object} utils.APIResponse{data=models.CreditLedgerEntry} "Credit deposited"
+// @Failure 400 {object} utils.APIResponse "Invalid request"
+// @Failure 404 {object} utils.APIResponse "Profile not found"
+// @Failure 500 {object} utils.APIResponse "Internal server error"
+// @Router /api/v1/billings/credits/{id}/deposits [post]
+func (h *CreditHandler) DepositCredit(c *gin.Context) {
+	profileID, err := utils.GetIDParam(c)
+	if err != nil {
+		utils.BadRequestResponse(c, "Invalid profile ID", err)
+		return
+	}
+
+	var req service.CreditDepositRequest
+	if err := c.ShouldBindJSON(&req); err != nil {
+		utils.BadRequestResponse(c, "Invalid request data", err)
+		return
+	}
+
+	var actorID uint
+	if user, ok := middleware.GetAuthUser(c); ok {
+		actorID = user.ID
+	}
+
+	entry, err := h.creditService.Deposit(profileID, &req, actorID)
+	if err != nil {
+		h.logger.WithError(err).WithField("profile_id", profileID).Error("Failed to deposit credit")
+		if errors.Is(err, gorm.ErrRecordNotFound) {
+			utils.NotFoundResponse(c, "Profile not found")
+			return
+		}
+		utils.InternalServerErrorResponse(c, "Failed to deposit credit", err)
+		return
+	}
+
+	utils.CreatedResponse(c, "Credit deposited", entry)
+}
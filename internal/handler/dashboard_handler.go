@@ -26,13 +26,14 @@ func NewDashboardHandler(dashboardService service.DashboardService, logger *logg
 
 // GetDashboardStatistics handles GET /api/v1/dashboard/statistics
 // @Summary Get dashboard statistics
-// @Description Get dashboard statistics with optional RT, bulan, and tahun filters. If rt=0 or not provided, no RT filter will be applied.
+// @Description Get dashboard statistics with optional RT, bulan, tahun and overdue filters. If rt=0 or not provided, no RT filter will be applied. `terlambat` counts unpaid billings the daily overdue job moved past their due date, which are no longer counted in `belum_bayar`. `sebagian_bayar` counts billings with part of their nominal paid, and `total_collected` and `total_outstanding` report the amounts paid and still owed.
 // @Tags dashboard
 // @Accept json
 // @Produce json
 // @Param rt query int false "Filter by RT (optional, if 0 or not provided, no RT filter applied)"
 // @Param bulan query int false "Filter by month (1-12)"
 // @Param tahun query int false "Filter by year"
+// @Param overdue query bool false "Only count overdue (Terlambat) billings"
 // @Success 200 {object} utils.APIResponse "Successfully retrieved dashboard statistics"
 // @Failure 400 {object} utils.APIResponse "Bad request - invalid parameter"
 // @Failure 500 {object} utils.APIResponse "Internal server error"
@@ -77,7 +78,19 @@ func (h *DashboardHandler) GetDashboardStatistics(c *gin.Context) {
 		tahun = &tahunValue
 	}
 
-	statistics, err := h.dashboardService.GetDashboardStatistics(rt, bulan, tahun)
+	// Get optional overdue parameter
+	var overdue bool
+	if overdueStr := c.Query("overdue"); overdueStr != "" {
+		overdueValue, err := strconv.ParseBool(overdueStr)
+		if err != nil {
+			h.logger.WithError(err).WithField("overdue", overdueStr).Error("Invalid overdue parameter format")
+			utils.BadRequestResponse(c, "Invalid overdue parameter format", err)
+			return
+		}
+		overdue = overdueValue
+	}
+
+	statistics, err := h.dashboardService.GetDashboardStatistics(rt, bulan, tahun, overdue)
 	if err != nil {
 		h.logger.WithError(err).WithField("rt", rt).Error("Failed to get dashboard statistics")
 		utils.InternalServerErrorResponse(c, "Failed to retrieve dashboard statistics", err)
@@ -89,13 +102,14 @@ func (h *DashboardHandler) GetDashboardStatistics(c *gin.Context) {
 
 // GetBillingList handles GET /api/v1/dashboard/billings
 // @Summary Get billing list with pagination
-// @Description Get list of billings with optional RT, bulan, tahun filters and pagination
+// @Description Get list of billings with optional RT, bulan, tahun and overdue filters and pagination
 // @Tags dashboard
 // @Accept json
 // @Produce json
 // @Param rt query int false "RT (Rukun Tetangga) number - optional, if not provided will return all"
 // @Param bulan query int false "Month (1-12) - optional"
 // @Param tahun query int false "Year - optional"
+// @Param overdue query bool false "Only list overdue (Terlambat) billings - optional"
 // @Param page query int false "Page number (default: 1)"
 // @Param limit query int false "Items per page (default: 10, max: 100)"
 // @Success 200 {object} utils.PaginatedResponse "Successfully retrieved billing list"
@@ -145,15 +159,28 @@ func (h *DashboardHandler) GetBillingList(c *gin.Context) {
 		tahun = &tahunValue
 	}
 
+	// Get optional overdue parameter
+	var overdue bool
+	if overdueStr := c.Query("overdue"); overdueStr != "" {
+		overdueValue, err := strconv.ParseBool(overdueStr)
+		if err != nil {
+			h.logger.WithError(err).WithField("overdue", overdueStr).Error("Invalid overdue parameter format")
+			utils.BadRequestResponse(c, "Invalid overdue parameter format", err)
+			return
+		}
+		overdue = overdueValue
+	}
+
 	// Get billing list
-	billings, total, err := h.dashboardService.GetBillingList(rt, bulan, tahun, page, limit)
+	billings, total, err := h.dashboardService.GetBillingList(rt, bulan, tahun, overdue, page, limit)
 	if err != nil {
 		h.logger.WithError(err).WithFields(map[string]interface{}{
-			"rt":    rt,
-			"bulan": bulan,
-			"tahun": tahun,
-			"page":  page,
-			"limit": limit,
+			"rt":      rt,
+			"bulan":   bulan,
+			"tahun":   tahun,
+			"overdue": overdue,
+			"page":    page,
+			"limit":   limit,
 		}).Error("Failed to get billing list")
 		utils.InternalServerErrorResponse(c, "Failed to retrieve billing list", err)
 		return
@@ -0,0 +1,105 @@
+package handler
+
+import (
+	"errors"
+	"html/template"
+	"net/http"
+
+	"ipl-be-svc/internal/service"
+	"ipl-be-svc/pkg/logger"
+
+	"github.com/gin-gonic/gin"
+)
+
+// fakePayPage renders a fake invoice with buttons that settle, fail or expire it
+var fakePayPage = template.Must(template.New("fake-pay").Parse(`<!DOCTYPE html>
+<html>
+<head>
+	<meta charset="utf-8">
+	<title>Fake Gateway - {{.Invoice.ID}}</title>
+	<style>
+		body { font-family: sans-serif; max-width: 480px; margin: 40px auto; }
+		table { width: 100%; border-collapse: collapse; margin-bottom: 24px; }
+		td { padding: 6px 0; border-bottom: 1px solid #eee; }
+		.error { color: #b00020; }
+		button { padding: 8px 16px; margin-right: 8px; }
+	</style>
+</head>
+<body>
+	<h2>Fake Payment Gateway</h2>
+	{{if .Error}}<p class="error">{{.Error}}</p>{{end}}
+	<table>
+		<tr><td>Invoice</td><td>{{.Invoice.ID}}</td></tr>
+		<tr><td>Customer</td><td>{{.Invoice.CustomerName}}</td></tr>
+		<tr><td>Description</td><td>{{.Invoice.Description}}</td></tr>
+		<tr><td>Amount</td><td>{{.Invoice.Amount}}</td></tr>
+		<tr><td>Admin fee</td><td>{{.Invoice.AdminFee}}</td></tr>
+		<tr><td><b>Total</b></td><td><b>{{.Invoice.Total}}</b></td></tr>
+		<tr><td>Status</td><td>{{.Invoice.Status}}</td></tr>
+	</table>
+	{{if eq .Invoice.Status "pending"}}
+	<form method="post">
+		<button name="action" value="pay">Pay</button>
+		<button name="action" value="fail">Fail</button>
+		<button name="action" value="expire">Expire</button>
+	</form>
+	{{end}}
+</body>
+</html>`))
+
+// FakeGatewayHandler serves the pay pages of the offline fake payment gateway
+type FakeGatewayHandler struct {
+	gateway *service.FakePaymentGateway
+	logger  *logger.Logger
+}
+
+// NewFakeGatewayHandler creates a new FakeGatewayHandler instance
+func NewFakeGatewayHandler(gateway *service.FakePaymentGateway, logger *logger.Logger) *FakeGatewayHandler {
+	return &FakeGatewayHandler{
+		gateway: gateway,
+		logger:  logger,
+	}
+}
+
+// ShowPayPage handles GET /fake-gateway/pay/:id
+func (h *FakeGatewayHandler) ShowPayPage(c *gin.Context) {
+	invoice, err := h.gateway.GetInvoice(c.Param("id"))
+	if err != nil {
+		c.String(http.StatusNotFound, "Invoice not found")
+		return
+	}
+
+	h.render(c, http.StatusOK, invoice, "")
+}
+
+// CompletePayment handles POST /fake-gateway/pay/:id, settling the invoice and firing its webhook
+func (h *FakeGatewayHandler) CompletePayment(c *gin.Context) {
+	invoice, err := h.gateway.Complete(c.Param("id"), c.PostForm("action"))
+	if err != nil {
+		h.logger.WithError(err).WithField("invoice_id", c.Param("id")).Error("Fake gateway payment failed")
+		if errors.Is(err, service.ErrGatewayInvoiceNotFound) {
+			c.String(http.StatusNotFound, "Invoice not found")
+			return
+		}
+		if invoice == nil {
+			invoice, _ = h.gateway.GetInvoice(c.Param("id"))
+		}
+		if invoice == nil {
+			c.String(http.StatusBadRequest, err.Error())
+			return
+		}
+		h.render(c, http.StatusBadRequest, invoice, err.Error())
+		return
+	}
+
+	c.Redirect(http.StatusSeeOther, c.Request.URL.Path)
+}
+
+// render writes the pay page for invoice
+func (h *FakeGatewayHandler) render(c *gin.Context, status int, invoice *service.FakeInvoice, message string) {
+	c.Status(status)
+	c.Header("Content-Type", "text/html; charset=utf-8")
+	if err := fakePayPage.Execute(c.Writer, gin.H{"Invoice": invoice, "Error": message}); err != nil {
+		h.logger.WithError(err).Error("Failed to render fake gateway pay page")
+	}
+}
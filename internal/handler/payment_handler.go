@@ -1,14 +1,18 @@
 package handler
 
 import (
+	"errors"
 	"log"
 	"net/http"
 	"strconv"
 
+	"ipl-be-svc/internal/middleware"
 	"ipl-be-svc/internal/service"
 	"ipl-be-svc/pkg/logger"
+	"ipl-be-svc/pkg/utils"
 
 	"github.com/gin-gonic/gin"
+	"gorm.io/gorm"
 )
 
 // PaymentHandler handles payment-related HTTP requests
@@ -32,14 +36,17 @@ func NewPaymentHandler(paymentService service.PaymentService, logger *logger.Log
 
 // CreatePaymentLink creates a payment link for a billing record
 // @Summary Create payment link
-// @Description Create a Mayar payment link for a billing record by ID
+// @Description Return the open payment link of what is outstanding on a billing record, or create one with the configured payment gateway. Use regenerate=true to void the open link and create a new one.
 // @Tags payments
 // @Accept json
 // @Produce json
 // @Param id path int true "Billing ID"
+// @Param regenerate query bool false "Void the open payment link and create a new one"
 // @Success 200 {object} service.PaymentLinkResponse "Payment link created successfully"
 // @Failure 400 {object} map[string]interface{} "Invalid billing ID"
+// @Failure 403 {object} map[string]interface{} "Billing of another resident"
 // @Failure 404 {object} map[string]interface{} "Billing not found"
+// @Failure 409 {object} map[string]interface{} "Billing cancelled or nothing outstanding"
 // @Failure 500 {object} map[string]interface{} "Internal server error"
 // @Router /api/v1/payments/billing/{id}/link [post]
 func (h *PaymentHandler) CreatePaymentLink(c *gin.Context) {
@@ -56,13 +63,55 @@ func (h *PaymentHandler) CreatePaymentLink(c *gin.Context) {
 		return
 	}
 
+	regenerate, _ := strconv.ParseBool(c.DefaultQuery("regenerate", "false"))
+
 	// Create payment link
-	response, err := h.paymentService.CreatePaymentLink(uint(billingID))
+	response, err := h.paymentService.CreatePaymentLink(uint(billingID), regenerate, paymentRequester(c))
 	if err != nil {
 		h.logger.WithError(err).WithField("billing_id", billingID).Error("Failed to create payment link")
 
+		if errors.Is(err, service.ErrPaymentForbidden) {
+			c.JSON(http.StatusForbidden, gin.H{
+				"error":   "Forbidden",
+				"message": err.Error(),
+			})
+			return
+		}
+
+		if errors.Is(err, service.ErrMultipleBillingOwners) {
+			c.JSON(http.StatusBadRequest, gin.H{
+				"error":   "Invalid request",
+				"message": "All billings in one payment must belong to the same resident",
+			})
+			return
+		}
+
+		if errors.Is(err, service.ErrBillingOwnerNotFound) {
+			c.JSON(http.StatusNotFound, gin.H{
+				"error":   "Billing owner not found",
+				"message": err.Error(),
+			})
+			return
+		}
+
+		if errors.Is(err, service.ErrBillingCancelled) {
+			c.JSON(http.StatusConflict, gin.H{
+				"error":   "Billing cancelled",
+				"message": err.Error(),
+			})
+			return
+		}
+
+		if errors.Is(err, service.ErrNothingOutstanding) {
+			c.JSON(http.StatusConflict, gin.H{
+				"error":   "Nothing outstanding",
+				"message": err.Error(),
+			})
+			return
+		}
+
 		// Check if it's a not found error
-		if err.Error() == "billing record not found" || err.Error() == "invalid billing nominal" {
+		if errors.Is(err, gorm.ErrRecordNotFound) || errors.Is(err, service.ErrInvalidBillingNominal) {
 			c.JSON(http.StatusNotFound, gin.H{
 				"error":   "Billing not found",
 				"message": err.Error(),
@@ -89,14 +138,17 @@ func (h *PaymentHandler) CreatePaymentLink(c *gin.Context) {
 
 // CreatePaymentLinkMultiple creates a payment link for multiple billing records
 // @Summary Create payment link for multiple billings
-// @Description Create a Mayar payment link for multiple billing records by IDs
+// @Description Return the open payment link covering what is outstanding on exactly these billing records, or create one with the configured payment gateway. Use regenerate=true to void the open link and create a new one.
 // @Tags payments
 // @Accept json
 // @Produce json
 // @Param request body CreatePaymentLinkMultipleRequest true "Billing IDs"
+// @Param regenerate query bool false "Void the open payment link and create a new one"
 // @Success 200 {object} service.PaymentLinkResponse "Payment link created successfully"
-// @Failure 400 {object} map[string]interface{} "Invalid billing IDs"
+// @Failure 400 {object} map[string]interface{} "Invalid billing IDs or billings of different residents"
+// @Failure 403 {object} map[string]interface{} "Billing of another resident"
 // @Failure 404 {object} map[string]interface{} "Billing not found"
+// @Failure 409 {object} map[string]interface{} "A billing is cancelled or has nothing outstanding"
 // @Failure 500 {object} map[string]interface{} "Internal server error"
 // @Router /api/v1/payments/billing/link [post]
 func (h *PaymentHandler) CreatePaymentLinkMultiple(c *gin.Context) {
@@ -119,13 +171,55 @@ func (h *PaymentHandler) CreatePaymentLinkMultiple(c *gin.Context) {
 		return
 	}
 
+	regenerate, _ := strconv.ParseBool(c.DefaultQuery("regenerate", "false"))
+
 	// Create payment link
-	response, err := h.paymentService.CreatePaymentLinkMultiple(request.BillingIDs)
+	response, err := h.paymentService.CreatePaymentLinkMultiple(request.BillingIDs, regenerate, paymentRequester(c))
 	if err != nil {
 		h.logger.WithError(err).WithField("billing_ids", request.BillingIDs).Error("Failed to create payment link")
 
+		if errors.Is(err, service.ErrPaymentForbidden) {
+			c.JSON(http.StatusForbidden, gin.H{
+				"error":   "Forbidden",
+				"message": err.Error(),
+			})
+			return
+		}
+
+		if errors.Is(err, service.ErrMultipleBillingOwners) {
+			c.JSON(http.StatusBadRequest, gin.H{
+				"error":   "Invalid request",
+				"message": "All billings in one payment must belong to the same resident",
+			})
+			return
+		}
+
+		if errors.Is(err, service.ErrBillingOwnerNotFound) {
+			c.JSON(http.StatusNotFound, gin.H{
+				"error":   "Billing owner not found",
+				"message": err.Error(),
+			})
+			return
+		}
+
+		if errors.Is(err, service.ErrBillingCancelled) {
+			c.JSON(http.StatusConflict, gin.H{
+				"error":   "Billing cancelled",
+				"message": err.Error(),
+			})
+			return
+		}
+
+		if errors.Is(err, service.ErrNothingOutstanding) {
+			c.JSON(http.StatusConflict, gin.H{
+				"error":   "Nothing outstanding",
+				"message": err.Error(),
+			})
+			return
+		}
+
 		// Check if it's a not found error
-		if err.Error() == "billing record not found" || err.Error() == "invalid billing nominal" {
+		if errors.Is(err, gorm.ErrRecordNotFound) || errors.Is(err, service.ErrInvalidBillingNominal) {
 			c.JSON(http.StatusNotFound, gin.H{
 				"error":   "Billing not found",
 				"message": err.Error(),
@@ -149,3 +243,321 @@ func (h *PaymentHandler) CreatePaymentLinkMultiple(c *gin.Context) {
 
 	c.JSON(http.StatusOK, response)
 }
+
+// CreateInstallmentPaymentLink creates a payment link for an installment of a billing
+// @Summary Create installment payment link
+// @Description Return the open payment link of what is left to pay of an installment, or create one with the configured payment gateway. Use regenerate=true to void the open link and create a new one.
+// @Tags payments
+// @Accept json
+// @Produce json
+// @Param id path int true "Installment ID"
+// @Param regenerate query bool false "Void the open payment link and create a new one"
+// @Success 200 {object} service.PaymentLinkResponse "Payment link created successfully"
+// @Failure 400 {object} map[string]interface{} "Invalid installment ID"
+// @Failure 403 {object} map[string]interface{} "Billing of another resident"
+// @Failure 404 {object} map[string]interface{} "Installment not found"
+// @Failure 409 {object} map[string]interface{} "Billing cancelled or installment already paid"
+// @Failure 500 {object} map[string]interface{} "Internal server error"
+// @Router /api/v1/payments/installments/{id}/link [post]
+func (h *PaymentHandler) CreateInstallmentPaymentLink(c *gin.Context) {
+	idParam := c.Param("id")
+	installmentID, err := strconv.ParseUint(idParam, 10, 32)
+	if err != nil {
+		h.logger.WithError(err).WithField("id_param", idParam).Error("Invalid installment ID parameter")
+		c.JSON(http.StatusBadRequest, gin.H{
+			"error":   "Invalid installment ID",
+			"message": "Installment ID must be a valid number",
+		})
+		return
+	}
+
+	regenerate, _ := strconv.ParseBool(c.DefaultQuery("regenerate", "false"))
+
+	response, err := h.paymentService.CreateInstallmentPaymentLink(uint(installmentID), regenerate, paymentRequester(c))
+	if err != nil {
+		h.logger.WithError(err).WithField("installment_id", installmentID).Error("Failed to create installment payment link")
+
+		switch {
+		case errors.Is(err, gorm.ErrRecordNotFound):
+			c.JSON(http.StatusNotFound, gin.H{
+				"error":   "Installment not found",
+				"message": err.Error(),
+			})
+		case errors.Is(err, service.ErrPaymentForbidden):
+			c.JSON(http.StatusForbidden, gin.H{
+				"error":   "Forbidden",
+				"message": err.Error(),
+			})
+		case errors.Is(err, service.ErrBillingOwnerNotFound):
+			c.JSON(http.StatusNotFound, gin.H{
+				"error":   "Billing owner not found",
+				"message": err.Error(),
+			})
+		case errors.Is(err, service.ErrBillingCancelled):
+			c.JSON(http.StatusConflict, gin.H{
+				"error":   "Billing cancelled",
+				"message": err.Error(),
+			})
+		case errors.Is(err, service.ErrInstallmentPaid):
+			c.JSON(http.StatusConflict, gin.H{
+				"error":   "Installment already paid",
+				"message": err.Error(),
+			})
+		default:
+			c.JSON(http.StatusInternalServerError, gin.H{
+				"error":   "Failed to create payment link",
+				"message": "Internal server error",
+			})
+		}
+		return
+	}
+
+	h.logger.WithFields(map[string]interface{}{
+		"installment_id": installmentID,
+		"billing_id":     response.BillingID,
+		"amount":         response.Amount,
+		"payment_url":    response.PaymentURL,
+	}).Info("Installment payment link created successfully")
+
+	c.JSON(http.StatusOK, response)
+}
+
+// CreatePrepaymentLink bills a resident's coming months in advance and creates one payment link for them
+// @Summary Prepay coming months
+// @Description Create a resident's monthly billings for the given number of months after the current one from the active monthly settings, and return one payment link covering them. Months already billed are not billed again, their billings still waiting to be paid are included in the link. The monthly generator skips the months billed in advance. Residents prepay their own months; billing staff may pass profile_id to prepay for a resident. Only penghuni users can prepay.
+// @Tags payments
+// @Accept json
+// @Produce json
+// @Param request body service.PrepayRequest true "Number of months and, for billing staff, the profile"
+// @Success 200 {object} utils.APIResponse{data=service.PrepaymentResponse} "Prepayment link created successfully"
+// @Failure 400 {object} utils.APIResponse "Invalid request, or the user is not a penghuni"
+// @Failure 401 {object} utils.APIResponse "Authentication required"
+// @Failure 403 {object} utils.APIResponse "Profile belongs to another resident"
+// @Failure 404 {object} utils.APIResponse "User or profile not found"
+// @Failure 409 {object} utils.APIResponse "The months are already paid or a billing is cancelled"
+// @Failure 500 {object} utils.APIResponse "Internal server error"
+// @Router /api/v1/payments/prepay [post]
+func (h *PaymentHandler) CreatePrepaymentLink(c *gin.Context) {
+	if _, ok := middleware.GetAuthUser(c); !ok {
+		utils.UnauthorizedResponse(c, "Authentication required")
+		return
+	}
+
+	var req service.PrepayRequest
+	if err := c.ShouldBindJSON(&req); err != nil {
+		utils.BadRequestResponse(c, "Invalid request data", err)
+		return
+	}
+
+	requester := paymentRequester(c)
+	response, err := h.paymentService.CreatePrepaymentLink(&req, requester)
+	if err != nil {
+		h.logger.WithError(err).WithFields(map[string]interface{}{
+			"user_id":    requester.UserID,
+			"profile_id": req.ProfileID,
+		}).Error("Failed to create prepayment link")
+		switch {
+		case errors.Is(err, gorm.ErrRecordNotFound):
+			utils.NotFoundResponse(c, "User or profile not found")
+		case errors.Is(err, service.ErrPaymentForbidden):
+			utils.ForbiddenResponse(c, "Profile belongs to another resident")
+		case errors.Is(err, service.ErrInvalidPrepayment):
+			utils.BadRequestResponse(c, "Months cannot be billed in advance", err)
+		case errors.Is(err, service.ErrNothingOutstanding), errors.Is(err, service.ErrBillingCancelled):
+			utils.ConflictResponse(c, "Months cannot be paid in advance", err)
+		default:
+			utils.InternalServerErrorResponse(c, "Failed to create prepayment link", err)
+		}
+		return
+	}
+
+	h.logger.WithFields(map[string]interface{}{
+		"user_id":     response.Advance.UserID,
+		"profile_id":  response.Advance.ProfileID,
+		"months":      req.Months,
+		"billing_ids": response.Advance.BillingIDs,
+		"amount":      response.Amount,
+	}).Info("Prepayment link created successfully")
+
+	utils.SuccessResponse(c, "Prepayment link created successfully", response)
+}
+
+// RecordManualPayment records a payment received outside a payment gateway
+// @Summary Record manual payment
+// @Description Record a payment an admin received outside a payment gateway, e.g. in cash. It may cover part of the billing, and the billing is marked as paid once nothing is outstanding. What is paid beyond the outstanding balance is added to the resident's credit balance.
+// @Tags payments
+// @Accept json
+// @Produce json
+// @Param id path int true "Billing ID"
+// @Param request body service.ManualPaymentRequest true "Payment"
+// @Success 201 {object} utils.APIResponse{data=models.Payment} "Payment recorded"
+// @Failure 400 {object} utils.APIResponse "Invalid request"
+// @Failure 404 {object} utils.APIResponse "Billing not found"
+// @Failure 409 {object} utils.APIResponse "Billing cannot be paid, or the amount exceeds the outstanding balance and there is no profile to credit"
+// @Failure 500 {object} utils.APIResponse "Internal server error"
+// @Router /api/v1/billings/{id}/payments [post]
+func (h *PaymentHandler) RecordManualPayment(c *gin.Context) {
+	billingID, err := utils.GetIDParam(c)
+	if err != nil {
+		utils.BadRequestResponse(c, "Invalid billing ID", err)
+		return
+	}
+
+	var req service.ManualPaymentRequest
+	if err := c.ShouldBindJSON(&req); err != nil {
+		utils.BadRequestResponse(c, "Invalid request data", err)
+		return
+	}
+
+	var actorID uint
+	if user, ok := middleware.GetAuthUser(c); ok {
+		actorID = user.ID
+	}
+
+	payment, err := h.paymentService.RecordManualPayment(billingID, &req, actorID)
+	if err != nil {
+		h.logger.WithError(err).WithField("billing_id", billingID).Error("Failed to record manual payment")
+		switch {
+		case errors.Is(err, gorm.ErrRecordNotFound):
+			utils.NotFoundResponse(c, "Billing not found")
+		case errors.Is(err, service.ErrInvalidTransition), errors.Is(err, service.ErrAmountExceedsOutstanding), errors.Is(err, service.ErrBillingOwnerNotFound):
+			utils.ConflictResponse(c, "Payment cannot be recorded", err)
+		default:
+			utils.InternalServerErrorResponse(c, "Failed to record payment", err)
+		}
+		return
+	}
+
+	utils.CreatedResponse(c, "Payment recorded", payment)
+}
+
+// GetPaymentsByBillingID returns the payment history of a billing
+// @Summary Get billing payment history
+// @Description Get every gateway payment created for a billing, newest first. Residents only see their own billings.
+// @Tags payments
+// @Accept json
+// @Produce json
+// @Param id path int true "Billing ID"
+// @Success 200 {object} utils.APIResponse{data=[]models.Payment} "Payment history retrieved successfully"
+// @Failure 400 {object} utils.APIResponse "Invalid billing ID"
+// @Failure 403 {object} utils.APIResponse "Billing of another resident"
+// @Failure 500 {object} utils.APIResponse "Internal server error"
+// @Router /api/v1/payments/billing/{id}/history [get]
+func (h *PaymentHandler) GetPaymentsByBillingID(c *gin.Context) {
+	billingID, err := utils.GetIDParam(c)
+	if err != nil {
+		utils.BadRequestResponse(c, "Invalid billing ID", err)
+		return
+	}
+
+	payments, err := h.paymentService.GetPaymentsByBillingID(billingID, paymentRequester(c))
+	if err != nil {
+		h.logger.WithError(err).WithField("billing_id", billingID).Error("Failed to get billing payment history")
+		if errors.Is(err, service.ErrPaymentForbidden) {
+			utils.ForbiddenResponse(c, "You do not have access to this billing")
+			return
+		}
+		utils.InternalServerErrorResponse(c, "Failed to get payment history", err)
+		return
+	}
+
+	utils.SuccessResponse(c, "Payment history retrieved successfully", payments)
+}
+
+// GetPaymentsByUserID returns the payment history of a resident
+// @Summary Get resident payment history
+// @Description Get the gateway payments covering billings owned by a user, newest first. Residents only see their own payments.
+// @Tags payments
+// @Accept json
+// @Produce json
+// @Param user_id path int true "User ID"
+// @Param page query int false "Page number" default(1)
+// @Param limit query int false "Items per page" default(10)
+// @Success 200 {object} utils.PaginatedResponse{data=[]models.Payment} "Payment history retrieved successfully"
+// @Failure 400 {object} utils.APIResponse "Invalid user ID"
+// @Failure 403 {object} utils.APIResponse "Another resident"
+// @Failure 500 {object} utils.APIResponse "Internal server error"
+// @Router /api/v1/payments/user/{user_id}/history [get]
+func (h *PaymentHandler) GetPaymentsByUserID(c *gin.Context) {
+	userID, err := strconv.ParseUint(c.Param("user_id"), 10, 32)
+	if err != nil {
+		utils.BadRequestResponse(c, "Invalid user ID", err)
+		return
+	}
+
+	page, limit := utils.GetPaginationParams(c)
+
+	payments, total, err := h.paymentService.GetPaymentsByUserID(uint(userID), page, limit, paymentRequester(c))
+	if err != nil {
+		h.logger.WithError(err).WithField("user_id", userID).Error("Failed to get resident payment history")
+		if errors.Is(err, service.ErrPaymentForbidden) {
+			utils.ForbiddenResponse(c, "You do not have access to this resident")
+			return
+		}
+		utils.InternalServerErrorResponse(c, "Failed to get payment history", err)
+		return
+	}
+
+	utils.PaginatedSuccessResponse(c, "Payment history retrieved successfully", payments, page, limit, total)
+}
+
+// paymentRequester returns the authenticated user as a payment requester, as staff when CheckMenu found
+// them linked to the billing menu
+func paymentRequester(c *gin.Context) *service.PaymentRequester {
+	requester := &service.PaymentRequester{Staff: middleware.HasMenuAccess(c)}
+	if user, ok := middleware.GetAuthUser(c); ok {
+		requester.UserID = user.ID
+	}
+	return requester
+}
+
+// RefundPayment refunds a paid payment through its gateway
+// @Summary Refund payment
+// @Description Refund a paid payment through the gateway it was paid with; manual payments are only recorded as refunded. Amount 0 refunds the full paid amount. A full refund moves the billings to refunded, or gives the amounts back to the outstanding balance of billings it only partly paid. After a partial refund the billings are kept as they are.
+// @Tags payments
+// @Accept json
+// @Produce json
+// @Param id path int true "Payment ID"
+// @Param request body service.RefundPaymentRequest true "Refund"
+// @Success 200 {object} utils.APIResponse{data=models.Payment} "Payment refunded"
+// @Failure 400 {object} utils.APIResponse "Invalid request or refund not supported by the gateway"
+// @Failure 404 {object} utils.APIResponse "Payment not found"
+// @Failure 409 {object} utils.APIResponse "Payment is not paid"
+// @Failure 500 {object} utils.APIResponse "Internal server error"
+// @Router /api/v1/payments/{id}/refund [post]
+func (h *PaymentHandler) RefundPayment(c *gin.Context) {
+	paymentID, err := utils.GetIDParam(c)
+	if err != nil {
+		utils.BadRequestResponse(c, "Invalid payment ID", err)
+		return
+	}
+
+	var req service.RefundPaymentRequest
+	if err := c.ShouldBindJSON(&req); err != nil {
+		utils.BadRequestResponse(c, "Invalid request body", err)
+		return
+	}
+
+	var actorID uint
+	if user, ok := middleware.GetAuthUser(c); ok {
+		actorID = user.ID
+	}
+
+	payment, err := h.paymentService.RefundPayment(paymentID, &req, actorID)
+	if err != nil {
+		h.logger.WithError(err).WithField("payment_id", paymentID).Error("Failed to refund payment")
+		switch {
+		case errors.Is(err, gorm.ErrRecordNotFound):
+			utils.NotFoundResponse(c, "Payment not found")
+		case errors.Is(err, service.ErrPaymentNotRefundable):
+			utils.ConflictResponse(c, "Payment cannot be refunded", err)
+		case errors.Is(err, service.ErrInvalidRefundAmount), errors.Is(err, service.ErrRefundNotSupported):
+			utils.BadRequestResponse(c, "Payment cannot be refunded", err)
+		default:
+			utils.InternalServerErrorResponse(c, "Failed to refund payment", err)
+		}
+		return
+	}
+
+	utils.SuccessResponse(c, "Payment refunded", payment)
+}
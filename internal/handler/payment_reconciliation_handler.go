@@ -0,0 +1,83 @@
+package handler
+
+import (
+	"errors"
+
+	"ipl-be-svc/internal/service"
+	"ipl-be-svc/pkg/logger"
+	"ipl-be-svc/pkg/utils"
+
+	"github.com/gin-gonic/gin"
+	"gorm.io/gorm"
+)
+
+// PaymentReconciliationHandler handles payment reconciliation run history HTTP requests
+type PaymentReconciliationHandler struct {
+	reconciliationService service.PaymentReconciliationService
+	logger                *logger.Logger
+}
+
+// NewPaymentReconciliationHandler creates a new PaymentReconciliationHandler instance
+func NewPaymentReconciliationHandler(reconciliationService service.PaymentReconciliationService, logger *logger.Logger) *PaymentReconciliationHandler {
+	return &PaymentReconciliationHandler{
+		reconciliationService: reconciliationService,
+		logger:                logger,
+	}
+}
+
+// ListReconciliationRuns handles GET /api/v1/billings/payment-reconciliations
+// @Summary List payment reconciliation runs
+// @Description List runs of the background job that polls gateways for pending invoices, newest first, with the payments each run changed or failed to check
+// @Tags billings
+// @Accept json
+// @Produce json
+// @Param page query int false "Page number" default(1)
+// @Param limit query int false "Items per page" default(10)
+// @Success 200 {object} utils.PaginatedResponse{data=[]models.PaymentReconciliationRun} "Reconciliation runs retrieved successfully"
+// @Failure 500 {object} utils.APIResponse "Internal server error"
+// @Router /api/v1/billings/payment-reconciliations [get]
+func (h *PaymentReconciliationHandler) ListReconciliationRuns(c *gin.Context) {
+	page, limit := utils.GetPaginationParams(c)
+
+	runs, total, err := h.reconciliationService.ListRuns(page, limit)
+	if err != nil {
+		h.logger.WithError(err).Error("Failed to list payment reconciliation runs")
+		utils.InternalServerErrorResponse(c, "Failed to list reconciliation runs", err)
+		return
+	}
+
+	utils.PaginatedSuccessResponse(c, "Reconciliation runs retrieved successfully", runs, page, limit, total)
+}
+
+// GetReconciliationRun handles GET /api/v1/billings/payment-reconciliations/:id
+// @Summary Get payment reconciliation run
+// @Description Get a reconciliation run with the payments it changed or failed to check
+// @Tags billings
+// @Accept json
+// @Produce json
+// @Param id path int true "Reconciliation run ID"
+// @Success 200 {object} utils.APIResponse{data=models.PaymentReconciliationRun} "Reconciliation run retrieved successfully"
+// @Failure 400 {object} utils.APIResponse "Invalid run ID"
+// @Failure 404 {object} utils.APIResponse "Reconciliation run not found"
+// @Failure 500 {object} utils.APIResponse "Internal server error"
+// @Router /api/v1/billings/payment-reconciliations/{id} [get]
+func (h *PaymentReconciliationHandler) GetReconciliationRun(c *gin.Context) {
+	id, err := utils.GetIDParam(c)
+	if err != nil {
+		utils.BadRequestResponse(c, "Invalid reconciliation run ID", err)
+		return
+	}
+
+	run, err := h.reconciliationService.GetRun(id)
+	if err != nil {
+		h.logger.WithError(err).WithField("run_id", id).Error("Failed to get payment reconciliation run")
+		if errors.Is(err, gorm.ErrRecordNotFound) {
+			utils.NotFoundResponse(c, "Reconciliation run not found")
+			return
+		}
+		utils.InternalServerErrorResponse(c, "Failed to get reconciliation run", err)
+		return
+	}
+
+	utils.SuccessResponse(c, "Reconciliation run retrieved successfully", run)
+}
@@ -0,0 +1,107 @@
+package handler
+
+import (
+	"errors"
+
+	"ipl-be-svc/internal/middleware"
+	"ipl-be-svc/internal/service"
+	"ipl-be-svc/pkg/logger"
+	"ipl-be-svc/pkg/utils"
+
+	"github.com/gin-gonic/gin"
+	"gorm.io/gorm"
+)
+
+// PaymentReviewHandler handles payment review queue HTTP requests
+type PaymentReviewHandler struct {
+	reviewService service.PaymentReviewService
+	logger        *logger.Logger
+}
+
+// NewPaymentReviewHandler creates a new PaymentReviewHandler instance
+func NewPaymentReviewHandler(reviewService service.PaymentReviewService, logger *logger.Logger) *PaymentReviewHandler {
+	return &PaymentReviewHandler{
+		reviewService: reviewService,
+		logger:        logger,
+	}
+}
+
+// ListPaymentReviews handles GET /api/v1/billings/payment-reviews
+// @Summary List payment reviews
+// @Description List gateway payments that could not be confirmed automatically (amount mismatch, partial, unknown or already paid billings)
+// @Tags billings
+// @Accept json
+// @Produce json
+// @Param status query string false "Filter by status (open, approved, dismissed)"
+// @Param page query int false "Page number" default(1)
+// @Param limit query int false "Items per page" default(10)
+// @Success 200 {object} utils.PaginatedResponse{data=[]models.PaymentReview} "Payment reviews retrieved successfully"
+// @Failure 500 {object} utils.APIResponse "Internal server error"
+// @Router /api/v1/billings/payment-reviews [get]
+func (h *PaymentReviewHandler) ListPaymentReviews(c *gin.Context) {
+	page, limit := utils.GetPaginationParams(c)
+
+	reviews, total, err := h.reviewService.ListReviews(c.Query("status"), page, limit)
+	if err != nil {
+		h.logger.WithError(err).Error("Failed to list payment reviews")
+		utils.InternalServerErrorResponse(c, "Failed to list payment reviews", err)
+		return
+	}
+
+	utils.PaginatedSuccessResponse(c, "Payment reviews retrieved successfully", reviews, page, limit, total)
+}
+
+// ResolvePaymentReview handles POST /api/v1/billings/payment-reviews/:id/resolve
+// @Summary Resolve payment review
+// @Description Approve or dismiss an open payment review. Approving applies the amount actually paid to the billings and marks the payment as paid. What it left outstanding is settled with write_off, otherwise those billings return to unpaid. Reviews opened before reviews recorded their payment can only be approved with write_off.
+// @Tags billings
+// @Accept json
+// @Produce json
+// @Param id path int true "Payment review ID"
+// @Param request body service.ResolvePaymentReviewRequest true "Resolution"
+// @Success 200 {object} utils.APIResponse{data=models.PaymentReview} "Payment review resolved"
+// @Failure 400 {object} utils.APIResponse "Invalid request or review without a payment approved without write_off"
+// @Failure 404 {object} utils.APIResponse "Payment review not found"
+// @Failure 409 {object} utils.APIResponse "Payment review already resolved or billings cannot be marked as paid"
+// @Failure 500 {object} utils.APIResponse "Internal server error"
+// @Router /api/v1/billings/payment-reviews/{id}/resolve [post]
+func (h *PaymentReviewHandler) ResolvePaymentReview(c *gin.Context) {
+	id, err := utils.GetIDParam(c)
+	if err != nil {
+		utils.BadRequestResponse(c, "Invalid payment review ID", err)
+		return
+	}
+
+	var req service.ResolvePaymentReviewRequest
+	if err := c.ShouldBindJSON(&req); err != nil {
+		utils.BadRequestResponse(c, "Invalid request data", err)
+		return
+	}
+
+	user, _ := middleware.GetAuthUser(c)
+
+	review, err := h.reviewService.ResolveReview(id, &req, user.ID)
+	if err != nil {
+		h.logger.WithError(err).WithField("review_id", id).Error("Failed to resolve payment review")
+		if errors.Is(err, gorm.ErrRecordNotFound) {
+			utils.NotFoundResponse(c, "Payment review not found")
+			return
+		}
+		if errors.Is(err, service.ErrPaymentReviewWithoutPayment) {
+			utils.BadRequestResponse(c, "Payment review must be approved with write_off", err)
+			return
+		}
+		if errors.Is(err, service.ErrPaymentReviewResolved) {
+			utils.ConflictResponse(c, "Payment review already resolved", err)
+			return
+		}
+		if errors.Is(err, service.ErrInvalidTransition) {
+			utils.ConflictResponse(c, "Billings cannot be marked as paid", err)
+			return
+		}
+		utils.InternalServerErrorResponse(c, "Failed to resolve payment review", err)
+		return
+	}
+
+	utils.SuccessResponse(c, "Payment review resolved", review)
+}
@@ -0,0 +1,74 @@
+package handler
+
+import (
+	"errors"
+
+	"ipl-be-svc/internal/service"
+	"ipl-be-svc/pkg/logger"
+	"ipl-be-svc/pkg/utils"
+
+	"github.com/gin-gonic/gin"
+)
+
+// PaymentWebhookHandler handles webhooks delivered by payment gateways
+type PaymentWebhookHandler struct {
+	webhookService service.PaymentWebhookService
+	logger         *logger.Logger
+}
+
+// NewPaymentWebhookHandler creates a new PaymentWebhookHandler instance
+func NewPaymentWebhookHandler(webhookService service.PaymentWebhookService, logger *logger.Logger) *PaymentWebhookHandler {
+	return &PaymentWebhookHandler{
+		webhookService: webhookService,
+		logger:         logger,
+	}
+}
+
+// HandleGatewayWebhook handles POST /api/v1/payments/webhook/:gateway
+// @Summary Payment gateway webhook
+// @Description Receive a payment notification from the named gateway (mayar, doku or fake). Each gateway verifies its own token or signature headers. Replayed deliveries are acknowledged without being applied again.
+// @Tags payments
+// @Accept json
+// @Produce json
+// @Param gateway path string true "Gateway name"
+// @Success 200 {object} utils.APIResponse{data=service.WebhookResult} "Webhook received"
+// @Failure 400 {object} utils.APIResponse "Invalid payload"
+// @Failure 401 {object} utils.APIResponse "Webhook verification failed"
+// @Failure 404 {object} utils.APIResponse "Unknown gateway"
+// @Router /api/v1/payments/webhook/{gateway} [post]
+func (h *PaymentWebhookHandler) HandleGatewayWebhook(c *gin.Context) {
+	handleGatewayWebhook(c, h.webhookService, h.logger, c.Param("gateway"))
+}
+
+// handleGatewayWebhook passes the raw body to the webhook service and maps its errors to responses
+func handleGatewayWebhook(c *gin.Context, webhookService service.PaymentWebhookService, logger *logger.Logger, gateway string) {
+	body, err := c.GetRawData()
+	if err != nil {
+		logger.WithError(err).Error("Failed to read webhook body")
+		utils.BadRequestResponse(c, "Invalid webhook payload", err)
+		return
+	}
+
+	result, err := webhookService.HandleWebhook(gateway, c.Request.Header, body)
+	if err != nil {
+		logger.WithError(err).WithField("gateway", gateway).Error("Failed to process payment webhook")
+		switch {
+		case errors.Is(err, service.ErrUnknownPaymentGateway):
+			utils.NotFoundResponse(c, "Unknown payment gateway")
+		case errors.Is(err, service.ErrWebhookUnauthorized):
+			utils.UnauthorizedResponse(c, "Webhook verification failed")
+		case errors.Is(err, service.ErrWebhookInvalidPayload), errors.Is(err, service.ErrWebhookStale):
+			utils.BadRequestResponse(c, "Invalid webhook payload", err)
+		default:
+			utils.InternalServerErrorResponse(c, "Failed to process webhook", err)
+		}
+		return
+	}
+
+	if result.Duplicate {
+		utils.SuccessResponse(c, "Webhook already processed", result)
+		return
+	}
+
+	utils.SuccessResponse(c, "Webhook received and payment confirmed", result)
+}
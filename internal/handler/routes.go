@@ -5,10 +5,23 @@ import (
 	swaggerFiles "github.com/swaggo/files"
 	ginSwagger "github.com/swaggo/gin-swagger"
 
+	"ipl-be-svc/internal/config"
+	"ipl-be-svc/internal/middleware"
 	"ipl-be-svc/internal/service"
 	"ipl-be-svc/pkg/logger"
 )
 
+// PublicPaths lists the path prefixes that are reachable without authentication
+var PublicPaths = []string{
+	"/swagger",
+	"/api/v1/health",
+	// Payment gateways call these webhooks without a user token
+	"/api/v1/billings/confirm-payment",
+	"/api/v1/payments/webhook",
+	// Pay pages of the offline fake gateway (only registered when it is enabled)
+	"/fake-gateway",
+}
+
 // Routes sets up all API routes
 func SetupRoutes(
 	router *gin.Engine,
@@ -19,20 +32,46 @@ func SetupRoutes(
 	masterMenuService service.MasterMenuService,
 	roleMenuService service.RoleMenuService,
 	dashboardService service.DashboardService,
+	webhookService service.PaymentWebhookService,
+	paymentReviewService service.PaymentReviewService,
+	reconciliationService service.PaymentReconciliationService,
+	penaltyService service.BillingPenaltyService,
+	statusRegistry service.StatusRegistry,
+	stateMachine service.BillingStateMachine,
+	adjustmentService service.BillingAdjustmentService,
+	installmentService service.BillingInstallmentService,
+	creditService service.CreditService,
+	fakeGateway *service.FakePaymentGateway,
+	rbac config.RBACConfig,
 	logger *logger.Logger,
 ) {
 	// Initialize handlers
 	menuHandler := NewMenuHandler(menuService, logger)
 	paymentHandler := NewPaymentHandler(paymentService, logger)
 	userHandler := NewUserHandler(userService, logger)
-	bulkBillingHandler := NewBulkBillingHandler(billingService, logger)
+	bulkBillingHandler := NewBulkBillingHandler(billingService, webhookService, logger)
 	masterMenuHandler := NewMasterMenuHandler(masterMenuService, logger)
 	roleMenuHandler := NewRoleMenuHandler(roleMenuService, logger)
 	dashboardHandler := NewDashboardHandler(dashboardService, logger)
+	paymentReviewHandler := NewPaymentReviewHandler(paymentReviewService, logger)
+	paymentWebhookHandler := NewPaymentWebhookHandler(webhookService, logger)
+	reconciliationHandler := NewPaymentReconciliationHandler(reconciliationService, logger)
+	penaltyHandler := NewBillingPenaltyHandler(penaltyService, logger)
+	statusHandler := NewBillingStatusHandler(statusRegistry, stateMachine, logger)
+	adjustmentHandler := NewBillingAdjustmentHandler(adjustmentService, logger)
+	installmentHandler := NewBillingInstallmentHandler(installmentService, logger)
+	creditHandler := NewCreditHandler(creditService, logger)
 
 	// Swagger documentation
 	router.GET("/swagger/*any", ginSwagger.WrapHandler(swaggerFiles.Handler))
 
+	// Offline fake payment gateway pay pages
+	if fakeGateway != nil {
+		fakeGatewayHandler := NewFakeGatewayHandler(fakeGateway, logger)
+		router.GET(service.FakeGatewayPayPath+":id", fakeGatewayHandler.ShowPayPage)
+		router.POST(service.FakeGatewayPayPath+":id", fakeGatewayHandler.CompletePayment)
+	}
+
 	// API v1 group
 	v1 := router.Group("/api/v1")
 	{
@@ -48,8 +87,18 @@ func SetupRoutes(
 		// Payment routes
 		payments := v1.Group("/payments")
 		{
-			payments.POST("/billing/:id/link", paymentHandler.CreatePaymentLink)
-			payments.POST("/billing/link", paymentHandler.CreatePaymentLinkMultiple)
+			// Residents may only pay and see the payments of their own billings, billing staff of everyone's
+			checkBillingMenu := middleware.CheckMenu(menuService, logger, rbac.BillingMenuCode)
+			payments.POST("/billing/:id/link", checkBillingMenu, paymentHandler.CreatePaymentLink)
+			payments.POST("/billing/link", checkBillingMenu, paymentHandler.CreatePaymentLinkMultiple)
+			payments.POST("/installments/:id/link", checkBillingMenu, paymentHandler.CreateInstallmentPaymentLink)
+			payments.GET("/billing/:id/history", checkBillingMenu, paymentHandler.GetPaymentsByBillingID)
+			payments.GET("/user/:user_id/history", checkBillingMenu, paymentHandler.GetPaymentsByUserID)
+			// Residents bill their coming months in advance and pay them with one link, billing staff for any resident
+			payments.POST("/prepay", checkBillingMenu, paymentHandler.CreatePrepaymentLink)
+			payments.POST("/:id/refund", middleware.RequireMenu(menuService, logger, rbac.BillingMenuCode), paymentHandler.RefundPayment)
+			// Gateway webhooks (public)
+			payments.POST("/webhook/:gateway", paymentWebhookHandler.HandleGatewayWebhook)
 		}
 
 		// User routes
@@ -59,13 +108,16 @@ func SetupRoutes(
 			users.GET("/penghuni", userHandler.GetPenghuniUsers)
 		}
 
+		// Payment confirmation webhook endpoint (public, outside the guarded billing group)
+		v1.POST("/billings/confirm-payment", bulkBillingHandler.ConfirmPaymentWebhook)
+
 		// Billing routes
-		billings := v1.Group("/billings")
+		billings := v1.Group("/billings", middleware.RequireMenu(menuService, logger, rbac.BillingMenuCode))
 		{
 			billings.POST("/bulk-monthly", bulkBillingHandler.CreateBulkMonthlyBillings)
 			billings.POST("/bulk-custom", bulkBillingHandler.CreateBulkCustomBillings)
-			// Payment confirmation webhook endpoint
-			billings.POST("/confirm-payment", bulkBillingHandler.ConfirmPaymentWebhook)
+			// Progress of bulk billings generated in the background
+			billings.GET("/jobs/:id", bulkBillingHandler.GetBulkBillingJob)
 			// Confirm single billing via JSON body {billing_id}
 			billings.POST("/confirm-single", bulkBillingHandler.ConfirmPaymentSingle)
 			// Admin endpoint to confirm payments by billing IDs
@@ -78,6 +130,40 @@ func SetupRoutes(
 			billings.GET("/by-profile", bulkBillingHandler.GetBillingByProfileID)
 			// Get billing statistics with optional filters
 			billings.GET("/statistics", bulkBillingHandler.GetBillingStatistics)
+			// Statuses the billing states resolved to
+			billings.GET("/statuses", statusHandler.ListBillingStatuses)
+			billings.POST("/statuses/refresh", statusHandler.RefreshBillingStatuses)
+			// Gateway payments waiting for manual review
+			billings.GET("/payment-reviews", paymentReviewHandler.ListPaymentReviews)
+			billings.POST("/payment-reviews/:id/resolve", paymentReviewHandler.ResolvePaymentReview)
+			// History of the background gateway reconciliation runs
+			billings.GET("/payment-reconciliations", reconciliationHandler.ListReconciliationRuns)
+			billings.GET("/payment-reconciliations/:id", reconciliationHandler.GetReconciliationRun)
+			// Late fees charged on overdue billings
+			billings.GET("/penalty-rules", penaltyHandler.ListPenaltyRules)
+			billings.PUT("/penalty-rules/:id", penaltyHandler.SavePenaltyRule)
+			billings.DELETE("/penalty-rules/:id", penaltyHandler.DeletePenaltyRule)
+			billings.POST("/penalties/:id/waive", penaltyHandler.WaivePenalty)
+			billings.GET("/:id/penalty", penaltyHandler.GetBillingPenalty)
+			// Credit balances of residents, by profile ID
+			billings.GET("/credits/:id", creditHandler.GetCreditBalance)
+			billings.GET("/credits/:id/entries", creditHandler.ListCreditEntries)
+			billings.POST("/credits/:id/deposits", creditHandler.DepositCredit)
+			// Status history of a billing
+			billings.GET("/:id/timeline", statusHandler.GetBillingTimeline)
+			// Corrections of wrongly generated billings
+			billings.POST("/:id/cancel", adjustmentHandler.CancelBilling)
+			billings.POST("/:id/adjustments", adjustmentHandler.AdjustBilling)
+			billings.GET("/:id/adjustments", adjustmentHandler.ListBillingAdjustments)
+			// Partial payments received outside a gateway and installment plans
+			billings.POST("/:id/payments", paymentHandler.RecordManualPayment)
+			billings.PUT("/:id/installments", installmentHandler.SetInstallmentPlan)
+			billings.GET("/:id/installments", installmentHandler.GetInstallmentPlan)
+			// Single billings with their status, kategori transaksi, owner and attachments
+			billings.POST("", bulkBillingHandler.CreateBilling)
+			billings.GET("/:id", bulkBillingHandler.GetBilling)
+			billings.PUT("/:id", bulkBillingHandler.UpdateBilling)
+			billings.DELETE("/:id", bulkBillingHandler.DeleteBilling)
 			// Billing attachments
 			billings.POST("/:id/attachments", bulkBillingHandler.UploadBillingAttachment)
 			billings.GET("/:id/attachments", bulkBillingHandler.ListBillingAttachments)
@@ -85,7 +171,7 @@ func SetupRoutes(
 		}
 
 		// Master Menu routes
-		masterMenus := v1.Group("/master-menus")
+		masterMenus := v1.Group("/master-menus", middleware.RequireMenu(menuService, logger, rbac.MasterMenuMenuCode))
 		{
 			masterMenus.POST("", masterMenuHandler.CreateMasterMenu)
 			masterMenus.GET("", masterMenuHandler.GetAllMasterMenus)
@@ -95,7 +181,7 @@ func SetupRoutes(
 		}
 
 		// Role Menu routes
-		roleMenus := v1.Group("/role-menus")
+		roleMenus := v1.Group("/role-menus", middleware.RequireMenu(menuService, logger, rbac.RoleMenuMenuCode))
 		{
 			roleMenus.POST("", roleMenuHandler.CreateRoleMenu)
 			roleMenus.GET("", roleMenuHandler.GetAllRoleMenus)
@@ -113,13 +199,13 @@ func SetupRoutes(
 		}
 
 		// Role-specific role menu routes
-		roles := v1.Group("/roles")
+		roles := v1.Group("/roles", middleware.RequireMenu(menuService, logger, rbac.RoleMenuMenuCode))
 		{
 			roles.GET("/:role_id/role-menus", roleMenuHandler.GetRoleMenusByRoleID)
 		}
 
 		// Dashboard routes
-		dashboard := v1.Group("/dashboard")
+		dashboard := v1.Group("/dashboard", middleware.RequireMenu(menuService, logger, rbac.DashboardMenuCode))
 		{
 			dashboard.GET("/statistics", dashboardHandler.GetDashboardStatistics)
 			dashboard.GET("/billings", dashboardHandler.GetBillingList)
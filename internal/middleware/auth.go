@@ -0,0 +1,91 @@
+package middleware
+
+import (
+	"errors"
+	"strings"
+
+	"github.com/gin-gonic/gin"
+
+	"ipl-be-svc/internal/service"
+	"ipl-be-svc/pkg/logger"
+	"ipl-be-svc/pkg/utils"
+)
+
+const (
+	// AuthUserKey is the gin context key holding the authenticated user
+	AuthUserKey = "auth_user"
+	// AuthTokenCookie is the cookie name Strapi uses for the JWT
+	AuthTokenCookie = "auth-token"
+)
+
+// Auth returns a middleware that requires a valid JWT on every request except
+// those whose path starts with one of publicPaths. The token is read from the
+// Authorization Bearer header first and falls back to the auth-token cookie.
+func Auth(authService service.AuthService, logger *logger.Logger, publicPaths ...string) gin.HandlerFunc {
+	return func(c *gin.Context) {
+		if isPublicPath(c.Request.URL.Path, publicPaths) {
+			c.Next()
+			return
+		}
+
+		tokenString := extractToken(c)
+		if tokenString == "" {
+			utils.UnauthorizedResponse(c, "Authentication required")
+			c.Abort()
+			return
+		}
+
+		user, err := authService.Authenticate(tokenString)
+		if err != nil {
+			logger.WithError(err).WithField("path", c.Request.URL.Path).Warn("Authentication failed")
+			switch {
+			case errors.Is(err, service.ErrUserBlocked):
+				utils.ForbiddenResponse(c, "User is blocked")
+			case errors.Is(err, service.ErrInvalidToken), errors.Is(err, service.ErrUserNotFound):
+				utils.UnauthorizedResponse(c, "Invalid or expired token")
+			default:
+				utils.InternalServerErrorResponse(c, "Failed to authenticate user", err)
+			}
+			c.Abort()
+			return
+		}
+
+		c.Set(AuthUserKey, user)
+		c.Next()
+	}
+}
+
+// GetAuthUser returns the authenticated user stored in the context by Auth
+func GetAuthUser(c *gin.Context) (*service.AuthenticatedUser, bool) {
+	value, exists := c.Get(AuthUserKey)
+	if !exists {
+		return nil, false
+	}
+	user, ok := value.(*service.AuthenticatedUser)
+	return user, ok
+}
+
+// extractToken reads the JWT from the Authorization header or the auth-token cookie
+func extractToken(c *gin.Context) string {
+	if authHeader := c.GetHeader("Authorization"); authHeader != "" {
+		if token, err := utils.ExtractBearerToken(authHeader); err == nil {
+			return strings.TrimSpace(token)
+		}
+	}
+
+	if cookie, err := c.Cookie(AuthTokenCookie); err == nil {
+		return strings.TrimSpace(cookie)
+	}
+
+	return ""
+}
+
+// isPublicPath reports whether path matches one of the allow-listed prefixes
+func isPublicPath(path string, publicPaths []string) bool {
+	for _, prefix := range publicPaths {
+		if path == prefix || strings.HasPrefix(path, strings.TrimSuffix(prefix, "/")+"/") {
+			return true
+		}
+	}
+	return false
+}
@@ -0,0 +1,82 @@
+package middleware
+
+import (
+	"github.com/gin-gonic/gin"
+
+	"ipl-be-svc/internal/service"
+	"ipl-be-svc/pkg/logger"
+	"ipl-be-svc/pkg/utils"
+)
+
+// RequireMenu returns a middleware that only lets through callers whose roles
+// are linked to the master menu identified by kodeMenu. It must run after Auth.
+func RequireMenu(menuService service.MenuService, logger *logger.Logger, kodeMenu string) gin.HandlerFunc {
+	return func(c *gin.Context) {
+		user, ok := GetAuthUser(c)
+		if !ok {
+			utils.UnauthorizedResponse(c, "Authentication required")
+			c.Abort()
+			return
+		}
+
+		allowed, err := menuService.HasMenuAccess(user.RoleIDs(), kodeMenu)
+		if err != nil {
+			logger.WithError(err).WithFields(map[string]interface{}{
+				"user_id":   user.ID,
+				"kode_menu": kodeMenu,
+			}).Error("Failed to check menu access")
+			utils.InternalServerErrorResponse(c, "Failed to check permissions", err)
+			c.Abort()
+			return
+		}
+
+		if !allowed {
+			logger.WithFields(map[string]interface{}{
+				"user_id":   user.ID,
+				"kode_menu": kodeMenu,
+				"path":      c.Request.URL.Path,
+			}).Warn("Access denied")
+			utils.ForbiddenResponse(c, "You do not have access to this resource")
+			c.Abort()
+			return
+		}
+
+		c.Next()
+	}
+}
+
+// MenuAccessKey is the gin context key holding whether CheckMenu found the caller linked to its menu
+const MenuAccessKey = "menu_access"
+
+// CheckMenu returns a middleware that records whether the caller's roles are linked to the master menu
+// identified by kodeMenu without rejecting anyone, for routes serving both staff and the residents
+// concerned. Handlers read it with HasMenuAccess. It must run after Auth.
+func CheckMenu(menuService service.MenuService, logger *logger.Logger, kodeMenu string) gin.HandlerFunc {
+	return func(c *gin.Context) {
+		user, ok := GetAuthUser(c)
+		if !ok {
+			utils.UnauthorizedResponse(c, "Authentication required")
+			c.Abort()
+			return
+		}
+
+		allowed, err := menuService.HasMenuAccess(user.RoleIDs(), kodeMenu)
+		if err != nil {
+			logger.WithError(err).WithFields(map[string]interface{}{
+				"user_id":   user.ID,
+				"kode_menu": kodeMenu,
+			}).Error("Failed to check menu access")
+			utils.InternalServerErrorResponse(c, "Failed to check permissions", err)
+			c.Abort()
+			return
+		}
+
+		c.Set(MenuAccessKey, allowed)
+		c.Next()
+	}
+}
+
+// HasMenuAccess reports whether CheckMenu found the caller linked to its menu
+func HasMenuAccess(c *gin.Context) bool {
+	return c.GetBool(MenuAccessKey)
+}
@@ -6,22 +6,45 @@ import (
 
 // Billing represents the billings table
 type Billing struct {
-	ID          uint       `json:"id" gorm:"primarykey"`
-	DocumentID  *string    `json:"document_id" gorm:"column:document_id"`
-	NamaBilling *string    `json:"nama_billing" gorm:"column:nama_billing"`
-	Keterangan  *string    `json:"keterangan" gorm:"column:keterangan"`
-	Bulan       *int       `json:"bulan" gorm:"column:bulan"`
-	Tahun       *int       `json:"tahun" gorm:"column:tahun"`
-	Nominal     *int64     `json:"nominal" gorm:"column:nominal"`
-	CreatedAt   *time.Time `json:"created_at"`
-	UpdatedAt   *time.Time `json:"updated_at"`
-	PublishedAt *time.Time `json:"published_at"`
-	CreatedByID *int       `json:"created_by_id"`
-	UpdatedByID *int       `json:"updated_by_id"`
-	Locale      *string    `json:"locale"`
+	ID                uint       `json:"id" gorm:"primarykey"`
+	DocumentID        *string    `json:"document_id" gorm:"column:document_id"`
+	NamaBilling       *string    `json:"nama_billing" gorm:"column:nama_billing"`
+	Keterangan        *string    `json:"keterangan" gorm:"column:keterangan"`
+	Bulan             *int       `json:"bulan" gorm:"column:bulan"`
+	Tahun             *int       `json:"tahun" gorm:"column:tahun"`
+	Nominal           *int64     `json:"nominal" gorm:"column:nominal"`
+	OutstandingAmount *int64     `json:"outstanding_amount" gorm:"column:outstanding_amount"`
+	SettingBillingID  *uint      `json:"setting_billing_id" gorm:"column:setting_billing_id"`
+	DueDate           *time.Time `json:"due_date" gorm:"column:due_date;type:date"`
+	CreatedAt         *time.Time `json:"created_at"`
+	UpdatedAt         *time.Time `json:"updated_at"`
+	PublishedAt       *time.Time `json:"published_at"`
+	CreatedByID       *int       `json:"created_by_id"`
+	UpdatedByID       *int       `json:"updated_by_id"`
+	Locale            *string    `json:"locale"`
 }
 
 // TableName sets the insert table name for Billing
 func (Billing) TableName() string {
 	return "billings"
 }
+
+// Outstanding returns what is left to pay of the billing. Billings from before balances were tracked
+// have no outstanding amount and owe their whole nominal until they are paid.
+func (b *Billing) Outstanding() int64 {
+	if b.OutstandingAmount != nil {
+		return *b.OutstandingAmount
+	}
+	if b.Nominal != nil {
+		return *b.Nominal
+	}
+	return 0
+}
+
+// PaidAmount returns how much of the billing's nominal has been paid
+func (b *Billing) PaidAmount() int64 {
+	if b.Nominal == nil {
+		return 0
+	}
+	return *b.Nominal - b.Outstanding()
+}
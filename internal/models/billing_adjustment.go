@@ -0,0 +1,22 @@
+package models
+
+import (
+	"time"
+)
+
+// BillingAdjustment records a correction of a billing's nominal. The billing carries NewNominal from then
+// on, OriginalNominal keeps what it was before.
+type BillingAdjustment struct {
+	ID              uint      `json:"id" gorm:"primarykey"`
+	BillingID       uint      `json:"billing_id" gorm:"column:billing_id;not null;index"`
+	OriginalNominal int64     `json:"original_nominal" gorm:"column:original_nominal;not null"`
+	NewNominal      int64     `json:"new_nominal" gorm:"column:new_nominal;not null"`
+	Reason          string    `json:"reason" gorm:"column:reason;type:text;not null"`
+	ActorID         *uint     `json:"actor_id" gorm:"column:actor_id"`
+	CreatedAt       time.Time `json:"created_at"`
+}
+
+// TableName sets the insert table name for BillingAdjustment
+func (BillingAdjustment) TableName() string {
+	return "billing_adjustments"
+}
@@ -0,0 +1,31 @@
+package models
+
+import (
+	"time"
+)
+
+// Installment statuses, worked out from the outstanding balance of their billing
+const (
+	InstallmentStatusUnpaid        = "unpaid"
+	InstallmentStatusPartiallyPaid = "partially_paid"
+	InstallmentStatusPaid          = "paid"
+)
+
+// BillingInstallment is one part of the schedule a billing's outstanding balance is paid in. Payments are
+// counted towards the installments in sequence, so PaidAmount and Status are not stored.
+type BillingInstallment struct {
+	ID         uint      `json:"id" gorm:"primarykey"`
+	BillingID  uint      `json:"billing_id" gorm:"column:billing_id;not null;index"`
+	Sequence   int       `json:"sequence" gorm:"column:sequence;not null"`
+	Amount     int64     `json:"amount" gorm:"column:amount;not null"`
+	DueDate    time.Time `json:"due_date" gorm:"column:due_date;type:date;not null"`
+	ActorID    *uint     `json:"actor_id" gorm:"column:actor_id"`
+	CreatedAt  time.Time `json:"created_at"`
+	PaidAmount int64     `json:"paid_amount" gorm:"-"`
+	Status     string    `json:"status" gorm:"-"`
+}
+
+// TableName sets the insert table name for BillingInstallment
+func (BillingInstallment) TableName() string {
+	return "billing_installments"
+}
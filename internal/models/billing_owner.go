@@ -0,0 +1,45 @@
+package models
+
+import (
+	"strings"
+)
+
+// BillingOwner represents the resident a billing belongs to, resolved through
+// billings_profile_id_lnk -> up_users -> profiles. RoleType is the user's first role, RoleTypes lists
+// every role separated by commas.
+type BillingOwner struct {
+	BillingID    uint   `json:"billing_id" gorm:"column:billing_id"`
+	UserID       uint   `json:"user_id" gorm:"column:user_id"`
+	ProfileID    *uint  `json:"profile_id" gorm:"column:profile_id"`
+	Username     string `json:"username" gorm:"column:username"`
+	Email        string `json:"email" gorm:"column:email"`
+	NamaPenghuni string `json:"nama_penghuni" gorm:"column:nama_penghuni"`
+	NoHP         string `json:"no_hp" gorm:"column:no_hp"`
+	NoTelp       string `json:"no_telp" gorm:"column:no_telp"`
+	RoleType     string `json:"role_type" gorm:"column:role_type"`
+	RoleTypes    string `json:"-" gorm:"column:role_types"`
+}
+
+// Roles returns every role type of the owner
+func (o *BillingOwner) Roles() []string {
+	if o.RoleTypes == "" {
+		return nil
+	}
+	return strings.Split(o.RoleTypes, ",")
+}
+
+// CustomerName prefers the resident name from the profile over the account username
+func (o *BillingOwner) CustomerName() string {
+	if o.NamaPenghuni != "" {
+		return o.NamaPenghuni
+	}
+	return o.Username
+}
+
+// CustomerPhone prefers the mobile number over the landline
+func (o *BillingOwner) CustomerPhone() string {
+	if o.NoHP != "" {
+		return o.NoHP
+	}
+	return o.NoTelp
+}
@@ -0,0 +1,65 @@
+package models
+
+import (
+	"time"
+)
+
+// Penalty rule modes
+const (
+	PenaltyModeFlat       = "flat"
+	PenaltyModePercentage = "percentage"
+)
+
+// BillingPenaltyRule is the late fee charged on overdue billings generated from a setting billing.
+// Flat charges Amount and percentage charges Percentage of the billing nominal for every month late,
+// capped at Cap when it is set.
+type BillingPenaltyRule struct {
+	ID               uint      `json:"id" gorm:"primarykey"`
+	SettingBillingID uint      `json:"setting_billing_id" gorm:"column:setting_billing_id;not null;uniqueIndex"`
+	Mode             string    `json:"mode" gorm:"column:mode;size:32;not null"`
+	Amount           int64     `json:"amount" gorm:"column:amount"`
+	Percentage       float64   `json:"percentage" gorm:"column:percentage"`
+	Cap              int64     `json:"cap" gorm:"column:cap"`
+	IsActive         bool      `json:"is_active" gorm:"column:is_active;not null;default:true"`
+	CreatedAt        time.Time `json:"created_at"`
+	UpdatedAt        time.Time `json:"updated_at"`
+}
+
+// TableName sets the insert table name for BillingPenaltyRule
+func (BillingPenaltyRule) TableName() string {
+	return "billing_penalty_rules"
+}
+
+// BillingPenalty links an overdue billing to the penalty billing carrying its late fee. The penalty
+// billing's nominal follows Amount until the penalty is waived or either billing is paid.
+type BillingPenalty struct {
+	ID               uint       `json:"id" gorm:"primarykey"`
+	BillingID        uint       `json:"billing_id" gorm:"column:billing_id;not null;uniqueIndex"`
+	PenaltyBillingID uint       `json:"penalty_billing_id" gorm:"column:penalty_billing_id;not null;uniqueIndex"`
+	RuleID           uint       `json:"rule_id" gorm:"column:rule_id;not null"`
+	MonthsLate       int        `json:"months_late" gorm:"column:months_late"`
+	Amount           int64      `json:"amount" gorm:"column:amount"`
+	WaivedAt         *time.Time `json:"waived_at" gorm:"column:waived_at"`
+	WaivedReason     *string    `json:"waived_reason" gorm:"column:waived_reason;type:text"`
+	WaivedByID       *uint      `json:"waived_by_id" gorm:"column:waived_by_id"`
+	CreatedAt        time.Time  `json:"created_at"`
+	UpdatedAt        time.Time  `json:"updated_at"`
+}
+
+// TableName sets the insert table name for BillingPenalty
+func (BillingPenalty) TableName() string {
+	return "billing_penalties"
+}
+
+// PenaltyCandidate is an overdue billing from a setting with an active penalty rule
+type PenaltyCandidate struct {
+	BillingID        uint
+	UserID           uint
+	SettingBillingID uint
+	NamaBilling      *string
+	Bulan            *int
+	Tahun            *int
+	Nominal          int64
+	DueDate          time.Time
+	KategoriID       *uint
+}
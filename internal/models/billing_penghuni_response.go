@@ -17,5 +17,5 @@ type BillingPenghuniResponse struct {
 	StatusBilling string `json:"status_billing" example:"Belum Dibayar"` // Billing status
 	Bulan         string `json:"bulan" example:"November"`               // Month name
 	Tahun         int    `json:"tahun" example:"2025"`                   // Year
-	BillingIDs    []uint `json:"billings_id,omitempty" example:"10,11" // Related billing IDs for the user/period`
+	BillingIDs    []uint `json:"billings_id,omitempty" example:"10,11"`  // Related billing IDs for the user/period
 }
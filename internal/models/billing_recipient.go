@@ -0,0 +1,12 @@
+package models
+
+// BillingRecipient represents a user bulk billing generation may bill, with the profile
+// the billing would be shown under. Resident reports whether the user has the penghuni role.
+type BillingRecipient struct {
+	UserID       uint   `json:"user_id" gorm:"column:user_id"`
+	Username     string `json:"username" gorm:"column:username"`
+	Blocked      bool   `json:"blocked" gorm:"column:blocked"`
+	ProfileID    *uint  `json:"profile_id" gorm:"column:profile_id"`
+	NamaPenghuni string `json:"nama_penghuni" gorm:"column:nama_penghuni"`
+	Resident     bool   `json:"resident" gorm:"column:resident"`
+}
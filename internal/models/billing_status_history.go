@@ -0,0 +1,36 @@
+package models
+
+import (
+	"time"
+)
+
+// BillingStatusHistory records a billing moving to a status. FromStatusID is nil for the status a
+// billing was created in.
+type BillingStatusHistory struct {
+	ID           uint      `json:"id" gorm:"primarykey"`
+	BillingID    uint      `json:"billing_id" gorm:"column:billing_id;not null;index"`
+	FromStatusID *uint     `json:"from_status_id" gorm:"column:from_status_id"`
+	ToStatusID   uint      `json:"to_status_id" gorm:"column:to_status_id;not null"`
+	ActorID      *uint     `json:"actor_id" gorm:"column:actor_id"`
+	Reason       *string   `json:"reason" gorm:"column:reason;type:text"`
+	CreatedAt    time.Time `json:"created_at"`
+}
+
+// TableName sets the insert table name for BillingStatusHistory
+func (BillingStatusHistory) TableName() string {
+	return "billing_status_histories"
+}
+
+// BillingStatusHistoryEntry is a status history row with its status names and actor
+type BillingStatusHistoryEntry struct {
+	ID             uint      `json:"id"`
+	BillingID      uint      `json:"billing_id"`
+	FromStatusID   *uint     `json:"from_status_id"`
+	FromStatusName *string   `json:"from_status_name"`
+	ToStatusID     uint      `json:"to_status_id"`
+	ToStatusName   *string   `json:"to_status_name"`
+	ActorID        *uint     `json:"actor_id"`
+	ActorUsername  *string   `json:"actor_username"`
+	Reason         *string   `json:"reason"`
+	CreatedAt      time.Time `json:"created_at"`
+}
@@ -0,0 +1,62 @@
+package models
+
+import (
+	"time"
+)
+
+// Bulk billing job kinds
+const (
+	BulkBillingJobKindMonthly = "monthly"
+	BulkBillingJobKindCustom  = "custom"
+)
+
+// Bulk billing job statuses
+const (
+	BulkBillingJobStatusQueued    = "queued"
+	BulkBillingJobStatusRunning   = "running"
+	BulkBillingJobStatusCompleted = "completed"
+	BulkBillingJobStatusFailed    = "failed"
+)
+
+// BulkBillingJob represents a bulk billing generation running in the background
+type BulkBillingJob struct {
+	ID               uint                     `json:"id" gorm:"primarykey"`
+	Kind             string                   `json:"kind" gorm:"column:kind;size:16;not null"`
+	SettingBillingID *uint                    `json:"setting_billing_id" gorm:"column:setting_billing_id"`
+	UserIDs          string                   `json:"user_ids" gorm:"column:user_ids;type:text"`
+	Bulan            int                      `json:"bulan" gorm:"column:bulan"`
+	Tahun            int                      `json:"tahun" gorm:"column:tahun"`
+	Status           string                   `json:"status" gorm:"column:status;size:16;not null;index"`
+	TotalUsers       int                      `json:"total_users" gorm:"column:total_users"`
+	ProcessedUsers   int                      `json:"processed_users" gorm:"column:processed_users"`
+	TotalBillings    int                      `json:"total_billings" gorm:"column:total_billings"`
+	SuccessCount     int                      `json:"success_count" gorm:"column:success_count"`
+	FailedCount      int                      `json:"failed_count" gorm:"column:failed_count"`
+	SkippedCount     int                      `json:"skipped_count" gorm:"column:skipped_count"`
+	Error            *string                  `json:"error" gorm:"column:error;type:text"`
+	CreatedByID      *uint                    `json:"created_by_id" gorm:"column:created_by_id"`
+	StartedAt        *time.Time               `json:"started_at" gorm:"column:started_at"`
+	FinishedAt       *time.Time               `json:"finished_at" gorm:"column:finished_at"`
+	CreatedAt        time.Time                `json:"created_at"`
+	UpdatedAt        time.Time                `json:"updated_at"`
+	Failures         []*BulkBillingJobFailure `json:"failures" gorm:"foreignKey:JobID"`
+}
+
+// TableName sets the insert table name for BulkBillingJob
+func (BulkBillingJob) TableName() string {
+	return "bulk_billing_jobs"
+}
+
+// BulkBillingJobFailure records a resident whose billings a job could not create
+type BulkBillingJobFailure struct {
+	ID        uint      `json:"id" gorm:"primarykey"`
+	JobID     uint      `json:"job_id" gorm:"column:job_id;not null;index"`
+	UserID    uint      `json:"user_id" gorm:"column:user_id;not null"`
+	Error     string    `json:"error" gorm:"column:error;type:text"`
+	CreatedAt time.Time `json:"created_at"`
+}
+
+// TableName sets the insert table name for BulkBillingJobFailure
+func (BulkBillingJobFailure) TableName() string {
+	return "bulk_billing_job_failures"
+}
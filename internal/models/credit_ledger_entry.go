@@ -0,0 +1,31 @@
+package models
+
+import (
+	"time"
+)
+
+// Credit ledger entry types
+const (
+	CreditEntryDeposit     = "deposit"
+	CreditEntryOverpayment = "overpayment"
+	CreditEntryApplied     = "applied"
+)
+
+// CreditLedgerEntry is a movement of a resident's credit balance. Deposits and overpayments add to it and
+// credit applied to billings takes from it, so the balance of a profile is the sum of its amounts.
+type CreditLedgerEntry struct {
+	ID          uint      `json:"id" gorm:"primarykey"`
+	ProfileID   uint      `json:"profile_id" gorm:"column:profile_id;not null;index"`
+	Type        string    `json:"type" gorm:"column:type;size:32;not null"`
+	Amount      int64     `json:"amount" gorm:"column:amount;not null"`
+	BillingID   *uint     `json:"billing_id" gorm:"column:billing_id;index"`
+	PaymentID   *uint     `json:"payment_id" gorm:"column:payment_id;index"`
+	Description string    `json:"description" gorm:"column:description;type:text"`
+	ActorID     *uint     `json:"actor_id" gorm:"column:actor_id"`
+	CreatedAt   time.Time `json:"created_at"`
+}
+
+// TableName sets the insert table name for CreditLedgerEntry
+func (CreditLedgerEntry) TableName() string {
+	return "credit_ledger_entries"
+}
@@ -0,0 +1,32 @@
+package models
+
+import (
+	"time"
+)
+
+// Job run statuses
+const (
+	JobRunStatusRunning   = "running"
+	JobRunStatusSucceeded = "succeeded"
+	JobRunStatusFailed    = "failed"
+)
+
+// JobRun records one execution of a scheduled background job for a period
+type JobRun struct {
+	ID         uint       `json:"id" gorm:"primarykey"`
+	Job        string     `json:"job" gorm:"column:job;size:64;not null;index:idx_job_runs_job_period"`
+	Period     string     `json:"period" gorm:"column:period;size:32;not null;index:idx_job_runs_job_period"`
+	Trigger    string     `json:"trigger" gorm:"column:trigger;size:32"`
+	Status     string     `json:"status" gorm:"column:status;size:32;not null"`
+	Result     *string    `json:"result" gorm:"column:result;type:text"`
+	Error      *string    `json:"error" gorm:"column:error;type:text"`
+	StartedAt  time.Time  `json:"started_at" gorm:"column:started_at;not null"`
+	FinishedAt *time.Time `json:"finished_at" gorm:"column:finished_at"`
+	CreatedAt  time.Time  `json:"created_at"`
+	UpdatedAt  time.Time  `json:"updated_at"`
+}
+
+// TableName sets the insert table name for JobRun
+func (JobRun) TableName() string {
+	return "job_runs"
+}
@@ -0,0 +1,53 @@
+package models
+
+import (
+	"time"
+)
+
+// Payment statuses
+const (
+	PaymentStatusPending     = "pending"
+	PaymentStatusPaid        = "paid"
+	PaymentStatusNeedsReview = "needs_review"
+	PaymentStatusExpired     = "expired"
+	PaymentStatusFailed      = "failed"
+	PaymentStatusRefunding   = "refunding"
+	PaymentStatusRefunded    = "refunded"
+	PaymentStatusVoided      = "voided"
+)
+
+// Payment represents a payment attempt made through a payment gateway for one or more billings
+type Payment struct {
+	ID            uint                  `json:"id" gorm:"primarykey"`
+	Gateway       string                `json:"gateway" gorm:"column:gateway;size:32;not null;index"`
+	Reference     *string               `json:"reference" gorm:"column:reference;size:36;uniqueIndex"`
+	InvoiceID     *string               `json:"invoice_id" gorm:"column:invoice_id;size:128;index"`
+	TransactionID *string               `json:"transaction_id" gorm:"column:transaction_id;size:128;index"`
+	Amount        int64                 `json:"amount" gorm:"column:amount;not null"`
+	AdminFee      int64                 `json:"admin_fee" gorm:"column:admin_fee;not null;default:0"`
+	AdminFeeRule  string                `json:"admin_fee_rule" gorm:"column:admin_fee_rule;size:32"`
+	Status        string                `json:"status" gorm:"column:status;size:32;not null;index"`
+	PaymentMethod *string               `json:"payment_method" gorm:"column:payment_method;size:64"`
+	PaymentURL    *string               `json:"payment_url" gorm:"column:payment_url;type:text"`
+	Description   *string               `json:"description" gorm:"column:description;type:text"`
+	ExpiredAt     *time.Time            `json:"expired_at" gorm:"column:expired_at"`
+	PaidAt        *time.Time            `json:"paid_at" gorm:"column:paid_at"`
+	ReconciledAt  *time.Time            `json:"reconciled_at" gorm:"column:reconciled_at"`
+	RefundID      *string               `json:"refund_id" gorm:"column:refund_id;size:128"`
+	RefundAmount  int64                 `json:"refund_amount" gorm:"column:refund_amount;not null;default:0"`
+	RefundReason  *string               `json:"refund_reason" gorm:"column:refund_reason;type:text"`
+	RefundedAt    *time.Time            `json:"refunded_at" gorm:"column:refunded_at"`
+	CreatedAt     time.Time             `json:"created_at"`
+	UpdatedAt     time.Time             `json:"updated_at"`
+	Billings      []*PaymentBillingLink `json:"billings,omitempty" gorm:"foreignKey:PaymentID"`
+}
+
+// TableName sets the insert table name for Payment
+func (Payment) TableName() string {
+	return "payments"
+}
+
+// TotalAmount returns the amount charged to the resident including the admin fee
+func (p *Payment) TotalAmount() int64 {
+	return p.Amount + p.AdminFee
+}
@@ -0,0 +1,17 @@
+package models
+
+import (
+	"time"
+)
+
+// PaymentApplication records that a payment was taken off its billings' outstanding balances. It is
+// written in the same transaction as the balances, so a payment is applied at most once.
+type PaymentApplication struct {
+	PaymentID uint      `json:"payment_id" gorm:"column:payment_id;primaryKey;autoIncrement:false"`
+	CreatedAt time.Time `json:"created_at"`
+}
+
+// TableName sets the insert table name for PaymentApplication
+func (PaymentApplication) TableName() string {
+	return "payment_applications"
+}
@@ -0,0 +1,14 @@
+package models
+
+// PaymentBillingLink represents the payments_billing_lnk table
+type PaymentBillingLink struct {
+	ID        uint  `json:"id" gorm:"primarykey"`
+	PaymentID uint  `json:"payment_id" gorm:"column:payment_id;not null;index"`
+	BillingID uint  `json:"t_billing_id" gorm:"column:t_billing_id;not null;index"`
+	Amount    int64 `json:"amount" gorm:"column:amount;not null"`
+}
+
+// TableName sets the insert table name for PaymentBillingLink
+func (PaymentBillingLink) TableName() string {
+	return "payments_billing_lnk"
+}
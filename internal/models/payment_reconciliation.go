@@ -0,0 +1,45 @@
+package models
+
+import (
+	"time"
+)
+
+// PaymentReconciliationRun records one pass of the reconciler over pending gateway invoices
+type PaymentReconciliationRun struct {
+	ID         uint                           `json:"id" gorm:"primarykey"`
+	StartedAt  time.Time                      `json:"started_at" gorm:"column:started_at;not null;index"`
+	FinishedAt *time.Time                     `json:"finished_at" gorm:"column:finished_at"`
+	Checked    int                            `json:"checked" gorm:"column:checked"`
+	Changed    int                            `json:"changed" gorm:"column:changed"`
+	Failed     int                            `json:"failed" gorm:"column:failed"`
+	Error      *string                        `json:"error" gorm:"column:error;type:text"`
+	Changes    []*PaymentReconciliationChange `json:"changes,omitempty" gorm:"foreignKey:RunID"`
+}
+
+// TableName sets the insert table name for PaymentReconciliationRun
+func (PaymentReconciliationRun) TableName() string {
+	return "payment_reconciliation_runs"
+}
+
+// PaymentReconciliationChange records a payment whose status a reconciliation run changed,
+// or could not check
+type PaymentReconciliationChange struct {
+	ID             uint      `json:"id" gorm:"primarykey"`
+	RunID          uint      `json:"run_id" gorm:"column:run_id;not null;index"`
+	PaymentID      uint      `json:"payment_id" gorm:"column:payment_id;not null;index"`
+	Gateway        string    `json:"gateway" gorm:"column:gateway;size:32"`
+	InvoiceID      string    `json:"invoice_id" gorm:"column:invoice_id;size:128"`
+	BillingIDs     string    `json:"billing_ids" gorm:"column:billing_ids;type:text"`
+	PreviousStatus string    `json:"previous_status" gorm:"column:previous_status;size:32"`
+	Status         string    `json:"status" gorm:"column:status;size:32"`
+	GatewayStatus  string    `json:"gateway_status" gorm:"column:gateway_status;size:64"`
+	Action         string    `json:"action" gorm:"column:action;size:32"`
+	ReviewID       *uint     `json:"review_id" gorm:"column:review_id"`
+	Error          *string   `json:"error" gorm:"column:error;type:text"`
+	CreatedAt      time.Time `json:"created_at"`
+}
+
+// TableName sets the insert table name for PaymentReconciliationChange
+func (PaymentReconciliationChange) TableName() string {
+	return "payment_reconciliation_changes"
+}
@@ -0,0 +1,37 @@
+package models
+
+import (
+	"time"
+)
+
+// Payment review statuses
+const (
+	PaymentReviewStatusOpen      = "open"
+	PaymentReviewStatusApproved  = "approved"
+	PaymentReviewStatusDismissed = "dismissed"
+)
+
+// PaymentReview represents a gateway payment that could not be confirmed automatically
+// (amount mismatch, partial payment, unknown or already paid billings) and needs an admin decision
+type PaymentReview struct {
+	ID             uint       `json:"id" gorm:"primarykey"`
+	Gateway        string     `json:"gateway" gorm:"column:gateway;size:32;not null"`
+	WebhookEventID *uint      `json:"webhook_event_id" gorm:"column:webhook_event_id"`
+	PaymentID      *uint      `json:"payment_id" gorm:"column:payment_id;index"`
+	TransactionID  string     `json:"transaction_id" gorm:"column:transaction_id;size:128;index"`
+	BillingIDs     string     `json:"billing_ids" gorm:"column:billing_ids;type:text"`
+	ExpectedAmount int64      `json:"expected_amount" gorm:"column:expected_amount"`
+	PaidAmount     int64      `json:"paid_amount" gorm:"column:paid_amount"`
+	Reason         string     `json:"reason" gorm:"column:reason;type:text"`
+	Status         string     `json:"status" gorm:"column:status;size:32;not null;index"`
+	Note           *string    `json:"note" gorm:"column:note;type:text"`
+	ResolvedByID   *uint      `json:"resolved_by_id" gorm:"column:resolved_by_id"`
+	ResolvedAt     *time.Time `json:"resolved_at" gorm:"column:resolved_at"`
+	CreatedAt      time.Time  `json:"created_at"`
+	UpdatedAt      time.Time  `json:"updated_at"`
+}
+
+// TableName sets the insert table name for PaymentReview
+func (PaymentReview) TableName() string {
+	return "payment_reviews"
+}
@@ -0,0 +1,24 @@
+package models
+
+import (
+	"time"
+)
+
+// PaymentWebhookEvent records every accepted payment gateway webhook delivery so
+// replayed deliveries can be acknowledged without being applied twice
+type PaymentWebhookEvent struct {
+	ID            uint       `json:"id" gorm:"primarykey"`
+	Gateway       string     `json:"gateway" gorm:"column:gateway;size:32;not null"`
+	EventKey      string     `json:"event_key" gorm:"column:event_key;size:255;not null;uniqueIndex"`
+	Event         string     `json:"event" gorm:"column:event;size:64"`
+	TransactionID string     `json:"transaction_id" gorm:"column:transaction_id;size:128;index"`
+	Status        string     `json:"status" gorm:"column:status;size:64"`
+	Payload       string     `json:"payload" gorm:"column:payload;type:text"`
+	ReceivedAt    time.Time  `json:"received_at" gorm:"column:received_at"`
+	ProcessedAt   *time.Time `json:"processed_at" gorm:"column:processed_at"`
+}
+
+// TableName sets the insert table name for PaymentWebhookEvent
+func (PaymentWebhookEvent) TableName() string {
+	return "payment_webhook_events"
+}
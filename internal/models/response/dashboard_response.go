@@ -1,20 +1,27 @@
 package response
 
+import "time"
+
 // DashboardStatisticsResponse represents dashboard statistics response
 type DashboardStatisticsResponse struct {
-	BelumBayar int `json:"belum_bayar" example:"5"`
-	SudahBayar int `json:"sudah_bayar" example:"10"`
-	Total      int `json:"total" example:"20"`
+	BelumBayar       int   `json:"belum_bayar" example:"5"`
+	SudahBayar       int   `json:"sudah_bayar" example:"10"`
+	Terlambat        int   `json:"terlambat" example:"3"`
+	SebagianBayar    int   `json:"sebagian_bayar" example:"2"`
+	Total            int   `json:"total" example:"20"`
+	TotalCollected   int64 `json:"total_collected" example:"1500000"`
+	TotalOutstanding int64 `json:"total_outstanding" example:"500000"`
 }
 
 // BillingListItem represents a single billing item in the list
 type BillingListItem struct {
-	Nominal      float64 `json:"nominal" example:"100000"`
-	Bulan        int     `json:"bulan" example:"12"`
-	Tahun        int     `json:"tahun" example:"2025"`
-	StatusName   string  `json:"status_name" example:"Lunas"`
-	RT           int     `json:"rt" example:"8"`
-	NamaPenghuni string  `json:"nama_penghuni" example:"John Doe"`
+	Nominal      float64    `json:"nominal" example:"100000"`
+	Bulan        int        `json:"bulan" example:"12"`
+	Tahun        int        `json:"tahun" example:"2025"`
+	DueDate      *time.Time `json:"due_date" example:"2025-12-10T00:00:00Z"`
+	StatusName   string     `json:"status_name" example:"Lunas"`
+	RT           int        `json:"rt" example:"8"`
+	NamaPenghuni string     `json:"nama_penghuni" example:"John Doe"`
 }
 
 // ProfileBillingResponse represents profile billing data
@@ -28,21 +35,28 @@ type ProfileBillingResponse struct {
 
 // BillingByProfileResponse represents billing data by profile ID
 type BillingByProfileResponse struct {
-	ID          uint   `json:"id" example:"1"`
-	ProfileID   uint   `json:"profile_id" example:"654"`
-	NamaBilling string `json:"nama_billing" example:"Iuran Bulanan"`
-	Bulan       int    `json:"bulan" example:"1"`
-	Tahun       int    `json:"tahun" example:"2026"`
-	Nominal     int    `json:"nominal" example:"100000"`
-	StatusID    uint   `json:"status_id" example:"2"`
-	StatusName  string `json:"status_name" example:"Belum Dibayar"`
-	Keterangan  string `json:"keterangan" example:"Iuran wajib bulanan"`
+	ID          uint       `json:"id" example:"1"`
+	ProfileID   uint       `json:"profile_id" example:"654"`
+	NamaBilling string     `json:"nama_billing" example:"Iuran Bulanan"`
+	Bulan       int        `json:"bulan" example:"1"`
+	Tahun       int        `json:"tahun" example:"2026"`
+	Nominal     int        `json:"nominal" example:"100000"`
+	DueDate     *time.Time `json:"due_date" example:"2026-01-10T00:00:00Z"`
+	StatusID    uint       `json:"status_id" example:"2"`
+	StatusName  string     `json:"status_name" example:"Belum Dibayar"`
+	Keterangan  string     `json:"keterangan" example:"Iuran wajib bulanan"`
+	// PenaltyForBillingID is set on penalty billings to the overdue billing the late fee is charged on
+	PenaltyForBillingID *uint `json:"penalty_for_billing_id,omitempty" example:"12"`
 }
 
 // BillingStatisticsResponse represents billing statistics data
 type BillingStatisticsResponse struct {
-	TotalBilling      int64 `json:"total_billing" example:"10"`
-	TotalSudahDibayar int64 `json:"total_sudah_dibayar" example:"7"`
-	TotalBelumDibayar int64 `json:"total_belum_dibayar" example:"3"`
-	TotalNominal      int64 `json:"total_nominal" example:"1000000"`
+	TotalBilling         int64 `json:"total_billing" example:"10"`
+	TotalSudahDibayar    int64 `json:"total_sudah_dibayar" example:"7"`
+	TotalBelumDibayar    int64 `json:"total_belum_dibayar" example:"3"`
+	TotalTerlambat       int64 `json:"total_terlambat" example:"1"`
+	TotalSebagianDibayar int64 `json:"total_sebagian_dibayar" example:"2"`
+	TotalNominal         int64 `json:"total_nominal" example:"1000000"`
+	TotalCollected       int64 `json:"total_collected" example:"650000"`
+	TotalOutstanding     int64 `json:"total_outstanding" example:"350000"`
 }
@@ -13,6 +13,7 @@ type SettingBilling struct {
 	Keterangan   string     `json:"keterangan" gorm:"column:keterangan"`
 	JenisBilling string     `json:"jenis_billing" gorm:"column:jenis_billing"`
 	IsActive     *bool      `json:"is_active" gorm:"column:is_active"`
+	DueDay       *int       `json:"due_day" gorm:"column:due_day"` // Day of the billing month its billings are due
 	CreatedAt    time.Time  `json:"created_at"`
 	UpdatedAt    time.Time  `json:"updated_at"`
 	PublishedAt  *time.Time `json:"published_at"`
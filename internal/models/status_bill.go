@@ -4,6 +4,45 @@ import (
 	"time"
 )
 
+// Semantic states billings move through, resolved to master_general_statuses by name
+const (
+	BillingStateUnpaid              = "unpaid"
+	BillingStatePaid                = "paid"
+	BillingStateOverdue             = "overdue"
+	BillingStateCancelled           = "cancelled"
+	BillingStatePendingVerification = "pending_verification"
+	BillingStateRefunded            = "refunded"
+)
+
+// BillingStatusIDs holds the master_general_statuses ID of each billing state
+type BillingStatusIDs struct {
+	Unpaid              uint `json:"unpaid"`
+	Paid                uint `json:"paid"`
+	Overdue             uint `json:"overdue"`
+	Cancelled           uint `json:"cancelled"`
+	PendingVerification uint `json:"pending_verification"`
+	Refunded            uint `json:"refunded"`
+}
+
+// ID returns the status ID of a billing state, or 0 for an unknown state
+func (ids BillingStatusIDs) ID(state string) uint {
+	switch state {
+	case BillingStateUnpaid:
+		return ids.Unpaid
+	case BillingStatePaid:
+		return ids.Paid
+	case BillingStateOverdue:
+		return ids.Overdue
+	case BillingStateCancelled:
+		return ids.Cancelled
+	case BillingStatePendingVerification:
+		return ids.PendingVerification
+	case BillingStateRefunded:
+		return ids.Refunded
+	}
+	return 0
+}
+
 // MasterGeneralStatus represents the master_general_statuses table
 type MasterGeneralStatus struct {
 	ID                uint       `json:"id" gorm:"primarykey"`
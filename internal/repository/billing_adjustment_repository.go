@@ -0,0 +1,78 @@
+package repository
+
+import (
+	"time"
+
+	"ipl-be-svc/internal/models"
+
+	"gorm.io/gorm"
+	"gorm.io/gorm/clause"
+)
+
+// BillingAdjustmentRepository defines the interface for billing adjustment data operations
+type BillingAdjustmentRepository interface {
+	Create(adjustment *models.BillingAdjustment, check func(billing *models.Billing, statusID uint) error) error
+	GetByBillingID(billingID uint) ([]*models.BillingAdjustment, error)
+}
+
+// billingAdjustmentRepository implements BillingAdjustmentRepository
+type billingAdjustmentRepository struct {
+	db *gorm.DB
+}
+
+// NewBillingAdjustmentRepository creates a new instance of BillingAdjustmentRepository
+func NewBillingAdjustmentRepository(db *gorm.DB) BillingAdjustmentRepository {
+	return &billingAdjustmentRepository{
+		db: db,
+	}
+}
+
+// Create locks the billing and its status link and passes them to check. When it passes, the adjustment
+// is recorded with the billing's current nominal as its original and the billing takes the new nominal.
+// Its outstanding balance changes by the same difference and its installment plan, which no longer adds
+// up, is removed.
+func (r *billingAdjustmentRepository) Create(adjustment *models.BillingAdjustment, check func(billing *models.Billing, statusID uint) error) error {
+	return r.db.Transaction(func(tx *gorm.DB) error {
+		var billing models.Billing
+		if err := tx.Clauses(clause.Locking{Strength: "UPDATE"}).First(&billing, adjustment.BillingID).Error; err != nil {
+			return err
+		}
+
+		var link models.BillingStatusBillLink
+		if err := tx.Clauses(clause.Locking{Strength: "UPDATE"}).
+			Where("t_billing_id = ?", adjustment.BillingID).
+			First(&link).Error; err != nil {
+			return err
+		}
+
+		if err := check(&billing, link.MasterGeneralStatusID); err != nil {
+			return err
+		}
+
+		if billing.Nominal != nil {
+			adjustment.OriginalNominal = *billing.Nominal
+		}
+		if err := tx.Create(adjustment).Error; err != nil {
+			return err
+		}
+
+		if err := tx.Where("billing_id = ?", adjustment.BillingID).Delete(&models.BillingInstallment{}).Error; err != nil {
+			return err
+		}
+
+		return tx.Model(&models.Billing{}).
+			Where("id = ?", adjustment.BillingID).
+			Updates(map[string]interface{}{
+				"outstanding_amount": billing.Outstanding() + adjustment.NewNominal - adjustment.OriginalNominal,
+				"nominal":            adjustment.NewNominal,
+				"updated_at":         time.Now(),
+			}).Error
+	})
+}
+
+// GetByBillingID retrieves the adjustments of a billing, oldest first
+func (r *billingAdjustmentRepository) GetByBillingID(billingID uint) ([]*models.BillingAdjustment, error) {
+	var adjustments []*models.BillingAdjustment
+	err := r.db.Where("billing_id = ?", billingID).Order("created_at, id").Find(&adjustments).Error
+	return adjustments, err
+}
@@ -0,0 +1,61 @@
+package repository
+
+import (
+	"ipl-be-svc/internal/models"
+
+	"gorm.io/gorm"
+)
+
+// BillingInstallmentRepository defines the interface for billing installment plan data operations
+type BillingInstallmentRepository interface {
+	ReplacePlan(billingID uint, installments []*models.BillingInstallment, check func(billing *models.Billing, statusID uint) error) error
+	GetByBillingID(billingID uint) ([]*models.BillingInstallment, error)
+	GetByID(id uint) (*models.BillingInstallment, error)
+}
+
+// billingInstallmentRepository implements BillingInstallmentRepository
+type billingInstallmentRepository struct {
+	db *gorm.DB
+}
+
+// NewBillingInstallmentRepository creates a new instance of BillingInstallmentRepository
+func NewBillingInstallmentRepository(db *gorm.DB) BillingInstallmentRepository {
+	return &billingInstallmentRepository{
+		db: db,
+	}
+}
+
+// ReplacePlan locks the billing and its status link and passes them to check. When it passes, the
+// billing's installment plan is replaced by the installments.
+func (r *billingInstallmentRepository) ReplacePlan(billingID uint, installments []*models.BillingInstallment, check func(billing *models.Billing, statusID uint) error) error {
+	return r.db.Transaction(func(tx *gorm.DB) error {
+		if err := lockBilling(tx, billingID, check); err != nil {
+			return err
+		}
+
+		if err := tx.Where("billing_id = ?", billingID).Delete(&models.BillingInstallment{}).Error; err != nil {
+			return err
+		}
+
+		return tx.Create(installments).Error
+	})
+}
+
+// GetByBillingID retrieves the installment plan of a billing in sequence
+func (r *billingInstallmentRepository) GetByBillingID(billingID uint) ([]*models.BillingInstallment, error) {
+	var installments []*models.BillingInstallment
+	err := r.db.Where("billing_id = ?", billingID).Order("sequence").Find(&installments).Error
+	return installments, err
+}
+
+// GetByID retrieves an installment by ID
+func (r *billingInstallmentRepository) GetByID(id uint) (*models.BillingInstallment, error) {
+	var installment models.BillingInstallment
+
+	err := r.db.Where("id = ?", id).First(&installment).Error
+	if err != nil {
+		return nil, err
+	}
+
+	return &installment, nil
+}
@@ -0,0 +1,215 @@
+package repository
+
+import (
+	"time"
+
+	"ipl-be-svc/internal/models"
+
+	"gorm.io/gorm"
+)
+
+// BillingPenaltyRepository defines the interface for late fee data operations
+type BillingPenaltyRepository interface {
+	ListRules() ([]*models.BillingPenaltyRule, error)
+	GetRuleBySettingID(settingBillingID uint) (*models.BillingPenaltyRule, error)
+	SaveRule(rule *models.BillingPenaltyRule) error
+	DeleteRule(settingBillingID uint) error
+	GetPenaltyCandidates(overdueStatusID uint) ([]*models.PenaltyCandidate, error)
+	GetByID(id uint) (*models.BillingPenalty, error)
+	GetByBillingID(billingID uint) (*models.BillingPenalty, error)
+	GetByBillingIDs(billingIDs []uint) (map[uint]*models.BillingPenalty, error)
+	GetOpenPenaltyBillings(billingIDs []uint, closedStatusIDs []uint) ([]*models.Billing, error)
+	Create(penalty *models.BillingPenalty, penaltyBilling *models.Billing, userID uint, statusID uint, kategoriID uint) error
+	UpdateAmount(penalty *models.BillingPenalty, keterangan string) error
+	Waive(penalty *models.BillingPenalty) error
+}
+
+// billingPenaltyRepository implements BillingPenaltyRepository
+type billingPenaltyRepository struct {
+	db *gorm.DB
+}
+
+// NewBillingPenaltyRepository creates a new instance of BillingPenaltyRepository
+func NewBillingPenaltyRepository(db *gorm.DB) BillingPenaltyRepository {
+	return &billingPenaltyRepository{
+		db: db,
+	}
+}
+
+// ListRules retrieves every penalty rule ordered by setting billing
+func (r *billingPenaltyRepository) ListRules() ([]*models.BillingPenaltyRule, error) {
+	var rules []*models.BillingPenaltyRule
+	err := r.db.Order("setting_billing_id").Find(&rules).Error
+	return rules, err
+}
+
+// GetRuleBySettingID retrieves the penalty rule of a setting billing
+func (r *billingPenaltyRepository) GetRuleBySettingID(settingBillingID uint) (*models.BillingPenaltyRule, error) {
+	var rule models.BillingPenaltyRule
+	err := r.db.Where("setting_billing_id = ?", settingBillingID).First(&rule).Error
+	if err != nil {
+		return nil, err
+	}
+	return &rule, nil
+}
+
+// SaveRule creates or updates a penalty rule
+func (r *billingPenaltyRepository) SaveRule(rule *models.BillingPenaltyRule) error {
+	return r.db.Save(rule).Error
+}
+
+// DeleteRule deletes the penalty rule of a setting billing
+func (r *billingPenaltyRepository) DeleteRule(settingBillingID uint) error {
+	result := r.db.Where("setting_billing_id = ?", settingBillingID).Delete(&models.BillingPenaltyRule{})
+	if result.Error != nil {
+		return result.Error
+	}
+	if result.RowsAffected == 0 {
+		return gorm.ErrRecordNotFound
+	}
+	return nil
+}
+
+// GetPenaltyCandidates retrieves the published billings in the overdue status whose setting billing has
+// an active penalty rule, with their resident and kategori
+func (r *billingPenaltyRepository) GetPenaltyCandidates(overdueStatusID uint) ([]*models.PenaltyCandidate, error) {
+	var candidates []*models.PenaltyCandidate
+
+	err := r.db.Table("billings b").
+		Select(`b.id AS billing_id, bpil.user_id, b.setting_billing_id, b.nama_billing, b.bulan, b.tahun,
+			b.nominal, b.due_date, MIN(bmktl.master_kategori_transaksi_id) AS kategori_id`).
+		Joins("JOIN billings_status_bill_lnk bsbl ON bsbl.t_billing_id = b.id").
+		Joins("JOIN billings_profile_id_lnk bpil ON bpil.t_billing_id = b.id").
+		Joins("JOIN billing_penalty_rules bpr ON bpr.setting_billing_id = b.setting_billing_id AND bpr.is_active").
+		Joins("LEFT JOIN billings_master_kategori_transaksi_lnk bmktl ON bmktl.t_billing_id = b.id").
+		Where("b.published_at IS NOT NULL AND b.due_date IS NOT NULL AND b.nominal > 0").
+		Where("bsbl.master_general_status_id = ?", overdueStatusID).
+		Group("b.id, bpil.user_id").
+		Order("b.id").
+		Scan(&candidates).Error
+	if err != nil {
+		return nil, err
+	}
+
+	return candidates, nil
+}
+
+// GetByID retrieves a penalty by ID
+func (r *billingPenaltyRepository) GetByID(id uint) (*models.BillingPenalty, error) {
+	var penalty models.BillingPenalty
+	err := r.db.First(&penalty, id).Error
+	if err != nil {
+		return nil, err
+	}
+	return &penalty, nil
+}
+
+// GetByBillingID retrieves the penalty charged on a billing
+func (r *billingPenaltyRepository) GetByBillingID(billingID uint) (*models.BillingPenalty, error) {
+	var penalty models.BillingPenalty
+	err := r.db.Where("billing_id = ?", billingID).First(&penalty).Error
+	if err != nil {
+		return nil, err
+	}
+	return &penalty, nil
+}
+
+// GetByBillingIDs retrieves the penalties charged on the billings, keyed by billing ID
+func (r *billingPenaltyRepository) GetByBillingIDs(billingIDs []uint) (map[uint]*models.BillingPenalty, error) {
+	penalties := make(map[uint]*models.BillingPenalty)
+	if len(billingIDs) == 0 {
+		return penalties, nil
+	}
+
+	var rows []*models.BillingPenalty
+	if err := r.db.Where("billing_id IN ?", billingIDs).Find(&rows).Error; err != nil {
+		return nil, err
+	}
+	for _, row := range rows {
+		penalties[row.BillingID] = row
+	}
+
+	return penalties, nil
+}
+
+// GetOpenPenaltyBillings retrieves the penalty billings charged on the billings that are not waived and not
+// in one of the closed statuses
+func (r *billingPenaltyRepository) GetOpenPenaltyBillings(billingIDs []uint, closedStatusIDs []uint) ([]*models.Billing, error) {
+	var billings []*models.Billing
+	if len(billingIDs) == 0 {
+		return billings, nil
+	}
+
+	err := r.db.Table("billings b").
+		Select("b.*").
+		Joins("JOIN billing_penalties bp ON bp.penalty_billing_id = b.id").
+		Joins("JOIN billings_status_bill_lnk bsbl ON bsbl.t_billing_id = b.id").
+		Where("bp.billing_id IN ? AND bp.waived_at IS NULL", billingIDs).
+		Where("b.published_at IS NOT NULL AND bsbl.master_general_status_id NOT IN ?", closedStatusIDs).
+		Order("b.id").
+		Find(&billings).Error
+	if err != nil {
+		return nil, err
+	}
+
+	return billings, nil
+}
+
+// Create creates the penalty billing with its resident, status and kategori links and initial status history,
+// and the penalty linking it
+func (r *billingPenaltyRepository) Create(penalty *models.BillingPenalty, penaltyBilling *models.Billing, userID uint, statusID uint, kategoriID uint) error {
+	return r.db.Transaction(func(tx *gorm.DB) error {
+		if err := tx.Create(penaltyBilling).Error; err != nil {
+			return err
+		}
+		if err := tx.Create(&models.BillingProfileLink{BillingID: penaltyBilling.ID, ProfileID: userID}).Error; err != nil {
+			return err
+		}
+		if err := tx.Create(&models.BillingStatusBillLink{BillingID: penaltyBilling.ID, MasterGeneralStatusID: statusID}).Error; err != nil {
+			return err
+		}
+		reason := "Late fee charged"
+		if err := tx.Create(&models.BillingStatusHistory{BillingID: penaltyBilling.ID, ToStatusID: statusID, Reason: &reason}).Error; err != nil {
+			return err
+		}
+		if err := tx.Create(&models.BillingKategoriTransaksiLink{BillingID: penaltyBilling.ID, MasterKategoriTransaksiID: kategoriID}).Error; err != nil {
+			return err
+		}
+
+		penalty.PenaltyBillingID = penaltyBilling.ID
+		return tx.Create(penalty).Error
+	})
+}
+
+// UpdateAmount saves the penalty's months late and amount and carries the amount to its penalty billing,
+// changing what is outstanding on it by the same difference
+func (r *billingPenaltyRepository) UpdateAmount(penalty *models.BillingPenalty, keterangan string) error {
+	return r.db.Transaction(func(tx *gorm.DB) error {
+		if err := tx.Save(penalty).Error; err != nil {
+			return err
+		}
+		return tx.Model(&models.Billing{}).
+			Where("id = ?", penalty.PenaltyBillingID).
+			Updates(map[string]interface{}{
+				"outstanding_amount": gorm.Expr("GREATEST(COALESCE(outstanding_amount, nominal) + ? - nominal, 0)", penalty.Amount),
+				"nominal":            penalty.Amount,
+				"keterangan":         keterangan,
+				"updated_at":         time.Now(),
+			}).Error
+	})
+}
+
+// Waive saves the waived penalty and unpublishes its penalty billing so it is no longer billed
+func (r *billingPenaltyRepository) Waive(penalty *models.BillingPenalty) error {
+	return r.db.Transaction(func(tx *gorm.DB) error {
+		if err := tx.Save(penalty).Error; err != nil {
+			return err
+		}
+		return tx.Model(&models.Billing{}).
+			Where("id = ?", penalty.PenaltyBillingID).
+			Updates(map[string]interface{}{
+				"published_at": nil,
+				"updated_at":   time.Now(),
+			}).Error
+	})
+}
@@ -5,23 +5,43 @@ import (
 	"ipl-be-svc/internal/models/response"
 	"strconv"
 	"strings"
+	"time"
 
 	"gorm.io/gorm"
+	"gorm.io/gorm/clause"
 )
 
 // BillingRepository defines the interface for billing data operations
 type BillingRepository interface {
 	GetBillingByID(id uint) (*models.Billing, error)
+	GetBillingsByIDs(ids []uint) ([]*models.Billing, error)
+	GetBillingStatusIDs(billingIDs []uint) (map[uint]uint, error)
+	GetBillingOwners(billingIDs []uint) ([]*models.BillingOwner, error)
+	GetBillingKategoriIDs(billingIDs []uint) (map[uint]uint, error)
+	GetKategoriTransaksiByID(id uint) (*models.MasterKategoriTransaksi, error)
+	GetStatusByID(id uint) (*models.MasterGeneralStatus, error)
+	CreateBilling(billing *models.Billing, userID uint, statusID uint, kategoriID uint, history *models.BillingStatusHistory) error
+	UpdateBilling(id uint, updates map[string]interface{}, kategoriID *uint, check func(billing *models.Billing, statusID uint) error) error
+	UnpublishBilling(id uint, check func(billing *models.Billing, statusID uint) error) error
+	ApplyPayment(paymentID uint, amounts map[uint]int64, check func(billing *models.Billing, statusID uint, amount int64) error, settle func(tx *gorm.DB, settled []uint) error) ([]uint, bool, error)
+	SettleBillings(billingIDs []uint) error
+	RestoreBalances(amounts map[uint]int64) error
+	HasOpenPayment(billingID uint) (bool, error)
 	GetBillingSettingsByID(id uint) (*models.SettingBilling, error)
 	GetUsersWithPenghuniRole() ([]*models.User, error)
 	GetActiveMonthlySettingBillings() ([]*models.SettingBilling, error)
+	GetBilledUserIDs(setting *models.SettingBilling, month int, year int) (map[uint]uint, error)
+	GetBillingRecipients(userIDs []uint) ([]*models.BillingRecipient, error)
+	GetProfileUserIDs(profileID uint) ([]uint, error)
+	BackfillDueDates(defaultDueDay int) (int64, error)
+	GetPastDueBillingIDs(unpaidStatusID uint, today time.Time) ([]uint, error)
 	CreateBulkBillings(billings []*models.Billing) error
 	CreateBulkBillingProfileLinks(links []*models.BillingProfileLink) error
 	GetBillingPenghuni(search string, page int, limit int) ([]*models.BillingPenghuniResponse, int64, error)
 	GetBillingPenghuniAll() ([]*models.BillingPenghuniResponse, error)
 	GetProfileBillingWithFilters(search string, bulan *int, tahun *int, rt *int, statusID *int, page int, limit int) ([]*response.ProfileBillingResponse, int64, error)
 	GetBillingByProfileID(profileID uint, bulan *int, tahun *int, statusID *int, rt *int, page int, limit int) ([]*response.BillingByProfileResponse, int64, error)
-	GetBillingStatistics(search string, bulan *int, tahun *int, rt *int, statusIDs []int) (*response.BillingStatisticsResponse, error)
+	GetBillingStatistics(search string, bulan *int, tahun *int, rt *int, statusIDs []int, overdue bool, statuses models.BillingStatusIDs) (*response.BillingStatisticsResponse, error)
 	// Note: attachment file operations are handled on disk (not persisted to DB)
 }
 
@@ -49,6 +69,336 @@ func (r *billingRepository) GetBillingByID(id uint) (*models.Billing, error) {
 	return &billing, nil
 }
 
+// GetBillingsByIDs retrieves published billing records by IDs
+func (r *billingRepository) GetBillingsByIDs(ids []uint) ([]*models.Billing, error) {
+	var billings []*models.Billing
+
+	if len(ids) == 0 {
+		return billings, nil
+	}
+
+	err := r.db.Where("id IN ? AND published_at IS NOT NULL", ids).Find(&billings).Error
+	if err != nil {
+		return nil, err
+	}
+
+	return billings, nil
+}
+
+// GetBillingStatusIDs retrieves the current master_general_status_id of each billing, keyed by billing ID
+func (r *billingRepository) GetBillingStatusIDs(billingIDs []uint) (map[uint]uint, error) {
+	statuses := make(map[uint]uint)
+
+	if len(billingIDs) == 0 {
+		return statuses, nil
+	}
+
+	var links []*models.BillingStatusBillLink
+	err := r.db.Where("t_billing_id IN ?", billingIDs).Find(&links).Error
+	if err != nil {
+		return nil, err
+	}
+
+	for _, link := range links {
+		statuses[link.BillingID] = link.MasterGeneralStatusID
+	}
+
+	return statuses, nil
+}
+
+// GetBillingOwners retrieves the resident owning each billing with all of their roles; billings without an
+// owner are omitted
+func (r *billingRepository) GetBillingOwners(billingIDs []uint) ([]*models.BillingOwner, error) {
+	var owners []*models.BillingOwner
+
+	if len(billingIDs) == 0 {
+		return owners, nil
+	}
+
+	query := `
+		select distinct on (bpil.t_billing_id)
+			   bpil.t_billing_id as billing_id, uu.id as user_id, uu.username, uu.email,
+			   p.id as profile_id, p.nama_penghuni, p.no_hp, p.no_telp,
+			   roles.role_type, roles.role_types
+		from billings_profile_id_lnk bpil
+		inner join up_users uu on uu.id = bpil.user_id
+		left join lateral (
+			select (array_agg(ur."type" order by ur.id))[1] as role_type,
+				   string_agg(ur."type", ',' order by ur.id) as role_types
+			from up_users_role_lnk uurl
+			inner join up_roles ur on ur.id = uurl.role_id
+			where uurl.user_id = uu.id
+		) roles on true
+		left join up_users_profile_lnk pul on pul.user_id = uu.id
+		left join profiles p on p.id = pul.profile_id and p.published_at IS NOT NULL
+		where bpil.t_billing_id IN ?
+		order by bpil.t_billing_id, p.id
+	`
+
+	err := r.db.Raw(query, billingIDs).Scan(&owners).Error
+	if err != nil {
+		return nil, err
+	}
+
+	return owners, nil
+}
+
+// GetBillingKategoriIDs retrieves the master_kategori_transaksi_id of each billing, keyed by billing ID
+func (r *billingRepository) GetBillingKategoriIDs(billingIDs []uint) (map[uint]uint, error) {
+	kategoriIDs := make(map[uint]uint)
+
+	if len(billingIDs) == 0 {
+		return kategoriIDs, nil
+	}
+
+	var links []*models.BillingKategoriTransaksiLink
+	err := r.db.Where("t_billing_id IN ?", billingIDs).Find(&links).Error
+	if err != nil {
+		return nil, err
+	}
+
+	for _, link := range links {
+		kategoriIDs[link.BillingID] = link.MasterKategoriTransaksiID
+	}
+
+	return kategoriIDs, nil
+}
+
+// GetKategoriTransaksiByID retrieves a master_kategori_transaksis record by ID
+func (r *billingRepository) GetKategoriTransaksiByID(id uint) (*models.MasterKategoriTransaksi, error) {
+	var kategori models.MasterKategoriTransaksi
+
+	err := r.db.Where("id = ?", id).First(&kategori).Error
+	if err != nil {
+		return nil, err
+	}
+
+	return &kategori, nil
+}
+
+// GetStatusByID retrieves a master_general_statuses record by ID
+func (r *billingRepository) GetStatusByID(id uint) (*models.MasterGeneralStatus, error) {
+	var status models.MasterGeneralStatus
+
+	err := r.db.Where("id = ?", id).First(&status).Error
+	if err != nil {
+		return nil, err
+	}
+
+	return &status, nil
+}
+
+// CreateBilling creates a billing with its profile, status and kategori transaksi links and the history
+// entry of the status it starts in, in one transaction
+func (r *billingRepository) CreateBilling(billing *models.Billing, userID uint, statusID uint, kategoriID uint, history *models.BillingStatusHistory) error {
+	return r.db.Transaction(func(tx *gorm.DB) error {
+		if err := tx.Create(billing).Error; err != nil {
+			return err
+		}
+
+		if err := tx.Create(&models.BillingProfileLink{BillingID: billing.ID, ProfileID: userID}).Error; err != nil {
+			return err
+		}
+
+		if err := tx.Create(&models.BillingStatusBillLink{BillingID: billing.ID, MasterGeneralStatusID: statusID}).Error; err != nil {
+			return err
+		}
+
+		if err := tx.Create(&models.BillingKategoriTransaksiLink{BillingID: billing.ID, MasterKategoriTransaksiID: kategoriID}).Error; err != nil {
+			return err
+		}
+
+		history.BillingID = billing.ID
+		history.ToStatusID = statusID
+		return tx.Create(history).Error
+	})
+}
+
+// UpdateBilling locks the billing and its status link and passes them to check. When it passes, the
+// billing takes the updates and, when kategoriID is set, moves to that kategori transaksi.
+func (r *billingRepository) UpdateBilling(id uint, updates map[string]interface{}, kategoriID *uint, check func(billing *models.Billing, statusID uint) error) error {
+	return r.db.Transaction(func(tx *gorm.DB) error {
+		if err := lockBilling(tx, id, check); err != nil {
+			return err
+		}
+
+		if kategoriID != nil {
+			if err := tx.Where("t_billing_id = ?", id).Delete(&models.BillingKategoriTransaksiLink{}).Error; err != nil {
+				return err
+			}
+			if err := tx.Create(&models.BillingKategoriTransaksiLink{BillingID: id, MasterKategoriTransaksiID: *kategoriID}).Error; err != nil {
+				return err
+			}
+		}
+
+		return tx.Model(&models.Billing{}).Where("id = ?", id).Updates(updates).Error
+	})
+}
+
+// UnpublishBilling locks the billing and its status link and passes them to check. When it passes, the
+// billing is unpublished, which hides it like a Strapi draft while keeping its links and history.
+func (r *billingRepository) UnpublishBilling(id uint, check func(billing *models.Billing, statusID uint) error) error {
+	return r.db.Transaction(func(tx *gorm.DB) error {
+		if err := lockBilling(tx, id, check); err != nil {
+			return err
+		}
+
+		return tx.Model(&models.Billing{}).
+			Where("id = ?", id).
+			Updates(map[string]interface{}{
+				"published_at": nil,
+				"updated_at":   time.Now(),
+			}).Error
+	})
+}
+
+// ApplyPayment locks the billings and their status links and passes each with the amount paid on it to
+// check. When all pass, the amounts are taken off their outstanding balances and the payment is recorded
+// as applied. The billings left with nothing outstanding are passed to settle in the same transaction and
+// their IDs are returned. Nothing is changed and false is returned when the payment was already applied.
+func (r *billingRepository) ApplyPayment(paymentID uint, amounts map[uint]int64, check func(billing *models.Billing, statusID uint, amount int64) error, settle func(tx *gorm.DB, settled []uint) error) ([]uint, bool, error) {
+	var settled []uint
+
+	if len(amounts) == 0 {
+		return settled, true, nil
+	}
+
+	billingIDs := make([]uint, 0, len(amounts))
+	for billingID := range amounts {
+		billingIDs = append(billingIDs, billingID)
+	}
+
+	applied := true
+	err := r.db.Transaction(func(tx *gorm.DB) error {
+		// A concurrent application of the same payment waits here and then finds it recorded
+		result := tx.Clauses(clause.OnConflict{DoNothing: true}).Create(&models.PaymentApplication{PaymentID: paymentID})
+		if result.Error != nil {
+			return result.Error
+		}
+		if result.RowsAffected == 0 {
+			applied = false
+			return nil
+		}
+
+		// Rows are locked in ID order so concurrent payments cannot deadlock
+		var billings []*models.Billing
+		if err := tx.Clauses(clause.Locking{Strength: "UPDATE"}).
+			Where("id IN ?", billingIDs).
+			Order("id").
+			Find(&billings).Error; err != nil {
+			return err
+		}
+		if len(billings) != len(billingIDs) {
+			return gorm.ErrRecordNotFound
+		}
+
+		var links []*models.BillingStatusBillLink
+		if err := tx.Clauses(clause.Locking{Strength: "UPDATE"}).
+			Where("t_billing_id IN ?", billingIDs).
+			Order("t_billing_id").
+			Find(&links).Error; err != nil {
+			return err
+		}
+		statusIDs := make(map[uint]uint, len(links))
+		for _, link := range links {
+			statusIDs[link.BillingID] = link.MasterGeneralStatusID
+		}
+
+		now := time.Now()
+		for _, billing := range billings {
+			amount := amounts[billing.ID]
+			if err := check(billing, statusIDs[billing.ID], amount); err != nil {
+				return err
+			}
+
+			outstanding := billing.Outstanding() - amount
+			if err := tx.Model(&models.Billing{}).
+				Where("id = ?", billing.ID).
+				Updates(map[string]interface{}{
+					"outstanding_amount": outstanding,
+					"updated_at":         now,
+				}).Error; err != nil {
+				return err
+			}
+			if outstanding <= 0 {
+				settled = append(settled, billing.ID)
+			}
+		}
+
+		if len(settled) == 0 {
+			return nil
+		}
+		return settle(tx, settled)
+	})
+	if err != nil {
+		return nil, false, err
+	}
+
+	return settled, applied, nil
+}
+
+// SettleBillings leaves the billings with nothing outstanding
+func (r *billingRepository) SettleBillings(billingIDs []uint) error {
+	if len(billingIDs) == 0 {
+		return nil
+	}
+
+	return r.db.Model(&models.Billing{}).
+		Where("id IN ?", billingIDs).
+		Updates(map[string]interface{}{
+			"outstanding_amount": 0,
+			"updated_at":         time.Now(),
+		}).Error
+}
+
+// RestoreBalances adds amounts that were given back to the outstanding balances of the billings, never
+// beyond their nominal
+func (r *billingRepository) RestoreBalances(amounts map[uint]int64) error {
+	return r.db.Transaction(func(tx *gorm.DB) error {
+		for billingID, amount := range amounts {
+			if err := tx.Model(&models.Billing{}).
+				Where("id = ?", billingID).
+				Updates(map[string]interface{}{
+					"outstanding_amount": gorm.Expr("LEAST(nominal, COALESCE(outstanding_amount, nominal) + ?)", amount),
+					"updated_at":         time.Now(),
+				}).Error; err != nil {
+				return err
+			}
+		}
+		return nil
+	})
+}
+
+// HasOpenPayment reports whether a payment link still waiting to be paid covers the billing
+func (r *billingRepository) HasOpenPayment(billingID uint) (bool, error) {
+	var count int64
+	err := r.db.Model(&models.Payment{}).
+		Joins("JOIN payments_billing_lnk pbl ON pbl.payment_id = payments.id").
+		Where("pbl.t_billing_id = ? AND payments.status = ?", billingID, models.PaymentStatusPending).
+		Count(&count).Error
+	if err != nil {
+		return false, err
+	}
+	return count > 0, nil
+}
+
+// lockBilling locks a billing and its status link for update and passes them to check
+func lockBilling(tx *gorm.DB, id uint, check func(billing *models.Billing, statusID uint) error) error {
+	var billing models.Billing
+	if err := tx.Clauses(clause.Locking{Strength: "UPDATE"}).First(&billing, id).Error; err != nil {
+		return err
+	}
+
+	var link models.BillingStatusBillLink
+	if err := tx.Clauses(clause.Locking{Strength: "UPDATE"}).
+		Where("t_billing_id = ?", id).
+		First(&link).Error; err != nil {
+		return err
+	}
+
+	return check(&billing, link.MasterGeneralStatusID)
+}
+
 // GetBillingSettingsByID retrieves a billing setting record by ID
 func (r *billingRepository) GetBillingSettingsByID(id uint) (*models.SettingBilling, error) {
 	var setting models.SettingBilling
@@ -90,6 +440,118 @@ func (r *billingRepository) GetActiveMonthlySettingBillings() ([]*models.Setting
 	return settings, nil
 }
 
+// GetBilledUserIDs returns the users that already have a billing from the setting for the month and year,
+// mapped to that billing's ID. Billings generated before the setting reference was stored are matched by name.
+func (r *billingRepository) GetBilledUserIDs(setting *models.SettingBilling, month int, year int) (map[uint]uint, error) {
+	var rows []struct {
+		UserID    uint
+		BillingID uint
+	}
+
+	err := r.db.Table("billings b").
+		Select("bpil.user_id, MIN(b.id) AS billing_id").
+		Joins("JOIN billings_profile_id_lnk bpil ON bpil.t_billing_id = b.id").
+		Where("b.published_at IS NOT NULL AND b.bulan = ? AND b.tahun = ?", month, year).
+		Where("b.setting_billing_id = ? OR (b.setting_billing_id IS NULL AND b.nama_billing = ?)", setting.ID, setting.NamaBilling).
+		Group("bpil.user_id").
+		Scan(&rows).Error
+	if err != nil {
+		return nil, err
+	}
+
+	billed := make(map[uint]uint, len(rows))
+	for _, row := range rows {
+		billed[row.UserID] = row.BillingID
+	}
+
+	return billed, nil
+}
+
+// GetBillingRecipients retrieves the given users, or every penghuni user when userIDs is empty,
+// with their profile if they have one, ordered by user ID
+func (r *billingRepository) GetBillingRecipients(userIDs []uint) ([]*models.BillingRecipient, error) {
+	var recipients []*models.BillingRecipient
+
+	query := `
+		select distinct on (uu.id)
+			   uu.id as user_id, uu.username, coalesce(uu.blocked, false) as blocked,
+			   p.id as profile_id, p.nama_penghuni,
+			   exists (
+				   select 1 from up_users_role_lnk url
+				   inner join up_roles r on r.id = url.role_id
+				   where url.user_id = uu.id and r."type" = 'penghuni'
+			   ) as resident
+		from up_users uu
+		left join up_users_profile_lnk pul on pul.user_id = uu.id
+		left join profiles p on p.id = pul.profile_id
+	`
+	var args []interface{}
+	if len(userIDs) > 0 {
+		query += " where uu.id IN ?"
+		args = append(args, userIDs)
+	} else {
+		query += ` where uu.id IN (
+			select url.user_id from up_users_role_lnk url
+			inner join up_roles r on r.id = url.role_id
+			where r."type" = 'penghuni'
+		)`
+	}
+	query += " order by uu.id, p.published_at IS NULL, p.id"
+
+	err := r.db.Raw(query, args...).Scan(&recipients).Error
+	if err != nil {
+		return nil, err
+	}
+
+	return recipients, nil
+}
+
+// GetProfileUserIDs retrieves the IDs of the users linked to a profile, which billings are linked to
+func (r *billingRepository) GetProfileUserIDs(profileID uint) ([]uint, error) {
+	var userIDs []uint
+
+	err := r.db.Table("up_users_profile_lnk").
+		Where("profile_id = ?", profileID).
+		Order("user_id").
+		Pluck("user_id", &userIDs).Error
+	if err != nil {
+		return nil, err
+	}
+
+	return userIDs, nil
+}
+
+// BackfillDueDates sets the due date of billings created before due dates were stored, from their
+// setting's due day or defaultDueDay, clamped to the length of the billing month
+func (r *billingRepository) BackfillDueDates(defaultDueDay int) (int64, error) {
+	result := r.db.Exec(`
+		UPDATE billings b
+		SET due_date = make_date(b.tahun, b.bulan, 1) + (LEAST(
+			COALESCE((SELECT sb.due_day FROM setting_billings sb WHERE sb.id = b.setting_billing_id), ?),
+			EXTRACT(DAY FROM make_date(b.tahun, b.bulan, 1) + INTERVAL '1 month' - INTERVAL '1 day')::int
+		) - 1)
+		WHERE b.due_date IS NULL AND b.tahun IS NOT NULL AND b.bulan BETWEEN 1 AND 12
+	`, defaultDueDay)
+	return result.RowsAffected, result.Error
+}
+
+// GetPastDueBillingIDs retrieves the IDs of published billings still in the unpaid status whose due date
+// is before today, in ID order
+func (r *billingRepository) GetPastDueBillingIDs(unpaidStatusID uint, today time.Time) ([]uint, error) {
+	var billingIDs []uint
+
+	err := r.db.Table("billings b").
+		Joins("INNER JOIN billings_status_bill_lnk bsbl ON bsbl.t_billing_id = b.id").
+		Where("b.published_at IS NOT NULL AND b.due_date < ? AND bsbl.master_general_status_id = ?", today.Format("2006-01-02"), unpaidStatusID).
+		Order("b.id").
+		Pluck("b.id", &billingIDs).Error
+	if err != nil {
+		return nil, err
+	}
+
+	return billingIDs, nil
+}
+
 // CreateBulkBillings creates multiple billing records in a transaction
 func (r *billingRepository) CreateBulkBillings(billings []*models.Billing) error {
 	return r.db.CreateInBatches(billings, 100).Error
@@ -428,12 +890,13 @@ func (r *billingRepository) GetBillingByProfileID(profileID uint, bulan *int, ta
 	offset := (page - 1) * limit
 
 	base := r.db.Table("billings_profile_id_lnk bpil").
-		Select("b.id, p.id as profile_id, b.nama_billing, b.bulan, b.tahun, b.nominal, mgs.id as status_id, mgs.status_name, b.keterangan").
+		Select("b.id, p.id as profile_id, b.nama_billing, b.bulan, b.tahun, b.nominal, b.due_date, mgs.id as status_id, mgs.status_name, b.keterangan, bp.billing_id as penalty_for_billing_id").
 		Joins("JOIN billings b ON bpil.t_billing_id = b.id AND b.published_at IS NOT NULL").
 		Joins("JOIN billings_status_bill_lnk bsbl ON bpil.t_billing_id = bsbl.t_billing_id").
 		Joins("JOIN master_general_statuses mgs ON bsbl.master_general_status_id = mgs.id AND mgs.published_at IS NOT NULL").
 		Joins("JOIN up_users_profile_lnk uupl ON bpil.user_id = uupl.user_id").
 		Joins("JOIN profiles p ON uupl.profile_id = p.id AND p.published_at IS NOT NULL").
+		Joins("LEFT JOIN billing_penalties bp ON bp.penalty_billing_id = b.id").
 		Where("p.id = ?", profileID)
 
 	// Apply optional filters
@@ -466,17 +929,31 @@ func (r *billingRepository) GetBillingByProfileID(profileID uint, bulan *int, ta
 	return results, total, nil
 }
 
-// GetBillingStatistics retrieves billing statistics with optional filters
-func (r *billingRepository) GetBillingStatistics(search string, bulan *int, tahun *int, rt *int, statusIDs []int) (*response.BillingStatisticsResponse, error) {
+// outstandingAmountSQL is the outstanding balance of billing b with status link bsbl, taking the paid and
+// refunded status IDs. Billings from before balances were tracked owe their nominal until they are settled.
+const outstandingAmountSQL = `(CASE
+	WHEN b.outstanding_amount IS NOT NULL THEN b.outstanding_amount
+	WHEN bsbl.master_general_status_id IN (?, ?) THEN 0
+	ELSE COALESCE(b.nominal, 0)
+END)`
+
+// GetBillingStatistics retrieves billing statistics with optional filters, counting states by the status IDs
+// in statuses. Cancelled billings are left out and with overdue only overdue billings are counted.
+func (r *billingRepository) GetBillingStatistics(search string, bulan *int, tahun *int, rt *int, statusIDs []int, overdue bool, statuses models.BillingStatusIDs) (*response.BillingStatisticsResponse, error) {
 	var result response.BillingStatisticsResponse
 
 	query := r.db.Table("billings_profile_id_lnk bpil").
 		Select(`
 			COUNT(b.id) AS total_billing,
-			SUM(CASE WHEN bsbl.master_general_status_id = 6 THEN 1 ELSE 0 END) AS total_sudah_dibayar,
-			SUM(CASE WHEN bsbl.master_general_status_id = 2 THEN 1 ELSE 0 END) AS total_belum_dibayar,
-			SUM(b.nominal) AS total_nominal
-		`).
+			SUM(CASE WHEN bsbl.master_general_status_id = ? THEN 1 ELSE 0 END) AS total_sudah_dibayar,
+			SUM(CASE WHEN bsbl.master_general_status_id = ? THEN 1 ELSE 0 END) AS total_belum_dibayar,
+			SUM(CASE WHEN bsbl.master_general_status_id = ? THEN 1 ELSE 0 END) AS total_terlambat,
+			SUM(CASE WHEN `+outstandingAmountSQL+` BETWEEN 1 AND b.nominal - 1 THEN 1 ELSE 0 END) AS total_sebagian_dibayar,
+			SUM(b.nominal) AS total_nominal,
+			COALESCE(SUM(b.nominal - `+outstandingAmountSQL+`), 0) AS total_collected,
+			COALESCE(SUM(`+outstandingAmountSQL+`), 0) AS total_outstanding
+		`, statuses.Paid, statuses.Unpaid, statuses.Overdue,
+			statuses.Paid, statuses.Refunded, statuses.Paid, statuses.Refunded, statuses.Paid, statuses.Refunded).
 		Joins("JOIN billings b ON bpil.t_billing_id = b.id AND b.published_at IS NOT NULL").
 		Joins("JOIN billings_status_bill_lnk bsbl ON bpil.t_billing_id = bsbl.t_billing_id").
 		Joins("JOIN master_general_statuses mgs ON bsbl.master_general_status_id = mgs.id AND mgs.published_at IS NOT NULL").
@@ -500,12 +977,17 @@ func (r *billingRepository) GetBillingStatistics(search string, bulan *int, tahu
 		query = query.Where("p.rt = ?", *rt)
 	}
 
+	// Cancelled billings are never counted
+	query = query.Where("bsbl.master_general_status_id <> ?", statuses.Cancelled)
+
 	// Handle status IDs filter
-	if len(statusIDs) > 0 {
+	if overdue {
+		query = query.Where("bsbl.master_general_status_id = ?", statuses.Overdue)
+	} else if len(statusIDs) > 0 {
 		query = query.Where("bsbl.master_general_status_id IN ?", statusIDs)
 	} else {
-		// Default to status IDs 2 and 6 if no status filter provided
-		query = query.Where("bsbl.master_general_status_id IN ?", []int{2, 6})
+		// Default to unpaid, paid and overdue billings if no status filter provided
+		query = query.Where("bsbl.master_general_status_id IN ?", []uint{statuses.Unpaid, statuses.Paid, statuses.Overdue})
 	}
 
 	err := query.Scan(&result).Error
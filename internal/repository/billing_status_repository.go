@@ -0,0 +1,110 @@
+package repository
+
+import (
+	"ipl-be-svc/internal/models"
+
+	"gorm.io/gorm"
+	"gorm.io/gorm/clause"
+)
+
+// BillingStatusRepository defines the interface for billing status transitions and their history
+type BillingStatusRepository interface {
+	ChangeStatus(billingIDs []uint, toStatusID uint, check func(current map[uint]uint) ([]uint, error), actorID *uint, reason *string) ([]uint, error)
+	ChangeStatusInTx(tx *gorm.DB, billingIDs []uint, toStatusID uint, check func(current map[uint]uint) ([]uint, error), actorID *uint, reason *string) ([]uint, error)
+	GetHistory(billingID uint) ([]*models.BillingStatusHistoryEntry, error)
+}
+
+// billingStatusRepository implements BillingStatusRepository
+type billingStatusRepository struct {
+	db *gorm.DB
+}
+
+// NewBillingStatusRepository creates a new instance of BillingStatusRepository
+func NewBillingStatusRepository(db *gorm.DB) BillingStatusRepository {
+	return &billingStatusRepository{
+		db: db,
+	}
+}
+
+// ChangeStatus locks the status links of the billings and passes their current status IDs, keyed by billing
+// ID, to check. The billings check returns are moved to toStatusID with a history entry each, in the same
+// transaction, and their IDs are returned.
+func (r *billingStatusRepository) ChangeStatus(billingIDs []uint, toStatusID uint, check func(current map[uint]uint) ([]uint, error), actorID *uint, reason *string) ([]uint, error) {
+	var moved []uint
+
+	err := r.db.Transaction(func(tx *gorm.DB) error {
+		var err error
+		moved, err = r.ChangeStatusInTx(tx, billingIDs, toStatusID, check, actorID, reason)
+		return err
+	})
+	if err != nil {
+		return nil, err
+	}
+
+	return moved, nil
+}
+
+// ChangeStatusInTx is ChangeStatus within tx, for status changes that must commit together with other
+// changes to the billings
+func (r *billingStatusRepository) ChangeStatusInTx(tx *gorm.DB, billingIDs []uint, toStatusID uint, check func(current map[uint]uint) ([]uint, error), actorID *uint, reason *string) ([]uint, error) {
+	var links []*models.BillingStatusBillLink
+	if err := tx.Clauses(clause.Locking{Strength: "UPDATE"}).
+		Where("t_billing_id IN ?", billingIDs).
+		Order("t_billing_id").
+		Find(&links).Error; err != nil {
+		return nil, err
+	}
+
+	current := make(map[uint]uint, len(links))
+	for _, link := range links {
+		current[link.BillingID] = link.MasterGeneralStatusID
+	}
+
+	moved, err := check(current)
+	if err != nil || len(moved) == 0 {
+		return nil, err
+	}
+
+	if err := tx.Model(&models.BillingStatusBillLink{}).
+		Where("t_billing_id IN ?", moved).
+		Update("master_general_status_id", toStatusID).Error; err != nil {
+		return nil, err
+	}
+
+	histories := make([]*models.BillingStatusHistory, len(moved))
+	for i, billingID := range moved {
+		fromStatusID := current[billingID]
+		histories[i] = &models.BillingStatusHistory{
+			BillingID:    billingID,
+			FromStatusID: &fromStatusID,
+			ToStatusID:   toStatusID,
+			ActorID:      actorID,
+			Reason:       reason,
+		}
+	}
+	if err := tx.CreateInBatches(histories, 100).Error; err != nil {
+		return nil, err
+	}
+
+	return moved, nil
+}
+
+// GetHistory retrieves the status history of a billing, oldest first, with status names and actor usernames
+func (r *billingStatusRepository) GetHistory(billingID uint) ([]*models.BillingStatusHistoryEntry, error) {
+	var entries []*models.BillingStatusHistoryEntry
+
+	err := r.db.Table("billing_status_histories h").
+		Select(`h.id, h.billing_id, h.from_status_id, fs.status_name AS from_status_name, h.to_status_id,
+			ts.status_name AS to_status_name, h.actor_id, u.username AS actor_username, h.reason, h.created_at`).
+		Joins("LEFT JOIN master_general_statuses fs ON fs.id = h.from_status_id").
+		Joins("LEFT JOIN master_general_statuses ts ON ts.id = h.to_status_id").
+		Joins("LEFT JOIN up_users u ON u.id = h.actor_id").
+		Where("h.billing_id = ?", billingID).
+		Order("h.created_at, h.id").
+		Scan(&entries).Error
+	if err != nil {
+		return nil, err
+	}
+
+	return entries, nil
+}
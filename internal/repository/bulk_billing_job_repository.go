@@ -0,0 +1,54 @@
+package repository
+
+import (
+	"ipl-be-svc/internal/models"
+
+	"gorm.io/gorm"
+)
+
+// BulkBillingJobRepository defines the interface for background bulk billing job data operations
+type BulkBillingJobRepository interface {
+	Create(job *models.BulkBillingJob) error
+	Update(job *models.BulkBillingJob) error
+	AddFailure(failure *models.BulkBillingJobFailure) error
+	GetByID(id uint) (*models.BulkBillingJob, error)
+}
+
+// bulkBillingJobRepository implements BulkBillingJobRepository
+type bulkBillingJobRepository struct {
+	db *gorm.DB
+}
+
+// NewBulkBillingJobRepository creates a new instance of BulkBillingJobRepository
+func NewBulkBillingJobRepository(db *gorm.DB) BulkBillingJobRepository {
+	return &bulkBillingJobRepository{
+		db: db,
+	}
+}
+
+// Create creates a new bulk billing job
+func (r *bulkBillingJobRepository) Create(job *models.BulkBillingJob) error {
+	return r.db.Omit("Failures").Create(job).Error
+}
+
+// Update saves the status and counters of a job (failures are not touched)
+func (r *bulkBillingJobRepository) Update(job *models.BulkBillingJob) error {
+	return r.db.Omit("Failures").Save(job).Error
+}
+
+// AddFailure records a resident the job failed to bill
+func (r *bulkBillingJobRepository) AddFailure(failure *models.BulkBillingJobFailure) error {
+	return r.db.Create(failure).Error
+}
+
+// GetByID retrieves a job with its failures
+func (r *bulkBillingJobRepository) GetByID(id uint) (*models.BulkBillingJob, error) {
+	var job models.BulkBillingJob
+	err := r.db.Preload("Failures", func(db *gorm.DB) *gorm.DB {
+		return db.Order("id ASC")
+	}).First(&job, id).Error
+	if err != nil {
+		return nil, err
+	}
+	return &job, nil
+}
@@ -0,0 +1,138 @@
+package repository
+
+import (
+	"sort"
+
+	"ipl-be-svc/internal/models"
+
+	"gorm.io/gorm"
+)
+
+// creditLockNamespace is the first key of the advisory locks held while credit is taken from a balance
+const creditLockNamespace = 1003
+
+// CreditLedgerRepository defines the interface for resident credit ledger data operations
+type CreditLedgerRepository interface {
+	Create(entry *models.CreditLedgerEntry) error
+	GetBalance(profileID uint) (int64, error)
+	GetBalances(profileIDs []uint) (map[uint]int64, error)
+	LockBalances(tx *gorm.DB, profileIDs []uint) (map[uint]int64, error)
+	GetByProfileID(profileID uint, page int, limit int) ([]*models.CreditLedgerEntry, int64, error)
+	GetProfile(profileID uint) (*models.Profile, error)
+}
+
+// creditLedgerRepository implements CreditLedgerRepository
+type creditLedgerRepository struct {
+	db *gorm.DB
+}
+
+// NewCreditLedgerRepository creates a new instance of CreditLedgerRepository
+func NewCreditLedgerRepository(db *gorm.DB) CreditLedgerRepository {
+	return &creditLedgerRepository{
+		db: db,
+	}
+}
+
+// Create records a ledger entry
+func (r *creditLedgerRepository) Create(entry *models.CreditLedgerEntry) error {
+	return r.db.Create(entry).Error
+}
+
+// GetBalance returns the credit balance of a profile
+func (r *creditLedgerRepository) GetBalance(profileID uint) (int64, error) {
+	balances, err := creditBalances(r.db, []uint{profileID})
+	if err != nil {
+		return 0, err
+	}
+	return balances[profileID], nil
+}
+
+// GetBalances returns the credit balances of the profiles that have any
+func (r *creditLedgerRepository) GetBalances(profileIDs []uint) (map[uint]int64, error) {
+	return creditBalances(r.db, profileIDs)
+}
+
+// LockBalances takes the credit locks of the profiles for the rest of the transaction and returns their
+// balances, which no other transaction can take credit from until it ends. Deposits are not blocked.
+func (r *creditLedgerRepository) LockBalances(tx *gorm.DB, profileIDs []uint) (map[uint]int64, error) {
+	sorted := append([]uint(nil), profileIDs...)
+	// Locks are taken in ID order so two transactions cannot wait on each other
+	sort.Slice(sorted, func(i, j int) bool { return sorted[i] < sorted[j] })
+	for i, profileID := range sorted {
+		if i > 0 && profileID == sorted[i-1] {
+			continue
+		}
+		if err := tx.Exec("SELECT pg_advisory_xact_lock(?, ?)", creditLockNamespace, profileID).Error; err != nil {
+			return nil, err
+		}
+	}
+
+	return creditBalances(tx, sorted)
+}
+
+// GetByProfileID retrieves the ledger entries of a profile, newest first
+func (r *creditLedgerRepository) GetByProfileID(profileID uint, page int, limit int) ([]*models.CreditLedgerEntry, int64, error) {
+	var entries []*models.CreditLedgerEntry
+	var total int64
+
+	if page < 1 {
+		page = 1
+	}
+	if limit <= 0 {
+		limit = 10
+	}
+
+	query := r.db.Model(&models.CreditLedgerEntry{}).Where("profile_id = ?", profileID)
+
+	if err := query.Count(&total).Error; err != nil {
+		return nil, 0, err
+	}
+
+	err := query.Order("created_at DESC, id DESC").
+		Limit(limit).
+		Offset((page - 1) * limit).
+		Find(&entries).Error
+	if err != nil {
+		return nil, 0, err
+	}
+
+	return entries, total, nil
+}
+
+// GetProfile retrieves a profile by ID
+func (r *creditLedgerRepository) GetProfile(profileID uint) (*models.Profile, error) {
+	var profile models.Profile
+
+	err := r.db.Where("id = ?", profileID).First(&profile).Error
+	if err != nil {
+		return nil, err
+	}
+
+	return &profile, nil
+}
+
+// creditBalances sums the ledger entries of the profiles
+func creditBalances(db *gorm.DB, profileIDs []uint) (map[uint]int64, error) {
+	balances := make(map[uint]int64, len(profileIDs))
+	if len(profileIDs) == 0 {
+		return balances, nil
+	}
+
+	var rows []struct {
+		ProfileID uint  `gorm:"column:profile_id"`
+		Balance   int64 `gorm:"column:balance"`
+	}
+	err := db.Model(&models.CreditLedgerEntry{}).
+		Select("profile_id, COALESCE(SUM(amount), 0) AS balance").
+		Where("profile_id IN ?", profileIDs).
+		Group("profile_id").
+		Scan(&rows).Error
+	if err != nil {
+		return nil, err
+	}
+
+	for _, row := range rows {
+		balances[row.ProfileID] = row.Balance
+	}
+	return balances, nil
+}
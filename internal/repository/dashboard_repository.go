@@ -1,6 +1,7 @@
 package repository
 
 import (
+	"ipl-be-svc/internal/models"
 	"ipl-be-svc/internal/models/response"
 
 	"gorm.io/gorm"
@@ -8,8 +9,8 @@ import (
 
 // DashboardRepository defines the interface for dashboard data operations
 type DashboardRepository interface {
-	GetDashboardStatistics(rt *int, bulan, tahun *int) (*response.DashboardStatisticsResponse, error)
-	GetBillingList(rt, bulan, tahun *int, page, limit int) ([]*response.BillingListItem, int64, error)
+	GetDashboardStatistics(rt *int, bulan, tahun *int, overdue bool, statuses models.BillingStatusIDs) (*response.DashboardStatisticsResponse, error)
+	GetBillingList(rt, bulan, tahun *int, overdue bool, statuses models.BillingStatusIDs, page, limit int) ([]*response.BillingListItem, int64, error)
 }
 
 // dashboardRepository implements DashboardRepository
@@ -24,15 +25,20 @@ func NewDashboardRepository(db *gorm.DB) DashboardRepository {
 	}
 }
 
-// GetDashboardStatistics retrieves dashboard statistics by RT with optional bulan and tahun filters
-func (r *dashboardRepository) GetDashboardStatistics(rt *int, bulan, tahun *int) (*response.DashboardStatisticsResponse, error) {
+// GetDashboardStatistics retrieves dashboard statistics by RT with optional bulan, tahun and overdue filters,
+// counting states by the status IDs in statuses and leaving out cancelled billings
+func (r *dashboardRepository) GetDashboardStatistics(rt *int, bulan, tahun *int, overdue bool, statuses models.BillingStatusIDs) (*response.DashboardStatisticsResponse, error) {
 	var result response.DashboardStatisticsResponse
 
 	query := `
 		SELECT
-			COUNT(*) FILTER (WHERE bsbl.master_general_status_id = 2) AS belum_bayar,
-			COUNT(*) FILTER (WHERE bsbl.master_general_status_id = 6) AS sudah_bayar,
-			COUNT(*) AS total
+			COUNT(*) FILTER (WHERE bsbl.master_general_status_id = ?) AS belum_bayar,
+			COUNT(*) FILTER (WHERE bsbl.master_general_status_id = ?) AS sudah_bayar,
+			COUNT(*) FILTER (WHERE bsbl.master_general_status_id = ?) AS terlambat,
+			COUNT(*) FILTER (WHERE ` + outstandingAmountSQL + ` BETWEEN 1 AND b.nominal - 1) AS sebagian_bayar,
+			COUNT(*) AS total,
+			COALESCE(SUM(b.nominal - ` + outstandingAmountSQL + `), 0) AS total_collected,
+			COALESCE(SUM(` + outstandingAmountSQL + `), 0) AS total_outstanding
 		FROM billings_profile_id_lnk bpil
 		JOIN billings b
 			ON b.id = bpil.t_billing_id
@@ -44,9 +50,20 @@ func (r *dashboardRepository) GetDashboardStatistics(rt *int, bulan, tahun *int)
 		   AND p.published_at IS NOT NULL
 		JOIN billings_status_bill_lnk bsbl
 			ON bsbl.t_billing_id = b.id
+		   AND bsbl.master_general_status_id <> ?
 	`
 
-	var args []interface{}
+	args := []interface{}{
+		statuses.Unpaid, statuses.Paid, statuses.Overdue,
+		statuses.Paid, statuses.Refunded, statuses.Paid, statuses.Refunded, statuses.Paid, statuses.Refunded,
+		statuses.Cancelled,
+	}
+
+	// Add overdue filter if requested
+	if overdue {
+		query += " AND bsbl.master_general_status_id = ?"
+		args = append(args, statuses.Overdue)
+	}
 
 	// Add RT filter if provided and not zero
 	if rt != nil && *rt != 0 {
@@ -74,8 +91,8 @@ func (r *dashboardRepository) GetDashboardStatistics(rt *int, bulan, tahun *int)
 	return &result, nil
 }
 
-// GetBillingList retrieves billing list with optional RT, bulan, tahun filters and pagination
-func (r *dashboardRepository) GetBillingList(rt, bulan, tahun *int, page, limit int) ([]*response.BillingListItem, int64, error) {
+// GetBillingList retrieves billing list with optional RT, bulan, tahun and overdue filters and pagination
+func (r *dashboardRepository) GetBillingList(rt, bulan, tahun *int, overdue bool, statuses models.BillingStatusIDs, page, limit int) ([]*response.BillingListItem, int64, error) {
 	var billings []*response.BillingListItem
 	var total int64
 
@@ -96,7 +113,7 @@ func (r *dashboardRepository) GetBillingList(rt, bulan, tahun *int, page, limit
 	// Base query for data
 	dataQuery := `
 		SELECT
-			b.nominal, b.bulan, b.tahun, mgs.status_name, p.rt, p.nama_penghuni
+			b.nominal, b.bulan, b.tahun, b.due_date, mgs.status_name, p.rt, p.nama_penghuni
 		FROM billings_profile_id_lnk bpil
 		JOIN billings b 
 			ON b.id = bpil.t_billing_id
@@ -151,6 +168,14 @@ func (r *dashboardRepository) GetBillingList(rt, bulan, tahun *int, page, limit
 		dataArgs = append(dataArgs, *tahun)
 	}
 
+	// Add overdue filter if requested
+	if overdue {
+		countQuery += " AND bsbl.master_general_status_id = ?"
+		dataQuery += " AND bsbl.master_general_status_id = ?"
+		countArgs = append(countArgs, statuses.Overdue)
+		dataArgs = append(dataArgs, statuses.Overdue)
+	}
+
 	// Add ORDER BY and pagination to data query
 	dataQuery += `
 		ORDER BY b.tahun DESC, b.bulan DESC
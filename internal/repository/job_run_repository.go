@@ -0,0 +1,78 @@
+package repository
+
+import (
+	"ipl-be-svc/internal/models"
+
+	"gorm.io/gorm"
+)
+
+// jobLockNamespace is the first key of the advisory locks that keep scheduled jobs on one replica
+const jobLockNamespace = 1002
+
+// JobRunRepository defines the interface for scheduled job run data operations
+type JobRunRepository interface {
+	Create(run *models.JobRun) error
+	Update(run *models.JobRun) error
+	GetLatest(job string, period string) (*models.JobRun, error)
+	GetFirst(job string) (*models.JobRun, error)
+	RunExclusive(lockID int, fn func() error) (bool, error)
+}
+
+// jobRunRepository implements JobRunRepository
+type jobRunRepository struct {
+	db *gorm.DB
+}
+
+// NewJobRunRepository creates a new instance of JobRunRepository
+func NewJobRunRepository(db *gorm.DB) JobRunRepository {
+	return &jobRunRepository{
+		db: db,
+	}
+}
+
+// Create creates a new job run
+func (r *jobRunRepository) Create(run *models.JobRun) error {
+	return r.db.Create(run).Error
+}
+
+// Update saves all fields of a job run
+func (r *jobRunRepository) Update(run *models.JobRun) error {
+	return r.db.Save(run).Error
+}
+
+// GetLatest retrieves the most recent run of the job for the period
+func (r *jobRunRepository) GetLatest(job string, period string) (*models.JobRun, error) {
+	var run models.JobRun
+	err := r.db.Where("job = ? AND period = ?", job, period).Order("started_at DESC, id DESC").First(&run).Error
+	if err != nil {
+		return nil, err
+	}
+	return &run, nil
+}
+
+// GetFirst retrieves the earliest run of the job
+func (r *jobRunRepository) GetFirst(job string) (*models.JobRun, error) {
+	var run models.JobRun
+	err := r.db.Where("job = ?", job).Order("started_at ASC, id ASC").First(&run).Error
+	if err != nil {
+		return nil, err
+	}
+	return &run, nil
+}
+
+// RunExclusive calls fn while holding the advisory lock for lockID, which is shared by every replica
+// on the database. It returns false without calling fn when another replica holds the lock.
+func (r *jobRunRepository) RunExclusive(lockID int, fn func() error) (bool, error) {
+	acquired := false
+	err := r.db.Transaction(func(tx *gorm.DB) error {
+		// The lock is released when the transaction ends
+		if err := tx.Raw("SELECT pg_try_advisory_xact_lock(?, ?)", jobLockNamespace, lockID).Scan(&acquired).Error; err != nil {
+			return err
+		}
+		if !acquired {
+			return nil
+		}
+		return fn()
+	})
+	return acquired, err
+}
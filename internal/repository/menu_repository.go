@@ -9,6 +9,7 @@ import (
 // MenuRepository interface defines menu repository methods
 type MenuRepository interface {
 	GetMenusByUserID(userID uint) ([]*models.MasterMenu, error)
+	HasMenuAccess(roleIDs []uint, kodeMenu string) (bool, error)
 }
 
 // menuRepository implements MenuRepository interface
@@ -38,3 +39,31 @@ func (r *menuRepository) GetMenusByUserID(userID uint) ([]*models.MasterMenu, er
 	err := r.db.Raw(query, userID).Scan(&menus).Error
 	return menus, err
 }
+
+// HasMenuAccess checks whether any of the given roles is linked to an active menu with the given kode_menu
+func (r *menuRepository) HasMenuAccess(roleIDs []uint, kodeMenu string) (bool, error) {
+	if len(roleIDs) == 0 {
+		return false, nil
+	}
+
+	var count int64
+
+	query := `
+		SELECT COUNT(*)
+		FROM role_menus_role_lnk rmrl
+		INNER JOIN role_menus rm ON rm.id = rmrl.role_menu_id
+		INNER JOIN role_menus_master_menu_lnk rmmml ON rmmml.role_menu_id = rm.id
+		INNER JOIN master_menus mm ON mm.id = rmmml.master_menu_id
+		WHERE rmrl.role_id IN ?
+		AND mm.kode_menu = ?
+		AND mm.is_active = true
+		AND rm.is_active IS NOT FALSE
+	`
+
+	err := r.db.Raw(query, roleIDs, kodeMenu).Scan(&count).Error
+	if err != nil {
+		return false, err
+	}
+
+	return count > 0, nil
+}
@@ -0,0 +1,85 @@
+package repository
+
+import (
+	"ipl-be-svc/internal/models"
+
+	"gorm.io/gorm"
+)
+
+// PaymentReconciliationRepository defines the interface for payment reconciliation run history
+type PaymentReconciliationRepository interface {
+	CreateRun(run *models.PaymentReconciliationRun) error
+	UpdateRun(run *models.PaymentReconciliationRun) error
+	CreateChange(change *models.PaymentReconciliationChange) error
+	GetRunByID(id uint) (*models.PaymentReconciliationRun, error)
+	ListRuns(page int, limit int) ([]*models.PaymentReconciliationRun, int64, error)
+}
+
+// paymentReconciliationRepository implements PaymentReconciliationRepository
+type paymentReconciliationRepository struct {
+	db *gorm.DB
+}
+
+// NewPaymentReconciliationRepository creates a new instance of PaymentReconciliationRepository
+func NewPaymentReconciliationRepository(db *gorm.DB) PaymentReconciliationRepository {
+	return &paymentReconciliationRepository{
+		db: db,
+	}
+}
+
+// CreateRun creates a new reconciliation run
+func (r *paymentReconciliationRepository) CreateRun(run *models.PaymentReconciliationRun) error {
+	return r.db.Omit("Changes").Create(run).Error
+}
+
+// UpdateRun saves the counters and completion time of a run (changes are not touched)
+func (r *paymentReconciliationRepository) UpdateRun(run *models.PaymentReconciliationRun) error {
+	return r.db.Omit("Changes").Save(run).Error
+}
+
+// CreateChange records a payment change made by a run
+func (r *paymentReconciliationRepository) CreateChange(change *models.PaymentReconciliationChange) error {
+	return r.db.Create(change).Error
+}
+
+// GetRunByID retrieves a run with its changes
+func (r *paymentReconciliationRepository) GetRunByID(id uint) (*models.PaymentReconciliationRun, error) {
+	var run models.PaymentReconciliationRun
+	err := r.db.Preload("Changes", func(db *gorm.DB) *gorm.DB {
+		return db.Order("id ASC")
+	}).First(&run, id).Error
+	if err != nil {
+		return nil, err
+	}
+	return &run, nil
+}
+
+// ListRuns retrieves runs with their changes, newest first
+func (r *paymentReconciliationRepository) ListRuns(page int, limit int) ([]*models.PaymentReconciliationRun, int64, error) {
+	var runs []*models.PaymentReconciliationRun
+	var total int64
+
+	if page < 1 {
+		page = 1
+	}
+	if limit <= 0 {
+		limit = 10
+	}
+
+	if err := r.db.Model(&models.PaymentReconciliationRun{}).Count(&total).Error; err != nil {
+		return nil, 0, err
+	}
+
+	err := r.db.Preload("Changes", func(db *gorm.DB) *gorm.DB {
+		return db.Order("id ASC")
+	}).
+		Order("started_at DESC, id DESC").
+		Limit(limit).
+		Offset((page - 1) * limit).
+		Find(&runs).Error
+	if err != nil {
+		return nil, 0, err
+	}
+
+	return runs, total, nil
+}
@@ -0,0 +1,247 @@
+package repository
+
+import (
+	"sort"
+	"strconv"
+	"strings"
+	"time"
+
+	"ipl-be-svc/internal/models"
+
+	"gorm.io/gorm"
+	"gorm.io/gorm/clause"
+)
+
+// paymentLinkLockNamespace is the first key of the advisory locks held while a payment link is issued
+const paymentLinkLockNamespace = 1004
+
+// PaymentRepository defines the interface for payment data operations
+type PaymentRepository interface {
+	Create(payment *models.Payment, links []*models.PaymentBillingLink) error
+	Update(payment *models.Payment) error
+	UpdateLocked(id uint, update func(payment *models.Payment) error) error
+	GetByID(id uint) (*models.Payment, error)
+	GetByGatewayReference(gateway string, refs ...string) (*models.Payment, error)
+	GetByReference(reference string) (*models.Payment, error)
+	GetByBillingID(billingID uint) ([]*models.Payment, error)
+	FindActiveByBillingSet(billingIDs []uint) (*models.Payment, error)
+	LockBillingSet(billingIDs []uint, fn func() error) error
+	GetByUserID(userID uint, page int, limit int) ([]*models.Payment, int64, error)
+	GetPendingForReconciliation(createdBefore time.Time, limit int) ([]*models.Payment, error)
+	MarkReconciled(paymentID uint, at time.Time) error
+}
+
+// paymentRepository implements PaymentRepository
+type paymentRepository struct {
+	db *gorm.DB
+}
+
+// NewPaymentRepository creates a new instance of PaymentRepository
+func NewPaymentRepository(db *gorm.DB) PaymentRepository {
+	return &paymentRepository{
+		db: db,
+	}
+}
+
+// Create creates a payment together with its billing links in a transaction
+func (r *paymentRepository) Create(payment *models.Payment, links []*models.PaymentBillingLink) error {
+	return r.db.Transaction(func(tx *gorm.DB) error {
+		if err := tx.Omit("Billings").Create(payment).Error; err != nil {
+			return err
+		}
+
+		for _, link := range links {
+			link.PaymentID = payment.ID
+		}
+
+		if len(links) > 0 {
+			if err := tx.CreateInBatches(links, 100).Error; err != nil {
+				return err
+			}
+		}
+
+		payment.Billings = links
+		return nil
+	})
+}
+
+// Update saves all fields of a payment (billing links are not touched)
+func (r *paymentRepository) Update(payment *models.Payment) error {
+	return r.db.Omit("Billings").Save(payment).Error
+}
+
+// UpdateLocked locks the payment row, passes the payment with its billing links to update and saves
+// it when update succeeds. Concurrent updates of the same payment run one after the other.
+func (r *paymentRepository) UpdateLocked(id uint, update func(payment *models.Payment) error) error {
+	return r.db.Transaction(func(tx *gorm.DB) error {
+		var payment models.Payment
+		if err := tx.Clauses(clause.Locking{Strength: "UPDATE"}).Preload("Billings").First(&payment, id).Error; err != nil {
+			return err
+		}
+
+		if err := update(&payment); err != nil {
+			return err
+		}
+
+		return tx.Omit("Billings").Save(&payment).Error
+	})
+}
+
+// GetByID retrieves a payment with its billing links
+func (r *paymentRepository) GetByID(id uint) (*models.Payment, error) {
+	var payment models.Payment
+	err := r.db.Preload("Billings").First(&payment, id).Error
+	if err != nil {
+		return nil, err
+	}
+	return &payment, nil
+}
+
+// GetByGatewayReference retrieves the payment whose invoice ID or transaction ID matches any of refs
+func (r *paymentRepository) GetByGatewayReference(gateway string, refs ...string) (*models.Payment, error) {
+	var nonEmpty []string
+	for _, ref := range refs {
+		if ref != "" {
+			nonEmpty = append(nonEmpty, ref)
+		}
+	}
+	if len(nonEmpty) == 0 {
+		return nil, gorm.ErrRecordNotFound
+	}
+
+	var payment models.Payment
+	err := r.db.Preload("Billings").
+		Where("gateway = ?", gateway).
+		Where("invoice_id IN ? OR transaction_id IN ?", nonEmpty, nonEmpty).
+		Order("id DESC").
+		First(&payment).Error
+	if err != nil {
+		return nil, err
+	}
+	return &payment, nil
+}
+
+// GetByReference retrieves the payment with the given opaque payment reference
+func (r *paymentRepository) GetByReference(reference string) (*models.Payment, error) {
+	var payment models.Payment
+	err := r.db.Preload("Billings").Where("reference = ?", reference).First(&payment).Error
+	if err != nil {
+		return nil, err
+	}
+	return &payment, nil
+}
+
+// GetByBillingID retrieves every payment that covered the billing, newest first
+func (r *paymentRepository) GetByBillingID(billingID uint) ([]*models.Payment, error) {
+	var payments []*models.Payment
+
+	err := r.db.Preload("Billings").
+		Where("id IN (?)", r.db.Model(&models.PaymentBillingLink{}).Select("payment_id").Where("t_billing_id = ?", billingID)).
+		Order("created_at DESC, id DESC").
+		Find(&payments).Error
+	if err != nil {
+		return nil, err
+	}
+
+	return payments, nil
+}
+
+// LockBillingSet holds a lock on the set of billings while fn runs, so payment links for the same billings
+// are issued one at a time. Other sets, even overlapping ones, are not blocked.
+func (r *paymentRepository) LockBillingSet(billingIDs []uint, fn func() error) error {
+	sorted := append([]uint(nil), billingIDs...)
+	sort.Slice(sorted, func(i, j int) bool { return sorted[i] < sorted[j] })
+	parts := make([]string, 0, len(sorted))
+	for i, id := range sorted {
+		if i > 0 && id == sorted[i-1] {
+			continue
+		}
+		parts = append(parts, strconv.FormatUint(uint64(id), 10))
+	}
+
+	return r.db.Transaction(func(tx *gorm.DB) error {
+		if err := tx.Exec("SELECT pg_advisory_xact_lock(?, hashtext(?))", paymentLinkLockNamespace, strings.Join(parts, ",")).Error; err != nil {
+			return err
+		}
+		return fn()
+	})
+}
+
+// FindActiveByBillingSet retrieves the newest pending, unexpired payment whose links cover exactly billingIDs
+func (r *paymentRepository) FindActiveByBillingSet(billingIDs []uint) (*models.Payment, error) {
+	if len(billingIDs) == 0 {
+		return nil, gorm.ErrRecordNotFound
+	}
+
+	exactSet := r.db.Model(&models.PaymentBillingLink{}).
+		Select("payment_id").
+		Group("payment_id").
+		Having("COUNT(*) = ? AND COUNT(*) FILTER (WHERE t_billing_id IN ?) = ?", len(billingIDs), billingIDs, len(billingIDs))
+
+	var payment models.Payment
+	err := r.db.Preload("Billings").
+		Where("status = ? AND payment_url IS NOT NULL", models.PaymentStatusPending).
+		Where("expired_at IS NULL OR expired_at > ?", time.Now()).
+		Where("id IN (?)", exactSet).
+		Order("id DESC").
+		First(&payment).Error
+	if err != nil {
+		return nil, err
+	}
+	return &payment, nil
+}
+
+// GetByUserID retrieves the payments covering billings owned by the user, newest first
+func (r *paymentRepository) GetByUserID(userID uint, page int, limit int) ([]*models.Payment, int64, error) {
+	var payments []*models.Payment
+	var total int64
+
+	if page < 1 {
+		page = 1
+	}
+	if limit <= 0 {
+		limit = 10
+	}
+
+	ownedPaymentIDs := r.db.Table("payments_billing_lnk pbl").
+		Select("pbl.payment_id").
+		Joins("JOIN billings_profile_id_lnk bpil ON bpil.t_billing_id = pbl.t_billing_id").
+		Where("bpil.user_id = ?", userID)
+
+	query := r.db.Model(&models.Payment{}).Where("id IN (?)", ownedPaymentIDs)
+
+	if err := query.Count(&total).Error; err != nil {
+		return nil, 0, err
+	}
+
+	err := query.Preload("Billings").
+		Order("created_at DESC, id DESC").
+		Limit(limit).
+		Offset((page - 1) * limit).
+		Find(&payments).Error
+	if err != nil {
+		return nil, 0, err
+	}
+
+	return payments, total, nil
+}
+
+// GetPendingForReconciliation retrieves pending payments with a gateway invoice created before
+// createdBefore, least recently reconciled first
+func (r *paymentRepository) GetPendingForReconciliation(createdBefore time.Time, limit int) ([]*models.Payment, error) {
+	var payments []*models.Payment
+	err := r.db.Preload("Billings").
+		Where("status = ? AND invoice_id IS NOT NULL AND created_at < ?", models.PaymentStatusPending, createdBefore).
+		Order("reconciled_at ASC NULLS FIRST, id ASC").
+		Limit(limit).
+		Find(&payments).Error
+	if err != nil {
+		return nil, err
+	}
+	return payments, nil
+}
+
+// MarkReconciled records when the payment was last checked against its gateway
+func (r *paymentRepository) MarkReconciled(paymentID uint, at time.Time) error {
+	return r.db.Model(&models.Payment{}).Where("id = ?", paymentID).UpdateColumn("reconciled_at", at).Error
+}
@@ -0,0 +1,79 @@
+package repository
+
+import (
+	"ipl-be-svc/internal/models"
+
+	"gorm.io/gorm"
+)
+
+// PaymentReviewRepository defines the interface for payment review data operations
+type PaymentReviewRepository interface {
+	Create(review *models.PaymentReview) error
+	GetByID(id uint) (*models.PaymentReview, error)
+	List(status string, page int, limit int) ([]*models.PaymentReview, int64, error)
+	Update(review *models.PaymentReview) error
+}
+
+// paymentReviewRepository implements PaymentReviewRepository
+type paymentReviewRepository struct {
+	db *gorm.DB
+}
+
+// NewPaymentReviewRepository creates a new instance of PaymentReviewRepository
+func NewPaymentReviewRepository(db *gorm.DB) PaymentReviewRepository {
+	return &paymentReviewRepository{
+		db: db,
+	}
+}
+
+// Create creates a new payment review
+func (r *paymentReviewRepository) Create(review *models.PaymentReview) error {
+	return r.db.Create(review).Error
+}
+
+// GetByID retrieves a payment review by ID
+func (r *paymentReviewRepository) GetByID(id uint) (*models.PaymentReview, error) {
+	var review models.PaymentReview
+	err := r.db.First(&review, id).Error
+	if err != nil {
+		return nil, err
+	}
+	return &review, nil
+}
+
+// List retrieves payment reviews, optionally filtered by status, newest first
+func (r *paymentReviewRepository) List(status string, page int, limit int) ([]*models.PaymentReview, int64, error) {
+	var reviews []*models.PaymentReview
+	var total int64
+
+	if page < 1 {
+		page = 1
+	}
+	if limit <= 0 {
+		limit = 10
+	}
+
+	query := r.db.Model(&models.PaymentReview{})
+	if status != "" {
+		query = query.Where("status = ?", status)
+	}
+
+	if err := query.Count(&total).Error; err != nil {
+		return nil, 0, err
+	}
+
+	err := query.Order("created_at DESC, id DESC").
+		Limit(limit).
+		Offset((page - 1) * limit).
+		Find(&reviews).Error
+	if err != nil {
+		return nil, 0, err
+	}
+
+	return reviews, total, nil
+}
+
+// Update saves all fields of a payment review
+func (r *paymentReviewRepository) Update(review *models.PaymentReview) error {
+	return r.db.Save(review).Error
+}
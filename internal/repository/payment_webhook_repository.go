@@ -0,0 +1,58 @@
+package repository
+
+import (
+	"time"
+
+	"ipl-be-svc/internal/models"
+
+	"gorm.io/gorm"
+	"gorm.io/gorm/clause"
+)
+
+// PaymentWebhookRepository defines the interface for payment webhook event data operations
+type PaymentWebhookRepository interface {
+	CreateEventIfNotExists(event *models.PaymentWebhookEvent) (*models.PaymentWebhookEvent, bool, error)
+	MarkEventProcessed(id uint) error
+}
+
+// paymentWebhookRepository implements PaymentWebhookRepository
+type paymentWebhookRepository struct {
+	db *gorm.DB
+}
+
+// NewPaymentWebhookRepository creates a new instance of PaymentWebhookRepository
+func NewPaymentWebhookRepository(db *gorm.DB) PaymentWebhookRepository {
+	return &paymentWebhookRepository{
+		db: db,
+	}
+}
+
+// CreateEventIfNotExists stores the event unless one with the same event key already exists.
+// It returns the stored event and whether it was newly created.
+func (r *paymentWebhookRepository) CreateEventIfNotExists(event *models.PaymentWebhookEvent) (*models.PaymentWebhookEvent, bool, error) {
+	result := r.db.Clauses(clause.OnConflict{
+		Columns:   []clause.Column{{Name: "event_key"}},
+		DoNothing: true,
+	}).Create(event)
+	if result.Error != nil {
+		return nil, false, result.Error
+	}
+
+	if result.RowsAffected > 0 {
+		return event, true, nil
+	}
+
+	var existing models.PaymentWebhookEvent
+	if err := r.db.Where("event_key = ?", event.EventKey).First(&existing).Error; err != nil {
+		return nil, false, err
+	}
+
+	return &existing, false, nil
+}
+
+// MarkEventProcessed sets processed_at on the event
+func (r *paymentWebhookRepository) MarkEventProcessed(id uint) error {
+	return r.db.Model(&models.PaymentWebhookEvent{}).
+		Where("id = ?", id).
+		Update("processed_at", time.Now()).Error
+}
@@ -0,0 +1,37 @@
+package repository
+
+import (
+	"ipl-be-svc/internal/models"
+
+	"gorm.io/gorm"
+)
+
+// StatusRepository defines the interface for master_general_statuses data operations
+type StatusRepository interface {
+	GetPublishedStatuses() ([]*models.MasterGeneralStatus, error)
+	Create(status *models.MasterGeneralStatus) error
+}
+
+// statusRepository implements StatusRepository
+type statusRepository struct {
+	db *gorm.DB
+}
+
+// NewStatusRepository creates a new instance of StatusRepository
+func NewStatusRepository(db *gorm.DB) StatusRepository {
+	return &statusRepository{
+		db: db,
+	}
+}
+
+// GetPublishedStatuses retrieves the published master_general_statuses rows ordered by ID
+func (r *statusRepository) GetPublishedStatuses() ([]*models.MasterGeneralStatus, error) {
+	var statuses []*models.MasterGeneralStatus
+	err := r.db.Where("published_at IS NOT NULL").Order("id").Find(&statuses).Error
+	return statuses, err
+}
+
+// Create creates a master_general_statuses row
+func (r *statusRepository) Create(status *models.MasterGeneralStatus) error {
+	return r.db.Create(status).Error
+}
@@ -10,6 +10,8 @@ import (
 type UserRepository interface {
 	GetUserDetailByProfileID(profileID uint) (*models.UserDetail, error)
 	GetUsersWithPenghuniRole() ([]*models.UserDetail, error)
+	GetUserByID(id uint) (*models.User, error)
+	GetRolesByUserID(userID uint) ([]*models.Role, error)
 }
 
 // userRepository implements UserRepository
@@ -74,3 +76,32 @@ func (r *userRepository) GetUsersWithPenghuniRole() ([]*models.UserDetail, error
 
 	return users, nil
 }
+
+// GetUserByID retrieves a user record by ID
+func (r *userRepository) GetUserByID(id uint) (*models.User, error) {
+	var user models.User
+
+	err := r.db.Where("id = ?", id).First(&user).Error
+	if err != nil {
+		return nil, err
+	}
+
+	return &user, nil
+}
+
+// GetRolesByUserID retrieves the roles linked to a user through up_users_role_lnk
+func (r *userRepository) GetRolesByUserID(userID uint) ([]*models.Role, error) {
+	var roles []*models.Role
+
+	err := r.db.Table("up_roles").
+		Select("up_roles.*").
+		Joins("JOIN up_users_role_lnk url ON url.role_id = up_roles.id").
+		Where("url.user_id = ?", userID).
+		Order("url.user_ord ASC, up_roles.id ASC").
+		Find(&roles).Error
+	if err != nil {
+		return nil, err
+	}
+
+	return roles, nil
+}
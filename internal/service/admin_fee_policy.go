@@ -0,0 +1,164 @@
+package service
+
+import (
+	"fmt"
+	"math"
+	"sort"
+	"strconv"
+	"strings"
+
+	"ipl-be-svc/internal/config"
+)
+
+// Admin fee modes
+const (
+	AdminFeeModeFlat       = "flat"
+	AdminFeeModePercentage = "percentage"
+	AdminFeeModeTiered     = "tiered"
+)
+
+// Admin fee rules recorded on payments
+const (
+	AdminFeeRuleWaivedKategori = "waived:kategori"
+	AdminFeeRuleWaivedRole     = "waived:role"
+)
+
+// AdminFeeTier charges Fee for invoice amounts of at least MinAmount
+type AdminFeeTier struct {
+	MinAmount int64
+	Fee       int64
+}
+
+// AdminFeePolicy calculates the admin fee added to payment links
+type AdminFeePolicy struct {
+	mode              string
+	flat              int64
+	percentage        float64
+	tiers             []AdminFeeTier
+	waivedKategoriIDs map[uint]bool
+	waivedRoleTypes   map[string]bool
+}
+
+// NewAdminFeePolicy validates the configuration and creates an AdminFeePolicy
+func NewAdminFeePolicy(cfg config.AdminFeeConfig) (*AdminFeePolicy, error) {
+	policy := &AdminFeePolicy{
+		mode:              strings.ToLower(strings.TrimSpace(cfg.Mode)),
+		flat:              int64(cfg.Flat),
+		percentage:        cfg.Percentage,
+		waivedKategoriIDs: make(map[uint]bool),
+		waivedRoleTypes:   make(map[string]bool),
+	}
+
+	switch policy.mode {
+	case AdminFeeModeFlat:
+		if policy.flat < 0 {
+			return nil, fmt.Errorf("admin fee must not be negative")
+		}
+	case AdminFeeModePercentage:
+		if policy.percentage < 0 || policy.percentage > 100 {
+			return nil, fmt.Errorf("admin fee percentage must be between 0 and 100")
+		}
+	case AdminFeeModeTiered:
+		tiers, err := parseAdminFeeTiers(cfg.Tiers)
+		if err != nil {
+			return nil, err
+		}
+		policy.tiers = tiers
+	default:
+		return nil, fmt.Errorf("unknown admin fee mode %q", cfg.Mode)
+	}
+
+	for _, value := range splitList(cfg.WaivedKategoriIDs) {
+		id, err := strconv.ParseUint(value, 10, 32)
+		if err != nil {
+			return nil, fmt.Errorf("invalid waived kategori ID %q", value)
+		}
+		policy.waivedKategoriIDs[uint(id)] = true
+	}
+	for _, value := range splitList(cfg.WaivedRoleTypes) {
+		policy.waivedRoleTypes[value] = true
+	}
+
+	return policy, nil
+}
+
+// Mode returns the configured fee mode, recorded on payments as the applied rule
+func (p *AdminFeePolicy) Mode() string {
+	return p.mode
+}
+
+// Calculate returns the fee for an invoice amount
+func (p *AdminFeePolicy) Calculate(amount int64) int64 {
+	if amount <= 0 {
+		return 0
+	}
+
+	switch p.mode {
+	case AdminFeeModePercentage:
+		// Rounded up so the fee never undercharges by a fraction of a rupiah
+		return int64(math.Ceil(float64(amount) * p.percentage / 100))
+	case AdminFeeModeTiered:
+		var fee int64
+		for _, tier := range p.tiers {
+			if amount >= tier.MinAmount {
+				fee = tier.Fee
+			}
+		}
+		return fee
+	default:
+		return p.flat
+	}
+}
+
+// IsKategoriWaived reports whether billings of the kategori are exempt from the fee
+func (p *AdminFeePolicy) IsKategoriWaived(kategoriID uint) bool {
+	return p.waivedKategoriIDs[kategoriID]
+}
+
+// IsRoleWaived reports whether residents with any of the role types are exempt from the fee
+func (p *AdminFeePolicy) IsRoleWaived(roleTypes ...string) bool {
+	for _, roleType := range roleTypes {
+		if roleType != "" && p.waivedRoleTypes[roleType] {
+			return true
+		}
+	}
+	return false
+}
+
+// parseAdminFeeTiers parses "<minimum amount>:<fee>" pairs sorted by minimum amount
+func parseAdminFeeTiers(value string) ([]AdminFeeTier, error) {
+	var tiers []AdminFeeTier
+	for _, pair := range splitList(value) {
+		parts := strings.SplitN(pair, ":", 2)
+		if len(parts) != 2 {
+			return nil, fmt.Errorf("invalid admin fee tier %q, expected <minimum amount>:<fee>", pair)
+		}
+		minAmount, err := strconv.ParseInt(strings.TrimSpace(parts[0]), 10, 64)
+		if err != nil || minAmount < 0 {
+			return nil, fmt.Errorf("invalid admin fee tier minimum amount %q", parts[0])
+		}
+		fee, err := strconv.ParseInt(strings.TrimSpace(parts[1]), 10, 64)
+		if err != nil || fee < 0 {
+			return nil, fmt.Errorf("invalid admin fee tier fee %q", parts[1])
+		}
+		tiers = append(tiers, AdminFeeTier{MinAmount: minAmount, Fee: fee})
+	}
+
+	if len(tiers) == 0 {
+		return nil, fmt.Errorf("tiered admin fee requires at least one tier")
+	}
+
+	sort.Slice(tiers, func(i, j int) bool { return tiers[i].MinAmount < tiers[j].MinAmount })
+	return tiers, nil
+}
+
+// splitList splits a comma-separated list, dropping empty entries
+func splitList(value string) []string {
+	var values []string
+	for _, part := range strings.Split(value, ",") {
+		if part = strings.TrimSpace(part); part != "" {
+			values = append(values, part)
+		}
+	}
+	return values
+}
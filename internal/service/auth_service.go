@@ -0,0 +1,105 @@
+package service
+
+import (
+	"errors"
+	"fmt"
+
+	"ipl-be-svc/internal/models"
+	"ipl-be-svc/internal/repository"
+	"ipl-be-svc/pkg/logger"
+	"ipl-be-svc/pkg/utils"
+)
+
+var (
+	// ErrInvalidToken is returned when the token cannot be parsed or verified
+	ErrInvalidToken = errors.New("invalid or expired token")
+	// ErrUserNotFound is returned when the token subject does not exist
+	ErrUserNotFound = errors.New("user not found")
+	// ErrUserBlocked is returned when the token subject has been blocked in Strapi
+	ErrUserBlocked = errors.New("user is blocked")
+)
+
+// AuthService defines the interface for authentication operations
+type AuthService interface {
+	Authenticate(tokenString string) (*AuthenticatedUser, error)
+}
+
+// AuthenticatedUser represents the caller resolved from a valid JWT
+type AuthenticatedUser struct {
+	ID         uint           `json:"id"`
+	DocumentID string         `json:"document_id"`
+	Username   string         `json:"username"`
+	Email      string         `json:"email"`
+	Roles      []*models.Role `json:"roles"`
+}
+
+// RoleIDs returns the IDs of all roles assigned to the user
+func (u *AuthenticatedUser) RoleIDs() []uint {
+	ids := make([]uint, 0, len(u.Roles))
+	for _, role := range u.Roles {
+		ids = append(ids, role.ID)
+	}
+	return ids
+}
+
+// HasRoleType reports whether the user has a role with the given Strapi role type
+func (u *AuthenticatedUser) HasRoleType(roleType string) bool {
+	for _, role := range u.Roles {
+		if role.Type == roleType {
+			return true
+		}
+	}
+	return false
+}
+
+// authService implements AuthService
+type authService struct {
+	userRepo  repository.UserRepository
+	jwtSecret string
+	logger    *logger.Logger
+}
+
+// NewAuthService creates a new instance of AuthService
+func NewAuthService(userRepo repository.UserRepository, jwtSecret string, logger *logger.Logger) AuthService {
+	return &authService{
+		userRepo:  userRepo,
+		jwtSecret: jwtSecret,
+		logger:    logger,
+	}
+}
+
+// Authenticate validates the token and loads the user together with their roles
+func (s *authService) Authenticate(tokenString string) (*AuthenticatedUser, error) {
+	claims, err := utils.ParseJWTTokenWithSecret(tokenString, s.jwtSecret)
+	if err != nil {
+		s.logger.WithError(err).Debug("Failed to parse JWT token")
+		return nil, ErrInvalidToken
+	}
+
+	if claims.UserID == 0 {
+		return nil, ErrInvalidToken
+	}
+
+	user, err := s.userRepo.GetUserByID(claims.UserID)
+	if err != nil {
+		s.logger.WithError(err).WithField("user_id", claims.UserID).Warn("Token subject not found")
+		return nil, ErrUserNotFound
+	}
+
+	if user.Blocked != nil && *user.Blocked {
+		return nil, ErrUserBlocked
+	}
+
+	roles, err := s.userRepo.GetRolesByUserID(user.ID)
+	if err != nil {
+		return nil, fmt.Errorf("failed to load user roles: %w", err)
+	}
+
+	return &AuthenticatedUser{
+		ID:         user.ID,
+		DocumentID: user.DocumentID,
+		Username:   user.Username,
+		Email:      user.Email,
+		Roles:      roles,
+	}, nil
+}
@@ -0,0 +1,157 @@
+package service
+
+import (
+	"errors"
+	"fmt"
+
+	"ipl-be-svc/internal/models"
+	"ipl-be-svc/internal/repository"
+	"ipl-be-svc/pkg/logger"
+)
+
+var (
+	// ErrBillingNotAdjustable is returned when adjusting a billing that is no longer waiting to be paid
+	ErrBillingNotAdjustable = errors.New("only unpaid and overdue billings can be adjusted")
+	// ErrNominalUnchanged is returned when an adjustment would keep the billing's nominal
+	ErrNominalUnchanged = errors.New("nominal is unchanged")
+	// ErrNominalBelowPaid is returned when an adjustment would leave nothing outstanding on a partly paid
+	// billing, or less than nothing
+	ErrNominalBelowPaid = errors.New("nominal must be above the amount already paid")
+)
+
+// CancelBillingRequest represents the request to cancel a billing
+type CancelBillingRequest struct {
+	Reason string `json:"reason" binding:"required" example:"Generated for a resident who moved out"`
+}
+
+// AdjustBillingRequest represents the request to correct a billing's nominal
+type AdjustBillingRequest struct {
+	Nominal int64  `json:"nominal" binding:"required,gt=0" example:"150000"`
+	Reason  string `json:"reason" binding:"required" example:"Wrong tariff applied"`
+}
+
+// BillingAdjustmentService defines the interface for correcting wrongly generated billings
+type BillingAdjustmentService interface {
+	CancelBilling(billingID uint, req *CancelBillingRequest, actorID uint) (*BillingTimeline, error)
+	AdjustBilling(billingID uint, req *AdjustBillingRequest, actorID uint) (*models.BillingAdjustment, error)
+	GetAdjustments(billingID uint) ([]*models.BillingAdjustment, error)
+}
+
+// billingAdjustmentService implements BillingAdjustmentService
+type billingAdjustmentService struct {
+	adjustmentRepo repository.BillingAdjustmentRepository
+	billingRepo    repository.BillingRepository
+	penaltyRepo    repository.BillingPenaltyRepository
+	stateMachine   BillingStateMachine
+	statuses       StatusRegistry
+	logger         *logger.Logger
+}
+
+// NewBillingAdjustmentService creates a new instance of BillingAdjustmentService
+func NewBillingAdjustmentService(
+	adjustmentRepo repository.BillingAdjustmentRepository,
+	billingRepo repository.BillingRepository,
+	penaltyRepo repository.BillingPenaltyRepository,
+	stateMachine BillingStateMachine,
+	statuses StatusRegistry,
+	logger *logger.Logger,
+) BillingAdjustmentService {
+	return &billingAdjustmentService{
+		adjustmentRepo: adjustmentRepo,
+		billingRepo:    billingRepo,
+		penaltyRepo:    penaltyRepo,
+		stateMachine:   stateMachine,
+		statuses:       statuses,
+		logger:         logger,
+	}
+}
+
+// CancelBilling cancels a billing that has not been paid, together with the open late fee charged on it,
+// and returns its timeline
+func (s *billingAdjustmentService) CancelBilling(billingID uint, req *CancelBillingRequest, actorID uint) (*BillingTimeline, error) {
+	if _, err := s.billingRepo.GetBillingByID(billingID); err != nil {
+		return nil, err
+	}
+
+	if _, err := s.stateMachine.Transition(&BillingTransitionRequest{
+		BillingIDs: []uint{billingID},
+		To:         models.BillingStateCancelled,
+		ActorID:    actorID,
+		Reason:     req.Reason,
+	}); err != nil {
+		return nil, err
+	}
+
+	statuses := s.statuses.IDs()
+	penalties, err := s.penaltyRepo.GetOpenPenaltyBillings([]uint{billingID}, []uint{statuses.Paid, statuses.Cancelled})
+	if err != nil {
+		return nil, fmt.Errorf("failed to get penalty billings: %w", err)
+	}
+	if len(penalties) > 0 {
+		penaltyIDs := make([]uint, len(penalties))
+		for i, penalty := range penalties {
+			penaltyIDs[i] = penalty.ID
+		}
+		if _, err := s.stateMachine.Transition(&BillingTransitionRequest{
+			BillingIDs:  penaltyIDs,
+			To:          models.BillingStateCancelled,
+			ActorID:     actorID,
+			Reason:      fmt.Sprintf("Billing #%d cancelled: %s", billingID, req.Reason),
+			SkipInvalid: true,
+		}); err != nil {
+			return nil, fmt.Errorf("failed to cancel penalty billings: %w", err)
+		}
+	}
+
+	return s.stateMachine.GetTimeline(billingID)
+}
+
+// AdjustBilling sets the nominal of an unpaid or overdue billing, recording the original amount, the
+// admin and the reason. What was already paid is kept, so the outstanding balance moves with the nominal
+// and the nominal must stay above it.
+func (s *billingAdjustmentService) AdjustBilling(billingID uint, req *AdjustBillingRequest, actorID uint) (*models.BillingAdjustment, error) {
+	adjustment := &models.BillingAdjustment{
+		BillingID:  billingID,
+		NewNominal: req.Nominal,
+		Reason:     req.Reason,
+	}
+	if actorID != 0 {
+		adjustment.ActorID = &actorID
+	}
+
+	err := s.adjustmentRepo.Create(adjustment, func(billing *models.Billing, statusID uint) error {
+		switch s.statuses.State(statusID) {
+		case models.BillingStateUnpaid, models.BillingStateOverdue:
+		default:
+			return ErrBillingNotAdjustable
+		}
+		if billing.Nominal != nil && *billing.Nominal == req.Nominal {
+			return ErrNominalUnchanged
+		}
+		// A billing with nothing outstanding would stay unpaid, it is settled by recording a payment instead
+		if paid := billing.PaidAmount(); paid > 0 && req.Nominal <= paid {
+			return fmt.Errorf("%w: %d paid", ErrNominalBelowPaid, paid)
+		}
+		return nil
+	})
+	if err != nil {
+		return nil, err
+	}
+
+	s.logger.WithFields(map[string]interface{}{
+		"billing_id":       billingID,
+		"original_nominal": adjustment.OriginalNominal,
+		"new_nominal":      adjustment.NewNominal,
+		"actor_id":         actorID,
+	}).Info("Billing adjusted")
+
+	return adjustment, nil
+}
+
+// GetAdjustments retrieves the adjustments of a billing, oldest first
+func (s *billingAdjustmentService) GetAdjustments(billingID uint) ([]*models.BillingAdjustment, error) {
+	if _, err := s.billingRepo.GetBillingByID(billingID); err != nil {
+		return nil, err
+	}
+	return s.adjustmentRepo.GetByBillingID(billingID)
+}
@@ -0,0 +1,300 @@
+package service
+
+import (
+	"errors"
+	"fmt"
+	"strings"
+	"time"
+
+	"ipl-be-svc/internal/models"
+
+	"github.com/google/uuid"
+	"gorm.io/gorm"
+)
+
+// defaultKategoriTransaksiID is the kategori transaksi of billings created without one, as in bulk generation
+const defaultKategoriTransaksiID = 1
+
+var (
+	// ErrInvalidBilling is returned when a billing cannot be created or edited as requested
+	ErrInvalidBilling = errors.New("invalid billing")
+	// ErrBillingNotEditable is returned when editing a billing that is no longer waiting to be paid
+	ErrBillingNotEditable = errors.New("only unpaid and overdue billings can be edited")
+	// ErrBillingNotDeletable is returned when deleting a billing that is not unpaid or cancelled, or that
+	// was partly paid or can still be paid through an open payment link
+	ErrBillingNotDeletable = errors.New("only unpaid and cancelled billings without payments can be deleted")
+)
+
+// BillingDetail is a billing with its status, kategori transaksi, owner and attachments
+type BillingDetail struct {
+	*models.Billing
+	Status            *BillingStatus                  `json:"status"`
+	KategoriTransaksi *models.MasterKategoriTransaksi `json:"kategori_transaksi"`
+	Owner             *models.BillingOwner            `json:"owner"`
+	Attachments       []*models.BillingAttachment     `json:"attachments"`
+}
+
+// CreateBillingRequest represents the request to create a one-off billing for a resident
+type CreateBillingRequest struct {
+	UserID      uint   `json:"user_id" binding:"required" example:"12"`
+	NamaBilling string `json:"nama_billing" binding:"required" example:"Perbaikan pagar"`
+	Keterangan  string `json:"keterangan" example:"Biaya perbaikan pagar depan"`
+	Nominal     int64  `json:"nominal" binding:"required,gt=0" example:"250000"`
+	Month       int    `json:"month" binding:"required,min=1,max=12" example:"6"`
+	Year        int    `json:"year" binding:"required,min=2020,max=2100" example:"2025"`
+	// DueDate defaults to the configured due day of the month
+	DueDate             string `json:"due_date" example:"2025-06-20"`
+	KategoriTransaksiID uint   `json:"kategori_transaksi_id" example:"1"`
+}
+
+// UpdateBillingRequest represents the fields of a billing that can be edited. Omitted fields are kept.
+type UpdateBillingRequest struct {
+	NamaBilling         *string `json:"nama_billing" example:"Perbaikan pagar"`
+	Keterangan          *string `json:"keterangan" example:"Biaya perbaikan pagar depan"`
+	DueDate             *string `json:"due_date" example:"2025-06-25"`
+	KategoriTransaksiID *uint   `json:"kategori_transaksi_id" example:"1"`
+}
+
+// GetBilling retrieves a published billing with its status, kategori transaksi, owner and attachments
+func (s *billingService) GetBilling(id uint) (*BillingDetail, error) {
+	billing, err := s.billingRepo.GetBillingByID(id)
+	if err != nil {
+		return nil, err
+	}
+	// Deleted billings are unpublished
+	if billing.PublishedAt == nil {
+		return nil, gorm.ErrRecordNotFound
+	}
+
+	detail := &BillingDetail{Billing: billing}
+
+	statusIDs, err := s.billingRepo.GetBillingStatusIDs([]uint{id})
+	if err != nil {
+		return nil, fmt.Errorf("failed to get billing status: %w", err)
+	}
+	if statusID, ok := statusIDs[id]; ok {
+		detail.Status = &BillingStatus{State: s.statuses.State(statusID), StatusID: statusID}
+		status, err := s.billingRepo.GetStatusByID(statusID)
+		if err != nil && !errors.Is(err, gorm.ErrRecordNotFound) {
+			return nil, fmt.Errorf("failed to get billing status: %w", err)
+		}
+		if status != nil && status.Status != nil {
+			detail.Status.Name = *status.Status
+		}
+	}
+
+	kategoriIDs, err := s.billingRepo.GetBillingKategoriIDs([]uint{id})
+	if err != nil {
+		return nil, fmt.Errorf("failed to get billing kategori transaksi: %w", err)
+	}
+	if kategoriID, ok := kategoriIDs[id]; ok {
+		detail.KategoriTransaksi, err = s.billingRepo.GetKategoriTransaksiByID(kategoriID)
+		if err != nil && !errors.Is(err, gorm.ErrRecordNotFound) {
+			return nil, fmt.Errorf("failed to get billing kategori transaksi: %w", err)
+		}
+	}
+
+	owners, err := s.billingRepo.GetBillingOwners([]uint{id})
+	if err != nil {
+		return nil, fmt.Errorf("failed to get billing owner: %w", err)
+	}
+	if len(owners) > 0 {
+		detail.Owner = owners[0]
+	}
+
+	detail.Attachments, err = s.GetBillingAttachments(id)
+	if err != nil {
+		return nil, err
+	}
+
+	return detail, nil
+}
+
+// CreateBilling creates an unpaid one-off billing for a resident, recording the admin who created it in
+// its timeline
+func (s *billingService) CreateBilling(req *CreateBillingRequest, actorID uint) (*BillingDetail, error) {
+	recipients, err := s.billingRepo.GetBillingRecipients([]uint{req.UserID})
+	if err != nil {
+		return nil, fmt.Errorf("failed to get resident: %w", err)
+	}
+	switch {
+	case len(recipients) == 0:
+		return nil, fmt.Errorf("%w: user %d not found", ErrInvalidBilling, req.UserID)
+	case recipients[0].Blocked:
+		return nil, fmt.Errorf("%w: user %d is blocked", ErrInvalidBilling, req.UserID)
+	case recipients[0].ProfileID == nil:
+		return nil, fmt.Errorf("%w: user %d has no profile", ErrInvalidBilling, req.UserID)
+	}
+
+	namaBilling := strings.TrimSpace(req.NamaBilling)
+	if namaBilling == "" {
+		return nil, fmt.Errorf("%w: nama_billing must not be empty", ErrInvalidBilling)
+	}
+
+	kategoriID := req.KategoriTransaksiID
+	if kategoriID == 0 {
+		kategoriID = defaultKategoriTransaksiID
+	}
+	if err := s.checkKategoriTransaksi(kategoriID); err != nil {
+		return nil, err
+	}
+
+	dueDate := s.duePolicy.DueDate(nil, req.Month, req.Year)
+	if req.DueDate != "" {
+		if dueDate, err = parseDueDate(req.DueDate); err != nil {
+			return nil, err
+		}
+	}
+
+	// Always use admin user (ID 1) as the creator, as bulk generation does
+	adminID := 1
+	now := time.Now()
+	docID := "single-" + uuid.New().String()
+	month := req.Month
+	year := req.Year
+	nominal := req.Nominal
+	keterangan := req.Keterangan
+	billing := &models.Billing{
+		DocumentID:        &docID,
+		NamaBilling:       &namaBilling,
+		Keterangan:        &keterangan,
+		Bulan:             &month,
+		Tahun:             &year,
+		Nominal:           &nominal,
+		OutstandingAmount: &nominal,
+		DueDate:           &dueDate,
+		CreatedAt:         &now,
+		UpdatedAt:         &now,
+		PublishedAt:       &now,
+		CreatedByID:       &adminID,
+		UpdatedByID:       &adminID,
+	}
+
+	reason := "Billing created"
+	history := &models.BillingStatusHistory{Reason: &reason}
+	if actorID != 0 {
+		history.ActorID = &actorID
+	}
+
+	if err := s.billingRepo.CreateBilling(billing, req.UserID, s.statuses.IDs().Unpaid, kategoriID, history); err != nil {
+		return nil, fmt.Errorf("failed to create billing: %w", err)
+	}
+
+	s.logger.WithFields(map[string]interface{}{
+		"billing_id": billing.ID,
+		"user_id":    req.UserID,
+		"nominal":    nominal,
+		"actor_id":   actorID,
+	}).Info("Billing created")
+
+	return s.GetBilling(billing.ID)
+}
+
+// UpdateBilling edits the name, description, due date or kategori transaksi of an unpaid or overdue
+// billing. The nominal is corrected through an adjustment so the original amount is kept.
+func (s *billingService) UpdateBilling(id uint, req *UpdateBillingRequest, actorID uint) (*BillingDetail, error) {
+	updates := map[string]interface{}{
+		"updated_at": time.Now(),
+	}
+
+	if req.NamaBilling != nil {
+		namaBilling := strings.TrimSpace(*req.NamaBilling)
+		if namaBilling == "" {
+			return nil, fmt.Errorf("%w: nama_billing must not be empty", ErrInvalidBilling)
+		}
+		updates["nama_billing"] = namaBilling
+	}
+	if req.Keterangan != nil {
+		updates["keterangan"] = *req.Keterangan
+	}
+	if req.DueDate != nil {
+		dueDate, err := parseDueDate(*req.DueDate)
+		if err != nil {
+			return nil, err
+		}
+		updates["due_date"] = dueDate
+	}
+	if req.KategoriTransaksiID != nil {
+		if err := s.checkKategoriTransaksi(*req.KategoriTransaksiID); err != nil {
+			return nil, err
+		}
+	}
+
+	err := s.billingRepo.UpdateBilling(id, updates, req.KategoriTransaksiID, func(billing *models.Billing, statusID uint) error {
+		if billing.PublishedAt == nil {
+			return gorm.ErrRecordNotFound
+		}
+		switch s.statuses.State(statusID) {
+		case models.BillingStateUnpaid, models.BillingStateOverdue:
+			return nil
+		}
+		return ErrBillingNotEditable
+	})
+	if err != nil {
+		return nil, err
+	}
+
+	s.logger.WithFields(map[string]interface{}{
+		"billing_id": id,
+		"actor_id":   actorID,
+	}).Info("Billing updated")
+
+	return s.GetBilling(id)
+}
+
+// DeleteBilling unpublishes an unpaid or cancelled billing. Its status history is kept, and billings that
+// were overdue must be cancelled first so their late fees are cancelled with them. Billings partly paid or
+// covered by an open payment link are kept, so no payment is left without its billing.
+func (s *billingService) DeleteBilling(id uint, actorID uint) error {
+	err := s.billingRepo.UnpublishBilling(id, func(billing *models.Billing, statusID uint) error {
+		if billing.PublishedAt == nil {
+			return gorm.ErrRecordNotFound
+		}
+		switch s.statuses.State(statusID) {
+		case models.BillingStateUnpaid, models.BillingStateCancelled:
+		default:
+			return ErrBillingNotDeletable
+		}
+		if paid := billing.PaidAmount(); paid > 0 {
+			return fmt.Errorf("%w: %d paid", ErrBillingNotDeletable, paid)
+		}
+		open, err := s.billingRepo.HasOpenPayment(billing.ID)
+		if err != nil {
+			return fmt.Errorf("failed to check payment links: %w", err)
+		}
+		if open {
+			return fmt.Errorf("%w: an open payment link covers it", ErrBillingNotDeletable)
+		}
+		return nil
+	})
+	if err != nil {
+		return err
+	}
+
+	s.logger.WithFields(map[string]interface{}{
+		"billing_id": id,
+		"actor_id":   actorID,
+	}).Info("Billing deleted")
+
+	return nil
+}
+
+// checkKategoriTransaksi fails with ErrInvalidBilling when the kategori transaksi does not exist
+func (s *billingService) checkKategoriTransaksi(id uint) error {
+	if _, err := s.billingRepo.GetKategoriTransaksiByID(id); err != nil {
+		if errors.Is(err, gorm.ErrRecordNotFound) {
+			return fmt.Errorf("%w: kategori transaksi %d not found", ErrInvalidBilling, id)
+		}
+		return fmt.Errorf("failed to get kategori transaksi: %w", err)
+	}
+	return nil
+}
+
+// parseDueDate parses a YYYY-MM-DD due date, comparable with the dates of BillingDuePolicy
+func parseDueDate(value string) (time.Time, error) {
+	dueDate, err := time.Parse("2006-01-02", value)
+	if err != nil {
+		return time.Time{}, fmt.Errorf("%w: due_date must be formatted as YYYY-MM-DD", ErrInvalidBilling)
+	}
+	return dueDate, nil
+}
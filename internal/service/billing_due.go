@@ -0,0 +1,70 @@
+package service
+
+import (
+	"fmt"
+	"time"
+
+	"ipl-be-svc/internal/config"
+)
+
+// BillingDuePolicy works out when billings are due
+type BillingDuePolicy struct {
+	defaultDueDay int
+	location      *time.Location
+}
+
+// NewBillingDuePolicy validates the configuration and creates a BillingDuePolicy
+func NewBillingDuePolicy(cfg config.BillingDueConfig) (*BillingDuePolicy, error) {
+	if cfg.DefaultDueDay < 1 || cfg.DefaultDueDay > 31 {
+		return nil, fmt.Errorf("billing default due day must be between 1 and 31")
+	}
+
+	location, err := time.LoadLocation(cfg.Timezone)
+	if err != nil {
+		return nil, fmt.Errorf("invalid billing due timezone %q: %w", cfg.Timezone, err)
+	}
+
+	return &BillingDuePolicy{
+		defaultDueDay: cfg.DefaultDueDay,
+		location:      location,
+	}, nil
+}
+
+// DefaultDueDay returns the due day of billings whose setting has none
+func (p *BillingDuePolicy) DefaultDueDay() int {
+	return p.defaultDueDay
+}
+
+// DueDate returns the due date of a billing for the month and year. A dueDay past the end of a short
+// month falls on its last day; nil or out of range days use the default.
+func (p *BillingDuePolicy) DueDate(dueDay *int, month int, year int) time.Time {
+	day := p.defaultDueDay
+	if dueDay != nil && *dueDay >= 1 && *dueDay <= 31 {
+		day = *dueDay
+	}
+	if last := time.Date(year, time.Month(month)+1, 0, 0, 0, 0, 0, time.UTC).Day(); day > last {
+		day = last
+	}
+	return time.Date(year, time.Month(month), day, 0, 0, 0, 0, time.UTC)
+}
+
+// NextDueDate returns the first default due date on or after the date, for charges made that day
+func (p *BillingDuePolicy) NextDueDate(from time.Time) time.Time {
+	due := p.DueDate(nil, int(from.Month()), from.Year())
+	if due.Before(from) {
+		next := time.Date(from.Year(), from.Month()+1, 1, 0, 0, 0, 0, time.UTC)
+		due = p.DueDate(nil, int(next.Month()), next.Year())
+	}
+	return due
+}
+
+// Today returns the current date in the policy's timezone, comparable with due dates
+func (p *BillingDuePolicy) Today() time.Time {
+	now := time.Now().In(p.location)
+	return time.Date(now.Year(), now.Month(), now.Day(), 0, 0, 0, 0, time.UTC)
+}
+
+// Location returns the timezone due dates are in
+func (p *BillingDuePolicy) Location() *time.Location {
+	return p.location
+}
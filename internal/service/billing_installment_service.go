@@ -0,0 +1,179 @@
+package service
+
+import (
+	"errors"
+	"fmt"
+
+	"ipl-be-svc/internal/models"
+	"ipl-be-svc/internal/repository"
+	"ipl-be-svc/pkg/logger"
+
+	"gorm.io/gorm"
+)
+
+var (
+	// ErrInvalidInstallmentPlan is returned when installments do not add up to the outstanding balance or are out of order
+	ErrInvalidInstallmentPlan = errors.New("invalid installment plan")
+	// ErrBillingNotInstallable is returned when defining installments for a billing that is not waiting to be paid
+	ErrBillingNotInstallable = errors.New("only unpaid and overdue billings with an outstanding balance can be paid in installments")
+	// ErrInstallmentPaid is returned when creating a payment link for an installment that is already paid
+	ErrInstallmentPaid = errors.New("installment is already paid")
+)
+
+// InstallmentRequest is one installment of an installment plan
+type InstallmentRequest struct {
+	Amount  int64  `json:"amount" binding:"required,gt=0" example:"500000"`
+	DueDate string `json:"due_date" binding:"required" example:"2025-07-10"`
+}
+
+// InstallmentPlanRequest represents the request to define how a billing's outstanding balance is paid
+type InstallmentPlanRequest struct {
+	Installments []InstallmentRequest `json:"installments" binding:"required,min=2,dive"`
+}
+
+// InstallmentPlan is a billing's balance with the installments it is paid in
+type InstallmentPlan struct {
+	BillingID    uint                         `json:"billing_id"`
+	Nominal      int64                        `json:"nominal"`
+	Outstanding  int64                        `json:"outstanding"`
+	Installments []*models.BillingInstallment `json:"installments"`
+}
+
+// BillingInstallmentService defines the interface for paying billings in installments
+type BillingInstallmentService interface {
+	SetPlan(billingID uint, req *InstallmentPlanRequest, actorID uint) (*InstallmentPlan, error)
+	GetPlan(billingID uint) (*InstallmentPlan, error)
+}
+
+// billingInstallmentService implements BillingInstallmentService
+type billingInstallmentService struct {
+	installmentRepo repository.BillingInstallmentRepository
+	billingRepo     repository.BillingRepository
+	statuses        StatusRegistry
+	logger          *logger.Logger
+}
+
+// NewBillingInstallmentService creates a new instance of BillingInstallmentService
+func NewBillingInstallmentService(installmentRepo repository.BillingInstallmentRepository, billingRepo repository.BillingRepository, statuses StatusRegistry, logger *logger.Logger) BillingInstallmentService {
+	return &billingInstallmentService{
+		installmentRepo: installmentRepo,
+		billingRepo:     billingRepo,
+		statuses:        statuses,
+		logger:          logger,
+	}
+}
+
+// SetPlan replaces the installment plan of an unpaid or overdue billing. The installments must add up to
+// its outstanding balance and be due in order.
+func (s *billingInstallmentService) SetPlan(billingID uint, req *InstallmentPlanRequest, actorID uint) (*InstallmentPlan, error) {
+	installments := make([]*models.BillingInstallment, len(req.Installments))
+	var total int64
+	for i, item := range req.Installments {
+		dueDate, err := parseDueDate(item.DueDate)
+		if err != nil {
+			return nil, fmt.Errorf("%w: installment %d: due_date must be formatted as YYYY-MM-DD", ErrInvalidInstallmentPlan, i+1)
+		}
+		if i > 0 && dueDate.Before(installments[i-1].DueDate) {
+			return nil, fmt.Errorf("%w: installment %d is due before installment %d", ErrInvalidInstallmentPlan, i+1, i)
+		}
+
+		installments[i] = &models.BillingInstallment{
+			BillingID: billingID,
+			Sequence:  i + 1,
+			Amount:    item.Amount,
+			DueDate:   dueDate,
+		}
+		if actorID != 0 {
+			installments[i].ActorID = &actorID
+		}
+		total += item.Amount
+	}
+
+	err := s.installmentRepo.ReplacePlan(billingID, installments, func(billing *models.Billing, statusID uint) error {
+		if billing.PublishedAt == nil {
+			return gorm.ErrRecordNotFound
+		}
+		switch s.statuses.State(statusID) {
+		case models.BillingStateUnpaid, models.BillingStateOverdue:
+		default:
+			return ErrBillingNotInstallable
+		}
+		outstanding := billing.Outstanding()
+		if outstanding <= 0 {
+			return ErrBillingNotInstallable
+		}
+		if total != outstanding {
+			return fmt.Errorf("%w: installments add up to %d, %d is outstanding", ErrInvalidInstallmentPlan, total, outstanding)
+		}
+		return nil
+	})
+	if err != nil {
+		return nil, err
+	}
+
+	s.logger.WithFields(map[string]interface{}{
+		"billing_id":   billingID,
+		"installments": len(installments),
+		"actor_id":     actorID,
+	}).Info("Billing installment plan set")
+
+	return s.GetPlan(billingID)
+}
+
+// GetPlan retrieves the installment plan of a billing with how much of each installment is paid
+func (s *billingInstallmentService) GetPlan(billingID uint) (*InstallmentPlan, error) {
+	billing, err := s.billingRepo.GetBillingByID(billingID)
+	if err != nil {
+		return nil, err
+	}
+	if billing.PublishedAt == nil {
+		return nil, gorm.ErrRecordNotFound
+	}
+
+	installments, err := s.installmentRepo.GetByBillingID(billingID)
+	if err != nil {
+		return nil, fmt.Errorf("failed to get installments: %w", err)
+	}
+	allocateInstallments(installments, billing.Outstanding())
+
+	plan := &InstallmentPlan{
+		BillingID:    billingID,
+		Outstanding:  billing.Outstanding(),
+		Installments: installments,
+	}
+	if billing.Nominal != nil {
+		plan.Nominal = *billing.Nominal
+	}
+	return plan, nil
+}
+
+// allocateInstallments works out how much of each installment is paid. The plan added up to the outstanding
+// balance when it was set, so whatever has been paid off since covers the installments in sequence.
+func allocateInstallments(installments []*models.BillingInstallment, outstanding int64) {
+	var total int64
+	for _, installment := range installments {
+		total += installment.Amount
+	}
+
+	paid := total - outstanding
+	if paid < 0 {
+		paid = 0
+	}
+
+	for _, installment := range installments {
+		installment.PaidAmount = installment.Amount
+		if paid < installment.Amount {
+			installment.PaidAmount = paid
+		}
+		paid -= installment.PaidAmount
+
+		switch {
+		case installment.PaidAmount == installment.Amount:
+			installment.Status = models.InstallmentStatusPaid
+		case installment.PaidAmount > 0:
+			installment.Status = models.InstallmentStatusPartiallyPaid
+		default:
+			installment.Status = models.InstallmentStatusUnpaid
+		}
+	}
+}
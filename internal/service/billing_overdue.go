@@ -0,0 +1,231 @@
+package service
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"time"
+
+	"ipl-be-svc/internal/config"
+	"ipl-be-svc/internal/models"
+	"ipl-be-svc/internal/repository"
+	"ipl-be-svc/pkg/logger"
+
+	"gorm.io/gorm"
+)
+
+// OverdueBillingJob is the job name of the daily overdue billing transition
+const OverdueBillingJob = "overdue_billings"
+
+const (
+	// overdueBillingLockID is the advisory lock keeping the overdue job on one replica
+	overdueBillingLockID = 2
+	// overdueJobTickInterval is how often the overdue job checks whether today's run is due
+	overdueJobTickInterval = 5 * time.Minute
+	// overdueBatchSize bounds how many billings move to overdue in one transaction
+	overdueBatchSize = 500
+)
+
+// OverdueBillingResult is the result recorded on an overdue job run
+type OverdueBillingResult struct {
+	Date            string            `json:"date"`
+	BackfilledCount int64             `json:"backfilled_count"`
+	OverdueCount    int64             `json:"overdue_count"`
+	Penalties       *PenaltyRunResult `json:"penalties,omitempty"`
+}
+
+// BillingOverdueJob moves unpaid billings past their due date to the overdue status once a day and
+// charges their late fees
+type BillingOverdueJob interface {
+	Start(ctx context.Context)
+	RunDue() error
+}
+
+// billingOverdueJob implements BillingOverdueJob
+type billingOverdueJob struct {
+	billingRepo    repository.BillingRepository
+	jobRunRepo     repository.JobRunRepository
+	penaltyService BillingPenaltyService
+	stateMachine   BillingStateMachine
+	statuses       StatusRegistry
+	duePolicy      *BillingDuePolicy
+	hour           int
+	logger         *logger.Logger
+}
+
+// NewBillingOverdueJob validates the configuration and creates a new BillingOverdueJob
+func NewBillingOverdueJob(billingRepo repository.BillingRepository, jobRunRepo repository.JobRunRepository, penaltyService BillingPenaltyService, stateMachine BillingStateMachine, statuses StatusRegistry, duePolicy *BillingDuePolicy, cfg config.BillingDueConfig, logger *logger.Logger) (BillingOverdueJob, error) {
+	if cfg.OverdueJobHour < 0 || cfg.OverdueJobHour > 23 {
+		return nil, fmt.Errorf("billing overdue job hour must be between 0 and 23")
+	}
+
+	return &billingOverdueJob{
+		billingRepo:    billingRepo,
+		jobRunRepo:     jobRunRepo,
+		penaltyService: penaltyService,
+		stateMachine:   stateMachine,
+		statuses:       statuses,
+		duePolicy:      duePolicy,
+		hour:           cfg.OverdueJobHour,
+		logger:         logger,
+	}, nil
+}
+
+// Start checks whether today's run is due right away and then periodically until ctx is cancelled
+func (j *billingOverdueJob) Start(ctx context.Context) {
+	j.logger.WithFields(map[string]interface{}{
+		"hour":     j.hour,
+		"timezone": j.duePolicy.Location().String(),
+	}).Info("Overdue billing job started")
+
+	go func() {
+		ticker := time.NewTicker(overdueJobTickInterval)
+		defer ticker.Stop()
+
+		for {
+			if err := j.RunDue(); err != nil {
+				j.logger.WithError(err).Error("Overdue billing job run failed")
+			}
+
+			select {
+			case <-ctx.Done():
+				j.logger.Info("Overdue billing job stopped")
+				return
+			case <-ticker.C:
+			}
+		}
+	}()
+}
+
+// RunDue runs today's overdue transition once the configured hour has passed, unless it already succeeded
+func (j *billingOverdueJob) RunDue() error {
+	due, err := j.isDue(time.Now())
+	if err != nil || !due {
+		return err
+	}
+
+	acquired, err := j.jobRunRepo.RunExclusive(overdueBillingLockID, func() error {
+		// Another replica may have finished the run while this one waited
+		due, err := j.isDue(time.Now())
+		if err != nil || !due {
+			return err
+		}
+
+		j.run()
+		return nil
+	})
+	if err != nil {
+		return err
+	}
+	if !acquired {
+		j.logger.Debug("Overdue billing job is running on another replica")
+	}
+
+	return nil
+}
+
+// isDue reports whether today's run has reached its hour without succeeding
+func (j *billingOverdueJob) isDue(now time.Time) (bool, error) {
+	if now.In(j.duePolicy.Location()).Hour() < j.hour {
+		return false, nil
+	}
+
+	latest, err := j.jobRunRepo.GetLatest(OverdueBillingJob, j.duePolicy.Today().Format("2006-01-02"))
+	if err != nil {
+		if errors.Is(err, gorm.ErrRecordNotFound) {
+			return true, nil
+		}
+		return false, fmt.Errorf("failed to get job run: %w", err)
+	}
+
+	// An interrupted run is left "running" and is retried like a failed one
+	return latest.Status != models.JobRunStatusSucceeded && now.Sub(latest.StartedAt) >= jobRetryInterval, nil
+}
+
+// run moves today's overdue billings, charges their late fees and records the run
+func (j *billingOverdueJob) run() {
+	today := j.duePolicy.Today()
+	run := &models.JobRun{
+		Job:       OverdueBillingJob,
+		Period:    today.Format("2006-01-02"),
+		Trigger:   JobTriggerSchedule,
+		Status:    models.JobRunStatusRunning,
+		StartedAt: time.Now(),
+	}
+	if err := j.jobRunRepo.Create(run); err != nil {
+		j.logger.WithError(err).WithField("period", run.Period).Error("Failed to record job run")
+		return
+	}
+
+	result, err := j.markOverdue(today)
+	if result != nil {
+		if encoded, marshalErr := json.Marshal(result); marshalErr == nil {
+			resultStr := string(encoded)
+			run.Result = &resultStr
+		}
+	}
+
+	finishedAt := time.Now()
+	run.FinishedAt = &finishedAt
+	run.Status = models.JobRunStatusSucceeded
+	if err != nil {
+		message := err.Error()
+		run.Error = &message
+		run.Status = models.JobRunStatusFailed
+		j.logger.WithError(err).WithField("period", run.Period).Error("Overdue billing transition failed")
+	} else {
+		j.logger.WithFields(map[string]interface{}{
+			"period":            run.Period,
+			"backfilled_count":  result.BackfilledCount,
+			"overdue_count":     result.OverdueCount,
+			"penalties_created": result.Penalties.CreatedCount,
+			"penalties_updated": result.Penalties.UpdatedCount,
+		}).Info("Overdue billings marked")
+	}
+
+	if err := j.jobRunRepo.Update(run); err != nil {
+		j.logger.WithError(err).WithField("period", run.Period).Error("Failed to record job run result")
+	}
+}
+
+// markOverdue gives billings without a due date one, moves unpaid billings due before today to the overdue
+// status through the state machine as the system and charges the late fees of overdue billings
+func (j *billingOverdueJob) markOverdue(today time.Time) (*OverdueBillingResult, error) {
+	result := &OverdueBillingResult{Date: today.Format("2006-01-02")}
+	statuses := j.statuses.IDs()
+
+	var err error
+	result.BackfilledCount, err = j.billingRepo.BackfillDueDates(j.duePolicy.DefaultDueDay())
+	if err != nil {
+		return result, fmt.Errorf("failed to backfill due dates: %w", err)
+	}
+
+	pastDue, err := j.billingRepo.GetPastDueBillingIDs(statuses.Unpaid, today)
+	if err != nil {
+		return result, fmt.Errorf("failed to get past due billings: %w", err)
+	}
+	for start := 0; start < len(pastDue); start += overdueBatchSize {
+		// A billing paid or changed since it was listed no longer moves
+		moved, err := j.stateMachine.Transition(&BillingTransitionRequest{
+			BillingIDs:  pastDue[start:min(start+overdueBatchSize, len(pastDue))],
+			To:          models.BillingStateOverdue,
+			Reason:      "Past due date",
+			SkipInvalid: true,
+		})
+		if err != nil {
+			return result, fmt.Errorf("failed to mark overdue billings: %w", err)
+		}
+		result.OverdueCount += int64(len(moved))
+	}
+
+	result.Penalties, err = j.penaltyService.ApplyPenalties(today)
+	if err != nil {
+		return result, fmt.Errorf("failed to apply penalties: %w", err)
+	}
+	if result.Penalties.FailedCount > 0 {
+		return result, fmt.Errorf("%d penalties failed", result.Penalties.FailedCount)
+	}
+
+	return result, nil
+}
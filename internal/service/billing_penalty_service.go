@@ -0,0 +1,361 @@
+package service
+
+import (
+	"errors"
+	"fmt"
+	"math"
+	"time"
+
+	"ipl-be-svc/internal/models"
+	"ipl-be-svc/internal/repository"
+	"ipl-be-svc/pkg/logger"
+
+	"github.com/google/uuid"
+)
+
+var (
+	// ErrInvalidPenaltyRule is returned when a penalty rule does not charge anything or its values are out of range
+	ErrInvalidPenaltyRule = errors.New("invalid penalty rule")
+	// ErrPenaltyWaived is returned when waiving a penalty that is already waived
+	ErrPenaltyWaived = errors.New("penalty already waived")
+	// ErrPenaltyPaid is returned when waiving a penalty whose billing has been paid
+	ErrPenaltyPaid = errors.New("penalty already paid")
+	// ErrPenaltyBeingPaid is returned when waiving a penalty while a payment of its billing is under way
+	ErrPenaltyBeingPaid = errors.New("penalty is being paid")
+)
+
+// BillingPenaltyService defines the interface for late fees on overdue billings
+type BillingPenaltyService interface {
+	ListRules() ([]*models.BillingPenaltyRule, error)
+	SaveRule(settingBillingID uint, req *PenaltyRuleRequest) (*models.BillingPenaltyRule, error)
+	DeleteRule(settingBillingID uint) error
+	GetPenaltyByBillingID(billingID uint) (*models.BillingPenalty, error)
+	WaivePenalty(id uint, req *WaivePenaltyRequest, actorID uint) (*models.BillingPenalty, error)
+	ApplyPenalties(today time.Time) (*PenaltyRunResult, error)
+}
+
+// PenaltyRuleRequest represents the late fee of a setting billing
+type PenaltyRuleRequest struct {
+	Mode       string  `json:"mode" binding:"required,oneof=flat percentage" example:"flat"`
+	Amount     int64   `json:"amount" example:"10000"`   // Charged per month late in flat mode
+	Percentage float64 `json:"percentage" example:"2.5"` // Percent of the billing nominal per month late in percentage mode
+	Cap        int64   `json:"cap" example:"50000"`      // Maximum penalty, 0 for no cap
+	IsActive   *bool   `json:"is_active" example:"true"`
+}
+
+// WaivePenaltyRequest represents the request to waive a penalty
+type WaivePenaltyRequest struct {
+	Reason string `json:"reason" binding:"required" example:"Resident was hospitalised"`
+}
+
+// PenaltyRunResult counts the penalties a daily run created and re-priced
+type PenaltyRunResult struct {
+	CreatedCount int `json:"created_count"`
+	UpdatedCount int `json:"updated_count"`
+	FailedCount  int `json:"failed_count"`
+}
+
+// billingPenaltyService implements BillingPenaltyService
+type billingPenaltyService struct {
+	penaltyRepo repository.BillingPenaltyRepository
+	billingRepo repository.BillingRepository
+	duePolicy   *BillingDuePolicy
+	statuses    StatusRegistry
+	logger      *logger.Logger
+}
+
+// NewBillingPenaltyService creates a new instance of BillingPenaltyService
+func NewBillingPenaltyService(penaltyRepo repository.BillingPenaltyRepository, billingRepo repository.BillingRepository, duePolicy *BillingDuePolicy, statuses StatusRegistry, logger *logger.Logger) BillingPenaltyService {
+	return &billingPenaltyService{
+		penaltyRepo: penaltyRepo,
+		billingRepo: billingRepo,
+		duePolicy:   duePolicy,
+		statuses:    statuses,
+		logger:      logger,
+	}
+}
+
+// ListRules lists the penalty rules of every setting billing that has one
+func (s *billingPenaltyService) ListRules() ([]*models.BillingPenaltyRule, error) {
+	return s.penaltyRepo.ListRules()
+}
+
+// SaveRule creates or replaces the penalty rule of a setting billing
+func (s *billingPenaltyService) SaveRule(settingBillingID uint, req *PenaltyRuleRequest) (*models.BillingPenaltyRule, error) {
+	switch {
+	case req.Mode == models.PenaltyModeFlat && req.Amount <= 0:
+		return nil, fmt.Errorf("%w: flat penalties need an amount", ErrInvalidPenaltyRule)
+	case req.Mode == models.PenaltyModePercentage && (req.Percentage <= 0 || req.Percentage > 100):
+		return nil, fmt.Errorf("%w: percentage must be above 0 and at most 100", ErrInvalidPenaltyRule)
+	case req.Cap < 0:
+		return nil, fmt.Errorf("%w: cap must not be negative", ErrInvalidPenaltyRule)
+	}
+
+	if _, err := s.billingRepo.GetBillingSettingsByID(settingBillingID); err != nil {
+		return nil, err
+	}
+
+	rule, err := s.penaltyRepo.GetRuleBySettingID(settingBillingID)
+	if err != nil {
+		rule = &models.BillingPenaltyRule{SettingBillingID: settingBillingID}
+	}
+	rule.Mode = req.Mode
+	rule.Amount = req.Amount
+	rule.Percentage = req.Percentage
+	rule.Cap = req.Cap
+	rule.IsActive = req.IsActive == nil || *req.IsActive
+
+	if err := s.penaltyRepo.SaveRule(rule); err != nil {
+		return nil, fmt.Errorf("failed to save penalty rule: %w", err)
+	}
+
+	s.logger.WithFields(map[string]interface{}{
+		"setting_billing_id": settingBillingID,
+		"mode":               rule.Mode,
+		"is_active":          rule.IsActive,
+	}).Info("Penalty rule saved")
+
+	return rule, nil
+}
+
+// DeleteRule removes the penalty rule of a setting billing; penalties already charged are kept
+func (s *billingPenaltyService) DeleteRule(settingBillingID uint) error {
+	return s.penaltyRepo.DeleteRule(settingBillingID)
+}
+
+// GetPenaltyByBillingID retrieves the penalty charged on an overdue billing
+func (s *billingPenaltyService) GetPenaltyByBillingID(billingID uint) (*models.BillingPenalty, error) {
+	return s.penaltyRepo.GetByBillingID(billingID)
+}
+
+// WaivePenalty waives an unpaid penalty, recording the reason and the admin who waived it
+func (s *billingPenaltyService) WaivePenalty(id uint, req *WaivePenaltyRequest, actorID uint) (*models.BillingPenalty, error) {
+	penalty, err := s.penaltyRepo.GetByID(id)
+	if err != nil {
+		return nil, err
+	}
+	if penalty.WaivedAt != nil {
+		return nil, ErrPenaltyWaived
+	}
+
+	statusIDs, err := s.billingRepo.GetBillingStatusIDs([]uint{penalty.PenaltyBillingID})
+	if err != nil {
+		return nil, fmt.Errorf("failed to get penalty billing status: %w", err)
+	}
+	if statusIDs[penalty.PenaltyBillingID] == s.statuses.IDs().Paid {
+		return nil, ErrPenaltyPaid
+	}
+	beingPaid, err := s.beingPaid(penalty, statusIDs[penalty.PenaltyBillingID])
+	if err != nil {
+		return nil, err
+	}
+	if beingPaid {
+		return nil, ErrPenaltyBeingPaid
+	}
+
+	now := time.Now()
+	penalty.WaivedAt = &now
+	penalty.WaivedReason = &req.Reason
+	if actorID != 0 {
+		penalty.WaivedByID = &actorID
+	}
+	if err := s.penaltyRepo.Waive(penalty); err != nil {
+		return nil, fmt.Errorf("failed to waive penalty: %w", err)
+	}
+
+	s.logger.WithFields(map[string]interface{}{
+		"penalty_id": penalty.ID,
+		"billing_id": penalty.BillingID,
+		"waived_by":  actorID,
+	}).Info("Penalty waived")
+
+	return penalty, nil
+}
+
+// ApplyPenalties charges the late fee of every overdue billing with an active rule as of today. New
+// penalties get a penalty billing; existing ones are re-priced as the months late grow, unless waived,
+// paid or being paid, since the open link or payment under verification is for the old amount. A billing that fails is logged and counted without stopping the others.
+func (s *billingPenaltyService) ApplyPenalties(today time.Time) (*PenaltyRunResult, error) {
+	result := &PenaltyRunResult{}
+	statuses := s.statuses.IDs()
+
+	rules, err := s.penaltyRepo.ListRules()
+	if err != nil {
+		return result, fmt.Errorf("failed to get penalty rules: %w", err)
+	}
+	rulesBySetting := make(map[uint]*models.BillingPenaltyRule, len(rules))
+	for _, rule := range rules {
+		rulesBySetting[rule.SettingBillingID] = rule
+	}
+
+	candidates, err := s.penaltyRepo.GetPenaltyCandidates(statuses.Overdue)
+	if err != nil {
+		return result, fmt.Errorf("failed to get overdue billings: %w", err)
+	}
+	if len(candidates) == 0 {
+		return result, nil
+	}
+
+	billingIDs := make([]uint, len(candidates))
+	for i, candidate := range candidates {
+		billingIDs[i] = candidate.BillingID
+	}
+	existing, err := s.penaltyRepo.GetByBillingIDs(billingIDs)
+	if err != nil {
+		return result, fmt.Errorf("failed to get penalties: %w", err)
+	}
+
+	var penaltyBillingIDs []uint
+	for _, penalty := range existing {
+		penaltyBillingIDs = append(penaltyBillingIDs, penalty.PenaltyBillingID)
+	}
+	penaltyStatuses, err := s.billingRepo.GetBillingStatusIDs(penaltyBillingIDs)
+	if err != nil {
+		return result, fmt.Errorf("failed to get penalty billing statuses: %w", err)
+	}
+
+	for _, candidate := range candidates {
+		rule := rulesBySetting[candidate.SettingBillingID]
+		monthsLate := lateMonths(candidate.DueDate, today)
+		amount := calculatePenalty(rule, candidate.Nominal, monthsLate)
+		if amount <= 0 {
+			continue
+		}
+
+		penalty, ok := existing[candidate.BillingID]
+		if !ok {
+			if err := s.createPenalty(candidate, rule, monthsLate, amount, statuses.Unpaid, today); err != nil {
+				s.logger.WithError(err).WithField("billing_id", candidate.BillingID).Error("Failed to create penalty")
+				result.FailedCount++
+				continue
+			}
+			result.CreatedCount++
+			continue
+		}
+
+		penaltyStatusID := penaltyStatuses[penalty.PenaltyBillingID]
+		if penalty.WaivedAt != nil || penaltyStatusID == statuses.Paid || penaltyStatusID == statuses.Cancelled || penalty.Amount == amount {
+			continue
+		}
+		beingPaid, err := s.beingPaid(penalty, penaltyStatusID)
+		if err != nil {
+			s.logger.WithError(err).WithField("penalty_id", penalty.ID).Error("Failed to check penalty payments")
+			result.FailedCount++
+			continue
+		}
+		if beingPaid {
+			continue
+		}
+
+		penalty.MonthsLate = monthsLate
+		penalty.Amount = amount
+		if err := s.penaltyRepo.UpdateAmount(penalty, penaltyKeterangan(candidate, monthsLate)); err != nil {
+			s.logger.WithError(err).WithField("penalty_id", penalty.ID).Error("Failed to update penalty")
+			result.FailedCount++
+			continue
+		}
+		result.UpdatedCount++
+	}
+
+	return result, nil
+}
+
+// beingPaid reports whether the penalty billing awaits payment verification or has a pending payment link
+func (s *billingPenaltyService) beingPaid(penalty *models.BillingPenalty, penaltyStatusID uint) (bool, error) {
+	if penaltyStatusID == s.statuses.IDs().PendingVerification {
+		return true, nil
+	}
+
+	open, err := s.billingRepo.HasOpenPayment(penalty.PenaltyBillingID)
+	if err != nil {
+		return false, fmt.Errorf("failed to check open payments: %w", err)
+	}
+	return open, nil
+}
+
+// createPenalty creates the penalty billing for an overdue billing, billed to the same resident. It is due
+// on the next due date after it is charged, so it is not overdue from the start.
+func (s *billingPenaltyService) createPenalty(candidate *models.PenaltyCandidate, rule *models.BillingPenaltyRule, monthsLate int, amount int64, unpaidStatusID uint, today time.Time) error {
+	// Penalty billings are created by the admin user like generated billings
+	adminID := 1
+	now := time.Now()
+	docID := "penalty-" + uuid.NewString()
+	namaBilling := "Denda"
+	if candidate.NamaBilling != nil {
+		namaBilling = "Denda " + *candidate.NamaBilling
+	}
+	keterangan := penaltyKeterangan(candidate, monthsLate)
+	dueDate := s.duePolicy.NextDueDate(today)
+
+	billing := &models.Billing{
+		DocumentID:        &docID,
+		NamaBilling:       &namaBilling,
+		Keterangan:        &keterangan,
+		Bulan:             candidate.Bulan,
+		Tahun:             candidate.Tahun,
+		Nominal:           &amount,
+		OutstandingAmount: &amount,
+		DueDate:           &dueDate,
+		CreatedAt:         &now,
+		UpdatedAt:         &now,
+		PublishedAt:       &now,
+		CreatedByID:       &adminID,
+		UpdatedByID:       &adminID,
+	}
+
+	kategoriID := uint(1)
+	if candidate.KategoriID != nil {
+		kategoriID = *candidate.KategoriID
+	}
+
+	penalty := &models.BillingPenalty{
+		BillingID:  candidate.BillingID,
+		RuleID:     rule.ID,
+		MonthsLate: monthsLate,
+		Amount:     amount,
+	}
+	return s.penaltyRepo.Create(penalty, billing, candidate.UserID, unpaidStatusID, kategoriID)
+}
+
+// penaltyKeterangan describes the late fee of a billing
+func penaltyKeterangan(candidate *models.PenaltyCandidate, monthsLate int) string {
+	return fmt.Sprintf("Denda keterlambatan %d bulan untuk billing #%d", monthsLate, candidate.BillingID)
+}
+
+// lateMonths returns how many months, started, have passed since the due date: 1 from the day after it
+// is due, 2 from the day after the same date a month later, and so on
+func lateMonths(dueDate time.Time, today time.Time) int {
+	if !today.After(dueDate) {
+		return 0
+	}
+
+	months := (today.Year()-dueDate.Year())*12 + int(today.Month()-dueDate.Month())
+	if today.Day() > dueDate.Day() {
+		months++
+	}
+	if months < 1 {
+		months = 1
+	}
+	return months
+}
+
+// calculatePenalty returns the late fee of a billing nominal for the months late, capped by the rule
+func calculatePenalty(rule *models.BillingPenaltyRule, nominal int64, monthsLate int) int64 {
+	if rule == nil || monthsLate <= 0 {
+		return 0
+	}
+
+	var amount int64
+	switch rule.Mode {
+	case models.PenaltyModePercentage:
+		// Rounded up like the admin fee so the penalty never undercharges by a fraction of a rupiah
+		amount = int64(math.Ceil(float64(nominal)*rule.Percentage/100)) * int64(monthsLate)
+	default:
+		amount = rule.Amount * int64(monthsLate)
+	}
+
+	if rule.Cap > 0 && amount > rule.Cap {
+		amount = rule.Cap
+	}
+	return amount
+}
@@ -0,0 +1,189 @@
+package service
+
+import (
+	"fmt"
+	"time"
+
+	"ipl-be-svc/internal/models"
+)
+
+// Reasons a resident is left out of bulk billing generation
+const (
+	BillingSkipNotFound  = "not_found"
+	BillingSkipNoProfile = "no_profile"
+	BillingSkipBlocked   = "blocked"
+	BillingSkipDuplicate = "duplicate"
+)
+
+// BillingPlan lists the billings a bulk generation run creates for a month and year,
+// and the residents it leaves out
+type BillingPlan struct {
+	Month         int                    `json:"month"`
+	Year          int                    `json:"year"`
+	TotalUsers    int                    `json:"total_users"`
+	TotalBillings int                    `json:"total_billings"`
+	TotalNominal  int64                  `json:"total_nominal"`
+	TotalCredit   int64                  `json:"total_credit_applied,omitempty"`
+	Settings      []*BillingPlanSetting  `json:"settings"`
+	Residents     []*BillingPlanResident `json:"residents"`
+	Skipped       []BulkBillingSkip      `json:"skipped"`
+}
+
+// BillingPlanSetting is a setting billings are generated from
+type BillingPlanSetting struct {
+	ID          uint   `json:"id"`
+	NamaBilling string `json:"nama_billing"`
+	Nominal     int64  `json:"nominal"`
+}
+
+// BillingPlanResident groups the billings planned for one resident
+type BillingPlanResident struct {
+	UserID        uint               `json:"user_id"`
+	ProfileID     *uint              `json:"profile_id"`
+	Username      string             `json:"username"`
+	NamaPenghuni  string             `json:"nama_penghuni"`
+	TotalNominal  int64              `json:"total_nominal"`
+	CreditApplied int64              `json:"credit_applied,omitempty"`
+	Items         []*BillingPlanItem `json:"items"`
+}
+
+// BillingPlanItem is a single planned billing. BillingID is set once the billing is created. CreditApplied
+// is paid from the resident's credit balance, a billing it fully covers is created as paid.
+type BillingPlanItem struct {
+	BillingID        uint      `json:"billing_id,omitempty"`
+	SettingBillingID uint      `json:"setting_billing_id"`
+	NamaBilling      string    `json:"nama_billing"`
+	Keterangan       string    `json:"keterangan"`
+	Nominal          int64     `json:"nominal"`
+	CreditApplied    int64     `json:"credit_applied,omitempty"`
+	DueDate          time.Time `json:"due_date"`
+}
+
+// buildBillingPlan works out which billings generating the settings for the users would create.
+// It only reads, so it backs both dry runs and the real generation inside its transaction.
+func (s *billingService) buildBillingPlan(userIDs []uint, settings []*models.SettingBilling, month int, year int) (*BillingPlan, error) {
+	plan := &BillingPlan{
+		Month:     month,
+		Year:      year,
+		Settings:  []*BillingPlanSetting{},
+		Residents: []*BillingPlanResident{},
+		Skipped:   []BulkBillingSkip{},
+	}
+
+	recipients, err := s.billingRepo.GetBillingRecipients(userIDs)
+	if err != nil {
+		return nil, fmt.Errorf("failed to get billing recipients: %w", err)
+	}
+	plan.TotalUsers = len(recipients)
+
+	found := make(map[uint]bool, len(recipients))
+	for _, recipient := range recipients {
+		found[recipient.UserID] = true
+	}
+	for _, userID := range userIDs {
+		if !found[userID] {
+			plan.Skipped = append(plan.Skipped, BulkBillingSkip{UserID: userID, Reason: BillingSkipNotFound})
+			found[userID] = true // Report repeated IDs once
+		}
+	}
+
+	var residents []*BillingPlanResident
+	for _, recipient := range recipients {
+		switch {
+		case recipient.Blocked:
+			plan.Skipped = append(plan.Skipped, BulkBillingSkip{UserID: recipient.UserID, Username: recipient.Username, Reason: BillingSkipBlocked})
+		case recipient.ProfileID == nil:
+			plan.Skipped = append(plan.Skipped, BulkBillingSkip{UserID: recipient.UserID, Username: recipient.Username, Reason: BillingSkipNoProfile})
+		default:
+			residents = append(residents, &BillingPlanResident{
+				UserID:       recipient.UserID,
+				ProfileID:    recipient.ProfileID,
+				Username:     recipient.Username,
+				NamaPenghuni: recipient.NamaPenghuni,
+			})
+		}
+	}
+
+	for _, setting := range settings {
+		// Skip settings that are not published
+		if setting.PublishedAt == nil {
+			continue
+		}
+
+		// Convert nominal from float64 to int64
+		nominal := int64(setting.Nominal)
+		dueDate := s.duePolicy.DueDate(setting.DueDay, month, year)
+		plan.Settings = append(plan.Settings, &BillingPlanSetting{ID: setting.ID, NamaBilling: setting.NamaBilling, Nominal: nominal})
+
+		existing, err := s.billingRepo.GetBilledUserIDs(setting, month, year)
+		if err != nil {
+			return nil, fmt.Errorf("failed to check existing billings: %w", err)
+		}
+
+		for _, resident := range residents {
+			if billingID, ok := existing[resident.UserID]; ok {
+				plan.Skipped = append(plan.Skipped, BulkBillingSkip{
+					UserID:           resident.UserID,
+					Username:         resident.Username,
+					SettingBillingID: setting.ID,
+					BillingID:        billingID,
+					Reason:           BillingSkipDuplicate,
+				})
+				continue
+			}
+
+			resident.Items = append(resident.Items, &BillingPlanItem{
+				SettingBillingID: setting.ID,
+				NamaBilling:      setting.NamaBilling,
+				Keterangan:       setting.Keterangan,
+				Nominal:          nominal,
+				DueDate:          dueDate,
+			})
+			resident.TotalNominal += nominal
+			plan.TotalBillings++
+			plan.TotalNominal += nominal
+		}
+	}
+
+	for _, resident := range residents {
+		if len(resident.Items) > 0 {
+			plan.Residents = append(plan.Residents, resident)
+		}
+	}
+
+	return plan, nil
+}
+
+// allocateCredit applies the residents' credit balances to their planned billings in order, until the
+// credit runs out. The last billing it reaches may only be covered in part.
+func allocateCredit(plan *BillingPlan, balances map[uint]int64) {
+	for _, resident := range plan.Residents {
+		if resident.ProfileID == nil {
+			continue
+		}
+		credit := balances[*resident.ProfileID]
+		for _, item := range resident.Items {
+			if credit <= 0 {
+				break
+			}
+			item.CreditApplied = item.Nominal
+			if credit < item.Nominal {
+				item.CreditApplied = credit
+			}
+			credit -= item.CreditApplied
+			resident.CreditApplied += item.CreditApplied
+			plan.TotalCredit += item.CreditApplied
+		}
+	}
+}
+
+// planProfileIDs returns the profile IDs of the residents in the plan
+func planProfileIDs(plan *BillingPlan) []uint {
+	profileIDs := make([]uint, 0, len(plan.Residents))
+	for _, resident := range plan.Residents {
+		if resident.ProfileID != nil {
+			profileIDs = append(profileIDs, *resident.ProfileID)
+		}
+	}
+	return profileIDs
+}
@@ -0,0 +1,134 @@
+package service
+
+import (
+	"errors"
+	"fmt"
+	"time"
+
+	"ipl-be-svc/internal/models"
+
+	"gorm.io/gorm"
+)
+
+// ErrInvalidPrepayment is returned when a resident's coming months cannot be billed in advance
+var ErrInvalidPrepayment = errors.New("invalid prepayment")
+
+// PrepayRequest represents the request of a resident to bill their coming months in advance and pay them
+// at once. ProfileID picks the resident for billing staff; residents prepay their own profile.
+type PrepayRequest struct {
+	Months    int  `json:"months" binding:"required,min=1,max=12" example:"6"`
+	ProfileID uint `json:"profile_id,omitempty" example:"7"`
+}
+
+// AdvanceBillings are a resident's monthly billings for coming months, created ahead of the monthly
+// generator. Plans has one plan per month, BillingIDs the billings of those months left to pay.
+type AdvanceBillings struct {
+	UserID     uint           `json:"user_id"`
+	ProfileID  uint           `json:"profile_id"`
+	Plans      []*BillingPlan `json:"plans"`
+	BillingIDs []uint         `json:"billing_ids"`
+}
+
+// CreateAdvanceMonthlyBillings creates a resident's monthly billings for the months after the current one
+// from the active monthly settings, all in one transaction. They carry their setting and period, so the
+// monthly generator skips them as duplicates later. Months already billed from a setting are kept, and
+// their billings still waiting to be paid are included in BillingIDs.
+func (s *billingService) CreateAdvanceMonthlyBillings(userID uint, months int) (*AdvanceBillings, error) {
+	recipients, err := s.billingRepo.GetBillingRecipients([]uint{userID})
+	if err != nil {
+		return nil, fmt.Errorf("failed to get resident: %w", err)
+	}
+	if len(recipients) == 0 {
+		return nil, gorm.ErrRecordNotFound
+	}
+	if recipients[0].Blocked || recipients[0].ProfileID == nil || !recipients[0].Resident {
+		return nil, fmt.Errorf("%w: user %d is not a penghuni that can be billed", ErrInvalidPrepayment, userID)
+	}
+	profileID := *recipients[0].ProfileID
+
+	settings, err := s.billingRepo.GetActiveMonthlySettingBillings()
+	if err != nil {
+		return nil, fmt.Errorf("failed to get setting billings: %w", err)
+	}
+	if len(settings) == 0 {
+		return nil, fmt.Errorf("%w: no active monthly setting billings found", ErrInvalidPrepayment)
+	}
+
+	advance := &AdvanceBillings{UserID: userID, ProfileID: profileID, BillingIDs: []uint{}}
+	today := s.duePolicy.Today()
+	err = s.db.Transaction(func(tx *gorm.DB) error {
+		for i := 1; i <= months; i++ {
+			period := time.Date(today.Year(), today.Month()+time.Month(i), 1, 0, 0, 0, 0, time.UTC)
+			plan, err := s.createBillingsInTx(tx, []uint{userID}, settings, "prepaid-", int(period.Month()), period.Year(), s.statuses.IDs().Unpaid, false)
+			if plan != nil {
+				advance.Plans = append(advance.Plans, plan)
+			}
+			if err != nil {
+				return fmt.Errorf("failed to create billings for %04d-%02d: %w", period.Year(), period.Month(), err)
+			}
+		}
+		return nil
+	})
+	if err != nil {
+		for _, plan := range advance.Plans {
+			resetBillingPlan(plan)
+		}
+		return nil, err
+	}
+
+	var existingIDs []uint
+	for _, plan := range advance.Plans {
+		for _, resident := range plan.Residents {
+			for _, item := range resident.Items {
+				advance.BillingIDs = append(advance.BillingIDs, item.BillingID)
+			}
+		}
+		for _, skip := range plan.Skipped {
+			if skip.BillingID != 0 {
+				existingIDs = append(existingIDs, skip.BillingID)
+			}
+		}
+	}
+
+	payable, err := s.payableBillingIDs(existingIDs)
+	if err != nil {
+		return nil, err
+	}
+	advance.BillingIDs = append(payable, advance.BillingIDs...)
+
+	s.logger.WithFields(map[string]interface{}{
+		"profile_id":  profileID,
+		"user_id":     userID,
+		"months":      months,
+		"billing_ids": advance.BillingIDs,
+	}).Info("Advance monthly billings created")
+
+	return advance, nil
+}
+
+// payableBillingIDs keeps the billings that are unpaid or overdue with something outstanding
+func (s *billingService) payableBillingIDs(billingIDs []uint) ([]uint, error) {
+	if len(billingIDs) == 0 {
+		return nil, nil
+	}
+
+	billings, err := s.billingRepo.GetBillingsByIDs(billingIDs)
+	if err != nil {
+		return nil, fmt.Errorf("failed to get billings: %w", err)
+	}
+	statusIDs, err := s.billingRepo.GetBillingStatusIDs(billingIDs)
+	if err != nil {
+		return nil, fmt.Errorf("failed to get billing statuses: %w", err)
+	}
+
+	var payable []uint
+	for _, billing := range billings {
+		switch s.statuses.State(statusIDs[billing.ID]) {
+		case models.BillingStateUnpaid, models.BillingStateOverdue:
+			if billing.Outstanding() > 0 {
+				payable = append(payable, billing.ID)
+			}
+		}
+	}
+	return payable, nil
+}
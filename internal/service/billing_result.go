@@ -0,0 +1,115 @@
+package service
+
+import "fmt"
+
+// Outcomes of bulk billing generation for a resident
+const (
+	BulkBillingOutcomeCreated = "created"
+	BulkBillingOutcomeSkipped = "skipped"
+	BulkBillingOutcomeFailed  = "failed"
+)
+
+// BillingSkipNoSettings is the reason a requested resident is skipped when none of the settings are published
+const BillingSkipNoSettings = "no_settings"
+
+// BulkBillingResult is the outcome of bulk billing generation for one resident. A resident is created
+// when any of their billings were created, even if others were skipped as duplicates; Reason is set
+// for skipped residents and Error for failed ones. CreditApplied was paid from their credit balance.
+type BulkBillingResult struct {
+	UserID        uint   `json:"user_id"`
+	Username      string `json:"username,omitempty"`
+	Outcome       string `json:"outcome" example:"created"`
+	Reason        string `json:"reason,omitempty" example:"duplicate"`
+	Error         string `json:"error,omitempty"`
+	BillingIDs    []uint `json:"billing_ids,omitempty"`
+	CreditApplied int64  `json:"credit_applied,omitempty"`
+}
+
+// newBulkBillingResponse creates a response that reports the requested users in request order
+func newBulkBillingResponse(userIDs []uint) *BulkBillingResponse {
+	response := &BulkBillingResponse{results: make(map[uint]*BulkBillingResult)}
+	for _, userID := range userIDs {
+		if _, ok := response.results[userID]; !ok {
+			response.results[userID] = nil
+			response.order = append(response.order, userID)
+		}
+	}
+	return response
+}
+
+// result returns the resident's result, adding it on first use
+func (r *BulkBillingResponse) result(userID uint, username string) *BulkBillingResult {
+	result := r.results[userID]
+	if result == nil {
+		if _, ok := r.results[userID]; !ok {
+			r.order = append(r.order, userID)
+		}
+		result = &BulkBillingResult{UserID: userID}
+		r.results[userID] = result
+	}
+	if result.Username == "" {
+		result.Username = username
+	}
+	return result
+}
+
+// addPlan adds the billings created from a plan and the residents it skipped, leaving out the excluded residents
+func (r *BulkBillingResponse) addPlan(plan *BillingPlan, exclude map[uint]bool) {
+	for _, skip := range plan.Skipped {
+		if exclude[skip.UserID] {
+			continue
+		}
+		r.Skipped = append(r.Skipped, skip)
+		result := r.result(skip.UserID, skip.Username)
+		if result.Reason == "" {
+			result.Reason = skip.Reason
+		}
+	}
+
+	for _, resident := range plan.Residents {
+		if exclude[resident.UserID] {
+			continue
+		}
+		result := r.result(resident.UserID, resident.Username)
+		result.CreditApplied += resident.CreditApplied
+		r.CreditApplied += resident.CreditApplied
+		for _, item := range resident.Items {
+			r.TotalBillings++
+			r.SuccessCount++
+			result.BillingIDs = append(result.BillingIDs, item.BillingID)
+		}
+	}
+}
+
+// addFailure counts the resident's planned billings as failed
+func (r *BulkBillingResponse) addFailure(resident *BillingPlanResident, err error) {
+	r.TotalBillings += len(resident.Items)
+	r.FailedCount += len(resident.Items)
+	r.Errors = append(r.Errors, fmt.Sprintf("user %d: %v", resident.UserID, err))
+	r.result(resident.UserID, resident.Username).Error = err.Error()
+}
+
+// finish sets the skipped count and the outcome of each resident
+func (r *BulkBillingResponse) finish() *BulkBillingResponse {
+	r.SkippedCount = len(r.Skipped)
+	r.Results = make([]*BulkBillingResult, 0, len(r.order))
+	for _, userID := range r.order {
+		result := r.results[userID]
+		if result == nil {
+			result = &BulkBillingResult{UserID: userID, Reason: BillingSkipNoSettings}
+		}
+
+		switch {
+		case result.Error != "":
+			result.Outcome = BulkBillingOutcomeFailed
+			result.Reason = ""
+		case len(result.BillingIDs) > 0:
+			result.Outcome = BulkBillingOutcomeCreated
+			result.Reason = ""
+		default:
+			result.Outcome = BulkBillingOutcomeSkipped
+		}
+		r.Results = append(r.Results, result)
+	}
+	return r
+}
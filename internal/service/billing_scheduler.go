@@ -0,0 +1,276 @@
+package service
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"strconv"
+	"strings"
+	"time"
+
+	"ipl-be-svc/internal/config"
+	"ipl-be-svc/internal/models"
+	"ipl-be-svc/internal/repository"
+	"ipl-be-svc/pkg/logger"
+
+	"gorm.io/gorm"
+)
+
+// MonthlyBillingJob is the job name of the scheduled monthly billing generation
+const MonthlyBillingJob = "monthly_billing"
+
+// Job run triggers
+const (
+	JobTriggerSchedule = "schedule"
+	JobTriggerCatchUp  = "catch_up"
+)
+
+const (
+	// monthlyBillingLockID is the advisory lock keeping monthly billing generation on one replica
+	monthlyBillingLockID = 1
+	// jobRetryInterval is how long a failed or interrupted run waits before it is retried
+	jobRetryInterval = time.Hour
+	// schedulerTickInterval is how often the scheduler checks for due runs
+	schedulerTickInterval = time.Minute
+)
+
+// BillingScheduler generates the monthly billings of all penghuni users on a schedule
+type BillingScheduler interface {
+	Start(ctx context.Context)
+	RunDue() error
+}
+
+// monthlySchedule is a parsed "minute hour day-of-month * *" cron expression
+type monthlySchedule struct {
+	minute int
+	hour   int
+	day    int
+}
+
+// billingScheduler implements BillingScheduler
+type billingScheduler struct {
+	billingService BillingService
+	jobRunRepo     repository.JobRunRepository
+	schedule       *monthlySchedule
+	location       *time.Location
+	catchUpMonths  int
+	logger         *logger.Logger
+}
+
+// NewBillingScheduler validates the schedule and creates a new BillingScheduler
+func NewBillingScheduler(billingService BillingService, jobRunRepo repository.JobRunRepository, cfg config.BillingSchedulerConfig, logger *logger.Logger) (BillingScheduler, error) {
+	schedule, err := parseMonthlySchedule(cfg.Schedule)
+	if err != nil {
+		return nil, err
+	}
+
+	location, err := time.LoadLocation(cfg.Timezone)
+	if err != nil {
+		return nil, fmt.Errorf("invalid billing scheduler timezone %q: %w", cfg.Timezone, err)
+	}
+
+	if cfg.CatchUpMonths < 0 {
+		return nil, fmt.Errorf("billing scheduler catch-up months must not be negative")
+	}
+
+	return &billingScheduler{
+		billingService: billingService,
+		jobRunRepo:     jobRunRepo,
+		schedule:       schedule,
+		location:       location,
+		catchUpMonths:  cfg.CatchUpMonths,
+		logger:         logger,
+	}, nil
+}
+
+// Start checks for due runs right away and then every minute until ctx is cancelled
+func (s *billingScheduler) Start(ctx context.Context) {
+	s.logger.WithFields(map[string]interface{}{
+		"day":      s.schedule.day,
+		"hour":     s.schedule.hour,
+		"minute":   s.schedule.minute,
+		"timezone": s.location.String(),
+	}).Info("Monthly billing scheduler started")
+
+	go func() {
+		ticker := time.NewTicker(schedulerTickInterval)
+		defer ticker.Stop()
+
+		for {
+			if err := s.RunDue(); err != nil {
+				s.logger.WithError(err).Error("Monthly billing scheduler run failed")
+			}
+
+			select {
+			case <-ctx.Done():
+				s.logger.Info("Monthly billing scheduler stopped")
+				return
+			case <-ticker.C:
+			}
+		}
+	}()
+}
+
+// RunDue generates the billings of every month whose scheduled time has passed without a successful run,
+// the current month as well as up to the configured number of missed months
+func (s *billingScheduler) RunDue() error {
+	due, err := s.duePeriods(time.Now())
+	if err != nil || len(due) == 0 {
+		return err
+	}
+
+	acquired, err := s.jobRunRepo.RunExclusive(monthlyBillingLockID, func() error {
+		// Another replica may have finished the runs while this one waited
+		due, err := s.duePeriods(time.Now())
+		if err != nil {
+			return err
+		}
+
+		for _, period := range due {
+			s.runPeriod(period)
+		}
+		return nil
+	})
+	if err != nil {
+		return err
+	}
+	if !acquired {
+		s.logger.Debug("Monthly billing generation is running on another replica")
+	}
+
+	return nil
+}
+
+// duePeriods returns the first day of every month that is due, oldest first. Catch-up never reaches
+// back before the first recorded run, so enabling the scheduler does not bill past months.
+func (s *billingScheduler) duePeriods(now time.Time) ([]time.Time, error) {
+	now = now.In(s.location)
+	current := time.Date(now.Year(), now.Month(), 1, 0, 0, 0, 0, s.location)
+
+	floor := current
+	first, err := s.jobRunRepo.GetFirst(MonthlyBillingJob)
+	if err != nil && !errors.Is(err, gorm.ErrRecordNotFound) {
+		return nil, fmt.Errorf("failed to get first job run: %w", err)
+	}
+	if first != nil {
+		if period, err := time.ParseInLocation("2006-01", first.Period, s.location); err == nil {
+			floor = period
+		}
+	}
+
+	var due []time.Time
+	for i := s.catchUpMonths; i >= 0; i-- {
+		period := current.AddDate(0, -i, 0)
+		if period.Before(floor) || s.schedule.at(period.Year(), period.Month(), s.location).After(now) {
+			continue
+		}
+
+		latest, err := s.jobRunRepo.GetLatest(MonthlyBillingJob, period.Format("2006-01"))
+		if err != nil && !errors.Is(err, gorm.ErrRecordNotFound) {
+			return nil, fmt.Errorf("failed to get job run: %w", err)
+		}
+		if latest != nil {
+			// An interrupted run is left "running" and is retried like a failed one
+			if latest.Status == models.JobRunStatusSucceeded || now.Sub(latest.StartedAt) < jobRetryInterval {
+				continue
+			}
+		}
+
+		due = append(due, period)
+	}
+
+	return due, nil
+}
+
+// runPeriod generates the monthly billings for the period and records the run
+func (s *billingScheduler) runPeriod(period time.Time) {
+	trigger := JobTriggerSchedule
+	now := time.Now().In(s.location)
+	if period.Year() != now.Year() || period.Month() != now.Month() {
+		trigger = JobTriggerCatchUp
+	}
+
+	run := &models.JobRun{
+		Job:       MonthlyBillingJob,
+		Period:    period.Format("2006-01"),
+		Trigger:   trigger,
+		Status:    models.JobRunStatusRunning,
+		StartedAt: time.Now(),
+	}
+	if err := s.jobRunRepo.Create(run); err != nil {
+		s.logger.WithError(err).WithField("period", run.Period).Error("Failed to record job run")
+		return
+	}
+
+	s.logger.WithFields(map[string]interface{}{
+		"period":  run.Period,
+		"trigger": trigger,
+	}).Info("Generating scheduled monthly billings")
+
+	response, err := s.billingService.CreateBulkMonthlyBillingsForAllUsers(int(period.Month()), period.Year())
+	// Errors without failed billings are residents whose billings could not even be planned
+	if err == nil && (response.FailedCount > 0 || len(response.Errors) > 0) {
+		err = fmt.Errorf("%d billings failed: %s", response.FailedCount, strings.Join(response.Errors, "; "))
+	}
+
+	if response != nil {
+		if result, marshalErr := json.Marshal(response); marshalErr == nil {
+			resultStr := string(result)
+			run.Result = &resultStr
+		}
+	}
+
+	finishedAt := time.Now()
+	run.FinishedAt = &finishedAt
+	run.Status = models.JobRunStatusSucceeded
+	if err != nil {
+		message := err.Error()
+		run.Error = &message
+		run.Status = models.JobRunStatusFailed
+		s.logger.WithError(err).WithField("period", run.Period).Error("Scheduled monthly billing generation failed")
+	} else {
+		s.logger.WithFields(map[string]interface{}{
+			"period":        run.Period,
+			"success_count": response.SuccessCount,
+			"skipped_count": response.SkippedCount,
+		}).Info("Scheduled monthly billings generated")
+	}
+
+	if err := s.jobRunRepo.Update(run); err != nil {
+		s.logger.WithError(err).WithField("period", run.Period).Error("Failed to record job run result")
+	}
+}
+
+// parseMonthlySchedule parses a "minute hour day-of-month * *" cron expression
+func parseMonthlySchedule(expr string) (*monthlySchedule, error) {
+	fields := strings.Fields(expr)
+	if len(fields) != 5 {
+		return nil, fmt.Errorf("invalid billing schedule %q, expected \"minute hour day-of-month * *\"", expr)
+	}
+	if fields[3] != "*" || fields[4] != "*" {
+		return nil, fmt.Errorf("invalid billing schedule %q, month and day-of-week must be *", expr)
+	}
+
+	values := make([]int, 3)
+	limits := [][2]int{{0, 59}, {0, 23}, {1, 31}}
+	names := []string{"minute", "hour", "day-of-month"}
+	for i := range values {
+		value, err := strconv.Atoi(fields[i])
+		if err != nil || value < limits[i][0] || value > limits[i][1] {
+			return nil, fmt.Errorf("invalid billing schedule %s %q, expected %d-%d", names[i], fields[i], limits[i][0], limits[i][1])
+		}
+		values[i] = value
+	}
+
+	return &monthlySchedule{minute: values[0], hour: values[1], day: values[2]}, nil
+}
+
+// at returns the scheduled time in the month; days past the end of a short month fall on its last day
+func (m *monthlySchedule) at(year int, month time.Month, loc *time.Location) time.Time {
+	day := m.day
+	if last := time.Date(year, month+1, 0, 0, 0, 0, 0, loc).Day(); day > last {
+		day = last
+	}
+	return time.Date(year, month, day, m.hour, m.minute, 0, 0, loc)
+}
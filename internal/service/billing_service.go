@@ -1,6 +1,7 @@
 package service
 
 import (
+	"errors"
 	"fmt"
 	"os"
 	"strings"
@@ -9,6 +10,7 @@ import (
 	"ipl-be-svc/internal/models"
 	"ipl-be-svc/internal/models/response"
 	"ipl-be-svc/internal/repository"
+	"ipl-be-svc/pkg/logger"
 
 	"github.com/google/uuid"
 	"gorm.io/gorm"
@@ -16,60 +18,97 @@ import (
 
 // BillingService defines the interface for billing business operations
 type BillingService interface {
+	GetBilling(id uint) (*BillingDetail, error)
+	CreateBilling(req *CreateBillingRequest, actorID uint) (*BillingDetail, error)
+	UpdateBilling(id uint, req *UpdateBillingRequest, actorID uint) (*BillingDetail, error)
+	DeleteBilling(id uint, actorID uint) error
 	CreateBulkMonthlyBillings(userIDs []uint, month int, year int) (*BulkBillingResponse, error)
 	CreateBulkCustomBillings(userIDs []uint, billingSettingsId int, month int, year int) (*BulkBillingResponse, error)
 	CreateBulkMonthlyBillingsForAllUsers(month int, year int) (*BulkBillingResponse, error)
-	CreateBulkCustomBillingsForAllUsers(month int, billingSettingsId int, year int) (*BulkBillingResponse, error)
+	CreateBulkCustomBillingsForAllUsers(billingSettingsId int, month int, year int) (*BulkBillingResponse, error)
+	PreviewBulkMonthlyBillings(userIDs []uint, month int, year int) (*BillingPlan, error)
+	PreviewBulkCustomBillings(userIDs []uint, billingSettingsId int, month int, year int) (*BillingPlan, error)
+	CreateAdvanceMonthlyBillings(userID uint, months int) (*AdvanceBillings, error)
+	SubmitBulkBillingJob(req *BulkBillingJobRequest, actorID uint) (*models.BulkBillingJob, error)
+	GetBulkBillingJob(id uint) (*models.BulkBillingJob, error)
 	GetBillingPenghuni(search string, page int, limit int) ([]*models.BillingPenghuniResponse, int64, error)
-	ConfirmPayment(listIds []uint) error
+	ConfirmPayment(billingIDs []uint, actorID uint, reason string) error
+	ApplyPayment(paymentID uint, amounts map[uint]int64, actorID uint, reason string) ([]uint, error)
 	GetBillingPenghuniAll() ([]*models.BillingPenghuniResponse, error)
 	GetProfileBillingWithFilters(search string, bulan *int, tahun *int, rt *int, statusID *int, page int, limit int) ([]*response.ProfileBillingResponse, int64, error)
 	GetBillingByProfileID(profileID uint, bulan *int, tahun *int, statusID *int, rt *int, page int, limit int) ([]*response.BillingByProfileResponse, int64, error)
-	GetBillingStatistics(search string, bulan *int, tahun *int, rt *int, statusIDs []int) (*response.BillingStatisticsResponse, error)
+	GetBillingStatistics(search string, bulan *int, tahun *int, rt *int, statusIDs []int, overdue bool) (*response.BillingStatisticsResponse, error)
 	// Attachments
 	UploadBillingAttachment(billingID uint, filename string, content []byte) (*models.BillingAttachment, error)
 	GetBillingAttachments(billingID uint) ([]*models.BillingAttachment, error)
 	GetBillingAttachmentByID(id uint) (*models.BillingAttachment, error)
 }
 
-// BulkBillingResponse represents the response for bulk billing creation
+// ErrAmountExceedsOutstanding is returned when a payment covers more of a billing than is outstanding on it
+var ErrAmountExceedsOutstanding = errors.New("amount exceeds the outstanding balance")
+
+// ErrPaymentAlreadyApplied is returned when a payment was already taken off its billings' balances
+var ErrPaymentAlreadyApplied = errors.New("payment already applied")
+
+// billingGenerationLockKey namespaces the advisory locks taken while generating billings for a period
+const billingGenerationLockKey = 1001
+
+// BulkBillingResponse represents the response for bulk billing creation. The counts are of billings,
+// Results has the outcome for each resident.
 type BulkBillingResponse struct {
-	TotalUsers    int      `json:"total_users"`
-	TotalBillings int      `json:"total_billings"`
-	SuccessCount  int      `json:"success_count"`
-	FailedCount   int      `json:"failed_count"`
-	Errors        []string `json:"errors,omitempty"`
+	TotalUsers    int                  `json:"total_users"`
+	TotalBillings int                  `json:"total_billings"`
+	SuccessCount  int                  `json:"success_count"`
+	FailedCount   int                  `json:"failed_count"`
+	SkippedCount  int                  `json:"skipped_count"`
+	CreditApplied int64                `json:"credit_applied,omitempty"`
+	Errors        []string             `json:"errors,omitempty"`
+	Skipped       []BulkBillingSkip    `json:"skipped,omitempty"`
+	Results       []*BulkBillingResult `json:"results"`
+
+	results map[uint]*BulkBillingResult
+	order   []uint
+}
+
+// BulkBillingSkip describes a resident left out of bulk billing generation. SettingBillingID and
+// BillingID are set for duplicates, the existing billing from the setting for the period.
+type BulkBillingSkip struct {
+	UserID           uint   `json:"user_id"`
+	Username         string `json:"username,omitempty"`
+	SettingBillingID uint   `json:"setting_billing_id,omitempty"`
+	BillingID        uint   `json:"billing_id,omitempty"`
+	Reason           string `json:"reason" example:"duplicate"`
 }
 
 // billingService implements BillingService
 type billingService struct {
-	billingRepo repository.BillingRepository
-	db          *gorm.DB
+	billingRepo  repository.BillingRepository
+	jobRepo      repository.BulkBillingJobRepository
+	creditRepo   repository.CreditLedgerRepository
+	duePolicy    *BillingDuePolicy
+	statuses     StatusRegistry
+	stateMachine BillingStateMachine
+	db           *gorm.DB
+	logger       *logger.Logger
 }
 
 // NewBillingService creates a new instance of BillingService
-func NewBillingService(billingRepo repository.BillingRepository, db *gorm.DB) BillingService {
+func NewBillingService(billingRepo repository.BillingRepository, jobRepo repository.BulkBillingJobRepository, creditRepo repository.CreditLedgerRepository, duePolicy *BillingDuePolicy, statuses StatusRegistry, stateMachine BillingStateMachine, db *gorm.DB, logger *logger.Logger) BillingService {
 	return &billingService{
-		billingRepo: billingRepo,
-		db:          db,
+		billingRepo:  billingRepo,
+		jobRepo:      jobRepo,
+		creditRepo:   creditRepo,
+		duePolicy:    duePolicy,
+		statuses:     statuses,
+		stateMachine: stateMachine,
+		db:           db,
+		logger:       logger,
 	}
 }
 
-// CreateBulkMonthlyBillings creates monthly billings for specified user IDs
+// CreateBulkMonthlyBillings creates monthly billings for specified user IDs. Residents' credit balances
+// are applied to the new billings, which are created as paid when fully covered.
 func (s *billingService) CreateBulkMonthlyBillings(userIDs []uint, month int, year int) (*BulkBillingResponse, error) {
-	// Always use admin user (ID 1) as the creator
-	adminID := 1
-	createdByInt := &adminID
-
-	// Get default status ("Belum Dibayar")
-	var defaultStatus models.MasterGeneralStatus
-	if err := s.db.Table("master_general_statuses").Where("status_name = ? AND published_at IS NOT NULL", "Belum Dibayar").First(&defaultStatus).Error; err != nil {
-		// If no default status found, get first available status
-		if err := s.db.Table("master_general_statuses").Where("published_at IS NOT NULL").First(&defaultStatus).Error; err != nil {
-			return nil, fmt.Errorf("failed to get default status: %w", err)
-		}
-	}
-
 	// Get setting billings
 	settings, err := s.billingRepo.GetActiveMonthlySettingBillings()
 	if err != nil {
@@ -80,360 +119,312 @@ func (s *billingService) CreateBulkMonthlyBillings(userIDs []uint, month int, ye
 		return nil, fmt.Errorf("no active monthly setting billings found")
 	}
 
-	// Get users with profiles
-	var users []*models.User
-	if len(userIDs) > 0 {
-		// Filter specific users
-		for _, userID := range userIDs {
-			user, err := s.getUserWithProfile(userID)
-			if err != nil {
-				continue // Skip if user not found or no profile
-			}
-			users = append(users, user)
-		}
-	} else {
-		// Get all penghuni users
-		users, err = s.billingRepo.GetUsersWithPenghuniRole()
-		if err != nil {
-			return nil, fmt.Errorf("failed to get penghuni users: %w", err)
-		}
-	}
+	return s.generateBillings(userIDs, settings, "monthly-", month, year, s.statuses.IDs().Unpaid, true)
+}
 
-	if len(users) == 0 {
-		return &BulkBillingResponse{
-			TotalUsers:    0,
-			TotalBillings: 0,
-			SuccessCount:  0,
-			FailedCount:   0,
-		}, nil
+// CreateBulkCustomBillings creates custom billings for specified user IDs
+func (s *billingService) CreateBulkCustomBillings(userIDs []uint, billingSettingsId int, month int, year int) (*BulkBillingResponse, error) {
+	// Get setting billings
+	setting, err := s.billingRepo.GetBillingSettingsByID(uint(billingSettingsId))
+	if err != nil {
+		return nil, fmt.Errorf("failed to get setting billings: %w", err)
 	}
 
-	// Prepare billings and links
-	var billings []*models.Billing
-	var links []*models.BillingProfileLink
-	var statusLinks []*models.BillingStatusBillLink
-	var kategoriLinks []*models.BillingKategoriTransaksiLink
-	now := time.Now()
-
-	for _, user := range users {
-		for _, setting := range settings {
-			// Skip settings that are not published
-			if setting.PublishedAt == nil {
-				continue
-			}
-
-			// Generate document ID
-			docID := "monthly-" + uuid.New().String()
-
-			// Convert nominal from float64 to int64
-			nominal := int64(setting.Nominal)
-
-			// Use provided month and year
-			billingMonth := month
-			billingYear := year
-
-			// Set PublishedAt based on setting's PublishedAt
-			var billingPublishedAt *time.Time
-			if setting.PublishedAt != nil {
-				billingPublishedAt = &now
-			} else {
-				billingPublishedAt = nil
-			}
-
-			// Create billing
-			billing := &models.Billing{
-				DocumentID:  &docID,
-				NamaBilling: &setting.NamaBilling,
-				Keterangan:  &setting.Keterangan,
-				Bulan:       &billingMonth,
-				Tahun:       &billingYear,
-				Nominal:     &nominal,
-				CreatedAt:   &now,
-				UpdatedAt:   &now,
-				PublishedAt: billingPublishedAt,
-				CreatedByID: createdByInt,
-				UpdatedByID: createdByInt,
-			}
-			billings = append(billings, billing)
+	return s.generateBillings(userIDs, []*models.SettingBilling{setting}, "custom-", month, year, s.statuses.IDs().Unpaid, false)
+}
 
-			// Create link
-			link := &models.BillingProfileLink{
-				BillingID: billing.ID, // Will be set after insert
-				ProfileID: user.ID,    // Use user ID directly
-			}
-			links = append(links, link)
+// CreateBulkMonthlyBillingsForAllUsers creates monthly billings for all penghuni users
+func (s *billingService) CreateBulkMonthlyBillingsForAllUsers(month int, year int) (*BulkBillingResponse, error) {
+	return s.CreateBulkMonthlyBillings([]uint{}, month, year)
+}
 
-			// Create status link
-			statusLink := &models.BillingStatusBillLink{
-				BillingID:             billing.ID, // Will be set after insert
-				MasterGeneralStatusID: defaultStatus.ID,
-			}
-			statusLinks = append(statusLinks, statusLink)
+// CreateBulkCustomBillingsForAllUsers creates custom billings for all penghuni users
+func (s *billingService) CreateBulkCustomBillingsForAllUsers(billingSettingsId int, month int, year int) (*BulkBillingResponse, error) {
+	return s.CreateBulkCustomBillings([]uint{}, billingSettingsId, month, year)
+}
 
-			// Create kategori transaksi link
-			kategoriLink := &models.BillingKategoriTransaksiLink{
-				BillingID:                 billing.ID, // Will be set after insert
-				MasterKategoriTransaksiID: 1,
-			}
-			kategoriLinks = append(kategoriLinks, kategoriLink)
-		}
+// generateBillings creates the billings planned for the users from the settings in one transaction.
+// If that fails the residents are retried one at a time, so only the residents whose billings
+// cannot be created are reported as failed. With applyCredit the residents' credit balances pay for them.
+func (s *billingService) generateBillings(userIDs []uint, settings []*models.SettingBilling, docPrefix string, month int, year int, statusID uint, applyCredit bool) (*BulkBillingResponse, error) {
+	plan, err := s.createBillings(userIDs, settings, docPrefix, month, year, statusID, applyCredit)
+	if plan == nil {
+		// Nothing was planned, so no billing was created and there are no residents to retry
+		return nil, err
 	}
 
-	// Execute in transaction
-	response := &BulkBillingResponse{
-		TotalUsers:    len(users),
-		TotalBillings: len(billings),
+	response := newBulkBillingResponse(userIDs)
+	response.TotalUsers = plan.TotalUsers
+	if err == nil {
+		response.addPlan(plan, nil)
+		return response.finish(), nil
 	}
 
-	err = s.db.Transaction(func(tx *gorm.DB) error {
-		// Create billings
-		if err := tx.CreateInBatches(billings, 100).Error; err != nil {
-			return fmt.Errorf("failed to create billings: %w", err)
-		}
+	s.logger.WithError(err).Warn("Bulk billing generation failed, retrying residents one by one")
 
-		// Update links with billing IDs
-		for i, billing := range billings {
-			if i < len(links) {
-				links[i].BillingID = billing.ID
-			}
-			if i < len(statusLinks) {
-				statusLinks[i].BillingID = billing.ID
-			}
-			if i < len(kategoriLinks) {
-				kategoriLinks[i].BillingID = billing.ID
-			}
-		}
-
-		// Create profile links
-		if err := tx.CreateInBatches(links, 100).Error; err != nil {
-			return fmt.Errorf("failed to create billing profile links: %w", err)
-		}
+	retried := make(map[uint]bool, len(plan.Residents))
+	for _, resident := range plan.Residents {
+		retried[resident.UserID] = true
+	}
+	// Residents being retried are reported from their own plan
+	response.addPlan(plan, retried)
 
-		// Create status bill links
-		if err := tx.CreateInBatches(statusLinks, 100).Error; err != nil {
-			return fmt.Errorf("failed to create billing status bill links: %w", err)
+	for _, resident := range plan.Residents {
+		residentPlan, err := s.createBillings([]uint{resident.UserID}, settings, docPrefix, month, year, statusID, applyCredit)
+		if err != nil {
+			s.logger.WithError(err).WithField("user_id", resident.UserID).Error("Failed to create billings for resident")
+			response.addFailure(resident, err)
+			continue
 		}
+		response.addPlan(residentPlan, nil)
+	}
 
-		// Create kategori transaksi links
-		if err := tx.CreateInBatches(kategoriLinks, 100).Error; err != nil {
-			return fmt.Errorf("failed to create billing kategori transaksi links: %w", err)
-		}
+	return response.finish(), nil
+}
 
-		response.SuccessCount = len(billings)
-		return nil
+// createBillings plans and inserts the billings for the users in one transaction holding the period lock.
+// With applyCredit it also holds the residents' credit locks, and the credit applied is taken from their
+// balances with ledger entries referencing the billings. The plan is returned even when inserting fails,
+// so callers can report what was attempted.
+func (s *billingService) createBillings(userIDs []uint, settings []*models.SettingBilling, docPrefix string, month int, year int, statusID uint, applyCredit bool) (*BillingPlan, error) {
+	var plan *BillingPlan
+	err := s.db.Transaction(func(tx *gorm.DB) error {
+		var err error
+		plan, err = s.createBillingsInTx(tx, userIDs, settings, docPrefix, month, year, statusID, applyCredit)
+		return err
 	})
 
-	if err != nil {
-		response.FailedCount = len(billings)
-		response.Errors = []string{err.Error()}
+	if err != nil && plan != nil {
+		resetBillingPlan(plan)
 	}
 
-	return response, nil
+	return plan, err
 }
 
-// CreateBulkCustomBillings creates custom billings for specified user IDs
-func (s *billingService) CreateBulkCustomBillings(userIDs []uint, billingSettingsId int, month int, year int) (*BulkBillingResponse, error) {
-	// Always use admin user (ID 1) as the creator
-	adminID := 1
-	createdByInt := &adminID
-
-	// Get default status ("Belum Dibayar")
-	var defaultStatus models.MasterGeneralStatus
-	if err := s.db.Table("master_general_statuses").Where("status_name = ? AND published_at IS NOT NULL", "Belum Dibayar").First(&defaultStatus).Error; err != nil {
-		// If no default status found, get first available status
-		if err := s.db.Table("master_general_statuses").Where("published_at IS NOT NULL").First(&defaultStatus).Error; err != nil {
-			return nil, fmt.Errorf("failed to get default status: %w", err)
-		}
+// createBillingsInTx takes the period lock, plans the billings for the users and inserts them in tx. The
+// plan is returned even when inserting fails.
+func (s *billingService) createBillingsInTx(tx *gorm.DB, userIDs []uint, settings []*models.SettingBilling, docPrefix string, month int, year int, statusID uint, applyCredit bool) (*BillingPlan, error) {
+	// Serializes concurrent generation for the period so two runs cannot both pass the duplicate check
+	if err := tx.Exec("SELECT pg_advisory_xact_lock(?, ?)", billingGenerationLockKey, year*100+month).Error; err != nil {
+		return nil, fmt.Errorf("failed to lock billing period: %w", err)
 	}
 
-	// Get setting billings
-	setting, err := s.billingRepo.GetBillingSettingsByID(uint(billingSettingsId))
+	plan, err := s.buildBillingPlan(userIDs, settings, month, year)
 	if err != nil {
-		return nil, fmt.Errorf("failed to get setting billings: %w", err)
+		return nil, err
 	}
 
-	// Get users with profiles
-	var users []*models.User
-	if len(userIDs) > 0 {
-		// Filter specific users
-		for _, userID := range userIDs {
-			user, err := s.getUserWithProfile(userID)
-			if err != nil {
-				continue // Skip if user not found or no profile
-			}
-			users = append(users, user)
-		}
-	} else {
-		// Get all penghuni users
-		users, err = s.billingRepo.GetUsersWithPenghuniRole()
+	if applyCredit {
+		balances, err := s.creditRepo.LockBalances(tx, planProfileIDs(plan))
 		if err != nil {
-			return nil, fmt.Errorf("failed to get penghuni users: %w", err)
+			return plan, fmt.Errorf("failed to lock credit balances: %w", err)
 		}
+		allocateCredit(plan, balances)
 	}
 
-	if len(users) == 0 {
-		return &BulkBillingResponse{
-			TotalUsers:    0,
-			TotalBillings: 0,
-			SuccessCount:  0,
-			FailedCount:   0,
-		}, nil
-	}
+	return plan, s.insertPlannedBillings(tx, plan, docPrefix, month, year, statusID)
+}
+
+// insertPlannedBillings inserts the billings of a plan with their links and status histories in tx
+func (s *billingService) insertPlannedBillings(tx *gorm.DB, plan *BillingPlan, docPrefix string, month int, year int, statusID uint) error {
+	// Always use admin user (ID 1) as the creator
+	adminID := 1
+	createdByInt := &adminID
+
+	now := time.Now()
+	generatedReason := "Billing generated"
+	creditReason := "Paid from credit balance"
+	paidStatusID := s.statuses.IDs().Paid
 
 	// Prepare billings and links
 	var billings []*models.Billing
 	var links []*models.BillingProfileLink
 	var statusLinks []*models.BillingStatusBillLink
 	var kategoriLinks []*models.BillingKategoriTransaksiLink
-	now := time.Now()
+	var histories []*models.BillingStatusHistory
+	var items []*BillingPlanItem
+	var itemResidents []*BillingPlanResident
 
-	for _, user := range users {
-		// Skip settings that are not published
-		if setting.PublishedAt == nil {
-			continue
-		}
-		// Generate document ID
-		docID := "custom-" + uuid.New().String()
-
-		// Convert nominal from float64 to int64
-		nominal := setting.Nominal
-
-		// Use provided month and year
-		billingMonth := month
-		billingYear := year
-
-		// Set PublishedAt based on setting's PublishedAt
-		var billingPublishedAt *time.Time
-		if setting.PublishedAt != nil {
-			billingPublishedAt = &now
-		} else {
-			billingPublishedAt = nil
-		}
+	for _, resident := range plan.Residents {
+		for _, item := range resident.Items {
+			items = append(items, item)
+			itemResidents = append(itemResidents, resident)
 
-		// Create billing
-		nominalPtr := int64(nominal)
-		billing := &models.Billing{
-			DocumentID:  &docID,
-			NamaBilling: &setting.NamaBilling,
-			Keterangan:  &setting.Keterangan,
-			Bulan:       &billingMonth,
-			Tahun:       &billingYear,
-			Nominal:     &nominalPtr,
-			CreatedAt:   &now,
-			UpdatedAt:   &now,
-			PublishedAt: billingPublishedAt,
-			CreatedByID: createdByInt,
-			UpdatedByID: createdByInt,
-		}
-		billings = append(billings, billing)
+			// Generate document ID
+			docID := docPrefix + uuid.New().String()
 
-		// Create link
-		link := &models.BillingProfileLink{
-			BillingID: billing.ID, // Will be set after insert
-			ProfileID: user.ID,    // Use user ID directly
-		}
-		links = append(links, link)
+			namaBilling := item.NamaBilling
+			keterangan := item.Keterangan
+			nominal := item.Nominal
+			outstanding := item.Nominal - item.CreditApplied
+			billingMonth := month
+			billingYear := year
+			settingID := item.SettingBillingID
+			dueDate := item.DueDate
 
-		// Create status link
-		statusLink := &models.BillingStatusBillLink{
-			BillingID:             billing.ID, // Will be set after insert
-			MasterGeneralStatusID: defaultStatus.ID,
-		}
-		statusLinks = append(statusLinks, statusLink)
+			// Create billing
+			billing := &models.Billing{
+				DocumentID:        &docID,
+				NamaBilling:       &namaBilling,
+				Keterangan:        &keterangan,
+				Bulan:             &billingMonth,
+				Tahun:             &billingYear,
+				Nominal:           &nominal,
+				OutstandingAmount: &outstanding,
+				SettingBillingID:  &settingID,
+				DueDate:           &dueDate,
+				CreatedAt:         &now,
+				UpdatedAt:         &now,
+				PublishedAt:       &now,
+				CreatedByID:       createdByInt,
+				UpdatedByID:       createdByInt,
+			}
+			billings = append(billings, billing)
+
+			// Create link
+			links = append(links, &models.BillingProfileLink{
+				ProfileID: resident.UserID, // Use user ID directly
+			})
+
+			// Create status link, billings the credit fully covers are paid
+			billingStatusID := statusID
+			if outstanding == 0 {
+				billingStatusID = paidStatusID
+			}
+			statusLinks = append(statusLinks, &models.BillingStatusBillLink{
+				MasterGeneralStatusID: billingStatusID,
+			})
 
-		// Create kategori transaksi link
-		kategoriLink := &models.BillingKategoriTransaksiLink{
-			BillingID:                 billing.ID, // Will be set after insert
-			MasterKategoriTransaksiID: 1,
+			// Create kategori transaksi link
+			kategoriLinks = append(kategoriLinks, &models.BillingKategoriTransaksiLink{
+				MasterKategoriTransaksiID: 1,
+			})
+
+			// Record the status the billing starts in
+			histories = append(histories, &models.BillingStatusHistory{
+				ToStatusID: statusID,
+				Reason:     &generatedReason,
+			})
 		}
-		kategoriLinks = append(kategoriLinks, kategoriLink)
 	}
 
-	// Execute in transaction
-	response := &BulkBillingResponse{
-		TotalUsers:    len(users),
-		TotalBillings: len(billings),
+	if len(billings) == 0 {
+		return nil
 	}
 
-	err = s.db.Transaction(func(tx *gorm.DB) error {
-		// Create billings
-		if err := tx.CreateInBatches(billings, 100).Error; err != nil {
-			return fmt.Errorf("failed to create billings: %w", err)
-		}
+	// Create billings
+	if err := tx.CreateInBatches(billings, 100).Error; err != nil {
+		return fmt.Errorf("failed to create billings: %w", err)
+	}
 
-		// Update links with billing IDs
-		for i, billing := range billings {
-			if i < len(links) {
-				links[i].BillingID = billing.ID
-			}
-			if i < len(statusLinks) {
-				statusLinks[i].BillingID = billing.ID
-			}
-			if i < len(kategoriLinks) {
-				kategoriLinks[i].BillingID = billing.ID
-			}
-		}
+	// Update links with billing IDs
+	for i, billing := range billings {
+		items[i].BillingID = billing.ID
+		links[i].BillingID = billing.ID
+		statusLinks[i].BillingID = billing.ID
+		kategoriLinks[i].BillingID = billing.ID
+		histories[i].BillingID = billing.ID
+	}
 
-		// Create profile links
-		if err := tx.CreateInBatches(links, 100).Error; err != nil {
-			return fmt.Errorf("failed to create billing profile links: %w", err)
-		}
+	// Create profile links
+	if err := tx.CreateInBatches(links, 100).Error; err != nil {
+		return fmt.Errorf("failed to create billing profile links: %w", err)
+	}
 
-		// Create status bill links
-		if err := tx.CreateInBatches(statusLinks, 100).Error; err != nil {
-			return fmt.Errorf("failed to create billing status bill links: %w", err)
-		}
+	// Create status bill links
+	if err := tx.CreateInBatches(statusLinks, 100).Error; err != nil {
+		return fmt.Errorf("failed to create billing status bill links: %w", err)
+	}
+
+	// Create kategori transaksi links
+	if err := tx.CreateInBatches(kategoriLinks, 100).Error; err != nil {
+		return fmt.Errorf("failed to create billing kategori transaksi links: %w", err)
+	}
 
-		// Create kategori transaksi links
-		if err := tx.CreateInBatches(kategoriLinks, 100).Error; err != nil {
-			return fmt.Errorf("failed to create billing kategori transaksi links: %w", err)
+	// Take the credit applied from the balances, recording the billings paid with it in their timeline
+	var entries []*models.CreditLedgerEntry
+	for i, item := range items {
+		if item.CreditApplied == 0 {
+			continue
 		}
+		billingID := billings[i].ID
+		entries = append(entries, &models.CreditLedgerEntry{
+			ProfileID:   *itemResidents[i].ProfileID,
+			Type:        models.CreditEntryApplied,
+			Amount:      -item.CreditApplied,
+			BillingID:   &billingID,
+			Description: fmt.Sprintf("Applied to billing #%d", billingID),
+		})
+		if *billings[i].OutstandingAmount == 0 {
+			histories = append(histories, &models.BillingStatusHistory{
+				BillingID:    billingID,
+				FromStatusID: &statusID,
+				ToStatusID:   paidStatusID,
+				Reason:       &creditReason,
+			})
+		}
+	}
 
-		response.SuccessCount = len(billings)
-		return nil
-	})
+	// Create status histories
+	if err := tx.CreateInBatches(histories, 100).Error; err != nil {
+		return fmt.Errorf("failed to create billing status histories: %w", err)
+	}
 
-	if err != nil {
-		response.FailedCount = len(billings)
-		response.Errors = []string{err.Error()}
+	if len(entries) > 0 {
+		if err := tx.CreateInBatches(entries, 100).Error; err != nil {
+			return fmt.Errorf("failed to create credit ledger entries: %w", err)
+		}
 	}
 
-	return response, nil
+	return nil
 }
 
-// CreateBulkMonthlyBillingsForAllUsers creates monthly billings for all penghuni users
-func (s *billingService) CreateBulkMonthlyBillingsForAllUsers(month int, year int) (*BulkBillingResponse, error) {
-	return s.CreateBulkMonthlyBillings([]uint{}, month, year)
+// resetBillingPlan clears what a plan recorded as created after its transaction rolled back, so none of
+// the planned billings exist and no credit was taken
+func resetBillingPlan(plan *BillingPlan) {
+	plan.TotalCredit = 0
+	for _, resident := range plan.Residents {
+		resident.CreditApplied = 0
+		for _, item := range resident.Items {
+			item.BillingID = 0
+			item.CreditApplied = 0
+		}
+	}
 }
 
-// CreateBulkCustomBillingsForAllUsers creates custom billings for all penghuni users
-func (s *billingService) CreateBulkCustomBillingsForAllUsers(month int, billingSettingsId int, year int) (*BulkBillingResponse, error) {
-	return s.CreateBulkCustomBillings([]uint{}, billingSettingsId, month, year)
-}
+// PreviewBulkMonthlyBillings returns the plan CreateBulkMonthlyBillings would carry out, without creating anything
+func (s *billingService) PreviewBulkMonthlyBillings(userIDs []uint, month int, year int) (*BillingPlan, error) {
+	settings, err := s.billingRepo.GetActiveMonthlySettingBillings()
+	if err != nil {
+		return nil, fmt.Errorf("failed to get setting billings: %w", err)
+	}
 
-// getUserWithProfile gets user with profile information
-func (s *billingService) getUserWithProfile(userID uint) (*models.User, error) {
-	var user models.User
-	err := s.db.Where("id = ?", userID).First(&user).Error
+	if len(settings) == 0 {
+		return nil, fmt.Errorf("no active monthly setting billings found")
+	}
+
+	plan, err := s.buildBillingPlan(userIDs, settings, month, year)
 	if err != nil {
 		return nil, err
 	}
 
-	// Check if user has profile via join
-	var count int64
-	err = s.db.Table("profiles").
-		Joins("JOIN up_users_profile_lnk pul ON profiles.id = pul.profile_id").
-		Where("pul.user_id = ?", userID).
-		Count(&count).Error
+	balances, err := s.creditRepo.GetBalances(planProfileIDs(plan))
 	if err != nil {
-		return nil, err
+		return nil, fmt.Errorf("failed to get credit balances: %w", err)
 	}
+	allocateCredit(plan, balances)
 
-	if count == 0 {
-		return nil, fmt.Errorf("user has no profile")
+	return plan, nil
+}
+
+// PreviewBulkCustomBillings returns the plan CreateBulkCustomBillings would carry out, without creating anything
+func (s *billingService) PreviewBulkCustomBillings(userIDs []uint, billingSettingsId int, month int, year int) (*BillingPlan, error) {
+	setting, err := s.billingRepo.GetBillingSettingsByID(uint(billingSettingsId))
+	if err != nil {
+		return nil, fmt.Errorf("failed to get setting billings: %w", err)
 	}
 
-	return &user, nil
+	return s.buildBillingPlan(userIDs, []*models.SettingBilling{setting}, month, year)
 }
 
 // GetBillingPenghuni retrieves all billing data for penghuni users
@@ -445,32 +436,65 @@ func (s *billingService) GetBillingPenghuniAll() ([]*models.BillingPenghuniRespo
 	return s.billingRepo.GetBillingPenghuniAll()
 }
 
-func (s *billingService) ConfirmPayment(listIds []uint) error {
-	// Run updates in a transaction: mark billings as paid and update status links
-	err := s.db.Transaction(func(tx *gorm.DB) error {
-		for _, id := range listIds {
-			fmt.Println("id to confirm payment: ", id)
-
-			// Update billing status links: set master_general_status_id = 6 for matching t_billing_id
-			if err := tx.Model(&models.BillingStatusBillLink{}).
-				Where("t_billing_id = ?", id).
-				Updates(map[string]interface{}{
-					"master_general_status_id": 6,
-				}).Error; err != nil {
-				return fmt.Errorf("failed to update billing status links: %w", err)
-			}
-		}
-
-		return nil
+// ConfirmPayment marks the billings as paid, leaving nothing outstanding on them. Nothing is marked when
+// any of them cannot be paid, e.g. because it was cancelled.
+func (s *billingService) ConfirmPayment(billingIDs []uint, actorID uint, reason string) error {
+	moved, err := s.stateMachine.Transition(&BillingTransitionRequest{
+		BillingIDs: billingIDs,
+		To:         models.BillingStatePaid,
+		ActorID:    actorID,
+		Reason:     reason,
 	})
-
 	if err != nil {
 		return err
 	}
 
+	if err := s.billingRepo.SettleBillings(moved); err != nil {
+		return fmt.Errorf("failed to settle billings: %w", err)
+	}
 	return nil
 }
 
+// ApplyPayment takes the amounts paid off the outstanding balances of the billings and marks the billings
+// left with nothing outstanding as paid in the same transaction, returning their IDs. Nothing is applied
+// when any billing cannot be paid or would be paid more than it owes. A payment is applied once,
+// ErrPaymentAlreadyApplied is returned when it is applied again.
+func (s *billingService) ApplyPayment(paymentID uint, amounts map[uint]int64, actorID uint, reason string) ([]uint, error) {
+	settled, applied, err := s.billingRepo.ApplyPayment(paymentID, amounts, func(billing *models.Billing, statusID uint, amount int64) error {
+		state := s.statuses.State(statusID)
+		if !s.stateMachine.CanTransition(state, models.BillingStatePaid) {
+			return fmt.Errorf("%w: billing %d cannot move from %s to %s", ErrInvalidTransition, billing.ID, describeState(state, statusID), models.BillingStatePaid)
+		}
+		if outstanding := billing.Outstanding(); amount > outstanding {
+			return fmt.Errorf("%w: billing %d has %d outstanding", ErrAmountExceedsOutstanding, billing.ID, outstanding)
+		}
+		return nil
+	}, func(tx *gorm.DB, settled []uint) error {
+		_, err := s.stateMachine.TransitionInTx(tx, &BillingTransitionRequest{
+			BillingIDs: settled,
+			To:         models.BillingStatePaid,
+			ActorID:    actorID,
+			Reason:     reason,
+		})
+		return err
+	})
+	if err != nil {
+		return nil, err
+	}
+	if !applied {
+		return nil, fmt.Errorf("%w: payment %d", ErrPaymentAlreadyApplied, paymentID)
+	}
+
+	s.logger.WithFields(map[string]interface{}{
+		"payment_id": paymentID,
+		"amounts":    amounts,
+		"settled":    settled,
+		"actor_id":   actorID,
+	}).Info("Payment applied to billings")
+
+	return settled, nil
+}
+
 // UploadBillingAttachment stores the uploaded file on disk and returns metadata (no DB persistence)
 func (s *billingService) UploadBillingAttachment(billingID uint, filename string, content []byte) (*models.BillingAttachment, error) {
 	// ensure billing exists
@@ -562,6 +586,6 @@ func (s *billingService) GetBillingByProfileID(profileID uint, bulan *int, tahun
 }
 
 // GetBillingStatistics retrieves billing statistics with optional filters
-func (s *billingService) GetBillingStatistics(search string, bulan *int, tahun *int, rt *int, statusIDs []int) (*response.BillingStatisticsResponse, error) {
-	return s.billingRepo.GetBillingStatistics(search, bulan, tahun, rt, statusIDs)
+func (s *billingService) GetBillingStatistics(search string, bulan *int, tahun *int, rt *int, statusIDs []int, overdue bool) (*response.BillingStatisticsResponse, error) {
+	return s.billingRepo.GetBillingStatistics(search, bulan, tahun, rt, statusIDs, overdue, s.statuses.IDs())
 }
@@ -0,0 +1,220 @@
+package service
+
+import (
+	"errors"
+	"fmt"
+
+	"ipl-be-svc/internal/models"
+	"ipl-be-svc/internal/repository"
+	"ipl-be-svc/pkg/logger"
+
+	"gorm.io/gorm"
+)
+
+// ErrInvalidTransition is returned when a billing cannot move from its current status to the requested one
+var ErrInvalidTransition = errors.New("invalid billing status transition")
+
+// billingTransitions lists the states a billing may move to from each state. Cancelled and refunded
+// billings are closed.
+var billingTransitions = map[string][]string{
+	models.BillingStateUnpaid: {
+		models.BillingStatePendingVerification,
+		models.BillingStatePaid,
+		models.BillingStateOverdue,
+		models.BillingStateCancelled,
+	},
+	models.BillingStateOverdue: {
+		models.BillingStatePendingVerification,
+		models.BillingStatePaid,
+		models.BillingStateCancelled,
+	},
+	models.BillingStatePendingVerification: {
+		models.BillingStatePaid,
+		models.BillingStateUnpaid,
+		models.BillingStateCancelled,
+	},
+	models.BillingStatePaid: {
+		models.BillingStateRefunded,
+	},
+}
+
+// BillingTransitionRequest moves billings to a state on behalf of an actor (0 for the system)
+type BillingTransitionRequest struct {
+	BillingIDs []uint
+	To         string
+	ActorID    uint
+	Reason     string
+	// SkipInvalid leaves billings that cannot move to To as they are instead of failing
+	SkipInvalid bool
+}
+
+// BillingTimeline is the current status of a billing with the transitions that led to it
+type BillingTimeline struct {
+	BillingID uint                    `json:"billing_id"`
+	StatusID  uint                    `json:"status_id"`
+	State     string                  `json:"state"`
+	Entries   []*BillingTimelineEntry `json:"entries"`
+}
+
+// BillingTimelineEntry is a status history entry with the billing states of its statuses
+type BillingTimelineEntry struct {
+	*models.BillingStatusHistoryEntry
+	FromState string `json:"from_state,omitempty"`
+	ToState   string `json:"to_state,omitempty"`
+}
+
+// BillingStateMachine is the only way billing statuses change after creation. It enforces the allowed
+// transitions and records each one in the status history.
+type BillingStateMachine interface {
+	CanTransition(from string, to string) bool
+	Transition(req *BillingTransitionRequest) ([]uint, error)
+	TransitionInTx(tx *gorm.DB, req *BillingTransitionRequest) ([]uint, error)
+	GetTimeline(billingID uint) (*BillingTimeline, error)
+}
+
+// billingStateMachine implements BillingStateMachine
+type billingStateMachine struct {
+	statusRepo  repository.BillingStatusRepository
+	billingRepo repository.BillingRepository
+	statuses    StatusRegistry
+	logger      *logger.Logger
+}
+
+// NewBillingStateMachine creates a new instance of BillingStateMachine
+func NewBillingStateMachine(statusRepo repository.BillingStatusRepository, billingRepo repository.BillingRepository, statuses StatusRegistry, logger *logger.Logger) BillingStateMachine {
+	return &billingStateMachine{
+		statusRepo:  statusRepo,
+		billingRepo: billingRepo,
+		statuses:    statuses,
+		logger:      logger,
+	}
+}
+
+// CanTransition reports whether a billing may move from one state to another
+func (m *billingStateMachine) CanTransition(from string, to string) bool {
+	for _, allowed := range billingTransitions[from] {
+		if allowed == to {
+			return true
+		}
+	}
+	return false
+}
+
+// Transition moves the billings to the requested state and returns the IDs of those that moved. Billings
+// already in it are left alone. Unless SkipInvalid is set, nothing moves when any billing cannot.
+func (m *billingStateMachine) Transition(req *BillingTransitionRequest) ([]uint, error) {
+	return m.transition(req, m.statusRepo.ChangeStatus)
+}
+
+// TransitionInTx is Transition within tx, so the status change commits together with the caller's changes
+func (m *billingStateMachine) TransitionInTx(tx *gorm.DB, req *BillingTransitionRequest) ([]uint, error) {
+	return m.transition(req, func(billingIDs []uint, toStatusID uint, check func(current map[uint]uint) ([]uint, error), actorID *uint, reason *string) ([]uint, error) {
+		return m.statusRepo.ChangeStatusInTx(tx, billingIDs, toStatusID, check, actorID, reason)
+	})
+}
+
+// transition checks the request against the allowed transitions and applies it with change
+func (m *billingStateMachine) transition(req *BillingTransitionRequest, change func(billingIDs []uint, toStatusID uint, check func(current map[uint]uint) ([]uint, error), actorID *uint, reason *string) ([]uint, error)) ([]uint, error) {
+	toStatusID := m.statuses.IDs().ID(req.To)
+	if toStatusID == 0 {
+		return nil, fmt.Errorf("%w: unknown state %q", ErrInvalidTransition, req.To)
+	}
+	if len(req.BillingIDs) == 0 {
+		return nil, nil
+	}
+
+	check := func(current map[uint]uint) ([]uint, error) {
+		seen := make(map[uint]bool, len(req.BillingIDs))
+		var moving []uint
+		for _, billingID := range req.BillingIDs {
+			if seen[billingID] {
+				continue
+			}
+			seen[billingID] = true
+
+			statusID, ok := current[billingID]
+			from := m.statuses.State(statusID)
+			switch {
+			case ok && from == req.To:
+				continue
+			case ok && m.CanTransition(from, req.To):
+				moving = append(moving, billingID)
+			case req.SkipInvalid:
+				continue
+			case !ok:
+				return nil, fmt.Errorf("%w: billing %d has no status", ErrInvalidTransition, billingID)
+			default:
+				return nil, fmt.Errorf("%w: billing %d cannot move from %s to %s", ErrInvalidTransition, billingID, describeState(from, statusID), req.To)
+			}
+		}
+		return moving, nil
+	}
+
+	var actorID *uint
+	if req.ActorID != 0 {
+		actorID = &req.ActorID
+	}
+	var reason *string
+	if req.Reason != "" {
+		reason = &req.Reason
+	}
+
+	moved, err := change(req.BillingIDs, toStatusID, check, actorID, reason)
+	if err != nil {
+		return nil, err
+	}
+
+	if len(moved) > 0 {
+		m.logger.WithFields(map[string]interface{}{
+			"billing_ids": moved,
+			"to":          req.To,
+			"actor_id":    req.ActorID,
+			"reason":      req.Reason,
+		}).Info("Billing status changed")
+	}
+
+	return moved, nil
+}
+
+// GetTimeline retrieves the current status of a billing and its status history, oldest first
+func (m *billingStateMachine) GetTimeline(billingID uint) (*BillingTimeline, error) {
+	if _, err := m.billingRepo.GetBillingByID(billingID); err != nil {
+		return nil, err
+	}
+
+	statusIDs, err := m.billingRepo.GetBillingStatusIDs([]uint{billingID})
+	if err != nil {
+		return nil, fmt.Errorf("failed to get billing status: %w", err)
+	}
+
+	history, err := m.statusRepo.GetHistory(billingID)
+	if err != nil {
+		return nil, fmt.Errorf("failed to get billing status history: %w", err)
+	}
+
+	timeline := &BillingTimeline{
+		BillingID: billingID,
+		StatusID:  statusIDs[billingID],
+		State:     m.statuses.State(statusIDs[billingID]),
+		Entries:   make([]*BillingTimelineEntry, len(history)),
+	}
+	for i, entry := range history {
+		timeline.Entries[i] = &BillingTimelineEntry{
+			BillingStatusHistoryEntry: entry,
+			ToState:                   m.statuses.State(entry.ToStatusID),
+		}
+		if entry.FromStatusID != nil {
+			timeline.Entries[i].FromState = m.statuses.State(*entry.FromStatusID)
+		}
+	}
+
+	return timeline, nil
+}
+
+// describeState names a state for error messages, falling back to the status ID of statuses of no state
+func describeState(state string, statusID uint) string {
+	if state == "" {
+		return fmt.Sprintf("status %d", statusID)
+	}
+	return state
+}
@@ -0,0 +1,202 @@
+package service
+
+import (
+	"fmt"
+	"time"
+
+	"ipl-be-svc/internal/models"
+)
+
+const (
+	// bulkBillingJobChunkSize is how many residents a background job bills per transaction
+	bulkBillingJobChunkSize = 50
+	// bulkBillingJobStaleAfter is how long a job may go without saving progress before it is
+	// reported as interrupted, e.g. by a restart
+	bulkBillingJobStaleAfter = 10 * time.Minute
+)
+
+// BulkBillingJobRequest describes a bulk billing generation to run in the background
+type BulkBillingJobRequest struct {
+	Kind              string
+	UserIDs           []uint
+	BillingSettingsId int
+	Month             int
+	Year              int
+}
+
+// SubmitBulkBillingJob validates the request, records a queued job and starts generating its
+// billings in the background
+func (s *billingService) SubmitBulkBillingJob(req *BulkBillingJobRequest, actorID uint) (*models.BulkBillingJob, error) {
+	job := &models.BulkBillingJob{
+		Kind:    req.Kind,
+		UserIDs: joinUintIDs(req.UserIDs),
+		Bulan:   req.Month,
+		Tahun:   req.Year,
+		Status:  models.BulkBillingJobStatusQueued,
+	}
+	if actorID != 0 {
+		job.CreatedByID = &actorID
+	}
+
+	var settings []*models.SettingBilling
+	var docPrefix string
+	var err error
+	switch req.Kind {
+	case models.BulkBillingJobKindMonthly:
+		settings, err = s.billingRepo.GetActiveMonthlySettingBillings()
+		if err != nil {
+			return nil, fmt.Errorf("failed to get setting billings: %w", err)
+		}
+		if len(settings) == 0 {
+			return nil, fmt.Errorf("no active monthly setting billings found")
+		}
+		docPrefix = "monthly-"
+	case models.BulkBillingJobKindCustom:
+		setting, err := s.billingRepo.GetBillingSettingsByID(uint(req.BillingSettingsId))
+		if err != nil {
+			return nil, fmt.Errorf("failed to get setting billings: %w", err)
+		}
+		settings = []*models.SettingBilling{setting}
+		job.SettingBillingID = &setting.ID
+		docPrefix = "custom-"
+	default:
+		return nil, fmt.Errorf("unknown bulk billing job kind %q", req.Kind)
+	}
+
+	if err := s.jobRepo.Create(job); err != nil {
+		return nil, fmt.Errorf("failed to record bulk billing job: %w", err)
+	}
+
+	s.logger.WithFields(map[string]interface{}{
+		"job_id": job.ID,
+		"kind":   job.Kind,
+		"bulan":  job.Bulan,
+		"tahun":  job.Tahun,
+	}).Info("Bulk billing job submitted")
+
+	queued := *job
+	go s.runBulkBillingJob(&queued, req.UserIDs, settings, docPrefix, s.statuses.IDs().Unpaid)
+
+	return job, nil
+}
+
+// GetBulkBillingJob retrieves a job with its per-resident failures
+func (s *billingService) GetBulkBillingJob(id uint) (*models.BulkBillingJob, error) {
+	job, err := s.jobRepo.GetByID(id)
+	if err != nil {
+		return nil, err
+	}
+
+	unfinished := job.Status == models.BulkBillingJobStatusQueued || job.Status == models.BulkBillingJobStatusRunning
+	if unfinished && time.Since(job.UpdatedAt) > bulkBillingJobStaleAfter {
+		s.finishBulkBillingJob(job, fmt.Errorf("job was interrupted before completing"))
+	}
+
+	return job, nil
+}
+
+// runBulkBillingJob bills the residents in chunks, each in its own transaction. A chunk that fails is
+// retried one resident at a time so a bad resident only fails their own billings. Monthly jobs apply the
+// residents' credit balances as CreateBulkMonthlyBillings does.
+func (s *billingService) runBulkBillingJob(job *models.BulkBillingJob, userIDs []uint, settings []*models.SettingBilling, docPrefix string, statusID uint) {
+	defer func() {
+		if r := recover(); r != nil {
+			s.finishBulkBillingJob(job, fmt.Errorf("job panicked: %v", r))
+		}
+	}()
+
+	now := time.Now()
+	job.Status = models.BulkBillingJobStatusRunning
+	job.StartedAt = &now
+
+	if len(userIDs) == 0 {
+		recipients, err := s.billingRepo.GetBillingRecipients(nil)
+		if err != nil {
+			s.finishBulkBillingJob(job, fmt.Errorf("failed to get billing recipients: %w", err))
+			return
+		}
+		for _, recipient := range recipients {
+			userIDs = append(userIDs, recipient.UserID)
+		}
+	}
+
+	job.TotalUsers = len(userIDs)
+	s.saveBulkBillingJob(job)
+
+	applyCredit := job.Kind == models.BulkBillingJobKindMonthly
+
+	for start := 0; start < len(userIDs); start += bulkBillingJobChunkSize {
+		chunk := userIDs[start:min(start+bulkBillingJobChunkSize, len(userIDs))]
+
+		plan, err := s.createBillings(chunk, settings, docPrefix, job.Bulan, job.Tahun, statusID, applyCredit)
+		if err == nil {
+			s.addBulkBillingJobPlan(job, plan)
+		} else {
+			s.logger.WithError(err).WithField("job_id", job.ID).Warn("Bulk billing job chunk failed, retrying residents one by one")
+			for _, userID := range chunk {
+				plan, err := s.createBillings([]uint{userID}, settings, docPrefix, job.Bulan, job.Tahun, statusID, applyCredit)
+				if err != nil {
+					s.recordBulkBillingJobFailure(job, userID, err)
+					continue
+				}
+				s.addBulkBillingJobPlan(job, plan)
+			}
+		}
+
+		job.ProcessedUsers += len(chunk)
+		s.saveBulkBillingJob(job)
+	}
+
+	s.finishBulkBillingJob(job, nil)
+}
+
+// addBulkBillingJobPlan adds the billings created from a chunk's plan to the job counters
+func (s *billingService) addBulkBillingJobPlan(job *models.BulkBillingJob, plan *BillingPlan) {
+	job.TotalBillings += plan.TotalBillings
+	job.SuccessCount += plan.TotalBillings
+	job.SkippedCount += len(plan.Skipped)
+}
+
+// recordBulkBillingJobFailure counts and stores a resident whose billings could not be created
+func (s *billingService) recordBulkBillingJobFailure(job *models.BulkBillingJob, userID uint, err error) {
+	s.logger.WithError(err).WithFields(map[string]interface{}{
+		"job_id":  job.ID,
+		"user_id": userID,
+	}).Error("Failed to create billings for resident")
+
+	job.FailedCount++
+	failure := &models.BulkBillingJobFailure{JobID: job.ID, UserID: userID, Error: err.Error()}
+	if err := s.jobRepo.AddFailure(failure); err != nil {
+		s.logger.WithError(err).WithField("job_id", job.ID).Error("Failed to record bulk billing job failure")
+		return
+	}
+	job.Failures = append(job.Failures, failure)
+}
+
+// finishBulkBillingJob marks the job completed, or failed when err is set, and saves it
+func (s *billingService) finishBulkBillingJob(job *models.BulkBillingJob, err error) {
+	now := time.Now()
+	job.FinishedAt = &now
+	job.Status = models.BulkBillingJobStatusCompleted
+	if err != nil {
+		message := err.Error()
+		job.Error = &message
+		job.Status = models.BulkBillingJobStatusFailed
+		s.logger.WithError(err).WithField("job_id", job.ID).Error("Bulk billing job failed")
+	} else {
+		s.logger.WithFields(map[string]interface{}{
+			"job_id":        job.ID,
+			"success_count": job.SuccessCount,
+			"failed_count":  job.FailedCount,
+			"skipped_count": job.SkippedCount,
+		}).Info("Bulk billing job completed")
+	}
+	s.saveBulkBillingJob(job)
+}
+
+// saveBulkBillingJob persists the job's progress, which also serves as its heartbeat
+func (s *billingService) saveBulkBillingJob(job *models.BulkBillingJob) {
+	if err := s.jobRepo.Update(job); err != nil {
+		s.logger.WithError(err).WithField("job_id", job.ID).Error("Failed to save bulk billing job progress")
+	}
+}
@@ -0,0 +1,121 @@
+package service
+
+import (
+	"fmt"
+
+	"ipl-be-svc/internal/models"
+	"ipl-be-svc/internal/repository"
+	"ipl-be-svc/pkg/logger"
+)
+
+// CreditDepositRequest represents money a resident paid in advance, to be applied to future billings
+type CreditDepositRequest struct {
+	Amount int64  `json:"amount" binding:"required,gt=0" example:"1800000"`
+	Note   string `json:"note" example:"Transfer for the next 6 months"`
+}
+
+// CreditBalance is the credit a resident has left to be applied to billings
+type CreditBalance struct {
+	ProfileID uint  `json:"profile_id"`
+	Balance   int64 `json:"balance"`
+}
+
+// CreditService defines the interface for the residents' credit balances. Credit is applied to the billings
+// generated by CreateBulkMonthlyBillings.
+type CreditService interface {
+	Deposit(profileID uint, req *CreditDepositRequest, actorID uint) (*models.CreditLedgerEntry, error)
+	AddOverpayment(profileID uint, amount int64, paymentID uint) (*models.CreditLedgerEntry, error)
+	GetBalance(profileID uint) (*CreditBalance, error)
+	GetEntries(profileID uint, page int, limit int) ([]*models.CreditLedgerEntry, int64, error)
+}
+
+// creditService implements CreditService
+type creditService struct {
+	creditRepo repository.CreditLedgerRepository
+	logger     *logger.Logger
+}
+
+// NewCreditService creates a new instance of CreditService
+func NewCreditService(creditRepo repository.CreditLedgerRepository, logger *logger.Logger) CreditService {
+	return &creditService{
+		creditRepo: creditRepo,
+		logger:     logger,
+	}
+}
+
+// Deposit adds money a resident paid in advance to their credit balance
+func (s *creditService) Deposit(profileID uint, req *CreditDepositRequest, actorID uint) (*models.CreditLedgerEntry, error) {
+	if _, err := s.creditRepo.GetProfile(profileID); err != nil {
+		return nil, err
+	}
+
+	description := req.Note
+	if description == "" {
+		description = "Advance deposit"
+	}
+
+	entry := &models.CreditLedgerEntry{
+		ProfileID:   profileID,
+		Type:        models.CreditEntryDeposit,
+		Amount:      req.Amount,
+		Description: description,
+	}
+	if actorID != 0 {
+		entry.ActorID = &actorID
+	}
+	if err := s.creditRepo.Create(entry); err != nil {
+		return nil, fmt.Errorf("failed to record deposit: %w", err)
+	}
+
+	s.logger.WithFields(map[string]interface{}{
+		"profile_id": profileID,
+		"amount":     req.Amount,
+		"actor_id":   actorID,
+	}).Info("Credit deposited")
+
+	return entry, nil
+}
+
+// AddOverpayment adds what a payment paid beyond its billings to the resident's credit balance
+func (s *creditService) AddOverpayment(profileID uint, amount int64, paymentID uint) (*models.CreditLedgerEntry, error) {
+	entry := &models.CreditLedgerEntry{
+		ProfileID:   profileID,
+		Type:        models.CreditEntryOverpayment,
+		Amount:      amount,
+		PaymentID:   &paymentID,
+		Description: fmt.Sprintf("Overpayment of payment #%d", paymentID),
+	}
+	if err := s.creditRepo.Create(entry); err != nil {
+		return nil, fmt.Errorf("failed to record overpayment: %w", err)
+	}
+
+	s.logger.WithFields(map[string]interface{}{
+		"profile_id": profileID,
+		"amount":     amount,
+		"payment_id": paymentID,
+	}).Info("Overpayment credited")
+
+	return entry, nil
+}
+
+// GetBalance returns the credit balance of a profile
+func (s *creditService) GetBalance(profileID uint) (*CreditBalance, error) {
+	if _, err := s.creditRepo.GetProfile(profileID); err != nil {
+		return nil, err
+	}
+
+	balance, err := s.creditRepo.GetBalance(profileID)
+	if err != nil {
+		return nil, fmt.Errorf("failed to get credit balance: %w", err)
+	}
+
+	return &CreditBalance{ProfileID: profileID, Balance: balance}, nil
+}
+
+// GetEntries retrieves the credit ledger of a profile, newest first
+func (s *creditService) GetEntries(profileID uint, page int, limit int) ([]*models.CreditLedgerEntry, int64, error) {
+	if _, err := s.creditRepo.GetProfile(profileID); err != nil {
+		return nil, 0, err
+	}
+	return s.creditRepo.GetByProfileID(profileID, page, limit)
+}
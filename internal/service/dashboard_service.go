@@ -9,27 +9,29 @@ import (
 
 // DashboardService interface defines dashboard service methods
 type DashboardService interface {
-	GetDashboardStatistics(rt *int, bulan, tahun *int) (*response.DashboardStatisticsResponse, error)
-	GetBillingList(rt, bulan, tahun *int, page, limit int) ([]*response.BillingListItem, int64, error)
+	GetDashboardStatistics(rt *int, bulan, tahun *int, overdue bool) (*response.DashboardStatisticsResponse, error)
+	GetBillingList(rt, bulan, tahun *int, overdue bool, page, limit int) ([]*response.BillingListItem, int64, error)
 }
 
 // dashboardService implements DashboardService interface
 type dashboardService struct {
 	dashboardRepo repository.DashboardRepository
+	statuses      StatusRegistry
 	logger        *logger.Logger
 }
 
 // NewDashboardService creates a new dashboard service
-func NewDashboardService(dashboardRepo repository.DashboardRepository, logger *logger.Logger) DashboardService {
+func NewDashboardService(dashboardRepo repository.DashboardRepository, statuses StatusRegistry, logger *logger.Logger) DashboardService {
 	return &dashboardService{
 		dashboardRepo: dashboardRepo,
+		statuses:      statuses,
 		logger:        logger,
 	}
 }
 
-// GetDashboardStatistics gets dashboard statistics by RT with optional bulan and tahun filters
-func (s *dashboardService) GetDashboardStatistics(rt *int, bulan, tahun *int) (*response.DashboardStatisticsResponse, error) {
-	statistics, err := s.dashboardRepo.GetDashboardStatistics(rt, bulan, tahun)
+// GetDashboardStatistics gets dashboard statistics by RT with optional bulan, tahun and overdue filters
+func (s *dashboardService) GetDashboardStatistics(rt *int, bulan, tahun *int, overdue bool) (*response.DashboardStatisticsResponse, error) {
+	statistics, err := s.dashboardRepo.GetDashboardStatistics(rt, bulan, tahun, overdue, s.statuses.IDs())
 	if err != nil {
 		s.logger.WithError(err).WithField("rt", rt).Error("Failed to get dashboard statistics")
 		return nil, err
@@ -38,7 +40,9 @@ func (s *dashboardService) GetDashboardStatistics(rt *int, bulan, tahun *int) (*
 	logFields := map[string]interface{}{
 		"rt":          rt,
 		"belum_bayar": statistics.BelumBayar,
+		"terlambat":   statistics.Terlambat,
 		"total":       statistics.Total,
+		"overdue":     overdue,
 	}
 	if bulan != nil {
 		logFields["bulan"] = *bulan
@@ -51,8 +55,8 @@ func (s *dashboardService) GetDashboardStatistics(rt *int, bulan, tahun *int) (*
 	return statistics, nil
 }
 
-// GetBillingList gets billing list with optional RT, bulan, tahun filters and pagination
-func (s *dashboardService) GetBillingList(rt, bulan, tahun *int, page, limit int) ([]*response.BillingListItem, int64, error) {
+// GetBillingList gets billing list with optional RT, bulan, tahun and overdue filters and pagination
+func (s *dashboardService) GetBillingList(rt, bulan, tahun *int, overdue bool, page, limit int) ([]*response.BillingListItem, int64, error) {
 	if page <= 0 {
 		page = 1
 	}
@@ -72,14 +76,15 @@ func (s *dashboardService) GetBillingList(rt, bulan, tahun *int, page, limit int
 		return nil, 0, fmt.Errorf("invalid bulan parameter, must be between 1-12")
 	}
 
-	billings, total, err := s.dashboardRepo.GetBillingList(rt, bulan, tahun, page, limit)
+	billings, total, err := s.dashboardRepo.GetBillingList(rt, bulan, tahun, overdue, s.statuses.IDs(), page, limit)
 	if err != nil {
 		s.logger.WithError(err).WithFields(map[string]interface{}{
-			"rt":    rt,
-			"bulan": bulan,
-			"tahun": tahun,
-			"page":  page,
-			"limit": limit,
+			"rt":      rt,
+			"bulan":   bulan,
+			"tahun":   tahun,
+			"overdue": overdue,
+			"page":    page,
+			"limit":   limit,
 		}).Error("Failed to get billing list")
 		return nil, 0, err
 	}
@@ -90,6 +95,9 @@ func (s *dashboardService) GetBillingList(rt, bulan, tahun *int, page, limit int
 		"total": total,
 		"count": len(billings),
 	}
+	if overdue {
+		logFields["overdue"] = true
+	}
 	if rt != nil {
 		logFields["rt"] = *rt
 	}
@@ -10,6 +10,7 @@ import (
 // MenuService interface defines menu service methods
 type MenuService interface {
 	GetMenusByUserID(userID uint) ([]*models.MasterMenu, error)
+	HasMenuAccess(roleIDs []uint, kodeMenu string) (bool, error)
 }
 
 // menuService implements MenuService interface
@@ -47,3 +48,12 @@ func (s *menuService) GetMenusByUserID(userID uint) ([]*models.MasterMenu, error
 
 	return activeMenus, nil
 }
+
+// HasMenuAccess reports whether the given roles grant access to the menu identified by kodeMenu
+func (s *menuService) HasMenuAccess(roleIDs []uint, kodeMenu string) (bool, error) {
+	if kodeMenu == "" {
+		return false, errors.New("kode_menu is required")
+	}
+
+	return s.menuRepo.HasMenuAccess(roleIDs, kodeMenu)
+}
@@ -0,0 +1,163 @@
+package service
+
+import (
+	"errors"
+	"fmt"
+	"net/http"
+	"strings"
+	"time"
+
+	"ipl-be-svc/internal/models"
+)
+
+var (
+	// ErrUnknownPaymentGateway is returned when no gateway is registered under the requested name
+	ErrUnknownPaymentGateway = errors.New("unknown payment gateway")
+	// ErrGatewayInvoiceNotFound is returned when the gateway does not know the invoice
+	ErrGatewayInvoiceNotFound = errors.New("invoice not found at payment gateway")
+	// ErrVoidNotSupported is returned by gateways that cannot close an open invoice
+	ErrVoidNotSupported = errors.New("voiding invoices is not supported by this payment gateway")
+	// ErrRefundNotSupported is returned by gateways that cannot refund through their API
+	ErrRefundNotSupported = errors.New("refund is not supported by this payment gateway")
+)
+
+// PaymentGateway is implemented by every payment provider the service can issue invoices with
+type PaymentGateway interface {
+	// Name returns the identifier stored on payments and used in webhook URLs
+	Name() string
+	CreateInvoice(req *GatewayInvoiceRequest) (*GatewayInvoice, error)
+	GetInvoiceStatus(invoiceID string) (*GatewayPaymentStatus, error)
+	// VoidInvoice closes an unpaid invoice so it can no longer be paid
+	VoidInvoice(invoiceID string) error
+	// ParseWebhook verifies the delivery and translates it into a gateway-agnostic event
+	ParseWebhook(header http.Header, body []byte) (*GatewayWebhookEvent, error)
+	Refund(req *GatewayRefundRequest) (*GatewayRefund, error)
+}
+
+// GatewayInvoiceRequest describes an invoice to be issued for one or more billings.
+// Reference is the opaque payment reference the gateway must echo back in its webhooks.
+type GatewayInvoiceRequest struct {
+	Reference     string
+	BillingIDs    []uint
+	DocumentIDs   []string
+	Amount        int64
+	AdminFee      int64
+	Description   string
+	CustomerName  string
+	CustomerEmail string
+	CustomerPhone string
+	ExpiredAt     time.Time
+}
+
+// GatewayInvoice is an invoice issued by a payment gateway
+type GatewayInvoice struct {
+	ID            string
+	TransactionID string
+	PaymentURL    string
+	ExpiredAt     *time.Time
+}
+
+// GatewayPaymentStatus is the current state of an invoice at the gateway.
+// Status is one of the models.PaymentStatus* values.
+type GatewayPaymentStatus struct {
+	InvoiceID     string
+	TransactionID string
+	Status        string
+	RawStatus     string
+	Amount        int64
+	PaymentMethod string
+	PaidAt        *time.Time
+}
+
+// GatewayWebhookEvent is a verified webhook delivery. Reference is the payment reference sent
+// with the invoice; BillingIDs is only set for legacy invoices that encoded them in the description.
+type GatewayWebhookEvent struct {
+	Gateway       string
+	Reference     string
+	Event         string
+	InvoiceID     string
+	TransactionID string
+	Status        string
+	RawStatus     string
+	Amount        int64
+	PaymentMethod string
+	BillingIDs    []uint
+}
+
+// GatewayRefundRequest describes a refund of a settled invoice
+type GatewayRefundRequest struct {
+	InvoiceID     string
+	TransactionID string
+	Amount        int64
+	Reason        string
+}
+
+// GatewayRefund is the gateway's answer to a refund request
+type GatewayRefund struct {
+	RefundID string
+	Status   string
+}
+
+// PaymentGatewayRegistry holds the configured gateways and the one new invoices are issued with
+type PaymentGatewayRegistry struct {
+	active   string
+	gateways map[string]PaymentGateway
+}
+
+// NewPaymentGatewayRegistry creates a registry; active must name one of the given gateways
+func NewPaymentGatewayRegistry(active string, gateways ...PaymentGateway) (*PaymentGatewayRegistry, error) {
+	registry := &PaymentGatewayRegistry{
+		active:   active,
+		gateways: make(map[string]PaymentGateway, len(gateways)),
+	}
+	for _, gateway := range gateways {
+		registry.gateways[gateway.Name()] = gateway
+	}
+
+	if _, ok := registry.gateways[active]; !ok {
+		return nil, fmt.Errorf("%w: %s", ErrUnknownPaymentGateway, active)
+	}
+
+	return registry, nil
+}
+
+// Active returns the gateway new invoices are created with
+func (r *PaymentGatewayRegistry) Active() PaymentGateway {
+	return r.gateways[r.active]
+}
+
+// Get returns the gateway registered under name
+func (r *PaymentGatewayRegistry) Get(name string) (PaymentGateway, error) {
+	gateway, ok := r.gateways[name]
+	if !ok {
+		return nil, fmt.Errorf("%w: %s", ErrUnknownPaymentGateway, name)
+	}
+	return gateway, nil
+}
+
+// successPaymentStatuses lists the gateway statuses that mean the payment has settled
+var successPaymentStatuses = map[string]bool{
+	"success":    true,
+	"paid":       true,
+	"settled":    true,
+	"settlement": true,
+}
+
+// normalizePaymentStatus maps raw gateway statuses to a models.PaymentStatus* value.
+// The first status that settles, expires or fails the payment wins; anything else is pending.
+func normalizePaymentStatus(statuses ...string) string {
+	for _, status := range statuses {
+		status = strings.ToLower(strings.TrimSpace(status))
+		switch {
+		case successPaymentStatuses[status]:
+			return models.PaymentStatusPaid
+		case strings.Contains(status, "refund"):
+			return models.PaymentStatusRefunded
+		case strings.Contains(status, "expire"):
+			return models.PaymentStatusExpired
+		case strings.Contains(status, "fail"), strings.Contains(status, "cancel"), strings.Contains(status, "reject"):
+			return models.PaymentStatusFailed
+		}
+	}
+	return models.PaymentStatusPending
+}
@@ -0,0 +1,338 @@
+package service
+
+import (
+	"bytes"
+	"crypto/hmac"
+	"crypto/sha256"
+	"encoding/base64"
+	"encoding/json"
+	"fmt"
+	"io"
+	"net/http"
+	"net/url"
+	"strings"
+	"time"
+
+	"github.com/google/uuid"
+
+	"ipl-be-svc/internal/config"
+	"ipl-be-svc/internal/models"
+	"ipl-be-svc/pkg/logger"
+)
+
+// PaymentGatewayDoku is the gateway name stored on payments created through DOKU Checkout
+const PaymentGatewayDoku = "doku"
+
+const (
+	dokuCheckoutPath = "/checkout/v1/payment"
+	dokuStatusPath   = "/orders/v1/status/"
+	dokuRefundPath   = "/refund/v1/refund"
+	// dokuInvoicePrefix precedes the payment reference in invoice numbers
+	dokuInvoicePrefix = "IPL-"
+	// dokuTimestampLayout is the UTC ISO8601 format DOKU expects in Request-Timestamp
+	dokuTimestampLayout = "2006-01-02T15:04:05Z"
+	// dokuExpiredDateLayout is the format of payment.expired_date in checkout responses
+	dokuExpiredDateLayout = "20060102150405"
+)
+
+// dokuCheckoutRequest represents the DOKU Checkout payment request
+type dokuCheckoutRequest struct {
+	Order struct {
+		Amount        int64          `json:"amount"`
+		InvoiceNumber string         `json:"invoice_number"`
+		LineItems     []dokuLineItem `json:"line_items,omitempty"`
+	} `json:"order"`
+	Payment struct {
+		PaymentDueDate int `json:"payment_due_date"`
+	} `json:"payment"`
+	Customer struct {
+		Name  string `json:"name,omitempty"`
+		Email string `json:"email,omitempty"`
+		Phone string `json:"phone,omitempty"`
+	} `json:"customer"`
+}
+
+// dokuLineItem represents an order line item
+type dokuLineItem struct {
+	Name     string `json:"name"`
+	Price    int64  `json:"price"`
+	Quantity int    `json:"quantity"`
+}
+
+// dokuCheckoutResponse represents the DOKU Checkout payment response
+type dokuCheckoutResponse struct {
+	Message  []string `json:"message"`
+	Response struct {
+		Order struct {
+			InvoiceNumber string `json:"invoice_number"`
+		} `json:"order"`
+		Payment struct {
+			URL         string `json:"url"`
+			TokenID     string `json:"token_id"`
+			ExpiredDate string `json:"expired_date"`
+		} `json:"payment"`
+	} `json:"response"`
+}
+
+// DokuNotification represents the HTTP notification DOKU sends when a payment changes state
+type DokuNotification struct {
+	Order struct {
+		InvoiceNumber string `json:"invoice_number"`
+		Amount        int64  `json:"amount"`
+	} `json:"order"`
+	Transaction struct {
+		Status            string `json:"status"`
+		Date              string `json:"date"`
+		OriginalRequestID string `json:"original_request_id"`
+	} `json:"transaction"`
+	Service struct {
+		ID string `json:"id"`
+	} `json:"service"`
+	Channel struct {
+		ID string `json:"id"`
+	} `json:"channel"`
+}
+
+// dokuRefundRequest represents the DOKU refund request
+type dokuRefundRequest struct {
+	Order struct {
+		InvoiceNumber string `json:"invoice_number"`
+	} `json:"order"`
+	Refund struct {
+		Amount int64  `json:"amount"`
+		Reason string `json:"reason,omitempty"`
+	} `json:"refund"`
+}
+
+// dokuRefundResponse represents the DOKU refund response
+type dokuRefundResponse struct {
+	Refund struct {
+		ID     string `json:"id"`
+		Status string `json:"status"`
+	} `json:"refund"`
+}
+
+// dokuGateway implements PaymentGateway on top of DOKU Checkout
+type dokuGateway struct {
+	config config.DokuConfig
+	client *http.Client
+	logger *logger.Logger
+}
+
+// NewDokuGateway creates a new DOKU PaymentGateway
+func NewDokuGateway(cfg config.DokuConfig, logger *logger.Logger) PaymentGateway {
+	return &dokuGateway{
+		config: cfg,
+		client: &http.Client{Timeout: 30 * time.Second},
+		logger: logger,
+	}
+}
+
+// Name returns the gateway name
+func (d *dokuGateway) Name() string {
+	return PaymentGatewayDoku
+}
+
+// CreateInvoice creates a DOKU Checkout payment page. The invoice number carries the payment
+// reference since DOKU limits it to 64 characters, billings are matched through the stored payment.
+func (d *dokuGateway) CreateInvoice(req *GatewayInvoiceRequest) (*GatewayInvoice, error) {
+	invoiceNumber := dokuInvoicePrefix + req.Reference
+
+	var checkoutReq dokuCheckoutRequest
+	checkoutReq.Order.Amount = req.Amount + req.AdminFee
+	checkoutReq.Order.InvoiceNumber = invoiceNumber
+	checkoutReq.Order.LineItems = []dokuLineItem{{Name: req.Description, Price: req.Amount, Quantity: 1}}
+	if req.AdminFee > 0 {
+		checkoutReq.Order.LineItems = append(checkoutReq.Order.LineItems, dokuLineItem{Name: "Admin Fee", Price: req.AdminFee, Quantity: 1})
+	}
+	checkoutReq.Payment.PaymentDueDate = int(time.Until(req.ExpiredAt).Minutes())
+	checkoutReq.Customer.Name = req.CustomerName
+	checkoutReq.Customer.Email = req.CustomerEmail
+	checkoutReq.Customer.Phone = req.CustomerPhone
+
+	var result dokuCheckoutResponse
+	if err := d.do(http.MethodPost, dokuCheckoutPath, checkoutReq, &result); err != nil {
+		d.logger.WithError(err).Error("Failed to create DOKU checkout payment")
+		return nil, err
+	}
+
+	if result.Response.Payment.URL == "" {
+		return nil, fmt.Errorf("payment link not found in response")
+	}
+
+	invoice := &GatewayInvoice{
+		ID:            invoiceNumber,
+		TransactionID: result.Response.Payment.TokenID,
+		PaymentURL:    result.Response.Payment.URL,
+	}
+	if expiredAt, err := time.ParseInLocation(dokuExpiredDateLayout, result.Response.Payment.ExpiredDate, time.UTC); err == nil {
+		invoice.ExpiredAt = &expiredAt
+	}
+
+	d.logger.WithFields(map[string]interface{}{
+		"invoice_number": invoiceNumber,
+		"billing_ids":    req.BillingIDs,
+		"payment_link":   invoice.PaymentURL,
+	}).Info("DOKU payment link created successfully")
+
+	return invoice, nil
+}
+
+// GetInvoiceStatus queries the DOKU order status API
+func (d *dokuGateway) GetInvoiceStatus(invoiceID string) (*GatewayPaymentStatus, error) {
+	var result DokuNotification
+	if err := d.do(http.MethodGet, dokuStatusPath+url.PathEscape(invoiceID), nil, &result); err != nil {
+		return nil, err
+	}
+
+	status := &GatewayPaymentStatus{
+		InvoiceID:     invoiceID,
+		TransactionID: result.Transaction.OriginalRequestID,
+		Status:        normalizePaymentStatus(result.Transaction.Status),
+		RawStatus:     result.Transaction.Status,
+		Amount:        result.Order.Amount,
+		PaymentMethod: result.Channel.ID,
+	}
+	if status.Status == models.PaymentStatusPaid {
+		if paidAt, err := time.Parse(time.RFC3339, result.Transaction.Date); err == nil {
+			status.PaidAt = &paidAt
+		}
+	}
+
+	return status, nil
+}
+
+// VoidInvoice is not offered for DOKU Checkout, payment pages stay open until payment_due_date
+func (d *dokuGateway) VoidInvoice(invoiceID string) error {
+	return ErrVoidNotSupported
+}
+
+// ParseWebhook verifies the notification signature computed over the request headers and body digest
+func (d *dokuGateway) ParseWebhook(header http.Header, body []byte) (*GatewayWebhookEvent, error) {
+	if header.Get("Client-Id") != d.config.ClientID {
+		return nil, ErrWebhookUnauthorized
+	}
+
+	expected := d.signature(header.Get("Request-Id"), header.Get("Request-Timestamp"), d.config.NotificationPath, body)
+	if !hmac.Equal([]byte(header.Get("Signature")), []byte(expected)) {
+		return nil, ErrWebhookUnauthorized
+	}
+
+	var notification DokuNotification
+	if err := json.Unmarshal(body, &notification); err != nil {
+		return nil, fmt.Errorf("%w: %v", ErrWebhookInvalidPayload, err)
+	}
+
+	if notification.Order.InvoiceNumber == "" {
+		return nil, fmt.Errorf("%w: missing invoice number", ErrWebhookInvalidPayload)
+	}
+
+	d.logger.WithFields(map[string]interface{}{
+		"invoice_number": notification.Order.InvoiceNumber,
+		"status":         notification.Transaction.Status,
+		"amount":         notification.Order.Amount,
+	}).Info("Received DOKU payment notification")
+
+	return &GatewayWebhookEvent{
+		Gateway:       PaymentGatewayDoku,
+		Event:         "payment." + strings.ToLower(notification.Transaction.Status),
+		Reference:     strings.TrimPrefix(notification.Order.InvoiceNumber, dokuInvoicePrefix),
+		InvoiceID:     notification.Order.InvoiceNumber,
+		TransactionID: notification.Transaction.OriginalRequestID,
+		Status:        normalizePaymentStatus(notification.Transaction.Status),
+		RawStatus:     notification.Transaction.Status,
+		Amount:        notification.Order.Amount,
+		PaymentMethod: notification.Channel.ID,
+	}, nil
+}
+
+// Refund requests a (partial) refund of a settled checkout payment
+func (d *dokuGateway) Refund(req *GatewayRefundRequest) (*GatewayRefund, error) {
+	var refundReq dokuRefundRequest
+	refundReq.Order.InvoiceNumber = req.InvoiceID
+	refundReq.Refund.Amount = req.Amount
+	refundReq.Refund.Reason = req.Reason
+
+	var result dokuRefundResponse
+	if err := d.do(http.MethodPost, dokuRefundPath, refundReq, &result); err != nil {
+		return nil, err
+	}
+
+	return &GatewayRefund{
+		RefundID: result.Refund.ID,
+		Status:   result.Refund.Status,
+	}, nil
+}
+
+// signature computes the DOKU "HMACSHA256=" signature. GET requests carry no body and no Digest component.
+func (d *dokuGateway) signature(requestID string, timestamp string, target string, body []byte) string {
+	component := fmt.Sprintf("Client-Id:%s\nRequest-Id:%s\nRequest-Timestamp:%s\nRequest-Target:%s", d.config.ClientID, requestID, timestamp, target)
+	if len(body) > 0 {
+		digest := sha256.Sum256(body)
+		component += "\nDigest:" + base64.StdEncoding.EncodeToString(digest[:])
+	}
+
+	mac := hmac.New(sha256.New, []byte(d.config.SecretKey))
+	mac.Write([]byte(component))
+	return "HMACSHA256=" + base64.StdEncoding.EncodeToString(mac.Sum(nil))
+}
+
+// do sends a signed request to the DOKU API and decodes the JSON response into out
+func (d *dokuGateway) do(method string, path string, payload interface{}, out interface{}) error {
+	var bodyJSON []byte
+	if payload != nil {
+		var err error
+		bodyJSON, err = json.Marshal(payload)
+		if err != nil {
+			return fmt.Errorf("failed to marshal request: %w", err)
+		}
+	}
+
+	httpReq, err := http.NewRequest(method, d.config.BaseURL+path, bytes.NewReader(bodyJSON))
+	if err != nil {
+		return fmt.Errorf("failed to create request: %w", err)
+	}
+
+	requestID := uuid.NewString()
+	timestamp := time.Now().UTC().Format(dokuTimestampLayout)
+	httpReq.Header.Set("Client-Id", d.config.ClientID)
+	httpReq.Header.Set("Request-Id", requestID)
+	httpReq.Header.Set("Request-Timestamp", timestamp)
+	httpReq.Header.Set("Signature", d.signature(requestID, timestamp, path, bodyJSON))
+	httpReq.Header.Set("Content-Type", "application/json")
+
+	d.logger.WithFields(map[string]interface{}{
+		"method": method,
+		"path":   path,
+		"body":   string(bodyJSON),
+	}).Info("Sending request to DOKU API")
+
+	resp, err := d.client.Do(httpReq)
+	if err != nil {
+		return fmt.Errorf("failed to send request: %w", err)
+	}
+	defer resp.Body.Close()
+
+	body, err := io.ReadAll(resp.Body)
+	if err != nil {
+		return fmt.Errorf("failed to read response: %w", err)
+	}
+
+	d.logger.WithFields(map[string]interface{}{
+		"status_code": resp.StatusCode,
+		"response":    string(body),
+	}).Info("DOKU API Response")
+
+	if resp.StatusCode == http.StatusNotFound {
+		return ErrGatewayInvoiceNotFound
+	}
+	if resp.StatusCode != http.StatusOK {
+		return fmt.Errorf("DOKU API returned status %d: %s", resp.StatusCode, string(body))
+	}
+
+	if err := json.Unmarshal(body, out); err != nil {
+		return fmt.Errorf("failed to parse response: %w", err)
+	}
+
+	return nil
+}
@@ -0,0 +1,309 @@
+package service
+
+import (
+	"bytes"
+	"crypto/hmac"
+	"crypto/sha256"
+	"encoding/hex"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"net/http"
+	"strings"
+	"sync"
+	"time"
+
+	"ipl-be-svc/internal/config"
+	"ipl-be-svc/internal/models"
+	"ipl-be-svc/pkg/logger"
+)
+
+// PaymentGatewayFake is the gateway name of the offline fake gateway used in development and tests
+const PaymentGatewayFake = "fake"
+
+const (
+	// FakeSignatureHeader carries a hex HMAC-SHA256 of the raw body keyed with the fake gateway secret
+	FakeSignatureHeader = "X-Fake-Signature"
+	// FakeGatewayWebhookPath is where the fake gateway delivers its webhooks
+	FakeGatewayWebhookPath = "/api/v1/payments/webhook/" + PaymentGatewayFake
+	// FakeGatewayPayPath is the prefix of the pay pages served for fake invoices
+	FakeGatewayPayPath = "/fake-gateway/pay/"
+)
+
+// Fake pay page actions
+const (
+	FakePayActionPay    = "pay"
+	FakePayActionFail   = "fail"
+	FakePayActionExpire = "expire"
+)
+
+// ErrFakeInvoiceNotPending is returned when a fake invoice has already been completed
+var ErrFakeInvoiceNotPending = errors.New("invoice is no longer pending")
+
+// FakeInvoice is an invoice held in memory by the fake gateway
+type FakeInvoice struct {
+	ID            string
+	Reference     string
+	TransactionID string
+	BillingIDs    []uint
+	Description   string
+	Amount        int64
+	AdminFee      int64
+	Status        string
+	PaymentMethod string
+	CustomerName  string
+	ExpiredAt     time.Time
+	PaidAt        *time.Time
+}
+
+// Total returns the amount the customer is asked to pay
+func (i *FakeInvoice) Total() int64 {
+	return i.Amount + i.AdminFee
+}
+
+// FakeWebhookPayload is the body the fake gateway posts back to the service
+type FakeWebhookPayload struct {
+	Event         string `json:"event"`
+	Reference     string `json:"reference"`
+	InvoiceID     string `json:"invoice_id"`
+	TransactionID string `json:"transaction_id"`
+	Status        string `json:"status"`
+	Amount        int64  `json:"amount"`
+	PaymentMethod string `json:"payment_method"`
+}
+
+// FakePaymentGateway is an in-memory PaymentGateway that serves its own pay page and
+// fires signed webhooks back at the service, so payment flows run without network access
+type FakePaymentGateway struct {
+	config   config.FakeGatewayConfig
+	client   *http.Client
+	logger   *logger.Logger
+	mu       sync.Mutex
+	invoices map[string]*FakeInvoice
+	sequence int
+}
+
+// NewFakePaymentGateway creates a new fake PaymentGateway
+func NewFakePaymentGateway(cfg config.FakeGatewayConfig, logger *logger.Logger) *FakePaymentGateway {
+	return &FakePaymentGateway{
+		config:   cfg,
+		client:   &http.Client{Timeout: 10 * time.Second},
+		logger:   logger,
+		invoices: make(map[string]*FakeInvoice),
+	}
+}
+
+// Name returns the gateway name
+func (f *FakePaymentGateway) Name() string {
+	return PaymentGatewayFake
+}
+
+// CreateInvoice stores the invoice in memory and links it to the local pay page
+func (f *FakePaymentGateway) CreateInvoice(req *GatewayInvoiceRequest) (*GatewayInvoice, error) {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+
+	f.sequence++
+	invoice := &FakeInvoice{
+		ID:            fmt.Sprintf("fake-inv-%d-%d", time.Now().Unix(), f.sequence),
+		TransactionID: fmt.Sprintf("fake-trx-%d-%d", time.Now().Unix(), f.sequence),
+		Reference:     req.Reference,
+		BillingIDs:    req.BillingIDs,
+		Description:   req.Description,
+		Amount:        req.Amount,
+		AdminFee:      req.AdminFee,
+		Status:        models.PaymentStatusPending,
+		CustomerName:  req.CustomerName,
+		ExpiredAt:     req.ExpiredAt,
+	}
+	f.invoices[invoice.ID] = invoice
+
+	return &GatewayInvoice{
+		ID:            invoice.ID,
+		TransactionID: invoice.TransactionID,
+		PaymentURL:    strings.TrimSuffix(f.config.BaseURL, "/") + FakeGatewayPayPath + invoice.ID,
+		ExpiredAt:     &invoice.ExpiredAt,
+	}, nil
+}
+
+// GetInvoiceStatus returns the in-memory state of the invoice
+func (f *FakePaymentGateway) GetInvoiceStatus(invoiceID string) (*GatewayPaymentStatus, error) {
+	invoice, err := f.GetInvoice(invoiceID)
+	if err != nil {
+		return nil, err
+	}
+
+	return &GatewayPaymentStatus{
+		InvoiceID:     invoice.ID,
+		TransactionID: invoice.TransactionID,
+		Status:        invoice.Status,
+		RawStatus:     invoice.Status,
+		Amount:        invoice.Total(),
+		PaymentMethod: invoice.PaymentMethod,
+		PaidAt:        invoice.PaidAt,
+	}, nil
+}
+
+// VoidInvoice closes a pending invoice so its pay page no longer accepts payment
+func (f *FakePaymentGateway) VoidInvoice(invoiceID string) error {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+
+	invoice, ok := f.invoices[invoiceID]
+	if !ok {
+		return ErrGatewayInvoiceNotFound
+	}
+	if invoice.Status != models.PaymentStatusPending {
+		return ErrFakeInvoiceNotPending
+	}
+
+	invoice.Status = models.PaymentStatusVoided
+	return nil
+}
+
+// ParseWebhook verifies the body signature of a delivery made by Complete
+func (f *FakePaymentGateway) ParseWebhook(header http.Header, body []byte) (*GatewayWebhookEvent, error) {
+	if !hmac.Equal([]byte(strings.ToLower(header.Get(FakeSignatureHeader))), []byte(f.sign(body))) {
+		return nil, ErrWebhookUnauthorized
+	}
+
+	var payload FakeWebhookPayload
+	if err := json.Unmarshal(body, &payload); err != nil {
+		return nil, fmt.Errorf("%w: %v", ErrWebhookInvalidPayload, err)
+	}
+	if payload.InvoiceID == "" {
+		return nil, fmt.Errorf("%w: missing invoice ID", ErrWebhookInvalidPayload)
+	}
+
+	return &GatewayWebhookEvent{
+		Gateway:       PaymentGatewayFake,
+		Event:         payload.Event,
+		Reference:     payload.Reference,
+		InvoiceID:     payload.InvoiceID,
+		TransactionID: payload.TransactionID,
+		Status:        payload.Status,
+		RawStatus:     payload.Status,
+		Amount:        payload.Amount,
+		PaymentMethod: payload.PaymentMethod,
+	}, nil
+}
+
+// Refund marks a paid invoice as refunded
+func (f *FakePaymentGateway) Refund(req *GatewayRefundRequest) (*GatewayRefund, error) {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+
+	invoice, ok := f.invoices[req.InvoiceID]
+	if !ok {
+		return nil, ErrGatewayInvoiceNotFound
+	}
+	if invoice.Status != models.PaymentStatusPaid {
+		return nil, fmt.Errorf("invoice %s is %s, only paid invoices can be refunded", invoice.ID, invoice.Status)
+	}
+
+	invoice.Status = models.PaymentStatusRefunded
+	return &GatewayRefund{
+		RefundID: "fake-refund-" + invoice.ID,
+		Status:   models.PaymentStatusRefunded,
+	}, nil
+}
+
+// GetInvoice returns a copy of the in-memory invoice
+func (f *FakePaymentGateway) GetInvoice(invoiceID string) (*FakeInvoice, error) {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+
+	invoice, ok := f.invoices[invoiceID]
+	if !ok {
+		return nil, ErrGatewayInvoiceNotFound
+	}
+	copied := *invoice
+	return &copied, nil
+}
+
+// Complete settles, fails or expires a pending invoice and delivers the matching webhook
+func (f *FakePaymentGateway) Complete(invoiceID string, action string) (*FakeInvoice, error) {
+	f.mu.Lock()
+	invoice, ok := f.invoices[invoiceID]
+	if !ok {
+		f.mu.Unlock()
+		return nil, ErrGatewayInvoiceNotFound
+	}
+	if invoice.Status != models.PaymentStatusPending {
+		f.mu.Unlock()
+		return nil, ErrFakeInvoiceNotPending
+	}
+
+	switch action {
+	case FakePayActionPay:
+		now := time.Now()
+		invoice.Status = models.PaymentStatusPaid
+		invoice.PaymentMethod = "FAKE"
+		invoice.PaidAt = &now
+	case FakePayActionFail:
+		invoice.Status = models.PaymentStatusFailed
+	case FakePayActionExpire:
+		invoice.Status = models.PaymentStatusExpired
+	default:
+		f.mu.Unlock()
+		return nil, fmt.Errorf("unknown action %q", action)
+	}
+	copied := *invoice
+	f.mu.Unlock()
+
+	payload := FakeWebhookPayload{
+		Event:         "payment." + copied.Status,
+		Reference:     copied.Reference,
+		InvoiceID:     copied.ID,
+		TransactionID: copied.TransactionID,
+		Status:        copied.Status,
+		Amount:        copied.Total(),
+		PaymentMethod: copied.PaymentMethod,
+	}
+	if err := f.sendWebhook(&payload); err != nil {
+		return &copied, err
+	}
+
+	return &copied, nil
+}
+
+// sendWebhook posts the signed payload to the service's fake gateway webhook endpoint
+func (f *FakePaymentGateway) sendWebhook(payload *FakeWebhookPayload) error {
+	body, err := json.Marshal(payload)
+	if err != nil {
+		return fmt.Errorf("failed to marshal webhook: %w", err)
+	}
+
+	endpoint := strings.TrimSuffix(f.config.BaseURL, "/") + FakeGatewayWebhookPath
+	req, err := http.NewRequest(http.MethodPost, endpoint, bytes.NewReader(body))
+	if err != nil {
+		return fmt.Errorf("failed to create webhook request: %w", err)
+	}
+	req.Header.Set("Content-Type", "application/json")
+	req.Header.Set(FakeSignatureHeader, f.sign(body))
+
+	resp, err := f.client.Do(req)
+	if err != nil {
+		return fmt.Errorf("failed to deliver webhook: %w", err)
+	}
+	defer resp.Body.Close()
+
+	f.logger.WithFields(map[string]interface{}{
+		"invoice_id":  payload.InvoiceID,
+		"status":      payload.Status,
+		"status_code": resp.StatusCode,
+	}).Info("Fake gateway webhook delivered")
+
+	if resp.StatusCode != http.StatusOK {
+		return fmt.Errorf("webhook endpoint returned status %d", resp.StatusCode)
+	}
+
+	return nil
+}
+
+// sign returns the hex HMAC-SHA256 of body keyed with the fake gateway secret
+func (f *FakePaymentGateway) sign(body []byte) string {
+	mac := hmac.New(sha256.New, []byte(f.config.Secret))
+	mac.Write(body)
+	return hex.EncodeToString(mac.Sum(nil))
+}
@@ -0,0 +1,418 @@
+package service
+
+import (
+	"bytes"
+	"crypto/hmac"
+	"crypto/sha256"
+	"crypto/subtle"
+	"encoding/hex"
+	"encoding/json"
+	"fmt"
+	"io"
+	"net/http"
+	"net/url"
+	"regexp"
+	"strconv"
+	"strings"
+	"time"
+
+	"ipl-be-svc/internal/config"
+	"ipl-be-svc/internal/models"
+	"ipl-be-svc/pkg/logger"
+)
+
+// PaymentGatewayMayar is the gateway name stored on payments created through Mayar
+const PaymentGatewayMayar = "mayar"
+
+const (
+	// MayarCallbackTokenHeader carries the webhook token configured in the Mayar dashboard
+	MayarCallbackTokenHeader = "X-Callback-Token"
+	// MayarSignatureHeader carries a hex HMAC-SHA256 of the raw body keyed with the webhook token
+	MayarSignatureHeader = "X-Mayar-Signature"
+	// MayarTimestampHeader carries the time a webhook delivery was sent, when Mayar sends it
+	MayarTimestampHeader = "X-Mayar-Timestamp"
+)
+
+// MayarItem represents an item in the invoice
+type MayarItem struct {
+	Quantity    int    `json:"quantity"`
+	Rate        int64  `json:"rate"`
+	Description string `json:"description"`
+}
+
+// MayarCreateInvoiceRequest represents the Mayar invoice creation request
+type MayarCreateInvoiceRequest struct {
+	Name        string      `json:"name"`
+	Email       string      `json:"email"`
+	Mobile      string      `json:"mobile"`
+	RedirectURL string      `json:"redirectUrl"`
+	Description string      `json:"description"`
+	ExpiredAt   string      `json:"expiredAt"`
+	Items       []MayarItem `json:"items"`
+}
+
+// MayarCreateInvoiceResponse represents the Mayar API response
+type MayarCreateInvoiceResponse struct {
+	StatusCode int    `json:"statusCode"`
+	Messages   string `json:"messages"`
+	Data       struct {
+		ID            string `json:"id"`
+		TransactionID string `json:"transactionId"`
+		Link          string `json:"link"`
+		ExpiredAt     int64  `json:"expiredAt"`
+	} `json:"data"`
+}
+
+// MayarInvoiceDetailResponse represents the Mayar invoice detail response
+type MayarInvoiceDetailResponse struct {
+	StatusCode int    `json:"statusCode"`
+	Messages   string `json:"messages"`
+	Data       struct {
+		ID            string `json:"id"`
+		TransactionID string `json:"transactionId"`
+		Status        string `json:"status"`
+		Amount        int64  `json:"amount"`
+		PaymentMethod string `json:"paymentMethod"`
+		UpdatedAt     int64  `json:"updatedAt"`
+	} `json:"data"`
+}
+
+// MayarWebhookRequest represents the payload sent by Mayar payment gateway webhooks
+type MayarWebhookRequest struct {
+	Event string `json:"event" example:"payment.received"`
+	Data  struct {
+		ID                          string      `json:"id"`
+		TransactionID               string      `json:"transactionId"`
+		Status                      string      `json:"status"`
+		TransactionStatus           string      `json:"transactionStatus"`
+		CreatedAt                   string      `json:"createdAt"`
+		UpdatedAt                   string      `json:"updatedAt"`
+		MerchantID                  string      `json:"merchantId"`
+		MerchantName                string      `json:"merchantName"`
+		MerchantEmail               string      `json:"merchantEmail"`
+		CustomerID                  string      `json:"customerId"`
+		CustomerName                string      `json:"customerName"`
+		CustomerEmail               string      `json:"customerEmail"`
+		CustomerMobile              string      `json:"customerMobile"`
+		Amount                      int64       `json:"amount"`
+		PaymentLinkAmount           int64       `json:"paymentLinkAmount"`
+		IsAdminFeeBorneByCustomer   interface{} `json:"isAdminFeeBorneByCustomer"`
+		IsChannelFeeBorneByCustomer interface{} `json:"isChannelFeeBorneByCustomer"`
+		ProductID                   string      `json:"productId"`
+		ProductName                 string      `json:"productName"`
+		ProductDescription          string      `json:"productDescription" example:"Pembayaran IPL (Ref: 0b5c3a8e-6f0d-4d5b-9a51-2f6f1c2d9e11)"`
+		ProductType                 string      `json:"productType"`
+		PixelFbp                    interface{} `json:"pixelFbp"`
+		PixelFbc                    interface{} `json:"pixelFbc"`
+		Qty                         int         `json:"qty"`
+		CouponUsed                  interface{} `json:"couponUsed"`
+		PaymentMethod               string      `json:"paymentMethod"`
+		NettAmount                  int64       `json:"nettAmount"`
+	} `json:"data"`
+}
+
+// mayarGateway implements PaymentGateway on top of the Mayar headless API
+type mayarGateway struct {
+	config    config.MayarConfig
+	tolerance time.Duration
+	client    *http.Client
+	logger    *logger.Logger
+}
+
+// NewMayarGateway creates a new Mayar PaymentGateway
+func NewMayarGateway(cfg config.MayarConfig, logger *logger.Logger) PaymentGateway {
+	if cfg.BaseURL == "" {
+		cfg.BaseURL = "https://api.mayar.id/hl/v1"
+	}
+
+	return &mayarGateway{
+		config:    cfg,
+		tolerance: time.Duration(cfg.WebhookToleranceSeconds) * time.Second,
+		client:    &http.Client{Timeout: 30 * time.Second},
+		logger:    logger,
+	}
+}
+
+// Name returns the gateway name
+func (m *mayarGateway) Name() string {
+	return PaymentGatewayMayar
+}
+
+// CreateInvoice creates a Mayar invoice with the billing amount and an admin fee item
+func (m *mayarGateway) CreateInvoice(req *GatewayInvoiceRequest) (*GatewayInvoice, error) {
+	if m.config.AuthKey == "" {
+		return nil, fmt.Errorf("Mayar auth key not configured")
+	}
+
+	// Format product description: "<description> (Ref: <payment reference>)"
+	// Mayar echoes the description back as the webhook's productDescription, the reference resolves the payment
+	productDescription := fmt.Sprintf("%s (Ref: %s)", req.Description, req.Reference)
+
+	items := []MayarItem{
+		{
+			Quantity:    1,
+			Rate:        req.Amount,
+			Description: req.Description,
+		},
+	}
+	if req.AdminFee > 0 {
+		items = append(items, MayarItem{
+			Quantity:    1,
+			Rate:        req.AdminFee,
+			Description: "Admin Fee",
+		})
+	}
+
+	invoiceReq := &MayarCreateInvoiceRequest{
+		Name:        req.CustomerName,
+		Email:       req.CustomerEmail,
+		Mobile:      req.CustomerPhone,
+		RedirectURL: "https://web.mayar.id",
+		Description: productDescription, // This will be in webhook's productDescription field
+		ExpiredAt:   req.ExpiredAt.Format(time.RFC3339),
+		Items:       items,
+	}
+
+	var result MayarCreateInvoiceResponse
+	if err := m.do(http.MethodPost, "/invoice/create", invoiceReq, &result); err != nil {
+		m.logger.WithError(err).Error("Failed to create Mayar invoice")
+		return nil, err
+	}
+
+	if result.Data.Link == "" {
+		m.logger.Error("Payment link not found in response")
+		return nil, fmt.Errorf("payment link not found in response")
+	}
+
+	m.logger.WithFields(map[string]interface{}{
+		"amount":              req.Amount,
+		"billing_ids":         joinUintIDs(req.BillingIDs),
+		"reference":           req.Reference,
+		"product_description": productDescription,
+		"payment_link":        result.Data.Link,
+		"invoice_id":          result.Data.ID,
+		"transaction_id":      result.Data.TransactionID,
+	}).Info("Mayar payment link created successfully")
+
+	return &GatewayInvoice{
+		ID:            result.Data.ID,
+		TransactionID: result.Data.TransactionID,
+		PaymentURL:    result.Data.Link,
+		ExpiredAt:     unixTimePtr(result.Data.ExpiredAt),
+	}, nil
+}
+
+// GetInvoiceStatus fetches the invoice detail from Mayar
+func (m *mayarGateway) GetInvoiceStatus(invoiceID string) (*GatewayPaymentStatus, error) {
+	var result MayarInvoiceDetailResponse
+	if err := m.do(http.MethodGet, "/invoice/"+url.PathEscape(invoiceID), nil, &result); err != nil {
+		return nil, err
+	}
+
+	status := &GatewayPaymentStatus{
+		InvoiceID:     result.Data.ID,
+		TransactionID: result.Data.TransactionID,
+		Status:        normalizePaymentStatus(result.Data.Status),
+		RawStatus:     result.Data.Status,
+		Amount:        result.Data.Amount,
+		PaymentMethod: result.Data.PaymentMethod,
+	}
+	if status.Status == models.PaymentStatusPaid {
+		status.PaidAt = unixTimePtr(result.Data.UpdatedAt)
+	}
+
+	return status, nil
+}
+
+// VoidInvoice closes an unpaid Mayar invoice
+func (m *mayarGateway) VoidInvoice(invoiceID string) error {
+	var result struct {
+		StatusCode int    `json:"statusCode"`
+		Messages   string `json:"messages"`
+	}
+	return m.do(http.MethodGet, "/invoice/close/"+url.PathEscape(invoiceID), nil, &result)
+}
+
+// ParseWebhook verifies the callback token or signature and the delivery timestamp
+func (m *mayarGateway) ParseWebhook(header http.Header, body []byte) (*GatewayWebhookEvent, error) {
+	if err := m.verifyRequest(header, body); err != nil {
+		return nil, err
+	}
+
+	var req MayarWebhookRequest
+	if err := json.Unmarshal(body, &req); err != nil {
+		return nil, fmt.Errorf("%w: %v", ErrWebhookInvalidPayload, err)
+	}
+
+	// The payload's own times are when the invoice changed, a late retry is still valid. Replays are
+	// de-duplicated by the recorded webhook events.
+	if err := checkWebhookTimestamp(m.tolerance, header.Get(MayarTimestampHeader)); err != nil {
+		return nil, err
+	}
+
+	m.logger.WithFields(map[string]interface{}{
+		"event":               req.Event,
+		"transaction_id":      req.Data.TransactionID,
+		"status":              req.Data.Status,
+		"amount":              req.Data.Amount,
+		"product_description": req.Data.ProductDescription,
+	}).Info("Received Mayar payment webhook")
+
+	if req.Data.ID == "" && req.Data.TransactionID == "" {
+		return nil, fmt.Errorf("%w: missing transaction ID", ErrWebhookInvalidPayload)
+	}
+
+	// Invoices created before payment references existed can only be matched through their billing IDs
+	reference := parseReferenceFromDescription(req.Data.ProductDescription)
+	var billingIDs []uint
+	if reference == "" {
+		billingIDs, _ = parseBillingIDsFromDescription(req.Data.ProductDescription)
+	}
+
+	return &GatewayWebhookEvent{
+		Gateway:       PaymentGatewayMayar,
+		Event:         req.Event,
+		Reference:     reference,
+		InvoiceID:     req.Data.ID,
+		TransactionID: req.Data.TransactionID,
+		Status:        normalizePaymentStatus(req.Data.Status, req.Data.TransactionStatus),
+		RawStatus:     req.Data.Status,
+		Amount:        req.Data.Amount,
+		PaymentMethod: req.Data.PaymentMethod,
+		BillingIDs:    billingIDs,
+	}, nil
+}
+
+// Refund is not offered by the Mayar headless API, refunds are made from the Mayar dashboard
+func (m *mayarGateway) Refund(req *GatewayRefundRequest) (*GatewayRefund, error) {
+	return nil, ErrRefundNotSupported
+}
+
+// verifyRequest accepts either a matching callback token header or a valid HMAC signature of the body
+func (m *mayarGateway) verifyRequest(header http.Header, body []byte) error {
+	if m.config.WebhookToken == "" {
+		m.logger.Error("Mayar webhook token is not configured, rejecting webhook")
+		return ErrWebhookUnauthorized
+	}
+
+	if signature := strings.TrimSpace(header.Get(MayarSignatureHeader)); signature != "" {
+		mac := hmac.New(sha256.New, []byte(m.config.WebhookToken))
+		mac.Write(body)
+		expected := hex.EncodeToString(mac.Sum(nil))
+		if hmac.Equal([]byte(strings.ToLower(signature)), []byte(expected)) {
+			return nil
+		}
+		return ErrWebhookUnauthorized
+	}
+
+	token := strings.TrimSpace(header.Get(MayarCallbackTokenHeader))
+	if token != "" && subtle.ConstantTimeCompare([]byte(token), []byte(m.config.WebhookToken)) == 1 {
+		return nil
+	}
+
+	return ErrWebhookUnauthorized
+}
+
+// do sends an authenticated request to the Mayar API and decodes the JSON response into out
+func (m *mayarGateway) do(method string, path string, payload interface{}, out interface{}) error {
+	endpoint := m.config.BaseURL + path
+
+	var reqBody io.Reader
+	var bodyJSON []byte
+	if payload != nil {
+		var err error
+		bodyJSON, err = json.Marshal(payload)
+		if err != nil {
+			return fmt.Errorf("failed to marshal request: %w", err)
+		}
+		reqBody = bytes.NewBuffer(bodyJSON)
+	}
+
+	httpReq, err := http.NewRequest(method, endpoint, reqBody)
+	if err != nil {
+		return fmt.Errorf("failed to create request: %w", err)
+	}
+	httpReq.Header.Set("Authorization", fmt.Sprintf("Bearer %s", m.config.AuthKey))
+	httpReq.Header.Set("Content-Type", "application/json")
+
+	m.logger.WithFields(map[string]interface{}{
+		"method": method,
+		"url":    endpoint,
+		"body":   string(bodyJSON),
+	}).Info("📡 Sending request to Mayar API...")
+
+	resp, err := m.client.Do(httpReq)
+	if err != nil {
+		return fmt.Errorf("failed to send request: %w", err)
+	}
+	defer resp.Body.Close()
+
+	body, err := io.ReadAll(resp.Body)
+	if err != nil {
+		return fmt.Errorf("failed to read response: %w", err)
+	}
+
+	m.logger.WithFields(map[string]interface{}{
+		"status_code": resp.StatusCode,
+		"response":    string(body),
+	}).Info("Mayar API Response")
+
+	if resp.StatusCode == http.StatusNotFound {
+		return ErrGatewayInvoiceNotFound
+	}
+	if resp.StatusCode != http.StatusOK {
+		return fmt.Errorf("Mayar API returned status %d: %s", resp.StatusCode, string(body))
+	}
+
+	if err := json.Unmarshal(body, out); err != nil {
+		return fmt.Errorf("failed to parse response: %w", err)
+	}
+
+	return nil
+}
+
+// mayarReferencePattern matches the payment reference appended to invoice descriptions
+var mayarReferencePattern = regexp.MustCompile(`\(Ref: ([0-9a-fA-F-]{36})\)`)
+
+// parseReferenceFromDescription extracts the payment reference from the product description,
+// returning an empty string for legacy descriptions
+func parseReferenceFromDescription(productDescription string) string {
+	match := mayarReferencePattern.FindStringSubmatch(productDescription)
+	if match == nil {
+		return ""
+	}
+	return strings.ToLower(match[1])
+}
+
+// parseBillingIDsFromDescription extracts billing IDs from the legacy product description.
+// Format: "1372,67 (DocumentID: ...)" or "1372 (DocumentID: ...)"
+func parseBillingIDsFromDescription(productDescription string) ([]uint, error) {
+	productDesc := strings.TrimSpace(productDescription)
+	if productDesc == "" {
+		return nil, fmt.Errorf("%w: empty product description", ErrWebhookInvalidPayload)
+	}
+
+	// Take the part before the first space, then split by comma
+	parts := strings.Split(productDesc, " ")
+	idStrings := strings.Split(parts[0], ",")
+
+	var billingIDs []uint
+	for _, idStr := range idStrings {
+		idStr = strings.TrimSpace(idStr)
+		if idStr == "" {
+			continue
+		}
+
+		id, err := strconv.ParseUint(idStr, 10, 32)
+		if err != nil {
+			return nil, fmt.Errorf("%w: invalid billing ID format: %s", ErrWebhookInvalidPayload, idStr)
+		}
+		billingIDs = append(billingIDs, uint(id))
+	}
+
+	if len(billingIDs) == 0 {
+		return nil, fmt.Errorf("%w: no valid billing IDs found", ErrWebhookInvalidPayload)
+	}
+
+	return billingIDs, nil
+}
@@ -0,0 +1,208 @@
+package service
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"sync"
+	"time"
+
+	"ipl-be-svc/internal/config"
+	"ipl-be-svc/internal/models"
+	"ipl-be-svc/internal/repository"
+	"ipl-be-svc/pkg/logger"
+)
+
+// ErrReconciliationRunning is returned when a reconciliation run is started while another is in progress
+var ErrReconciliationRunning = errors.New("payment reconciliation already running")
+
+// PaymentReconciliationService defines the interface for reconciling pending invoices with their gateways
+type PaymentReconciliationService interface {
+	Start(ctx context.Context)
+	Reconcile() (*models.PaymentReconciliationRun, error)
+	ListRuns(page int, limit int) ([]*models.PaymentReconciliationRun, int64, error)
+	GetRun(id uint) (*models.PaymentReconciliationRun, error)
+}
+
+// paymentReconciliationService implements PaymentReconciliationService
+type paymentReconciliationService struct {
+	reconciliationRepo repository.PaymentReconciliationRepository
+	paymentRepo        repository.PaymentRepository
+	webhookService     PaymentWebhookService
+	gateways           *PaymentGatewayRegistry
+	config             config.ReconcilerConfig
+	logger             *logger.Logger
+	running            sync.Mutex
+}
+
+// NewPaymentReconciliationService creates a new instance of PaymentReconciliationService
+func NewPaymentReconciliationService(
+	reconciliationRepo repository.PaymentReconciliationRepository,
+	paymentRepo repository.PaymentRepository,
+	webhookService PaymentWebhookService,
+	gateways *PaymentGatewayRegistry,
+	cfg config.ReconcilerConfig,
+	logger *logger.Logger,
+) PaymentReconciliationService {
+	return &paymentReconciliationService{
+		reconciliationRepo: reconciliationRepo,
+		paymentRepo:        paymentRepo,
+		webhookService:     webhookService,
+		gateways:           gateways,
+		config:             cfg,
+		logger:             logger,
+	}
+}
+
+// Start runs Reconcile every configured interval until ctx is cancelled
+func (s *paymentReconciliationService) Start(ctx context.Context) {
+	interval := time.Duration(s.config.IntervalMinutes) * time.Minute
+	if interval <= 0 {
+		s.logger.Warn("Payment reconciler interval is not positive, reconciler not started")
+		return
+	}
+
+	s.logger.WithField("interval", interval.String()).Info("Payment reconciler started")
+
+	go func() {
+		ticker := time.NewTicker(interval)
+		defer ticker.Stop()
+
+		for {
+			select {
+			case <-ctx.Done():
+				s.logger.Info("Payment reconciler stopped")
+				return
+			case <-ticker.C:
+				if _, err := s.Reconcile(); err != nil {
+					s.logger.WithError(err).Error("Payment reconciliation run failed")
+				}
+			}
+		}
+	}()
+}
+
+// Reconcile queries the gateway of every pending invoice and applies paid, expired or failed
+// outcomes through the webhook confirmation path, recording what changed
+func (s *paymentReconciliationService) Reconcile() (*models.PaymentReconciliationRun, error) {
+	if !s.running.TryLock() {
+		return nil, ErrReconciliationRunning
+	}
+	defer s.running.Unlock()
+
+	run := &models.PaymentReconciliationRun{StartedAt: time.Now()}
+	if err := s.reconciliationRepo.CreateRun(run); err != nil {
+		return nil, fmt.Errorf("failed to record reconciliation run: %w", err)
+	}
+
+	minAge := time.Duration(s.config.MinAgeMinutes) * time.Minute
+	payments, err := s.paymentRepo.GetPendingForReconciliation(run.StartedAt.Add(-minAge), s.config.BatchSize)
+	if err != nil {
+		message := err.Error()
+		run.Error = &message
+		s.finishRun(run)
+		return nil, fmt.Errorf("failed to get pending payments: %w", err)
+	}
+
+	for _, payment := range payments {
+		run.Checked++
+
+		change, err := s.reconcilePayment(payment)
+		if err != nil {
+			s.logger.WithError(err).WithField("payment_id", payment.ID).Error("Failed to reconcile payment")
+			message := err.Error()
+			change.Error = &message
+			run.Failed++
+		} else if change == nil {
+			continue
+		} else {
+			run.Changed++
+		}
+
+		change.RunID = run.ID
+		if err := s.reconciliationRepo.CreateChange(change); err != nil {
+			s.logger.WithError(err).WithField("payment_id", payment.ID).Error("Failed to record reconciliation change")
+		}
+		run.Changes = append(run.Changes, change)
+	}
+
+	s.finishRun(run)
+
+	s.logger.WithFields(map[string]interface{}{
+		"run_id":  run.ID,
+		"checked": run.Checked,
+		"changed": run.Changed,
+		"failed":  run.Failed,
+	}).Info("Payment reconciliation run completed")
+
+	return run, nil
+}
+
+// reconcilePayment checks one payment against its gateway. It returns a nil change when the
+// invoice is still pending; on error the returned change describes the payment that failed.
+func (s *paymentReconciliationService) reconcilePayment(payment *models.Payment) (*models.PaymentReconciliationChange, error) {
+	change := &models.PaymentReconciliationChange{
+		PaymentID:      payment.ID,
+		Gateway:        payment.Gateway,
+		InvoiceID:      *payment.InvoiceID,
+		BillingIDs:     joinUintIDs(paymentBillingIDs(payment)),
+		PreviousStatus: payment.Status,
+		Status:         payment.Status,
+	}
+
+	now := time.Now()
+	if err := s.paymentRepo.MarkReconciled(payment.ID, now); err != nil {
+		return change, fmt.Errorf("failed to mark payment reconciled: %w", err)
+	}
+	payment.ReconciledAt = &now
+
+	gateway, err := s.gateways.Get(payment.Gateway)
+	if err != nil {
+		return change, err
+	}
+
+	status, err := gateway.GetInvoiceStatus(*payment.InvoiceID)
+	if err != nil {
+		return change, fmt.Errorf("failed to get invoice status: %w", err)
+	}
+	change.GatewayStatus = status.RawStatus
+
+	if status.Status == models.PaymentStatusPending {
+		// Gateways that keep reporting an unpaid invoice after its expiry are closed locally
+		if payment.ExpiredAt == nil || payment.ExpiredAt.After(now) {
+			return nil, nil
+		}
+		expired := *status
+		expired.Status = models.PaymentStatusExpired
+		status = &expired
+	}
+
+	result, err := s.webhookService.ApplyGatewayStatus(payment, status)
+	if err != nil {
+		return change, err
+	}
+
+	change.Status = payment.Status
+	change.Action = result.Action
+	change.ReviewID = result.ReviewID
+	return change, nil
+}
+
+// finishRun stamps the run's completion time and saves its counters
+func (s *paymentReconciliationService) finishRun(run *models.PaymentReconciliationRun) {
+	now := time.Now()
+	run.FinishedAt = &now
+	if err := s.reconciliationRepo.UpdateRun(run); err != nil {
+		s.logger.WithError(err).WithField("run_id", run.ID).Error("Failed to record reconciliation run")
+	}
+}
+
+// ListRuns lists reconciliation runs with the changes they made, newest first
+func (s *paymentReconciliationService) ListRuns(page int, limit int) ([]*models.PaymentReconciliationRun, int64, error) {
+	return s.reconciliationRepo.ListRuns(page, limit)
+}
+
+// GetRun retrieves a reconciliation run with the changes it made
+func (s *paymentReconciliationService) GetRun(id uint) (*models.PaymentReconciliationRun, error) {
+	return s.reconciliationRepo.GetRunByID(id)
+}
@@ -0,0 +1,257 @@
+package service
+
+import (
+	"errors"
+	"fmt"
+	"sort"
+	"strconv"
+	"strings"
+	"time"
+
+	"ipl-be-svc/internal/models"
+	"ipl-be-svc/internal/repository"
+	"ipl-be-svc/pkg/logger"
+)
+
+// Payment review resolution actions
+const (
+	PaymentReviewActionApprove = "approve"
+	PaymentReviewActionDismiss = "dismiss"
+)
+
+var (
+	// ErrPaymentReviewResolved is returned when resolving a review that is no longer open
+	ErrPaymentReviewResolved = errors.New("payment review already resolved")
+	// ErrPaymentReviewWithoutPayment is returned when approving, without writing off, a review opened before
+	// reviews recorded their payment, whose paid amount cannot be applied
+	ErrPaymentReviewWithoutPayment = errors.New("payment review has no recorded payment")
+)
+
+// PaymentReviewService defines the interface for the payment "needs review" queue
+type PaymentReviewService interface {
+	ListReviews(status string, page int, limit int) ([]*models.PaymentReview, int64, error)
+	ResolveReview(id uint, req *ResolvePaymentReviewRequest, actorID uint) (*models.PaymentReview, error)
+}
+
+// ResolvePaymentReviewRequest represents the request to resolve a payment review
+type ResolvePaymentReviewRequest struct {
+	Action string  `json:"action" binding:"required,oneof=approve dismiss" example:"approve"`
+	Note   *string `json:"note" example:"Transfer verified against bank statement"`
+	// WriteOff settles what an approved payment left outstanding on its billings instead of returning them
+	// to unpaid
+	WriteOff bool `json:"write_off" example:"false"`
+}
+
+// paymentReviewService implements PaymentReviewService
+type paymentReviewService struct {
+	reviewRepo     repository.PaymentReviewRepository
+	paymentRepo    repository.PaymentRepository
+	billingRepo    repository.BillingRepository
+	billingService BillingService
+	stateMachine   BillingStateMachine
+	statuses       StatusRegistry
+	logger         *logger.Logger
+}
+
+// NewPaymentReviewService creates a new instance of PaymentReviewService
+func NewPaymentReviewService(
+	reviewRepo repository.PaymentReviewRepository,
+	paymentRepo repository.PaymentRepository,
+	billingRepo repository.BillingRepository,
+	billingService BillingService,
+	stateMachine BillingStateMachine,
+	statuses StatusRegistry,
+	logger *logger.Logger,
+) PaymentReviewService {
+	return &paymentReviewService{
+		reviewRepo:     reviewRepo,
+		paymentRepo:    paymentRepo,
+		billingRepo:    billingRepo,
+		billingService: billingService,
+		stateMachine:   stateMachine,
+		statuses:       statuses,
+		logger:         logger,
+	}
+}
+
+// ListReviews lists payment reviews, optionally filtered by status
+func (s *paymentReviewService) ListReviews(status string, page int, limit int) ([]*models.PaymentReview, int64, error) {
+	return s.reviewRepo.List(status, page, limit)
+}
+
+// ResolveReview approves or dismisses an open payment review. Approving applies the amount actually paid to
+// the billings and marks the payment as paid; with WriteOff what it left outstanding is settled too,
+// otherwise those billings return to unpaid. Dismissing returns billings waiting for verification to unpaid.
+func (s *paymentReviewService) ResolveReview(id uint, req *ResolvePaymentReviewRequest, actorID uint) (*models.PaymentReview, error) {
+	review, err := s.reviewRepo.GetByID(id)
+	if err != nil {
+		return nil, err
+	}
+
+	if review.Status != models.PaymentReviewStatusOpen {
+		return nil, fmt.Errorf("%w: %s", ErrPaymentReviewResolved, review.Status)
+	}
+
+	billingIDs, err := splitUintIDs(review.BillingIDs)
+	if err != nil {
+		return nil, err
+	}
+	switch req.Action {
+	case PaymentReviewActionApprove:
+		if err := s.approve(review, billingIDs, req.WriteOff, actorID); err != nil {
+			return nil, err
+		}
+		review.Status = models.PaymentReviewStatusApproved
+	case PaymentReviewActionDismiss:
+		if _, err := s.stateMachine.Transition(&BillingTransitionRequest{
+			BillingIDs:  billingIDs,
+			To:          models.BillingStateUnpaid,
+			ActorID:     actorID,
+			Reason:      fmt.Sprintf("Payment review #%d dismissed", review.ID),
+			SkipInvalid: true,
+		}); err != nil {
+			return nil, fmt.Errorf("failed to return billings to unpaid: %w", err)
+		}
+		review.Status = models.PaymentReviewStatusDismissed
+	default:
+		return nil, fmt.Errorf("invalid action: %s", req.Action)
+	}
+
+	now := time.Now()
+	review.Note = req.Note
+	review.ResolvedByID = &actorID
+	review.ResolvedAt = &now
+
+	if err := s.reviewRepo.Update(review); err != nil {
+		return nil, fmt.Errorf("failed to update payment review: %w", err)
+	}
+
+	s.logger.WithFields(map[string]interface{}{
+		"review_id": review.ID,
+		"status":    review.Status,
+		"actor_id":  actorID,
+	}).Info("Payment review resolved")
+
+	return review, nil
+}
+
+// approve applies the amount the reviewed payment actually paid to its billings and marks it as paid. The
+// billings still waiting for verification afterwards are settled with writeOff or returned to unpaid.
+func (s *paymentReviewService) approve(review *models.PaymentReview, billingIDs []uint, writeOff bool, actorID uint) error {
+	reason := fmt.Sprintf("Payment review #%d approved", review.ID)
+
+	if review.PaymentID == nil {
+		if !writeOff {
+			return fmt.Errorf("%w: review %d must be approved with write_off", ErrPaymentReviewWithoutPayment, review.ID)
+		}
+		if err := s.billingService.ConfirmPayment(billingIDs, actorID, reason); err != nil {
+			return fmt.Errorf("failed to confirm payment: %w", err)
+		}
+		return nil
+	}
+
+	err := s.paymentRepo.UpdateLocked(*review.PaymentID, func(payment *models.Payment) error {
+		amounts, err := s.reviewedAmounts(payment, review.PaidAmount-payment.AdminFee)
+		if err != nil {
+			return err
+		}
+		if len(amounts) > 0 {
+			_, err := s.billingService.ApplyPayment(payment.ID, amounts, actorID, reason)
+			if err != nil && !errors.Is(err, ErrPaymentAlreadyApplied) {
+				return fmt.Errorf("failed to apply payment: %w", err)
+			}
+		}
+
+		if payment.Status != models.PaymentStatusPaid {
+			now := time.Now()
+			payment.Status = models.PaymentStatusPaid
+			payment.PaidAt = &now
+		}
+		return nil
+	})
+	if err != nil {
+		return err
+	}
+
+	if writeOff {
+		if err := s.billingService.ConfirmPayment(billingIDs, actorID, reason+", rest written off"); err != nil {
+			return fmt.Errorf("failed to write off billings: %w", err)
+		}
+		return nil
+	}
+
+	// Billings the payment did not settle wait to be paid again
+	if _, err := s.stateMachine.Transition(&BillingTransitionRequest{
+		BillingIDs:  billingIDs,
+		To:          models.BillingStateUnpaid,
+		ActorID:     actorID,
+		Reason:      reason + ", rest left unpaid",
+		SkipInvalid: true,
+	}); err != nil {
+		return fmt.Errorf("failed to return billings to unpaid: %w", err)
+	}
+	return nil
+}
+
+// reviewedAmounts spreads what was paid for the billings over the payment's billing links in order, each
+// billing taking at most its link amount and what is outstanding on it. Billings that no longer exist or
+// cannot be paid take nothing.
+func (s *paymentReviewService) reviewedAmounts(payment *models.Payment, paid int64) (map[uint]int64, error) {
+	amounts := make(map[uint]int64)
+	if paid <= 0 || len(payment.Billings) == 0 {
+		return amounts, nil
+	}
+
+	billingIDs := paymentBillingIDs(payment)
+	billings, err := s.billingRepo.GetBillingsByIDs(billingIDs)
+	if err != nil {
+		return nil, fmt.Errorf("failed to get billings: %w", err)
+	}
+	statusIDs, err := s.billingRepo.GetBillingStatusIDs(billingIDs)
+	if err != nil {
+		return nil, fmt.Errorf("failed to get billing statuses: %w", err)
+	}
+
+	outstanding := make(map[uint]int64, len(billings))
+	for _, billing := range billings {
+		if s.stateMachine.CanTransition(s.statuses.State(statusIDs[billing.ID]), models.BillingStatePaid) {
+			outstanding[billing.ID] = billing.Outstanding()
+		}
+	}
+
+	links := append([]*models.PaymentBillingLink(nil), payment.Billings...)
+	sort.Slice(links, func(i, j int) bool { return links[i].ID < links[j].ID })
+	for _, link := range links {
+		amount := min(link.Amount, outstanding[link.BillingID]-amounts[link.BillingID], paid)
+		if amount <= 0 {
+			continue
+		}
+		amounts[link.BillingID] += amount
+		paid -= amount
+	}
+
+	if paid > 0 {
+		s.logger.WithFields(map[string]interface{}{
+			"payment_id": payment.ID,
+			"unapplied":  paid,
+		}).Warn("Approved payment paid more than its billings take")
+	}
+	return amounts, nil
+}
+
+// splitUintIDs parses a comma-separated list of IDs
+func splitUintIDs(value string) ([]uint, error) {
+	var ids []uint
+	for _, part := range strings.Split(value, ",") {
+		part = strings.TrimSpace(part)
+		if part == "" {
+			continue
+		}
+		id, err := strconv.ParseUint(part, 10, 32)
+		if err != nil {
+			return nil, fmt.Errorf("invalid billing ID %q: %w", part, err)
+		}
+		ids = append(ids, uint(id))
+	}
+	return ids, nil
+}
@@ -1,71 +1,93 @@
 package service
 
 import (
-	"bytes"
-	"encoding/json"
+	"errors"
 	"fmt"
-	"io"
-	"net/http"
-	"os"
-	"strings"
 	"time"
 
+	"ipl-be-svc/internal/models"
 	"ipl-be-svc/internal/repository"
 	"ipl-be-svc/pkg/logger"
+
+	"github.com/google/uuid"
+	"gorm.io/gorm"
 )
 
-// MayarConfig holds Mayar API configuration
-type MayarConfig struct {
-	AuthKey string
-	BaseURL string
-}
+// legacyAdminFee is the fixed admin fee Mayar invoices carried before the fee became configurable
+const legacyAdminFee int64 = 5000
+
+// invoiceValidity is how long an issued invoice can be paid
+const invoiceValidity = 30 * 24 * time.Hour
+
+// ManualPaymentGateway is the gateway of payments an admin records after receiving them outside a payment
+// gateway, e.g. in cash
+const ManualPaymentGateway = "manual"
+
+var (
+	// ErrBillingOwnerNotFound is returned when a billing is not linked to a resident
+	ErrBillingOwnerNotFound = errors.New("billing has no resident")
+	// ErrMultipleBillingOwners is returned when one payment would cover billings of different residents
+	ErrMultipleBillingOwners = errors.New("billings belong to different residents")
+	// ErrPaymentNotRefundable is returned when refunding a payment that has not settled
+	ErrPaymentNotRefundable = errors.New("only paid payments can be refunded")
+	// ErrInvalidRefundAmount is returned when the refund amount exceeds what was paid
+	ErrInvalidRefundAmount = errors.New("refund amount must be between 1 and the paid amount")
+	// ErrBillingCancelled is returned when creating a payment link for a cancelled billing
+	ErrBillingCancelled = errors.New("billing is cancelled")
+	// ErrNothingOutstanding is returned when creating a payment link for a billing that is fully paid
+	ErrNothingOutstanding = errors.New("billing has nothing outstanding")
+	// ErrPaymentForbidden is returned when a resident asks for payments of another resident's billings
+	ErrPaymentForbidden = errors.New("billings belong to another resident")
+	// ErrInvalidBillingNominal is returned when creating a payment link for a billing without a nominal
+	ErrInvalidBillingNominal = errors.New("invalid billing nominal")
+)
 
-// MayarItem represents an item in the invoice
-type MayarItem struct {
-	Quantity    int    `json:"quantity"`
-	Rate        int64  `json:"rate"`
-	Description string `json:"description"`
+// PaymentService defines the interface for payment operations
+type PaymentService interface {
+	CreatePaymentLink(billingID uint, regenerate bool, requester *PaymentRequester) (*PaymentLinkResponse, error)
+	CreatePaymentLinkMultiple(billingIDs []uint, regenerate bool, requester *PaymentRequester) (*PaymentLinkResponse, error)
+	CreateInstallmentPaymentLink(installmentID uint, regenerate bool, requester *PaymentRequester) (*PaymentLinkResponse, error)
+	CreatePrepaymentLink(req *PrepayRequest, requester *PaymentRequester) (*PrepaymentResponse, error)
+	RecordManualPayment(billingID uint, req *ManualPaymentRequest, actorID uint) (*models.Payment, error)
+	GetPaymentsByBillingID(billingID uint, requester *PaymentRequester) ([]*models.Payment, error)
+	GetPaymentsByUserID(userID uint, page int, limit int, requester *PaymentRequester) ([]*models.Payment, int64, error)
+	RefundPayment(paymentID uint, req *RefundPaymentRequest, actorID uint) (*models.Payment, error)
 }
 
-// MayarCreateInvoiceRequest represents the Mayar invoice creation request
-type MayarCreateInvoiceRequest struct {
-	Name        string      `json:"name"`
-	Email       string      `json:"email"`
-	Mobile      string      `json:"mobile"`
-	RedirectURL string      `json:"redirectUrl"`
-	Description string      `json:"description"`
-	ExpiredAt   string      `json:"expiredAt"`
-	Items       []MayarItem `json:"items"`
+// PaymentRequester is the user asking for payment links or payment history. Staff may act on the billings
+// of any resident, residents only on their own.
+type PaymentRequester struct {
+	UserID uint
+	Staff  bool
 }
 
-// MayarCreateInvoiceResponse represents the Mayar API response
-type MayarCreateInvoiceResponse struct {
-	StatusCode int    `json:"statusCode"`
-	Messages   string `json:"messages"`
-	Data       struct {
-		ID            string `json:"id"`
-		TransactionID string `json:"transactionId"`
-		Link          string `json:"link"`
-		ExpiredAt     int64  `json:"expiredAt"`
-	} `json:"data"`
+// restricted reports whether the requester may only act on their own billings. A nil requester is the
+// service itself.
+func (r *PaymentRequester) restricted() bool {
+	return r != nil && !r.Staff
 }
 
-// MayarService defines the interface for Mayar payment operations
-type MayarService interface {
-	CreatePaymentLink(amount int64, billingIDsStr string, documentIDs string, humanDescription string, customerName string, customerEmail string, customerPhone string) (string, error)
-	CreateInvoice(req *MayarCreateInvoiceRequest) (*MayarCreateInvoiceResponse, error)
+// RefundPaymentRequest represents a refund of a paid payment; Amount 0 refunds everything that was paid
+type RefundPaymentRequest struct {
+	Amount int64  `json:"amount" example:"155000"`
+	Reason string `json:"reason" binding:"required" example:"Double payment"`
 }
 
-// PaymentService defines the interface for payment operations
-type PaymentService interface {
-	CreatePaymentLink(billingID uint) (*PaymentLinkResponse, error)
-	CreatePaymentLinkMultiple(billingIDs []uint) (*PaymentLinkResponse, error)
+// ManualPaymentRequest represents a payment received outside a payment gateway. It may cover part of the
+// billing's outstanding balance, what it pays beyond it is credited to the resident.
+type ManualPaymentRequest struct {
+	Amount        int64  `json:"amount" binding:"required,gt=0" example:"250000"`
+	PaymentMethod string `json:"payment_method" example:"cash"`
+	Note          string `json:"note" example:"Paid at the management office"`
 }
 
 // PaymentLinkResponse represents the response for payment link creation
 type PaymentLinkResponse struct {
+	PaymentID     uint   `json:"payment_id,omitempty"`
+	Gateway       string `json:"gateway,omitempty"`
 	BillingID     uint   `json:"billing_id,omitempty"`
 	BillingIDs    []uint `json:"billing_ids,omitempty"`
+	InstallmentID uint   `json:"installment_id,omitempty"`
 	Amount        int64  `json:"amount"`
 	PaymentURL    string `json:"payment_url"`
 	Description   string `json:"description"`
@@ -73,26 +95,69 @@ type PaymentLinkResponse struct {
 	TransactionID string `json:"transaction_id,omitempty"`
 	ExpiredAt     int64  `json:"expired_at,omitempty"`
 	DocumentID    string `json:"document_id,omitempty"`
+	// Reused is true when an existing open payment link was returned instead of a new one
+	Reused bool `json:"reused"`
+}
+
+// PrepaymentResponse is the combined payment link of a resident's coming months with the billings created
+// for them
+type PrepaymentResponse struct {
+	*PaymentLinkResponse
+	Advance *AdvanceBillings `json:"advance"`
 }
 
 // paymentService implements PaymentService
 type paymentService struct {
-	billingRepo  repository.BillingRepository
-	mayarService MayarService
-	logger       *logger.Logger
+	billingRepo     repository.BillingRepository
+	paymentRepo     repository.PaymentRepository
+	penaltyRepo     repository.BillingPenaltyRepository
+	installmentRepo repository.BillingInstallmentRepository
+	billingService  BillingService
+	creditService   CreditService
+	statuses        StatusRegistry
+	stateMachine    BillingStateMachine
+	gateways        *PaymentGatewayRegistry
+	feePolicy       *AdminFeePolicy
+	logger          *logger.Logger
 }
 
 // NewPaymentService creates a new instance of PaymentService
-func NewPaymentService(billingRepo repository.BillingRepository, mayarService MayarService, logger *logger.Logger) PaymentService {
+func NewPaymentService(
+	billingRepo repository.BillingRepository,
+	paymentRepo repository.PaymentRepository,
+	penaltyRepo repository.BillingPenaltyRepository,
+	installmentRepo repository.BillingInstallmentRepository,
+	billingService BillingService,
+	creditService CreditService,
+	statuses StatusRegistry,
+	stateMachine BillingStateMachine,
+	gateways *PaymentGatewayRegistry,
+	feePolicy *AdminFeePolicy,
+	logger *logger.Logger,
+) PaymentService {
 	return &paymentService{
-		billingRepo:  billingRepo,
-		mayarService: mayarService,
-		logger:       logger,
+		billingRepo:     billingRepo,
+		paymentRepo:     paymentRepo,
+		penaltyRepo:     penaltyRepo,
+		installmentRepo: installmentRepo,
+		billingService:  billingService,
+		creditService:   creditService,
+		statuses:        statuses,
+		stateMachine:    stateMachine,
+		gateways:        gateways,
+		feePolicy:       feePolicy,
+		logger:          logger,
 	}
 }
 
-// CreatePaymentLink creates a Mayar payment link for a billing record
-func (s *paymentService) CreatePaymentLink(billingID uint) (*PaymentLinkResponse, error) {
+// CreatePaymentLink returns the open payment link of a billing record, creating one when there is none.
+// It charges what is outstanding on the billing, and open late fees charged on the billing are paid with
+// it. With regenerate the open link is voided and a new one is always created.
+func (s *paymentService) CreatePaymentLink(billingID uint, regenerate bool, requester *PaymentRequester) (*PaymentLinkResponse, error) {
+	if err := s.checkRequester(requester, []uint{billingID}); err != nil {
+		return nil, err
+	}
+
 	// Get billing record
 	billing, err := s.billingRepo.GetBillingByID(billingID)
 	if err != nil {
@@ -104,21 +169,45 @@ func (s *paymentService) CreatePaymentLink(billingID uint) (*PaymentLinkResponse
 	// Validate nominal exists
 	if billing.Nominal == nil || *billing.Nominal <= 0 {
 		s.logger.WithField("billing_id", billingID).Error("Invalid billing nominal")
-		return nil, fmt.Errorf("invalid billing nominal")
+		return nil, ErrInvalidBillingNominal
+	}
+
+	if err := s.checkNotCancelled([]uint{billingID}); err != nil {
+		return nil, err
 	}
 
-	// Use default customer information
-	userName := "Penghuni IPL"
-	userEmail := "billing@ipl.com"
-	userPhone := "08123456789"
+	if billing.Outstanding() <= 0 {
+		return nil, fmt.Errorf("%w: billing %d", ErrNothingOutstanding, billingID)
+	}
 
-	// Create billing IDs string for webhook parsing (just the ID)
-	billingIDsStr := fmt.Sprintf("%d", billingID)
+	owner, err := s.resolveBillingOwner([]uint{billingID})
+	if err != nil {
+		return nil, err
+	}
 
 	// Get document ID
 	documentID := ""
+	var documentIDs []string
 	if billing.DocumentID != nil {
 		documentID = *billing.DocumentID
+		documentIDs = append(documentIDs, documentID)
+	}
+
+	billingIDs := []uint{billingID}
+	amount := billing.Outstanding()
+	links := []*models.PaymentBillingLink{{BillingID: billingID, Amount: amount}}
+
+	penalties, err := s.openPenaltyBillings(billingIDs)
+	if err != nil {
+		return nil, err
+	}
+	for _, penalty := range penalties {
+		billingIDs = append(billingIDs, penalty.ID)
+		amount += penalty.Outstanding()
+		links = append(links, &models.PaymentBillingLink{BillingID: penalty.ID, Amount: penalty.Outstanding()})
+		if penalty.DocumentID != nil {
+			documentIDs = append(documentIDs, *penalty.DocumentID)
+		}
 	}
 
 	// Create human-readable description
@@ -127,31 +216,47 @@ func (s *paymentService) CreatePaymentLink(billingID uint) (*PaymentLinkResponse
 		humanDescription = fmt.Sprintf("Payment for %d/%d - Billing ID %d", *billing.Bulan, *billing.Tahun, billingID)
 	}
 
-	// Create Mayar payment link
-	paymentURL, err := s.mayarService.CreatePaymentLink(*billing.Nominal, billingIDsStr, documentID, humanDescription, userName, userEmail, userPhone)
+	payment, reused, err := s.issueInvoice(&GatewayInvoiceRequest{
+		BillingIDs:    billingIDs,
+		DocumentIDs:   documentIDs,
+		Amount:        amount,
+		Description:   humanDescription,
+		CustomerName:  owner.CustomerName(),
+		CustomerEmail: owner.Email,
+		CustomerPhone: owner.CustomerPhone(),
+	}, owner, links, regenerate)
 	if err != nil {
-		s.logger.WithError(err).WithField("billing_id", billingID).Error("Failed to create Mayar payment link")
-		return nil, fmt.Errorf("failed to create payment link: %w", err)
+		return nil, err
 	}
 
-	return &PaymentLinkResponse{
-		BillingID:   billingID,
-		Amount:      *billing.Nominal,
-		PaymentURL:  paymentURL,
-		DocumentID:  documentID,
-		Description: humanDescription,
-	}, nil
+	response := newPaymentLinkResponse(payment, reused)
+	response.BillingID = billingID
+	response.DocumentID = documentID
+	if len(penalties) > 0 {
+		response.BillingIDs = billingIDs
+	}
+	return response, nil
 }
 
-// CreatePaymentLinkMultiple creates a Mayar payment link for multiple billing records
-func (s *paymentService) CreatePaymentLinkMultiple(billingIDs []uint) (*PaymentLinkResponse, error) {
+// CreatePaymentLinkMultiple returns the open payment link covering what is outstanding on exactly these
+// billing records and their open late fees, creating one when there is none. With regenerate the open link
+// is voided first.
+func (s *paymentService) CreatePaymentLinkMultiple(billingIDs []uint, regenerate bool, requester *PaymentRequester) (*PaymentLinkResponse, error) {
 	if len(billingIDs) == 0 {
 		return nil, fmt.Errorf("billing IDs cannot be empty")
 	}
 
+	// A billing listed twice would otherwise be charged twice in the same payment
+	billingIDs = uniqueBillingIDs(billingIDs)
+
+	if err := s.checkRequester(requester, billingIDs); err != nil {
+		return nil, err
+	}
+
 	var totalAmount int64 = 0
 	var listBillingIDs []uint
 	var listDocumentIDs []string
+	var links []*models.PaymentBillingLink
 
 	for _, billingID := range billingIDs {
 		// Get billing record
@@ -164,221 +269,728 @@ func (s *paymentService) CreatePaymentLinkMultiple(billingIDs []uint) (*PaymentL
 		// Validate nominal exists
 		if billing.Nominal == nil || *billing.Nominal <= 0 {
 			s.logger.WithField("billing_id", billingID).Error("Invalid billing nominal")
-			return nil, fmt.Errorf("invalid billing nominal for ID %d", billingID)
+			return nil, fmt.Errorf("%w for ID %d", ErrInvalidBillingNominal, billingID)
+		}
+
+		if billing.Outstanding() <= 0 {
+			return nil, fmt.Errorf("%w: billing %d", ErrNothingOutstanding, billingID)
 		}
 
-		totalAmount += *billing.Nominal
+		totalAmount += billing.Outstanding()
+		links = append(links, &models.PaymentBillingLink{BillingID: billingID, Amount: billing.Outstanding()})
 		listBillingIDs = append(listBillingIDs, billingID)
 		if billing.DocumentID != nil {
 			listDocumentIDs = append(listDocumentIDs, *billing.DocumentID)
 		}
 	}
 
-	// Use default customer information
-	userName := "Penghuni IPL"
-	userEmail := "billing@ipl.com"
-	userPhone := "08123456789"
+	if err := s.checkNotCancelled(listBillingIDs); err != nil {
+		return nil, err
+	}
 
-	// Create billing IDs string for webhook parsing (comma-separated)
-	billingIDsStr := strings.Join(func() []string {
-		parts := make([]string, len(billingIDs))
-		for i, id := range billingIDs {
-			parts[i] = fmt.Sprintf("%d", id)
+	penalties, err := s.openPenaltyBillings(listBillingIDs)
+	if err != nil {
+		return nil, err
+	}
+	for _, penalty := range penalties {
+		totalAmount += penalty.Outstanding()
+		links = append(links, &models.PaymentBillingLink{BillingID: penalty.ID, Amount: penalty.Outstanding()})
+		listBillingIDs = append(listBillingIDs, penalty.ID)
+		if penalty.DocumentID != nil {
+			listDocumentIDs = append(listDocumentIDs, *penalty.DocumentID)
 		}
-		return parts
-	}(), ",")
+	}
 
-	// Create document IDs string
-	documentIDsStr := strings.Join(listDocumentIDs, ", ")
+	owner, err := s.resolveBillingOwner(listBillingIDs)
+	if err != nil {
+		return nil, err
+	}
 
 	// Create human-readable description
 	humanDescription := fmt.Sprintf("Payment for %d billings", len(billingIDs))
 
-	// Create Mayar payment link
-	paymentURL, err := s.mayarService.CreatePaymentLink(totalAmount, billingIDsStr, documentIDsStr, humanDescription, userName, userEmail, userPhone)
+	payment, reused, err := s.issueInvoice(&GatewayInvoiceRequest{
+		BillingIDs:    listBillingIDs,
+		DocumentIDs:   listDocumentIDs,
+		Amount:        totalAmount,
+		Description:   humanDescription,
+		CustomerName:  owner.CustomerName(),
+		CustomerEmail: owner.Email,
+		CustomerPhone: owner.CustomerPhone(),
+	}, owner, links, regenerate)
+	if err != nil {
+		return nil, err
+	}
+
+	response := newPaymentLinkResponse(payment, reused)
+	response.BillingIDs = listBillingIDs
+	return response, nil
+}
+
+// CreateInstallmentPaymentLink returns the open payment link of what is left to pay of an installment,
+// creating one when there is none. With regenerate the open link is voided first.
+func (s *paymentService) CreateInstallmentPaymentLink(installmentID uint, regenerate bool, requester *PaymentRequester) (*PaymentLinkResponse, error) {
+	installment, err := s.installmentRepo.GetByID(installmentID)
+	if err != nil {
+		return nil, err
+	}
+
+	if err := s.checkRequester(requester, []uint{installment.BillingID}); err != nil {
+		return nil, err
+	}
+
+	billing, err := s.billingRepo.GetBillingByID(installment.BillingID)
+	if err != nil {
+		return nil, err
+	}
+
+	if err := s.checkNotCancelled([]uint{billing.ID}); err != nil {
+		return nil, err
+	}
+
+	installments, err := s.installmentRepo.GetByBillingID(billing.ID)
+	if err != nil {
+		return nil, fmt.Errorf("failed to get installments: %w", err)
+	}
+	allocateInstallments(installments, billing.Outstanding())
+
+	var amount int64
+	for _, item := range installments {
+		if item.ID == installmentID {
+			amount = item.Amount - item.PaidAmount
+		}
+	}
+	if amount <= 0 {
+		return nil, fmt.Errorf("%w: installment %d", ErrInstallmentPaid, installmentID)
+	}
+
+	owner, err := s.resolveBillingOwner([]uint{billing.ID})
+	if err != nil {
+		return nil, err
+	}
+
+	var documentIDs []string
+	if billing.DocumentID != nil {
+		documentIDs = append(documentIDs, *billing.DocumentID)
+	}
+
+	payment, reused, err := s.issueInvoice(&GatewayInvoiceRequest{
+		BillingIDs:    []uint{billing.ID},
+		DocumentIDs:   documentIDs,
+		Amount:        amount,
+		Description:   fmt.Sprintf("Installment %d of %d - Billing ID %d", installment.Sequence, len(installments), billing.ID),
+		CustomerName:  owner.CustomerName(),
+		CustomerEmail: owner.Email,
+		CustomerPhone: owner.CustomerPhone(),
+	}, owner, []*models.PaymentBillingLink{{BillingID: billing.ID, Amount: amount}}, regenerate)
+	if err != nil {
+		return nil, err
+	}
+
+	response := newPaymentLinkResponse(payment, reused)
+	response.BillingID = billing.ID
+	response.InstallmentID = installmentID
+	if billing.DocumentID != nil {
+		response.DocumentID = *billing.DocumentID
+	}
+	return response, nil
+}
+
+// CreatePrepaymentLink bills a resident's coming months in advance and returns the payment link covering
+// them, as CreatePaymentLinkMultiple does. Asking again for the same months returns the same open link.
+// Without a profile the requester prepays their own months.
+func (s *paymentService) CreatePrepaymentLink(req *PrepayRequest, requester *PaymentRequester) (*PrepaymentResponse, error) {
+	userID := requester.UserID
+	if req.ProfileID != 0 {
+		userIDs, err := s.billingRepo.GetProfileUserIDs(req.ProfileID)
+		if err != nil {
+			return nil, fmt.Errorf("failed to get profile users: %w", err)
+		}
+		if len(userIDs) == 0 {
+			return nil, gorm.ErrRecordNotFound
+		}
+		if len(userIDs) > 1 {
+			return nil, fmt.Errorf("%w: profile %d belongs to %d users", ErrInvalidPrepayment, req.ProfileID, len(userIDs))
+		}
+		userID = userIDs[0]
+	}
+
+	if requester.restricted() && userID != requester.UserID {
+		return nil, fmt.Errorf("%w: profile %d", ErrPaymentForbidden, req.ProfileID)
+	}
+
+	advance, err := s.billingService.CreateAdvanceMonthlyBillings(userID, req.Months)
+	if err != nil {
+		return nil, err
+	}
+	if len(advance.BillingIDs) == 0 {
+		return nil, fmt.Errorf("%w: the next %d months are paid", ErrNothingOutstanding, req.Months)
+	}
+
+	link, err := s.CreatePaymentLinkMultiple(advance.BillingIDs, false, requester)
+	if err != nil {
+		return nil, err
+	}
+
+	return &PrepaymentResponse{PaymentLinkResponse: link, Advance: advance}, nil
+}
+
+// RecordManualPayment records a payment an admin received outside a payment gateway and takes it off the
+// billing's outstanding balance. The billing is marked as paid once nothing is outstanding, and what was
+// paid beyond that is added to the resident's credit balance instead of the payment, as with gateway
+// overpayments.
+func (s *paymentService) RecordManualPayment(billingID uint, req *ManualPaymentRequest, actorID uint) (*models.Payment, error) {
+	billing, err := s.billingRepo.GetBillingByID(billingID)
+	if err != nil {
+		return nil, err
+	}
+	if billing.PublishedAt == nil {
+		return nil, gorm.ErrRecordNotFound
+	}
+
+	applied := min(req.Amount, billing.Outstanding())
+	overpaid := req.Amount - applied
+	var creditProfileID *uint
+	if overpaid > 0 {
+		owner, err := s.resolveBillingOwner([]uint{billingID})
+		if err != nil {
+			return nil, err
+		}
+		if owner.ProfileID == nil {
+			return nil, fmt.Errorf("%w: billing %d has %d outstanding and its owner has no profile to credit", ErrAmountExceedsOutstanding, billingID, applied)
+		}
+		creditProfileID = owner.ProfileID
+	}
+
+	description := req.Note
+	if description == "" {
+		description = fmt.Sprintf("Manual payment for Billing ID %d", billingID)
+	}
+
+	reference := uuid.NewString()
+	payment := &models.Payment{
+		Gateway:       ManualPaymentGateway,
+		Reference:     &reference,
+		Amount:        applied,
+		Status:        models.PaymentStatusPending,
+		PaymentMethod: stringPtr(req.PaymentMethod),
+		Description:   stringPtr(description),
+	}
+	if err := s.paymentRepo.Create(payment, []*models.PaymentBillingLink{{BillingID: billingID, Amount: applied}}); err != nil {
+		return nil, fmt.Errorf("failed to record payment: %w", err)
+	}
+
+	if _, err := s.billingService.ApplyPayment(payment.ID, map[uint]int64{billingID: applied}, actorID, fmt.Sprintf("Manual payment #%d", payment.ID)); err != nil {
+		payment.Status = models.PaymentStatusFailed
+		if updateErr := s.paymentRepo.Update(payment); updateErr != nil {
+			s.logger.WithError(updateErr).WithField("payment_id", payment.ID).Error("Failed to mark payment failed")
+		}
+		return nil, err
+	}
+
+	now := time.Now()
+	payment.Status = models.PaymentStatusPaid
+	payment.PaidAt = &now
+	if err := s.paymentRepo.Update(payment); err != nil {
+		return nil, fmt.Errorf("failed to update payment: %w", err)
+	}
+
+	if creditProfileID != nil {
+		// The billing is already paid, so a failure is logged for the credit to be deposited by hand
+		if _, err := s.creditService.AddOverpayment(*creditProfileID, overpaid, payment.ID); err != nil {
+			s.logger.WithError(err).WithFields(map[string]interface{}{
+				"payment_id": payment.ID,
+				"profile_id": *creditProfileID,
+				"amount":     overpaid,
+			}).Error("Failed to credit overpayment")
+		}
+	}
+
+	s.logger.WithFields(map[string]interface{}{
+		"payment_id": payment.ID,
+		"billing_id": billingID,
+		"amount":     req.Amount,
+		"actor_id":   actorID,
+	}).Info("Manual payment recorded")
+
+	return payment, nil
+}
+
+// GetPaymentsByBillingID returns the payment history of a billing
+func (s *paymentService) GetPaymentsByBillingID(billingID uint, requester *PaymentRequester) ([]*models.Payment, error) {
+	if err := s.checkRequester(requester, []uint{billingID}); err != nil {
+		return nil, err
+	}
+	return s.paymentRepo.GetByBillingID(billingID)
+}
+
+// GetPaymentsByUserID returns the payment history of a resident
+func (s *paymentService) GetPaymentsByUserID(userID uint, page int, limit int, requester *PaymentRequester) ([]*models.Payment, int64, error) {
+	if requester.restricted() && requester.UserID != userID {
+		return nil, 0, fmt.Errorf("%w: user %d", ErrPaymentForbidden, userID)
+	}
+	return s.paymentRepo.GetByUserID(userID, page, limit)
+}
+
+// checkRequester returns ErrPaymentForbidden when a restricted requester does not own all the billings.
+// Billings whose owner cannot be resolved, including ones that do not exist, are denied as well.
+func (s *paymentService) checkRequester(requester *PaymentRequester, billingIDs []uint) error {
+	if !requester.restricted() {
+		return nil
+	}
+
+	owners, err := s.billingRepo.GetBillingOwners(billingIDs)
+	if err != nil {
+		return fmt.Errorf("failed to get billing owners: %w", err)
+	}
+
+	// A billing whose owner cannot be resolved is not the requester's
+	owned := make(map[uint]bool, len(owners))
+	for _, owner := range owners {
+		owned[owner.BillingID] = owner.UserID == requester.UserID
+	}
+	for _, billingID := range billingIDs {
+		if !owned[billingID] {
+			s.logger.WithFields(map[string]interface{}{
+				"user_id":    requester.UserID,
+				"billing_id": billingID,
+			}).Warn("Resident asked for payments of a billing that is not theirs")
+			return fmt.Errorf("%w: billing %d", ErrPaymentForbidden, billingID)
+		}
+	}
+	return nil
+}
+
+// checkNotCancelled returns ErrBillingCancelled when any of the billings is cancelled
+func (s *paymentService) checkNotCancelled(billingIDs []uint) error {
+	statusIDs, err := s.billingRepo.GetBillingStatusIDs(billingIDs)
 	if err != nil {
-		s.logger.WithError(err).WithField("billing_ids", billingIDs).Error("Failed to create Mayar payment link")
-		return nil, fmt.Errorf("failed to create payment link: %w", err)
+		return fmt.Errorf("failed to get billing statuses: %w", err)
 	}
 
-	return &PaymentLinkResponse{
-		BillingIDs:  billingIDs,
-		Amount:      totalAmount,
-		PaymentURL:  paymentURL,
-		Description: humanDescription,
-	}, nil
+	cancelledID := s.statuses.IDs().Cancelled
+	for _, billingID := range billingIDs {
+		if statusIDs[billingID] == cancelledID {
+			return fmt.Errorf("%w: billing %d", ErrBillingCancelled, billingID)
+		}
+	}
+	return nil
 }
 
-// mayarService implements MayarService
-type mayarService struct {
-	logger *logger.Logger
-	config MayarConfig
+// openPenaltyBillings returns the unpaid, unwaived and not cancelled penalty billings charged on the billings
+// that are not already among them
+func (s *paymentService) openPenaltyBillings(billingIDs []uint) ([]*models.Billing, error) {
+	statuses := s.statuses.IDs()
+	penalties, err := s.penaltyRepo.GetOpenPenaltyBillings(billingIDs, []uint{statuses.Paid, statuses.Cancelled})
+	if err != nil {
+		return nil, fmt.Errorf("failed to get penalty billings: %w", err)
+	}
+
+	included := make(map[uint]bool, len(billingIDs))
+	for _, id := range billingIDs {
+		included[id] = true
+	}
+
+	var open []*models.Billing
+	for _, penalty := range penalties {
+		if included[penalty.ID] || penalty.Outstanding() <= 0 {
+			continue
+		}
+		included[penalty.ID] = true
+		open = append(open, penalty)
+	}
+	return open, nil
 }
 
-// NewMayarService creates a new instance of MayarService
-func NewMayarService(logger *logger.Logger) MayarService {
-	config := MayarConfig{
-		AuthKey: os.Getenv("MAYAR_AUTH_KEY"),
-		BaseURL: os.Getenv("MAYAR_BASE_URL"),
+// resolveBillingOwner returns the single resident owning all billings
+func (s *paymentService) resolveBillingOwner(billingIDs []uint) (*models.BillingOwner, error) {
+	owners, err := s.billingRepo.GetBillingOwners(billingIDs)
+	if err != nil {
+		return nil, fmt.Errorf("failed to get billing owners: %w", err)
 	}
 
-	if config.BaseURL == "" {
-		config.BaseURL = "https://api.mayar.id/hl/v1"
+	byBilling := make(map[uint]*models.BillingOwner, len(owners))
+	for _, owner := range owners {
+		byBilling[owner.BillingID] = owner
 	}
 
-	return &mayarService{
-		logger: logger,
-		config: config,
+	var resident *models.BillingOwner
+	for _, billingID := range billingIDs {
+		owner, ok := byBilling[billingID]
+		if !ok {
+			return nil, fmt.Errorf("%w: billing %d", ErrBillingOwnerNotFound, billingID)
+		}
+		if resident != nil && owner.UserID != resident.UserID {
+			s.logger.WithField("billing_ids", billingIDs).Warn("Payment link spans billings of different residents")
+			return nil, ErrMultipleBillingOwners
+		}
+		resident = owner
 	}
+
+	return resident, nil
 }
 
-// CreateInvoice creates an invoice using Mayar API
-func (m *mayarService) CreateInvoice(req *MayarCreateInvoiceRequest) (*MayarCreateInvoiceResponse, error) {
-	url := fmt.Sprintf("%s/invoice/create", m.config.BaseURL)
+// RefundPayment refunds a paid payment through the gateway it was paid with; manual payments are only
+// recorded as refunded. A full refund moves its billings to refunded, or gives the amounts back to the
+// outstanding balance of billings it only partly paid. After a partial refund they are kept as they are.
+// The payment is marked refunding under its row lock before the gateway is asked, so it is refunded once.
+func (s *paymentService) RefundPayment(paymentID uint, req *RefundPaymentRequest, actorID uint) (*models.Payment, error) {
+	var payment *models.Payment
+	var amount int64
+	err := s.paymentRepo.UpdateLocked(paymentID, func(locked *models.Payment) error {
+		if locked.Status != models.PaymentStatusPaid {
+			return ErrPaymentNotRefundable
+		}
 
-	// Marshal request to JSON
-	bodyJSON, err := json.Marshal(req)
+		amount = req.Amount
+		if amount == 0 {
+			amount = locked.TotalAmount()
+		}
+		if amount < 0 || amount > locked.TotalAmount() {
+			return ErrInvalidRefundAmount
+		}
+
+		locked.Status = models.PaymentStatusRefunding
+		payment = locked
+		return nil
+	})
 	if err != nil {
-		return nil, fmt.Errorf("failed to marshal request: %w", err)
+		return nil, err
 	}
 
-	// Create HTTP request
-	httpReq, err := http.NewRequest("POST", url, bytes.NewBuffer(bodyJSON))
+	refund, err := s.refundThroughGateway(payment, amount, req.Reason)
 	if err != nil {
-		return nil, fmt.Errorf("failed to create request: %w", err)
+		// The refund did not go through, so the payment can be refunded again
+		payment.Status = models.PaymentStatusPaid
+		if updateErr := s.paymentRepo.Update(payment); updateErr != nil {
+			s.logger.WithError(updateErr).WithField("payment_id", paymentID).Error("Failed to mark payment paid after a failed refund")
+		}
+		return nil, err
+	}
+
+	now := time.Now()
+	payment.Status = models.PaymentStatusRefunded
+	payment.RefundID = stringPtr(refund.RefundID)
+	payment.RefundAmount = amount
+	payment.RefundReason = stringPtr(req.Reason)
+	payment.RefundedAt = &now
+	if err := s.paymentRepo.Update(payment); err != nil {
+		return nil, fmt.Errorf("failed to update payment: %w", err)
+	}
+
+	s.logger.WithFields(map[string]interface{}{
+		"payment_id": paymentID,
+		"gateway":    payment.Gateway,
+		"amount":     amount,
+		"refund_id":  refund.RefundID,
+	}).Info("Payment refunded")
+
+	if amount == payment.TotalAmount() {
+		// The gateway already refunded, so a billing that cannot move is logged rather than failing the refund
+		moved, err := s.stateMachine.Transition(&BillingTransitionRequest{
+			BillingIDs:  paymentBillingIDs(payment),
+			To:          models.BillingStateRefunded,
+			ActorID:     actorID,
+			Reason:      fmt.Sprintf("Payment #%d refunded: %s", payment.ID, req.Reason),
+			SkipInvalid: true,
+		})
+		if err != nil {
+			s.logger.WithError(err).WithField("payment_id", paymentID).Error("Failed to mark refunded billings")
+		} else if err := s.restorePartialPayments(payment, moved); err != nil {
+			s.logger.WithError(err).WithField("payment_id", paymentID).Error("Failed to restore outstanding balances")
+		}
+	}
+
+	return payment, nil
+}
+
+// refundThroughGateway asks the gateway the payment was paid with to refund amount; manual payments have
+// nothing to refund through
+func (s *paymentService) refundThroughGateway(payment *models.Payment, amount int64, reason string) (*GatewayRefund, error) {
+	refund := &GatewayRefund{}
+	if payment.Gateway != ManualPaymentGateway {
+		gateway, err := s.gateways.Get(payment.Gateway)
+		if err != nil {
+			return nil, err
+		}
+
+		refundReq := &GatewayRefundRequest{Amount: amount, Reason: reason}
+		if payment.InvoiceID != nil {
+			refundReq.InvoiceID = *payment.InvoiceID
+		}
+		if payment.TransactionID != nil {
+			refundReq.TransactionID = *payment.TransactionID
+		}
+
+		refund, err = gateway.Refund(refundReq)
+		if err != nil {
+			s.logger.WithError(err).WithField("payment_id", payment.ID).Error("Failed to refund payment")
+			return nil, fmt.Errorf("failed to refund payment: %w", err)
+		}
 	}
 
-	// Set headers
-	httpReq.Header.Set("Authorization", fmt.Sprintf("Bearer %s", m.config.AuthKey))
-	httpReq.Header.Set("Content-Type", "application/json")
+	return refund, nil
+}
 
-	// Log request
-	m.logger.WithFields(map[string]interface{}{
-		"url":  url,
-		"body": string(bodyJSON),
-	}).Info("📡 Sending request to Mayar API...")
+// restorePartialPayments gives the amounts of a refunded payment back to the billings it only partly paid,
+// those that did not move to refunded
+func (s *paymentService) restorePartialPayments(payment *models.Payment, refunded []uint) error {
+	moved := make(map[uint]bool, len(refunded))
+	for _, billingID := range refunded {
+		moved[billingID] = true
+	}
 
-	// Execute request
-	client := &http.Client{Timeout: 30 * time.Second}
-	resp, err := client.Do(httpReq)
+	amounts := make(map[uint]int64)
+	for _, link := range payment.Billings {
+		if !moved[link.BillingID] && link.Amount > 0 {
+			amounts[link.BillingID] += link.Amount
+		}
+	}
+	if len(amounts) == 0 {
+		return nil
+	}
+
+	statusIDs, err := s.billingRepo.GetBillingStatusIDs(paymentBillingIDs(payment))
 	if err != nil {
-		return nil, fmt.Errorf("failed to send request: %w", err)
+		return fmt.Errorf("failed to get billing statuses: %w", err)
+	}
+	for billingID := range amounts {
+		// Only billings still waiting to be paid owe the refunded amount again
+		if !s.stateMachine.CanTransition(s.statuses.State(statusIDs[billingID]), models.BillingStatePaid) {
+			delete(amounts, billingID)
+		}
 	}
-	defer resp.Body.Close()
 
-	// Read response
-	body, err := io.ReadAll(resp.Body)
+	return s.billingRepo.RestoreBalances(amounts)
+}
+
+// uniqueBillingIDs drops repeated billing IDs, keeping the first occurrence of each
+func uniqueBillingIDs(billingIDs []uint) []uint {
+	seen := make(map[uint]bool, len(billingIDs))
+	unique := make([]uint, 0, len(billingIDs))
+	for _, id := range billingIDs {
+		if seen[id] {
+			continue
+		}
+		seen[id] = true
+		unique = append(unique, id)
+	}
+	return unique
+}
+
+// issueInvoice returns the active invoice covering exactly the same billings and amounts, or creates one
+// with the active gateway and records it as a pending payment. The boolean reports whether it was reused.
+// Requests for the same billings are serialized until the invoice is recorded, so they share one link.
+func (s *paymentService) issueInvoice(req *GatewayInvoiceRequest, owner *models.BillingOwner, links []*models.PaymentBillingLink, regenerate bool) (*models.Payment, bool, error) {
+	adminFee, adminFeeRule, err := s.calculateAdminFee(owner, links)
 	if err != nil {
-		return nil, fmt.Errorf("failed to read response: %w", err)
-	}
-
-	// Log response
-	m.logger.WithFields(map[string]interface{}{
-		"status_code": resp.StatusCode,
-		"response":    string(body),
-	}).Info("Mayar API Response")
-
-	// Check for non-200 status codes
-	if resp.StatusCode != http.StatusOK {
-		return nil, fmt.Errorf("Mayar API returned status %d: %s", resp.StatusCode, string(body))
-	}
-
-	// Parse response
-	var mayarResp MayarCreateInvoiceResponse
-	if err := json.Unmarshal(body, &mayarResp); err != nil {
-		return nil, fmt.Errorf("failed to parse response: %w", err)
-	}
-
-	// Debug log the parsed response
-	m.logger.WithFields(map[string]interface{}{
-		"status":         mayarResp.StatusCode,
-		"message":        mayarResp.Messages,
-		"invoice_id":     mayarResp.Data.ID,
-		"transaction_id": mayarResp.Data.TransactionID,
-		"payment_link":   mayarResp.Data.Link,
-	}).Info("Successfully parsed Mayar response")
-
-	return &mayarResp, nil
-}
-
-// CreatePaymentLink creates a payment link using Mayar service
-// billingIDsStr: comma-separated billing IDs (e.g., "1372,67" or "1372")
-// documentIDs: comma-separated document IDs for reference
-// humanDescription: human-readable description for display
-func (m *mayarService) CreatePaymentLink(amount int64, billingIDsStr string, documentIDs string, humanDescription string, customerName string, customerEmail string, customerPhone string) (string, error) {
-	m.logger.WithFields(map[string]interface{}{
-		"amount":            amount,
-		"billing_ids":       billingIDsStr,
-		"document_ids":      documentIDs,
-		"human_description": humanDescription,
-		"customer_name":     customerName,
-		"customer_email":    customerEmail,
-		"customer_phone":    customerPhone,
-	}).Info("Creating Mayar payment link")
-
-	// Validate auth key
-	if m.config.AuthKey == "" {
-		return "", fmt.Errorf("Mayar auth key not configured")
-	}
-
-	// Set expiration to 30 days from now
-	expiredAt := time.Now().Add(30 * 24 * time.Hour).Format(time.RFC3339)
-
-	// Format product description: "<billing_ids> (DocumentID: <document_ids>)"
-	// This format is required for webhook parsing
-	productDescription := billingIDsStr
-	if documentIDs != "" {
-		productDescription = fmt.Sprintf("%s (DocumentID: %s)", billingIDsStr, documentIDs)
-	} else {
-		productDescription = fmt.Sprintf("%s (DocumentID: N/A)", billingIDsStr)
-	}
-
-	// Create invoice request
-	invoiceReq := &MayarCreateInvoiceRequest{
-		Name:        customerName,
-		Email:       customerEmail,
-		Mobile:      customerPhone,
-		RedirectURL: "https://web.mayar.id",
-		Description: productDescription, // This will be in webhook's productDescription field
-		ExpiredAt:   expiredAt,
-		Items: []MayarItem{
-			{
-				Quantity:    1,
-				Rate:        amount,
-				Description: humanDescription,
-			},
-			{
-				Quantity:    1,
-				Rate:        5000, // Fixed admin fee
-				Description: "Admin Fee",
-			},
-		},
-	}
-
-	m.logger.WithField("product_description", productDescription).Info("Product description for webhook parsing")
-
-	// Create invoice
-	result, err := m.CreateInvoice(invoiceReq)
+		return nil, false, err
+	}
+
+	var payment *models.Payment
+	var reused bool
+	err = s.paymentRepo.LockBillingSet(req.BillingIDs, func() error {
+		var err error
+		payment, reused, err = s.issueInvoiceLocked(req, links, adminFee, adminFeeRule, regenerate)
+		return err
+	})
 	if err != nil {
-		m.logger.WithError(err).Error("Failed to create Mayar invoice")
-		return "", err
+		return nil, false, err
 	}
 
-	// Extract payment link from response
-	if result.Data.Link == "" {
-		m.logger.Error("Payment link not found in response")
-		return "", fmt.Errorf("payment link not found in response")
+	return payment, reused, nil
+}
+
+// issueInvoiceLocked does the work of issueInvoice while the billing set is locked
+func (s *paymentService) issueInvoiceLocked(req *GatewayInvoiceRequest, links []*models.PaymentBillingLink, adminFee int64, adminFeeRule string, regenerate bool) (*models.Payment, bool, error) {
+	active, err := s.paymentRepo.FindActiveByBillingSet(req.BillingIDs)
+	if err != nil && !errors.Is(err, gorm.ErrRecordNotFound) {
+		return nil, false, fmt.Errorf("failed to look up active payment: %w", err)
+	}
+
+	if active != nil {
+		if !regenerate && active.AdminFee == adminFee && sameBillingAmounts(active.Billings, links) {
+			s.logger.WithFields(map[string]interface{}{
+				"payment_id":  active.ID,
+				"billing_ids": req.BillingIDs,
+			}).Info("Reusing active payment link")
+			return active, true, nil
+		}
+
+		if err := s.voidPayment(active); err != nil {
+			return nil, false, err
+		}
+	}
+
+	gateway := s.gateways.Active()
+	req.Reference = uuid.NewString()
+	req.AdminFee = adminFee
+	req.ExpiredAt = time.Now().Add(invoiceValidity)
+
+	// The payment is recorded before the gateway call so a webhook can always be resolved by its reference
+	payment := &models.Payment{
+		Gateway:      gateway.Name(),
+		Reference:    &req.Reference,
+		Amount:       req.Amount,
+		AdminFee:     req.AdminFee,
+		AdminFeeRule: adminFeeRule,
+		Status:       models.PaymentStatusPending,
+		Description:  stringPtr(req.Description),
 	}
 
-	m.logger.WithFields(map[string]interface{}{
-		"amount":              amount,
-		"billing_ids":         billingIDsStr,
-		"document_ids":        documentIDs,
-		"product_description": productDescription,
-		"payment_link":        result.Data.Link,
-		"invoice_id":          result.Data.ID,
-		"transaction_id":      result.Data.TransactionID,
-	}).Info("Mayar payment link created successfully")
+	if err := s.paymentRepo.Create(payment, links); err != nil {
+		s.logger.WithError(err).WithField("reference", req.Reference).Error("Failed to record payment")
+		return nil, false, fmt.Errorf("failed to record payment: %w", err)
+	}
+
+	invoice, err := gateway.CreateInvoice(req)
+	if err != nil {
+		s.logger.WithError(err).WithFields(map[string]interface{}{
+			"gateway":     gateway.Name(),
+			"billing_ids": req.BillingIDs,
+		}).Error("Failed to create payment link")
+
+		payment.Status = models.PaymentStatusFailed
+		if updateErr := s.paymentRepo.Update(payment); updateErr != nil {
+			s.logger.WithError(updateErr).WithField("payment_id", payment.ID).Error("Failed to mark payment failed")
+		}
+		return nil, false, fmt.Errorf("failed to create payment link: %w", err)
+	}
+
+	payment.InvoiceID = stringPtr(invoice.ID)
+	payment.TransactionID = stringPtr(invoice.TransactionID)
+	payment.PaymentURL = stringPtr(invoice.PaymentURL)
+	payment.ExpiredAt = invoice.ExpiredAt
+	if err := s.paymentRepo.Update(payment); err != nil {
+		s.logger.WithError(err).WithField("invoice_id", invoice.ID).Error("Failed to record payment invoice")
+		return nil, false, fmt.Errorf("failed to record payment: %w", err)
+	}
 
-	return result.Data.Link, nil
+	return payment, false, nil
+}
+
+// calculateAdminFee applies the admin fee policy to the billings that are not waived by kategori.
+// The returned rule records why the fee was charged or waived.
+func (s *paymentService) calculateAdminFee(owner *models.BillingOwner, links []*models.PaymentBillingLink) (int64, string, error) {
+	if s.feePolicy.IsRoleWaived(owner.Roles()...) {
+		return 0, AdminFeeRuleWaivedRole, nil
+	}
+
+	billingIDs := make([]uint, len(links))
+	for i, link := range links {
+		billingIDs[i] = link.BillingID
+	}
+	kategoriIDs, err := s.billingRepo.GetBillingKategoriIDs(billingIDs)
+	if err != nil {
+		return 0, "", fmt.Errorf("failed to get billing kategori: %w", err)
+	}
+
+	var feeable int64
+	for _, link := range links {
+		if kategoriID, ok := kategoriIDs[link.BillingID]; ok && s.feePolicy.IsKategoriWaived(kategoriID) {
+			continue
+		}
+		feeable += link.Amount
+	}
+
+	if feeable == 0 {
+		return 0, AdminFeeRuleWaivedKategori, nil
+	}
+
+	return s.feePolicy.Calculate(feeable), s.feePolicy.Mode(), nil
+}
+
+// voidPayment closes the invoice at its gateway and marks the payment voided. Gateways that cannot
+// close invoices leave the old link payable until it expires; a payment on it goes to review.
+func (s *paymentService) voidPayment(payment *models.Payment) error {
+	gateway, err := s.gateways.Get(payment.Gateway)
+	if err != nil {
+		return err
+	}
+
+	if payment.InvoiceID != nil {
+		err := gateway.VoidInvoice(*payment.InvoiceID)
+		switch {
+		case errors.Is(err, ErrVoidNotSupported):
+			s.logger.WithField("payment_id", payment.ID).Warn("Gateway cannot void invoices, old link stays payable until it expires")
+		case err != nil:
+			s.logger.WithError(err).WithField("payment_id", payment.ID).Error("Failed to void invoice")
+			return fmt.Errorf("failed to void payment link: %w", err)
+		}
+	}
+
+	payment.Status = models.PaymentStatusVoided
+	if err := s.paymentRepo.Update(payment); err != nil {
+		return fmt.Errorf("failed to update payment: %w", err)
+	}
+
+	s.logger.WithField("payment_id", payment.ID).Info("Payment link voided")
+	return nil
+}
+
+// sameBillingAmounts reports whether both link sets charge the same amount for the same billings
+func sameBillingAmounts(existing []*models.PaymentBillingLink, links []*models.PaymentBillingLink) bool {
+	if len(existing) != len(links) {
+		return false
+	}
+
+	amounts := make(map[uint]int64, len(existing))
+	for _, link := range existing {
+		amounts[link.BillingID] = link.Amount
+	}
+	for _, link := range links {
+		amount, ok := amounts[link.BillingID]
+		if !ok || amount != link.Amount {
+			return false
+		}
+	}
+	return true
+}
+
+// newPaymentLinkResponse builds the payment link response shared by the single and multiple billing flows
+func newPaymentLinkResponse(payment *models.Payment, reused bool) *PaymentLinkResponse {
+	response := &PaymentLinkResponse{
+		PaymentID: payment.ID,
+		Gateway:   payment.Gateway,
+		Amount:    payment.Amount,
+		Reused:    reused,
+	}
+	if payment.PaymentURL != nil {
+		response.PaymentURL = *payment.PaymentURL
+	}
+	if payment.Description != nil {
+		response.Description = *payment.Description
+	}
+	if payment.InvoiceID != nil {
+		response.InvoiceID = *payment.InvoiceID
+	}
+	if payment.TransactionID != nil {
+		response.TransactionID = *payment.TransactionID
+	}
+	if payment.ExpiredAt != nil {
+		response.ExpiredAt = payment.ExpiredAt.UnixMilli()
+	}
+	return response
+}
+
+// stringPtr returns nil for empty strings so optional columns stay NULL
+func stringPtr(value string) *string {
+	if value == "" {
+		return nil
+	}
+	return &value
+}
+
+// unixTimePtr converts a unix timestamp in seconds or milliseconds to a time, nil when unset
+func unixTimePtr(value int64) *time.Time {
+	if value <= 0 {
+		return nil
+	}
+	var t time.Time
+	if value > 1e12 {
+		t = time.UnixMilli(value)
+	} else {
+		t = time.Unix(value, 0)
+	}
+	return &t
 }
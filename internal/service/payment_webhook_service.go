@@ -0,0 +1,592 @@
+package service
+
+import (
+	"errors"
+	"fmt"
+	"net/http"
+	"strconv"
+	"strings"
+	"time"
+
+	"ipl-be-svc/internal/models"
+	"ipl-be-svc/internal/repository"
+	"ipl-be-svc/pkg/logger"
+
+	"gorm.io/gorm"
+)
+
+var (
+	// ErrWebhookUnauthorized is returned when the webhook token or signature does not match
+	ErrWebhookUnauthorized = errors.New("webhook verification failed")
+	// ErrWebhookInvalidPayload is returned when the webhook body cannot be parsed
+	ErrWebhookInvalidPayload = errors.New("invalid webhook payload")
+	// ErrWebhookStale is returned when the webhook timestamp is outside the tolerance window
+	ErrWebhookStale = errors.New("webhook timestamp outside tolerance window")
+)
+
+// Webhook processing actions
+const (
+	WebhookActionConfirmed   = "confirmed"
+	WebhookActionNeedsReview = "needs_review"
+	WebhookActionIgnored     = "ignored"
+)
+
+// WebhookResult describes the outcome of processing a webhook delivery
+type WebhookResult struct {
+	Gateway       string `json:"gateway"`
+	Event         string `json:"event"`
+	TransactionID string `json:"transaction_id"`
+	Status        string `json:"status"`
+	BillingIDs    []uint `json:"billing_ids"`
+	Duplicate     bool   `json:"duplicate"`
+	Action        string `json:"action,omitempty"`
+	ReviewID      *uint  `json:"review_id,omitempty"`
+	ReviewReason  string `json:"review_reason,omitempty"`
+}
+
+// PaymentWebhookService defines the interface for processing payment gateway webhooks
+type PaymentWebhookService interface {
+	HandleWebhook(gatewayName string, header http.Header, body []byte) (*WebhookResult, error)
+	ApplyGatewayStatus(payment *models.Payment, status *GatewayPaymentStatus) (*WebhookResult, error)
+}
+
+// paymentOutcome is a gateway-reported payment state, from a webhook or a status query
+type paymentOutcome struct {
+	TransactionID string
+	Status        string
+	RawStatus     string
+	Amount        int64
+	PaymentMethod string
+}
+
+// paymentWebhookService implements PaymentWebhookService
+type paymentWebhookService struct {
+	webhookRepo    repository.PaymentWebhookRepository
+	reviewRepo     repository.PaymentReviewRepository
+	paymentRepo    repository.PaymentRepository
+	billingRepo    repository.BillingRepository
+	billingService BillingService
+	creditService  CreditService
+	stateMachine   BillingStateMachine
+	statuses       StatusRegistry
+	gateways       *PaymentGatewayRegistry
+	logger         *logger.Logger
+}
+
+// NewPaymentWebhookService creates a new instance of PaymentWebhookService
+func NewPaymentWebhookService(
+	webhookRepo repository.PaymentWebhookRepository,
+	reviewRepo repository.PaymentReviewRepository,
+	paymentRepo repository.PaymentRepository,
+	billingRepo repository.BillingRepository,
+	billingService BillingService,
+	creditService CreditService,
+	stateMachine BillingStateMachine,
+	statuses StatusRegistry,
+	gateways *PaymentGatewayRegistry,
+	logger *logger.Logger,
+) PaymentWebhookService {
+	return &paymentWebhookService{
+		webhookRepo:    webhookRepo,
+		reviewRepo:     reviewRepo,
+		paymentRepo:    paymentRepo,
+		billingRepo:    billingRepo,
+		billingService: billingService,
+		creditService:  creditService,
+		stateMachine:   stateMachine,
+		statuses:       statuses,
+		gateways:       gateways,
+		logger:         logger,
+	}
+}
+
+// HandleWebhook verifies, de-duplicates and processes a webhook delivery from the named gateway
+func (s *paymentWebhookService) HandleWebhook(gatewayName string, header http.Header, body []byte) (*WebhookResult, error) {
+	gateway, err := s.gateways.Get(gatewayName)
+	if err != nil {
+		return nil, err
+	}
+
+	gatewayEvent, err := gateway.ParseWebhook(header, body)
+	if err != nil {
+		return nil, err
+	}
+
+	result := &WebhookResult{
+		Gateway:       gatewayEvent.Gateway,
+		Event:         gatewayEvent.Event,
+		TransactionID: gatewayEvent.TransactionID,
+		Status:        gatewayEvent.RawStatus,
+	}
+
+	eventRef := gatewayEvent.TransactionID
+	if eventRef == "" {
+		eventRef = gatewayEvent.InvoiceID
+	}
+
+	event, created, err := s.webhookRepo.CreateEventIfNotExists(&models.PaymentWebhookEvent{
+		Gateway:       gatewayEvent.Gateway,
+		EventKey:      fmt.Sprintf("%s:%s:%s", gatewayEvent.Gateway, gatewayEvent.Event, eventRef),
+		Event:         gatewayEvent.Event,
+		TransactionID: eventRef,
+		Status:        gatewayEvent.RawStatus,
+		Payload:       string(body),
+		ReceivedAt:    time.Now(),
+	})
+	if err != nil {
+		return nil, fmt.Errorf("failed to record webhook event: %w", err)
+	}
+
+	// A delivery that was recorded but never finished processing is retried
+	if !created && event.ProcessedAt != nil {
+		s.logger.WithFields(map[string]interface{}{
+			"event_key":    event.EventKey,
+			"processed_at": event.ProcessedAt,
+		}).Info("Ignoring replayed webhook")
+		result.Duplicate = true
+		return result, nil
+	}
+
+	payment, err := s.findOrCreatePayment(gatewayEvent)
+	if err != nil {
+		return nil, err
+	}
+
+	s.logger.WithFields(map[string]interface{}{
+		"payment_id":  payment.ID,
+		"billing_ids": paymentBillingIDs(payment),
+	}).Info("Resolved payment from webhook")
+
+	outcome := &paymentOutcome{
+		TransactionID: gatewayEvent.TransactionID,
+		Status:        gatewayEvent.Status,
+		RawStatus:     gatewayEvent.RawStatus,
+		Amount:        gatewayEvent.Amount,
+		PaymentMethod: gatewayEvent.PaymentMethod,
+	}
+	if err := s.applyOutcome(payment, outcome, &event.ID, result); err != nil {
+		return nil, err
+	}
+
+	if err := s.webhookRepo.MarkEventProcessed(event.ID); err != nil {
+		return nil, fmt.Errorf("failed to mark webhook event processed: %w", err)
+	}
+
+	return result, nil
+}
+
+// ApplyGatewayStatus applies the result of a gateway status query to a recorded payment through the
+// same confirmation and review rules as a webhook, for payments whose webhook never arrived
+func (s *paymentWebhookService) ApplyGatewayStatus(payment *models.Payment, status *GatewayPaymentStatus) (*WebhookResult, error) {
+	result := &WebhookResult{
+		Gateway:       payment.Gateway,
+		TransactionID: status.TransactionID,
+		Status:        status.RawStatus,
+	}
+
+	outcome := &paymentOutcome{
+		TransactionID: status.TransactionID,
+		Status:        status.Status,
+		RawStatus:     status.RawStatus,
+		Amount:        status.Amount,
+		PaymentMethod: status.PaymentMethod,
+	}
+	if err := s.applyOutcome(payment, outcome, nil, result); err != nil {
+		return nil, err
+	}
+
+	return result, nil
+}
+
+// applyOutcome confirms, reviews or closes the payment according to the gateway outcome and saves it.
+// The payment row stays locked meanwhile, so a webhook and a status query for the same payment are
+// applied one after the other. webhookEventID is nil when the outcome comes from a status query.
+func (s *paymentWebhookService) applyOutcome(payment *models.Payment, outcome *paymentOutcome, webhookEventID *uint, result *WebhookResult) error {
+	var updated *models.Payment
+	err := s.paymentRepo.UpdateLocked(payment.ID, func(locked *models.Payment) error {
+		// Invoice details resolved from the webhook are saved with the outcome
+		if locked.InvoiceID == nil {
+			locked.InvoiceID = payment.InvoiceID
+		}
+		if locked.TransactionID == nil {
+			locked.TransactionID = payment.TransactionID
+		}
+		updated = locked
+		return s.applyLockedOutcome(locked, outcome, webhookEventID, result)
+	})
+	if err != nil {
+		return fmt.Errorf("failed to update payment: %w", err)
+	}
+
+	*payment = *updated
+	return nil
+}
+
+// applyLockedOutcome applies the gateway outcome to the locked payment
+func (s *paymentWebhookService) applyLockedOutcome(payment *models.Payment, outcome *paymentOutcome, webhookEventID *uint, result *WebhookResult) error {
+	billingIDs := paymentBillingIDs(payment)
+	result.BillingIDs = billingIDs
+
+	switch payment.Status {
+	case models.PaymentStatusPaid, models.PaymentStatusNeedsReview, models.PaymentStatusRefunding, models.PaymentStatusRefunded:
+		// The payment was settled or queued for review by an earlier delivery or status query
+		s.logger.WithFields(map[string]interface{}{
+			"payment_id":     payment.ID,
+			"status":         outcome.RawStatus,
+			"payment_status": payment.Status,
+		}).Info("Payment already settled, outcome ignored")
+		result.Action = WebhookActionIgnored
+		return nil
+	}
+
+	if outcome.PaymentMethod != "" {
+		payment.PaymentMethod = &outcome.PaymentMethod
+	}
+	if outcome.TransactionID != "" {
+		payment.TransactionID = &outcome.TransactionID
+	}
+
+	switch outcome.Status {
+	case models.PaymentStatusPaid:
+		return s.reconcileAndConfirm(webhookEventID, payment, billingIDs, outcome.Amount, result)
+	case models.PaymentStatusPending:
+		s.logger.WithFields(map[string]interface{}{
+			"status":      outcome.RawStatus,
+			"billing_ids": billingIDs,
+		}).Info("Payment status is not a success state, billings left unchanged")
+		result.Action = WebhookActionIgnored
+	default:
+		s.logger.WithFields(map[string]interface{}{
+			"status":      outcome.RawStatus,
+			"billing_ids": billingIDs,
+		}).Info("Payment closed without settling, billings left unchanged")
+		// A voided payment is never overwritten by a late failure notification
+		if payment.Status != models.PaymentStatusVoided {
+			payment.Status = outcome.Status
+		}
+		result.Action = WebhookActionIgnored
+	}
+
+	return nil
+}
+
+// findOrCreatePayment returns the payment recorded for the gateway invoice. Invoices issued before
+// payments were recorded carry their billing IDs in the webhook, a payment is created for those so
+// every webhook ends up in the payment history.
+func (s *paymentWebhookService) findOrCreatePayment(gatewayEvent *GatewayWebhookEvent) (*models.Payment, error) {
+	payment, err := s.paymentRepo.GetByGatewayReference(gatewayEvent.Gateway, gatewayEvent.InvoiceID, gatewayEvent.TransactionID)
+	if err == nil {
+		return payment, nil
+	}
+	if !errors.Is(err, gorm.ErrRecordNotFound) {
+		return nil, fmt.Errorf("failed to get payment: %w", err)
+	}
+
+	// The payment reference is recorded before the gateway call, so it still resolves
+	// payments whose invoice details were never stored
+	if gatewayEvent.Reference != "" {
+		payment, err = s.paymentRepo.GetByReference(gatewayEvent.Reference)
+		if err == nil && payment.Gateway == gatewayEvent.Gateway {
+			if payment.InvoiceID == nil {
+				payment.InvoiceID = stringPtr(gatewayEvent.InvoiceID)
+			}
+			if payment.TransactionID == nil {
+				payment.TransactionID = stringPtr(gatewayEvent.TransactionID)
+			}
+			return payment, nil
+		}
+		if err != nil && !errors.Is(err, gorm.ErrRecordNotFound) {
+			return nil, fmt.Errorf("failed to get payment: %w", err)
+		}
+		return nil, fmt.Errorf("%w: no payment found for reference %s", ErrWebhookInvalidPayload, gatewayEvent.Reference)
+	}
+
+	// Legacy invoices are only known by the billing IDs encoded in their description
+	if len(gatewayEvent.BillingIDs) == 0 {
+		return nil, fmt.Errorf("%w: no payment found for invoice %s", ErrWebhookInvalidPayload, gatewayEvent.InvoiceID)
+	}
+
+	billings, err := s.billingRepo.GetBillingsByIDs(gatewayEvent.BillingIDs)
+	if err != nil {
+		return nil, fmt.Errorf("failed to get billings: %w", err)
+	}
+
+	var amount int64
+	links := make([]*models.PaymentBillingLink, 0, len(gatewayEvent.BillingIDs))
+	outstanding := make(map[uint]int64, len(billings))
+	for _, billing := range billings {
+		outstanding[billing.ID] = billing.Outstanding()
+	}
+	// Unknown IDs are linked too so reconciliation can flag them
+	for _, id := range gatewayEvent.BillingIDs {
+		amount += outstanding[id]
+		links = append(links, &models.PaymentBillingLink{BillingID: id, Amount: outstanding[id]})
+	}
+
+	payment = &models.Payment{
+		Gateway:       gatewayEvent.Gateway,
+		InvoiceID:     stringPtr(gatewayEvent.InvoiceID),
+		TransactionID: stringPtr(gatewayEvent.TransactionID),
+		Amount:        amount,
+		AdminFee:      legacyAdminFee,
+		AdminFeeRule:  AdminFeeModeFlat,
+		Status:        models.PaymentStatusPending,
+	}
+	if err := s.paymentRepo.Create(payment, links); err != nil {
+		return nil, fmt.Errorf("failed to record payment: %w", err)
+	}
+
+	return payment, nil
+}
+
+// reconcileAndConfirm takes the payment off the billings' outstanding balances when the paid amount
+// covers what was invoiced, otherwise it queues the payment for manual review. Billings are confirmed
+// once nothing is outstanding, so a link may pay part of a billing. What was paid beyond the invoice is
+// added to the resident's credit balance, or reviewed when the billings have no single owner profile.
+func (s *paymentWebhookService) reconcileAndConfirm(webhookEventID *uint, payment *models.Payment, billingIDs []uint, paidAmount int64, result *WebhookResult) error {
+	transactionID := ""
+	if payment.TransactionID != nil {
+		transactionID = *payment.TransactionID
+	}
+
+	billings, err := s.billingRepo.GetBillingsByIDs(billingIDs)
+	if err != nil {
+		return fmt.Errorf("failed to get billings: %w", err)
+	}
+
+	found := make(map[uint]bool, len(billings))
+	outstanding := make(map[uint]int64, len(billings))
+	for _, billing := range billings {
+		found[billing.ID] = true
+		outstanding[billing.ID] = billing.Outstanding()
+	}
+	expectedAmount := payment.TotalAmount()
+
+	amounts := make(map[uint]int64, len(payment.Billings))
+	for _, link := range payment.Billings {
+		amounts[link.BillingID] += link.Amount
+	}
+
+	var unknownIDs []string
+	for _, id := range billingIDs {
+		if !found[id] {
+			unknownIDs = append(unknownIDs, strconv.FormatUint(uint64(id), 10))
+		}
+	}
+
+	statusIDs, err := s.billingRepo.GetBillingStatusIDs(billingIDs)
+	if err != nil {
+		return fmt.Errorf("failed to get billing statuses: %w", err)
+	}
+	var paidIDs, closedIDs, exceedingIDs []string
+	for _, id := range billingIDs {
+		statusID, ok := statusIDs[id]
+		if !ok {
+			continue
+		}
+		switch state := s.statuses.State(statusID); {
+		case state == models.BillingStatePaid:
+			paidIDs = append(paidIDs, strconv.FormatUint(uint64(id), 10))
+		case !s.stateMachine.CanTransition(state, models.BillingStatePaid):
+			closedIDs = append(closedIDs, strconv.FormatUint(uint64(id), 10))
+		case amounts[id] > outstanding[id]:
+			exceedingIDs = append(exceedingIDs, strconv.FormatUint(uint64(id), 10))
+		}
+	}
+
+	var creditProfileID *uint
+	if paidAmount > expectedAmount && len(unknownIDs) == 0 {
+		if creditProfileID, err = s.ownerProfileID(billingIDs); err != nil {
+			return err
+		}
+	}
+
+	var reason string
+	switch {
+	case len(unknownIDs) > 0:
+		reason = fmt.Sprintf("unknown billing IDs: %s", strings.Join(unknownIDs, ","))
+	case payment.Status == models.PaymentStatusVoided:
+		reason = "payment link was voided and regenerated"
+	case len(paidIDs) > 0:
+		reason = fmt.Sprintf("billings already paid: %s", strings.Join(paidIDs, ","))
+	case len(closedIDs) > 0:
+		reason = fmt.Sprintf("billings cannot be paid: %s", strings.Join(closedIDs, ","))
+	case len(exceedingIDs) > 0:
+		reason = fmt.Sprintf("amount exceeds outstanding balance: %s", strings.Join(exceedingIDs, ","))
+	case paidAmount < expectedAmount:
+		reason = fmt.Sprintf("partial payment: paid %d, expected %d", paidAmount, expectedAmount)
+	case paidAmount > expectedAmount && creditProfileID == nil:
+		reason = fmt.Sprintf("amount mismatch: paid %d, expected %d", paidAmount, expectedAmount)
+	}
+
+	if reason != "" {
+		review := &models.PaymentReview{
+			Gateway:        payment.Gateway,
+			WebhookEventID: webhookEventID,
+			PaymentID:      &payment.ID,
+			TransactionID:  transactionID,
+			BillingIDs:     joinUintIDs(billingIDs),
+			ExpectedAmount: expectedAmount,
+			PaidAmount:     paidAmount,
+			Reason:         reason,
+			Status:         models.PaymentReviewStatusOpen,
+		}
+		if err := s.reviewRepo.Create(review); err != nil {
+			return fmt.Errorf("failed to queue payment for review: %w", err)
+		}
+
+		s.logger.WithFields(map[string]interface{}{
+			"review_id":      review.ID,
+			"transaction_id": transactionID,
+			"billing_ids":    billingIDs,
+			"reason":         reason,
+		}).Warn("Payment queued for manual review")
+
+		// The money arrived, so the billings that can wait for the review do so instead of staying unpaid
+		if _, err := s.stateMachine.Transition(&BillingTransitionRequest{
+			BillingIDs:  billingIDs,
+			To:          models.BillingStatePendingVerification,
+			Reason:      fmt.Sprintf("Payment review #%d: %s", review.ID, reason),
+			SkipInvalid: true,
+		}); err != nil {
+			return fmt.Errorf("failed to mark billings as pending verification: %w", err)
+		}
+
+		payment.Status = models.PaymentStatusNeedsReview
+		result.Action = WebhookActionNeedsReview
+		result.ReviewID = &review.ID
+		result.ReviewReason = reason
+		return nil
+	}
+
+	settled, err := s.billingService.ApplyPayment(payment.ID, amounts, 0, fmt.Sprintf("Paid via %s transaction %s", payment.Gateway, transactionID))
+	if errors.Is(err, ErrPaymentAlreadyApplied) {
+		// An earlier attempt applied the payment and settled its billings but failed before saving the payment
+		s.logger.WithFields(map[string]interface{}{
+			"payment_id":     payment.ID,
+			"transaction_id": transactionID,
+		}).Warn("Payment already applied to billings")
+		now := time.Now()
+		payment.Status = models.PaymentStatusPaid
+		payment.PaidAt = &now
+		result.Action = WebhookActionConfirmed
+		return nil
+	}
+	if err != nil {
+		return fmt.Errorf("failed to confirm payment: %w", err)
+	}
+
+	s.logger.WithFields(map[string]interface{}{
+		"transaction_id": transactionID,
+		"billing_ids":    billingIDs,
+		"settled_ids":    settled,
+		"amount":         paidAmount,
+	}).Info("Payment confirmed")
+
+	if creditProfileID != nil {
+		// The billings are already paid, so a failure is logged for the credit to be deposited by hand
+		if _, err := s.creditService.AddOverpayment(*creditProfileID, paidAmount-expectedAmount, payment.ID); err != nil {
+			s.logger.WithError(err).WithFields(map[string]interface{}{
+				"payment_id": payment.ID,
+				"profile_id": *creditProfileID,
+				"amount":     paidAmount - expectedAmount,
+			}).Error("Failed to credit overpayment")
+		}
+	}
+
+	now := time.Now()
+	payment.Status = models.PaymentStatusPaid
+	payment.PaidAt = &now
+	result.Action = WebhookActionConfirmed
+	return nil
+}
+
+// ownerProfileID returns the profile owning all the billings, or nil when they have none or several
+func (s *paymentWebhookService) ownerProfileID(billingIDs []uint) (*uint, error) {
+	owners, err := s.billingRepo.GetBillingOwners(billingIDs)
+	if err != nil {
+		return nil, fmt.Errorf("failed to get billing owners: %w", err)
+	}
+
+	owned := make(map[uint]bool, len(owners))
+	var profileID *uint
+	for _, owner := range owners {
+		owned[owner.BillingID] = true
+		switch {
+		case owner.ProfileID == nil:
+			return nil, nil
+		case profileID == nil:
+			profileID = owner.ProfileID
+		case *profileID != *owner.ProfileID:
+			return nil, nil
+		}
+	}
+	for _, billingID := range billingIDs {
+		if !owned[billingID] {
+			return nil, nil
+		}
+	}
+	return profileID, nil
+}
+
+// paymentBillingIDs returns the IDs of the billings a payment covers
+func paymentBillingIDs(payment *models.Payment) []uint {
+	billingIDs := make([]uint, 0, len(payment.Billings))
+	for _, link := range payment.Billings {
+		billingIDs = append(billingIDs, link.BillingID)
+	}
+	return billingIDs
+}
+
+// joinUintIDs formats IDs as a comma-separated list
+func joinUintIDs(ids []uint) string {
+	parts := make([]string, len(ids))
+	for i, id := range ids {
+		parts[i] = strconv.FormatUint(uint64(id), 10)
+	}
+	return strings.Join(parts, ",")
+}
+
+// checkWebhookTimestamp rejects deliveries whose delivery timestamp is further from now than tolerance.
+// Deliveries without one are accepted.
+func checkWebhookTimestamp(tolerance time.Duration, value string) error {
+	if tolerance <= 0 || strings.TrimSpace(value) == "" {
+		return nil
+	}
+
+	ts, ok := parseWebhookTimestamp(value)
+	if !ok {
+		return fmt.Errorf("%w: invalid timestamp %q", ErrWebhookStale, value)
+	}
+
+	age := time.Since(ts)
+	if age < 0 {
+		age = -age
+	}
+	if age > tolerance {
+		return fmt.Errorf("%w: %s", ErrWebhookStale, ts.Format(time.RFC3339))
+	}
+	return nil
+}
+
+// parseWebhookTimestamp accepts RFC3339 strings as well as unix timestamps in seconds or milliseconds
+func parseWebhookTimestamp(value string) (time.Time, bool) {
+	value = strings.TrimSpace(value)
+	if value == "" {
+		return time.Time{}, false
+	}
+
+	if ts, err := time.Parse(time.RFC3339, value); err == nil {
+		return ts, true
+	}
+
+	if n, err := strconv.ParseInt(value, 10, 64); err == nil {
+		// Values this large can only be milliseconds
+		if n > 1e12 {
+			return time.UnixMilli(n), true
+		}
+		return time.Unix(n, 0), true
+	}
+
+	return time.Time{}, false
+}
@@ -0,0 +1,210 @@
+package service
+
+import (
+	"errors"
+	"fmt"
+	"strings"
+	"sync"
+	"time"
+
+	"ipl-be-svc/internal/config"
+	"ipl-be-svc/internal/models"
+	"ipl-be-svc/internal/repository"
+	"ipl-be-svc/pkg/logger"
+
+	"github.com/google/uuid"
+)
+
+// ErrStatusNotFound is returned when master_general_statuses has no status for a required billing state
+var ErrStatusNotFound = errors.New("billing status not found")
+
+// BillingStatus is a billing state with the master_general_statuses row it resolved to
+type BillingStatus struct {
+	State    string `json:"state" example:"unpaid"`
+	StatusID uint   `json:"status_id" example:"2"`
+	Name     string `json:"status_name" example:"Belum Dibayar"`
+}
+
+// StatusRegistry resolves the billing states to their master_general_statuses IDs by name, so no
+// status ID is assumed from the database it was first deployed on
+type StatusRegistry interface {
+	Refresh() error
+	IDs() models.BillingStatusIDs
+	State(statusID uint) string
+	Statuses() []*BillingStatus
+}
+
+// statusDefinition is the configured status name of a billing state
+type statusDefinition struct {
+	state       string
+	name        string
+	description string
+	// required states are never created, billings already use them
+	required bool
+}
+
+// statusRegistry implements StatusRegistry
+type statusRegistry struct {
+	statusRepo  repository.StatusRepository
+	definitions []statusDefinition
+	logger      *logger.Logger
+
+	// refreshMu keeps concurrent refreshes from creating the same status twice
+	refreshMu sync.Mutex
+	mu        sync.RWMutex
+	ids       models.BillingStatusIDs
+	states    map[uint]string
+	statuses  []*BillingStatus
+}
+
+// NewStatusRegistry validates the configured status names and loads the registry
+func NewStatusRegistry(statusRepo repository.StatusRepository, cfg config.BillingStatusConfig, logger *logger.Logger) (StatusRegistry, error) {
+	definitions := []statusDefinition{
+		{state: models.BillingStateUnpaid, name: cfg.Unpaid, required: true},
+		{state: models.BillingStatePaid, name: cfg.Paid, required: true},
+		{state: models.BillingStateOverdue, name: cfg.Overdue, description: "Belum dibayar setelah jatuh tempo"},
+		{state: models.BillingStateCancelled, name: cfg.Cancelled, description: "Tagihan dibatalkan"},
+		{state: models.BillingStatePendingVerification, name: cfg.PendingVerification, description: "Pembayaran menunggu verifikasi"},
+		{state: models.BillingStateRefunded, name: cfg.Refunded, description: "Pembayaran dikembalikan"},
+	}
+
+	seen := make(map[string]string, len(definitions))
+	for _, def := range definitions {
+		key := statusKey(def.name)
+		if key == "" {
+			return nil, fmt.Errorf("billing status name of %s must not be empty", def.state)
+		}
+		if other, ok := seen[key]; ok {
+			return nil, fmt.Errorf("billing states %s and %s share the status name %q", other, def.state, def.name)
+		}
+		seen[key] = def.state
+	}
+
+	registry := &statusRegistry{
+		statusRepo:  statusRepo,
+		definitions: definitions,
+		logger:      logger,
+	}
+	if err := registry.Refresh(); err != nil {
+		return nil, err
+	}
+
+	return registry, nil
+}
+
+// Refresh reloads the status IDs from master_general_statuses, creating the statuses of optional
+// states that are missing. On error the previously loaded IDs are kept.
+func (r *statusRegistry) Refresh() error {
+	r.refreshMu.Lock()
+	defer r.refreshMu.Unlock()
+
+	rows, err := r.statusRepo.GetPublishedStatuses()
+	if err != nil {
+		return fmt.Errorf("failed to get statuses: %w", err)
+	}
+
+	// Rows are ordered by ID, so a duplicated name resolves to its oldest row
+	byName := make(map[string]*models.MasterGeneralStatus, len(rows))
+	for _, row := range rows {
+		if row.Status == nil {
+			continue
+		}
+		if _, ok := byName[statusKey(*row.Status)]; !ok {
+			byName[statusKey(*row.Status)] = row
+		}
+	}
+
+	resolved := make(map[string]uint, len(r.definitions))
+	states := make(map[uint]string, len(r.definitions))
+	statuses := make([]*BillingStatus, 0, len(r.definitions))
+	for _, def := range r.definitions {
+		row, ok := byName[statusKey(def.name)]
+		if !ok {
+			if def.required {
+				return fmt.Errorf("%w: %q (%s)", ErrStatusNotFound, def.name, def.state)
+			}
+			if row, err = r.createStatus(def); err != nil {
+				return err
+			}
+		}
+
+		resolved[def.state] = row.ID
+		states[row.ID] = def.state
+		statuses = append(statuses, &BillingStatus{State: def.state, StatusID: row.ID, Name: *row.Status})
+	}
+
+	ids := models.BillingStatusIDs{
+		Unpaid:              resolved[models.BillingStateUnpaid],
+		Paid:                resolved[models.BillingStatePaid],
+		Overdue:             resolved[models.BillingStateOverdue],
+		Cancelled:           resolved[models.BillingStateCancelled],
+		PendingVerification: resolved[models.BillingStatePendingVerification],
+		Refunded:            resolved[models.BillingStateRefunded],
+	}
+
+	r.mu.Lock()
+	r.ids = ids
+	r.states = states
+	r.statuses = statuses
+	r.mu.Unlock()
+
+	r.logger.WithField("statuses", resolved).Info("Billing statuses loaded")
+	return nil
+}
+
+// IDs returns the status ID of every billing state
+func (r *statusRegistry) IDs() models.BillingStatusIDs {
+	r.mu.RLock()
+	defer r.mu.RUnlock()
+	return r.ids
+}
+
+// State returns the billing state a status ID resolved to, or "" for statuses of no billing state
+func (r *statusRegistry) State(statusID uint) string {
+	r.mu.RLock()
+	defer r.mu.RUnlock()
+	return r.states[statusID]
+}
+
+// Statuses lists the billing states with the status each resolved to
+func (r *statusRegistry) Statuses() []*BillingStatus {
+	r.mu.RLock()
+	defer r.mu.RUnlock()
+
+	statuses := make([]*BillingStatus, len(r.statuses))
+	for i, status := range r.statuses {
+		copied := *status
+		statuses[i] = &copied
+	}
+	return statuses
+}
+
+// createStatus creates the published status of a billing state missing from master_general_statuses
+func (r *statusRegistry) createStatus(def statusDefinition) (*models.MasterGeneralStatus, error) {
+	now := time.Now()
+	documentID := uuid.NewString()
+	name := def.name
+	description := def.description
+	status := &models.MasterGeneralStatus{
+		DocumentID:        &documentID,
+		Status:            &name,
+		StatusDescription: &description,
+		CreatedAt:         &now,
+		UpdatedAt:         &now,
+		PublishedAt:       &now,
+	}
+	if err := r.statusRepo.Create(status); err != nil {
+		return nil, fmt.Errorf("failed to create %q status: %w", def.name, err)
+	}
+
+	r.logger.WithFields(map[string]interface{}{
+		"state":     def.state,
+		"status_id": status.ID,
+	}).Info("Created billing status")
+	return status, nil
+}
+
+// statusKey normalises a status name for matching, ignoring case and surrounding spaces
+func statusKey(name string) string {
+	return strings.ToLower(strings.TrimSpace(name))
+}
@@ -38,6 +38,15 @@ func ParseJWTToken(tokenString string) (*JWTClaims, error) {
 		secret = "your-secret-key" // fallback for development
 	}
 
+	return ParseJWTTokenWithSecret(tokenString, secret)
+}
+
+// ParseJWTTokenWithSecret parses and validates a JWT token against the given secret
+func ParseJWTTokenWithSecret(tokenString string, secret string) (*JWTClaims, error) {
+	if secret == "" {
+		return nil, errors.New("JWT secret is not configured")
+	}
+
 	token, err := jwt.ParseWithClaims(tokenString, &JWTClaims{}, func(token *jwt.Token) (interface{}, error) {
 		// Validate the alg is what we expect
 		if _, ok := token.Method.(*jwt.SigningMethodHMAC); !ok {
@@ -52,6 +52,16 @@ func CreatedResponse(c *gin.Context, message string, data interface{}) {
 	c.JSON(http.StatusCreated, response)
 }
 
+// AcceptedResponse sends an accepted response for work that continues in the background
+func AcceptedResponse(c *gin.Context, message string, data interface{}) {
+	response := APIResponse{
+		Success: true,
+		Message: message,
+		Data:    data,
+	}
+	c.JSON(http.StatusAccepted, response)
+}
+
 // ErrorResponse sends an error response
 func ErrorResponse(c *gin.Context, statusCode int, message string, err error) {
 	response := APIResponse{